@@ -22,9 +22,13 @@ package conf
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"time"
+	"unicode"
 
 	log "github.com/apache/pulsar/pulsar-function-go/logutil"
 	"gopkg.in/yaml.v2"
@@ -32,6 +36,12 @@ import (
 
 const ConfigPath = "conf/conf.yaml"
 
+// EnvPrefix starts the names of the environment variables overriding the
+// config, which go on with the json name of the field in upper snake case,
+// e.g. PULSAR_FUNCTION_PULSAR_SERVICE_URL for pulsarServiceURL. Strings are
+// taken as is, other values are parsed as YAML, e.g. true, 10 or {"k": "v"}.
+const EnvPrefix = "PULSAR_FUNCTION_"
+
 type Conf struct {
 	PulsarServiceURL string        `json:"pulsarServiceURL" yaml:"pulsarServiceURL"`
 	InstanceID       int           `json:"instanceID" yaml:"instanceID"`
@@ -41,20 +51,37 @@ type Conf struct {
 	Port             int           `json:"port" yaml:"port"`
 	ClusterName      string        `json:"clusterName" yaml:"clusterName"`
 	KillAfterIdleMs  time.Duration `json:"killAfterIdleMs" yaml:"killAfterIdleMs"`
+	// exit once this many messages are processed, after their outputs are published, 0 means never
+	MaxMessagesToProcess int64 `json:"maxMessagesToProcess" yaml:"maxMessagesToProcess"`
 	// function details config
 	Tenant               string `json:"tenant" yaml:"tenant"`
 	NameSpace            string `json:"nameSpace" yaml:"nameSpace"`
 	Name                 string `json:"name" yaml:"name"`
 	LogTopic             string `json:"logTopic" yaml:"logTopic"`
+	LogTopicCompression  string `json:"logTopicCompression" yaml:"logTopicCompression"`
 	ProcessingGuarantees int32  `json:"processingGuarantees" yaml:"processingGuarantees"`
 	SecretsMap           string `json:"secretsMap" yaml:"secretsMap"`
+	SecretsDirectory     string `json:"secretsDirectory" yaml:"secretsDirectory"`
 	Runtime              int32  `json:"runtime" yaml:"runtime"`
+	// publish a snapshot of the metrics to the log topic at this interval, 0 means never
+	LogTopicMetricsIntervalMs int64 `json:"logTopicMetricsIntervalMs" yaml:"logTopicMetricsIntervalMs"`
 	// Authentication
 	ClientAuthenticationPlugin     string `json:"clientAuthenticationPlugin" yaml:"clientAuthenticationPlugin"`
 	ClientAuthenticationParameters string `json:"clientAuthenticationParameters" yaml:"clientAuthenticationParameters"`
 	TLSTrustCertsFilePath          string `json:"tlsTrustCertsFilePath" yaml:"tlsTrustCertsFilePath"`
 	TLSAllowInsecureConnection     bool   `json:"tlsAllowInsecureConnection" yaml:"tlsAllowInsecureConnection"`
 	TLSHostnameVerificationEnable  bool   `json:"tlsHostnameVerificationEnable" yaml:"tlsHostnameVerificationEnable"`
+	// how long before its expiry the auth token is fetched again, 1 minute by default
+	ClientAuthTokenRefreshMarginMs int64 `json:"clientAuthTokenRefreshMarginMs" yaml:"clientAuthTokenRefreshMarginMs"`
+	// minimum TLS version (e.g. TLSv1.2) and comma separated cipher suite names
+	TLSMinVersion   string `json:"tlsMinVersion" yaml:"tlsMinVersion"`
+	TLSCipherSuites string `json:"tlsCipherSuites" yaml:"tlsCipherSuites"`
+	// client connection settings, the client defaults apply when unset
+	ConnectionTimeoutMs int64 `json:"connectionTimeoutMs" yaml:"connectionTimeoutMs"`
+	KeepAliveIntervalMs int64 `json:"keepAliveIntervalMs" yaml:"keepAliveIntervalMs"`
+	OperationTimeoutMs  int64 `json:"operationTimeoutMs" yaml:"operationTimeoutMs"`
+	// connections opened to each broker, the client default of one applies when unset
+	ConnectionsPerBroker int `json:"connectionsPerBroker" yaml:"connectionsPerBroker"`
 	// Deprecated
 	AutoACK     bool  `json:"autoAck" yaml:"autoAck"`
 	Parallelism int32 `json:"parallelism" yaml:"parallelism"`
@@ -64,8 +91,58 @@ type Conf struct {
 	SubscriptionName     string `json:"subscriptionName" yaml:"subscriptionName"`
 	CleanupSubscription  bool   `json:"cleanupSubscription"  yaml:"cleanupSubscription"`
 	SubscriptionPosition int32  `json:"subscriptionPosition" yaml:"subscriptionPosition"`
-	//source input specs
+	// chunked message reassembly on the consumer side
+	MaxPendingChunkedMessage int  `json:"maxPendingChunkedMessage" yaml:"maxPendingChunkedMessage"`
+	AutoAckIncompleteChunk   bool `json:"autoAckIncompleteChunk" yaml:"autoAckIncompleteChunk"`
+	// read only the latest value per key, requires a Failover subscription
+	ReadCompacted bool `json:"readCompacted" yaml:"readCompacted"`
+	// ack and skip tombstones, the keyed messages without payload that delete a key from a compacted topic,
+	// instead of passing them to the function
+	SkipTombstones bool `json:"skipTombstones" yaml:"skipTombstones"`
+	// ack and skip messages published longer ago than this, instead of passing them to the
+	// function, 0 processes messages whatever their age
+	MaxMessageAgeMs int64 `json:"maxMessageAgeMs" yaml:"maxMessageAgeMs"`
+	// messages the broker delivered are redelivered when the instance restarts, after a crash
+	// too, unless they were acked. Messages neither acked nor nacked this long after they were
+	// received are also redelivered while the instance runs. 0 falls back to timeoutMs, the ack
+	// timeout of the source, and leaves them in flight until then when both are unset
+	AckTimeoutMs int64 `json:"ackTimeoutMs" yaml:"ackTimeoutMs"`
+	// topic a marker, keyed as the input, is published to for every message redelivered as it
+	// timed out under ackTimeoutMs or timeoutMs, before it is nacked
+	OnTimeoutTopic string `json:"onTimeoutTopic" yaml:"onTimeoutTopic"`
+	// window in milliseconds over which acks are grouped, 0 sends every ack immediately
+	AckGroupingTimeMs int64 `json:"ackGroupingTimeMs" yaml:"ackGroupingTimeMs"`
+	// wait for the broker to confirm each ack, failed acks are reported as system exceptions,
+	// acks are not grouped then
+	AckWithResponse bool `json:"ackWithResponse" yaml:"ackWithResponse"`
+	// ack the messages of a batch individually, so that a failed one does not redeliver the whole batch
+	BatchIndexAckEnabled bool `json:"batchIndexAckEnabled" yaml:"batchIndexAckEnabled"`
+	// Durable (default) or NonDurable, the latter does not persist a cursor
+	SubscriptionMode string `json:"subscriptionMode" yaml:"subscriptionMode"`
+	// replicate the subscription cursor across geo-replicated clusters, so that a function failing
+	// over to another cluster resumes where it left off, Durable subscriptions only
+	ReplicateSubscriptionState bool `json:"replicateSubscriptionState" yaml:"replicateSubscriptionState"`
+	// listener (default), the client pushes the messages, or receive, the instance pulls one message at a time
+	ConsumptionMode string `json:"consumptionMode" yaml:"consumptionMode"`
+	// on subscription, seek back to this long before the current time, taking precedence over subscriptionPosition
+	StartMessageRollbackDurationMs int64 `json:"startMessageRollbackDurationMs" yaml:"startMessageRollbackDurationMs"`
+	// whether the message a subscription is reset to with the SeekToMessageID RPC, or a reader
+	// created with FunctionContext.NewReader starts at, is processed, it is skipped by default
+	StartMessageIDInclusive bool `json:"startMessageIDInclusive" yaml:"startMessageIDInclusive"`
+	// delay before a new active consumer of a Failover subscription takes over, ignored for other types.
+	// The Pulsar client has no such consumer option yet, the setting is only validated
+	ConsumerFailoverDelayMs int64 `json:"consumerFailoverDelayMs" yaml:"consumerFailoverDelayMs"`
+	// poll for new partitions of the input topics every autoUpdatePartitionsIntervalMs,
+	// the client's default period applies when disabled
+	AutoUpdatePartitions           bool  `json:"autoUpdatePartitions" yaml:"autoUpdatePartitions"`
+	AutoUpdatePartitionsIntervalMs int64 `json:"autoUpdatePartitionsIntervalMs" yaml:"autoUpdatePartitionsIntervalMs"`
+	//source input specs, besides the ConsumerSpec fields a spec may set a deadLetterTopic, which
+	// the messages of that input go to instead of the deadLetterTopic of the function
 	SourceInputSpecs map[string]string `json:"sourceInputSpecs" yaml:"sourceInputSpecs"`
+	// comma separated input topics consumed with the sourceSchemaType and receiverQueueSize settings
+	SourceTopicsList string `json:"sourceTopicsList" yaml:"sourceTopicsList"`
+	// Avro schema definitions (JSON) keyed by input topic, used instead of the registry schema to decode
+	InputSchemaDefinition map[string]string `json:"inputSchemaDefinition" yaml:"inputSchemaDefinition"`
 	// for backward compatibility
 	// Deprecated
 	SourceSpecTopic string `json:"sourceSpecsTopic" yaml:"sourceSpecsTopic"`
@@ -78,6 +155,53 @@ type Conf struct {
 	//sink spec config
 	SinkSpecTopic  string `json:"sinkSpecsTopic" yaml:"sinkSpecsTopic"`
 	SinkSchemaType string `json:"sinkSchemaType" yaml:"sinkSchemaType"`
+	// Avro schema definition (JSON) registered for the output, the sinkSchemaType must be AVRO or JSON
+	OutputSchemaDefinition string `json:"outputSchemaDefinition" yaml:"outputSchemaDefinition"`
+	// create the sink producer without registering the output schema, for namespaces where the
+	// broker does not let clients create schemas, outputs are still encoded with the definition
+	DisableSchemaCreation bool `json:"disableSchemaCreation" yaml:"disableSchemaCreation"`
+	// whether the sink topic may be created when it does not exist, true by default. The
+	// broker decides on it, when false a missing sink topic fails the instance with a clear error
+	AllowAutoTopicCreation *bool `json:"allowAutoTopicCreation" yaml:"allowAutoTopicCreation"`
+	// check at startup that the schemas declared for the inputs and the sink agree with the ones
+	// registered on their topics, read from pulsarWebServiceURL
+	CheckSchemaCompatibility bool `json:"checkSchemaCompatibility" yaml:"checkSchemaCompatibility"`
+	// fail startup when an input topic does not exist, looked up from pulsarWebServiceURL.
+	// Topics patterns are not checked
+	RequireInputTopicsExist bool `json:"requireInputTopicsExist" yaml:"requireInputTopicsExist"`
+	// allow the sink topic to be one of the input topics, i.e. an intentional processing loop
+	AllowSinkInputOverlap bool `json:"allowSinkInputOverlap" yaml:"allowSinkInputOverlap"`
+	// chunking can only be enabled when batching is disabled
+	EnableChunking  bool `json:"enableChunking" yaml:"enableChunking"`
+	DisableBatching bool `json:"disableBatching" yaml:"disableBatching"`
+	// event time set on output messages: passthrough, processing or none (default)
+	OutputEventTimeStrategy string `json:"outputEventTimeStrategy" yaml:"outputEventTimeStrategy"`
+	// copy the W3C traceparent and tracestate properties of the input to the output messages
+	PropagateTraceContext bool `json:"propagateTraceContext" yaml:"propagateTraceContext"`
+	// with an output combiner registered with RegisterOutputCombiner, how many outputs are
+	// buffered, 100 by default, and for how long at most, 1s by default, before they are combined
+	OutputAggregationMaxRecords int   `json:"outputAggregationMaxRecords" yaml:"outputAggregationMaxRecords"`
+	OutputAggregationMaxDelayMs int64 `json:"outputAggregationMaxDelayMs" yaml:"outputAggregationMaxDelayMs"`
+	// with a key extractor registered with RegisterDeduplicationKeyExtractor, for how long the key
+	// of a processed message makes the later ones with the same key skipped, unset means until it
+	// is evicted, and how many keys are remembered at most, 10000 by default
+	DeduplicationWindowMs int64 `json:"deduplicationWindowMs" yaml:"deduplicationWindowMs"`
+	DeduplicationMaxKeys  int   `json:"deduplicationMaxKeys" yaml:"deduplicationMaxKeys"`
+	// partition routing of the sink: roundRobin, keyBased or singlePartition (to sinkPartition),
+	// the client default routing applies when unset
+	SinkPartitionRouting string `json:"sinkPartitionRouting" yaml:"sinkPartitionRouting"`
+	SinkPartition        int    `json:"sinkPartition" yaml:"sinkPartition"`
+	// hashing of message keys into partitions by the producers, JavaStringHash (default) or Murmur3_32Hash,
+	// to match the one of the other clients publishing to or reading from the same topics
+	ProducerHashingScheme string `json:"producerHashingScheme" yaml:"producerHashingScheme"`
+	// maximum number of messages in flight across the producers created by NewOutputMessage,
+	// sends block once it is reached, 0 means unbounded
+	MaxPendingPublishes int `json:"maxPendingPublishes" yaml:"maxPendingPublishes"`
+	// producers of the topics PublishToDerivedTopic publishes to are closed once they have not
+	// sent for this long, and recreated on next use. The sink producer is kept, 0 keeps them all
+	ProducerIdleTimeoutMs int64 `json:"producerIdleTimeoutMs" yaml:"producerIdleTimeoutMs"`
+	// maximum number of messages processed per second, intake blocks once it is reached, 0 means unlimited
+	MaxProcessingRate float64 `json:"maxProcessingRate" yaml:"maxProcessingRate"`
 	//resources config
 	Cpu  float64 `json:"cpu" yaml:"cpu"`
 	Ram  int64   `json:"ram" yaml:"ram"`
@@ -85,10 +209,66 @@ type Conf struct {
 	//retryDetails config
 	MaxMessageRetries           int32  `json:"maxMessageRetries" yaml:"maxMessageRetries"`
 	DeadLetterTopic             string `json:"deadLetterTopic" yaml:"deadLetterTopic"`
+	RetryLetterTopic            string `json:"retryLetterTopic" yaml:"retryLetterTopic"`
+	DeadLetterMaxErrorLength    int    `json:"deadLetterMaxErrorLength" yaml:"deadLetterMaxErrorLength"`
+	RetryBackoffInitialMs       int64  `json:"retryBackoffInitialMs" yaml:"retryBackoffInitialMs"`
+	RetryBackoffMaxMs           int64  `json:"retryBackoffMaxMs" yaml:"retryBackoffMaxMs"`
+	NegativeAckBackoffMinMs     int64  `json:"negativeAckBackoffMinMs" yaml:"negativeAckBackoffMinMs"`
+	NegativeAckBackoffMaxMs     int64  `json:"negativeAckBackoffMaxMs" yaml:"negativeAckBackoffMaxMs"`
 	ExpectedHealthCheckInterval int32  `json:"expectedHealthCheckInterval" yaml:"expectedHealthCheckInterval"`
 	UserConfig                  string `json:"userConfig" yaml:"userConfig"`
+	// route the input to the dead letter topic, rather than redelivering it, when an output
+	// exceeds the max message size of the broker
+	DeadLetterOversizedOutputs bool `json:"deadLetterOversizedOutputs" yaml:"deadLetterOversizedOutputs"`
+	// fail startup, rather than warn, when userConfig has keys the function did not declare
+	FailOnUnknownUserConfigKeys bool `json:"failOnUnknownUserConfigKeys" yaml:"failOnUnknownUserConfigKeys"`
+	// what happens to a message once the function failed on it and the retries are exhausted:
+	// retry (default), skip, dlt or fail. With retry and no deadLetterTopic, a message
+	// that exhausted maxMessageRetries is acked and dropped
+	OnProcessError string `json:"onProcessError" yaml:"onProcessError"`
+	// what happens to a message once the send of one of its outputs failed: retry (default), nack
+	// or fail. retry sends the output again up to maxSendRetries times, 5 by default, before nacking
+	OnSendFailure  string `json:"onSendFailure" yaml:"onSendFailure"`
+	MaxSendRetries int    `json:"maxSendRetries" yaml:"maxSendRetries"`
+	// how many input deserialization errors are logged before sampling them, all of them are
+	// logged when neither this nor deserializationErrorLogEvery is set
+	DeserializationErrorLogFirst int `json:"deserializationErrorLogFirst" yaml:"deserializationErrorLogFirst"`
+	// once deserializationErrorLogFirst errors are logged, only one in this many is, 0 logs no more
+	DeserializationErrorLogEvery int `json:"deserializationErrorLogEvery" yaml:"deserializationErrorLogEvery"`
+	// include the start of the payload in the logs about the messages that failed, off by default as
+	// payloads may carry sensitive data, up to maxPayloadPreviewBytes, 256 by default, quoted or hex
+	// encoded with payloadPreviewHex
+	LogPayloadOnError      bool `json:"logPayloadOnError" yaml:"logPayloadOnError"`
+	MaxPayloadPreviewBytes int  `json:"maxPayloadPreviewBytes" yaml:"maxPayloadPreviewBytes"`
+	PayloadPreviewHex      bool `json:"payloadPreviewHex" yaml:"payloadPreviewHex"`
 	//metrics config
 	MetricsPort int `json:"metricsPort" yaml:"metricsPort"`
+	// port serving the handlers registered with RegisterDebugHandler, 0 disables it
+	DebugPort int `json:"debugPort" yaml:"debugPort"`
+	// replaces the pulsar_function prefix of all metric names
+	MetricsNamespace string `json:"metricsNamespace" yaml:"metricsNamespace"`
+	// keep running without metrics instead of failing when metricsPort cannot be bound
+	ContinueWithoutMetrics bool `json:"continueWithoutMetrics" yaml:"continueWithoutMetrics"`
+	// serve the metrics in the OpenMetrics format, which carries the trace id exemplars of the
+	// latency histogram, even to scrapers that do not ask for it
+	MetricsOpenMetrics bool `json:"metricsOpenMetrics" yaml:"metricsOpenMetrics"`
+	// serve the function status, including the last error, as JSON on /status of metricsPort
+	MetricsStatusEndpoint bool `json:"metricsStatusEndpoint" yaml:"metricsStatusEndpoint"`
+	// how often the connection stats of the Pulsar client are copied to the metrics, 10s when unset
+	ClientStatsIntervalMs int64 `json:"clientStatsIntervalMs" yaml:"clientStatsIntervalMs"`
+	// how often the messages and bytes received per input topic are copied to the metrics,
+	// unset means they are not
+	ConsumerStatsIntervalMs int64 `json:"consumerStatsIntervalMs" yaml:"consumerStatsIntervalMs"`
+	// how often the function registered with RegisterGenerator is called, 1s by default
+	GenerateIntervalMs int64 `json:"generateIntervalMs" yaml:"generateIntervalMs"`
+	//admin config, used to read the subscription backlog
+	PulsarWebServiceURL    string `json:"pulsarWebServiceURL" yaml:"pulsarWebServiceURL"`
+	BacklogCacheIntervalMs int64  `json:"backlogCacheIntervalMs" yaml:"backlogCacheIntervalMs"`
+	//startup config, report ready only once subscribed and, if the threshold is set, caught up on the backlog
+	StartupWaitForSubscription bool  `json:"startupWaitForSubscription" yaml:"startupWaitForSubscription"`
+	StartupBacklogThreshold    int64 `json:"startupBacklogThreshold" yaml:"startupBacklogThreshold"`
+	// subscribe and report ready, but only start consuming once resumed through the Resume RPC
+	StartPaused bool `json:"startPaused" yaml:"startPaused"`
 }
 
 var (
@@ -97,6 +277,9 @@ var (
 	confContent  string
 )
 
+// GetConf loads the config into c from, in increasing order of precedence,
+// the file given by -instance-conf-path, the JSON content given by
+// -instance-conf and the environment variables named after EnvPrefix
 func (c *Conf) GetConf() *Conf {
 	flag.Parse()
 
@@ -134,9 +317,80 @@ func (c *Conf) GetConf() *Conf {
 		}
 	}
 
+	envConf, err := LoadEnvConf()
+	if err != nil {
+		log.Errorf("load conf from environment failed, err:%s", err.Error())
+		return nil
+	}
+	*c = *MergeConf(c, envConf)
+
 	return c
 }
 
+// MergeConf returns a copy of base, where each field set in override, i.e.
+// not holding its zero value, replaces the one of base. A field cannot be
+// overridden back to its zero value, such as false, unless it is a pointer.
+func MergeConf(base, override *Conf) *Conf {
+	merged := *base
+	if override == nil {
+		return &merged
+	}
+	mergedValue := reflect.ValueOf(&merged).Elem()
+	overrideValue := reflect.ValueOf(override).Elem()
+	for i := 0; i < overrideValue.NumField(); i++ {
+		if field := overrideValue.Field(i); !field.IsZero() {
+			mergedValue.Field(i).Set(field)
+		}
+	}
+	return &merged
+}
+
+// LoadEnvConf returns the config set by the environment variables named after
+// EnvPrefix, the fields without a variable are left to their zero value
+func LoadEnvConf() (*Conf, error) {
+	envConf := &Conf{}
+	confValue := reflect.ValueOf(envConf).Elem()
+	confType := confValue.Type()
+	for i := 0; i < confType.NumField(); i++ {
+		name := strings.Split(confType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		envName := EnvPrefix + upperSnakeCase(name)
+		value, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		field := confValue.Field(i)
+		if field.Kind() == reflect.String {
+			field.SetString(value)
+			continue
+		}
+		if err := yaml.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return nil, fmt.Errorf("invalid value %q of %s: %v", value, envName, err)
+		}
+	}
+	return envConf, nil
+}
+
+// upperSnakeCase turns a camel case name into upper snake case, keeping the
+// acronyms together, e.g. pulsarServiceURL into PULSAR_SERVICE_URL
+func upperSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
 func init() {
 	var defaultPath string
 	if err := os.Chdir("../"); err == nil {
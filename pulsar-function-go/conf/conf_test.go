@@ -0,0 +1,129 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useConfFile points GetConf at a file holding content until the end of t
+func useConfFile(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "conf.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	originalPath, originalContent := confFilePath, confContent
+	confFilePath, confContent = path, ""
+	t.Cleanup(func() {
+		confFilePath, confContent = originalPath, originalContent
+	})
+}
+
+func TestMergeConf(t *testing.T) {
+	allow := false
+	base := &Conf{PulsarServiceURL: "pulsar://base:6650", InstanceID: 1, AutoACK: true, KillAfterIdleMs: 1000}
+	override := &Conf{InstanceID: 2, AllowAutoTopicCreation: &allow, UserConfig: "{}"}
+
+	merged := MergeConf(base, override)
+
+	assert.Equal(t, &Conf{
+		PulsarServiceURL:       "pulsar://base:6650",
+		InstanceID:             2,
+		AutoACK:                true,
+		KillAfterIdleMs:        1000,
+		AllowAutoTopicCreation: &allow,
+		UserConfig:             "{}",
+	}, merged)
+	// neither of the sources is modified
+	assert.Equal(t, 1, base.InstanceID)
+	assert.Nil(t, base.AllowAutoTopicCreation)
+	assert.Equal(t, "", override.PulsarServiceURL)
+
+	assert.Equal(t, base, MergeConf(base, nil))
+}
+
+func TestGetConf_EnvOverridesFile(t *testing.T) {
+	useConfFile(t, `
+pulsarServiceURL: "pulsar://file:6650"
+instanceID: 101
+funcID: "pulsar-function"
+autoAck: true
+parallelism: 3
+sourceInputSpecs:
+  "persistent://public/default/topic-01": "{}"
+`)
+	t.Setenv(EnvPrefix+"PULSAR_SERVICE_URL", "pulsar://env:6650")
+	t.Setenv(EnvPrefix+"INSTANCE_ID", "7")
+	t.Setenv(EnvPrefix+"MAX_PROCESSING_RATE", "2.5")
+	t.Setenv(EnvPrefix+"ALLOW_AUTO_TOPIC_CREATION", "false")
+	t.Setenv(EnvPrefix+"SOURCE_INPUT_SPECS", `{"persistent://public/default/topic-02": "{}"}`)
+
+	conf := (&Conf{}).GetConf()
+
+	if assert.NotNil(t, conf) {
+		assert.Equal(t, "pulsar://env:6650", conf.PulsarServiceURL)
+		assert.Equal(t, 7, conf.InstanceID)
+		assert.Equal(t, 2.5, conf.MaxProcessingRate)
+		if assert.NotNil(t, conf.AllowAutoTopicCreation) {
+			assert.False(t, *conf.AllowAutoTopicCreation)
+		}
+		assert.Equal(t, map[string]string{"persistent://public/default/topic-02": "{}"}, conf.SourceInputSpecs)
+		// the variables that are not set leave the values of the file
+		assert.Equal(t, "pulsar-function", conf.FuncID)
+		assert.True(t, conf.AutoACK)
+		assert.Equal(t, int32(3), conf.Parallelism)
+	}
+}
+
+func TestGetConf_UnsetEnvLeavesFile(t *testing.T) {
+	useConfFile(t, `
+pulsarServiceURL: "pulsar://file:6650"
+instanceID: 101
+`)
+
+	conf := (&Conf{}).GetConf()
+
+	if assert.NotNil(t, conf) {
+		assert.Equal(t, "pulsar://file:6650", conf.PulsarServiceURL)
+		assert.Equal(t, 101, conf.InstanceID)
+	}
+}
+
+func TestGetConf_InvalidEnv(t *testing.T) {
+	useConfFile(t, "instanceID: 101\n")
+	t.Setenv(EnvPrefix+"INSTANCE_ID", "one")
+
+	assert.Nil(t, (&Conf{}).GetConf())
+}
+
+func TestUpperSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"port":                  "PORT",
+		"instanceID":            "INSTANCE_ID",
+		"pulsarServiceURL":      "PULSAR_SERVICE_URL",
+		"pulsarWebServiceURL":   "PULSAR_WEB_SERVICE_URL",
+		"tlsTrustCertsFilePath": "TLS_TRUST_CERTS_FILE_PATH",
+		"killAfterIdleMs":       "KILL_AFTER_IDLE_MS",
+	} {
+		assert.Equal(t, expected, upperSnakeCase(name))
+	}
+}
@@ -0,0 +1,157 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// The broker redelivers the messages left unacked by a consumer once it
+// disconnects, so the messages in flight when the instance crashes are
+// reprocessed by the restarted instance, whatever the subscription type. Unlike
+// the Java client, the Go client has no ack timeout redelivering the messages
+// of a consumer that is still connected, inFlightMessages makes up for it.
+
+// Properties of the marker messages published to onTimeoutTopic
+const (
+	TimeoutMarkerOriginTopicProperty     = "ORIGIN_TOPIC"
+	TimeoutMarkerOriginMessageIDProperty = "ORIGIN_MESSAGE_ID"
+	TimeoutMarkerTimeoutProperty         = "ACK_TIMEOUT_MS"
+	TimeoutMarkerTimestampProperty       = "TIMEOUT_TIMESTAMP"
+)
+
+// inFlightMessages tracks the messages received, and neither acked nor nacked
+// yet, along with when they were received
+type inFlightMessages struct {
+	mu       sync.Mutex
+	messages map[pulsar.Message]time.Time
+}
+
+func newInFlightMessages() *inFlightMessages {
+	return &inFlightMessages{messages: make(map[pulsar.Message]time.Time)}
+}
+
+func (m *inFlightMessages) add(msg pulsar.Message, receivedAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages[msg] = receivedAt
+}
+
+func (m *inFlightMessages) remove(msg pulsar.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.messages, msg)
+}
+
+// removeReceivedBefore stops tracking the messages received before deadline
+// and returns them
+func (m *inFlightMessages) removeReceivedBefore(deadline time.Time) []pulsar.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired []pulsar.Message
+	for msg, receivedAt := range m.messages {
+		if receivedAt.Before(deadline) {
+			expired = append(expired, msg)
+			delete(m.messages, msg)
+		}
+	}
+	return expired
+}
+
+// trackInFlight records that msg was received, when an ack timeout is set
+func (gi *goInstance) trackInFlight(msg pulsar.Message) {
+	if gi.inFlight != nil {
+		gi.inFlight.add(msg, time.Now())
+	}
+}
+
+// startAckTimeoutSweep redelivers the messages neither acked nor nacked within
+// ackTimeout, checking twice per timeout, until the returned function is called
+func (gi *goInstance) startAckTimeoutSweep() (stop func()) {
+	timeout := gi.context.instanceConf.ackTimeout
+	if timeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				gi.redeliverTimedOutMessages(timeout)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// redeliverTimedOutMessages nacks the messages received longer ago than
+// timeout and still in flight, once a marker is published for them when
+// onTimeoutTopic is set. One that is acked later on, as its processing
+// completes after all, may then be processed twice.
+func (gi *goInstance) redeliverTimedOutMessages(timeout time.Duration) {
+	for _, msg := range gi.inFlight.removeReceivedBefore(time.Now().Add(-timeout)) {
+		log.Warnf("message ID %s was neither acked nor nacked within %v, redelivering it",
+			messageIDStr(msg), timeout)
+		if gi.context.instanceConf.onTimeoutTopic == "" {
+			gi.nackInputMessage(msg)
+			continue
+		}
+		gi.publishTimeoutMarker(msg, timeout)
+	}
+}
+
+// publishTimeoutMarker publishes to onTimeoutTopic a marker of msg timing out,
+// then nacks msg whether the marker could be published or not
+func (gi *goInstance) publishTimeoutMarker(msg pulsar.Message, timeout time.Duration) {
+	producer, release, err := gi.getDerivedProducer(gi.context.instanceConf.onTimeoutTopic)
+	if err != nil {
+		log.Errorf("failed to set up the producer of onTimeoutTopic: %v", err)
+		gi.nackInputMessage(msg)
+		return
+	}
+	marker := &pulsar.ProducerMessage{
+		Key: msg.Key(),
+		Properties: map[string]string{
+			TimeoutMarkerOriginTopicProperty:     msg.Topic(),
+			TimeoutMarkerOriginMessageIDProperty: messageIDStr(msg),
+			TimeoutMarkerTimeoutProperty:         strconv.FormatInt(timeout.Milliseconds(), 10),
+			TimeoutMarkerTimestampProperty:       strconv.FormatInt(time.Now().UnixMilli(), 10),
+		},
+	}
+	producer.SendAsync(context.Background(), marker, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+		release()
+		if err != nil {
+			log.Errorf("failed to publish the timeout marker of message ID %s: %v", messageIDStr(msg), err)
+			gi.stats.incrTotalSysExceptions(err)
+		}
+		gi.nackInputMessage(msg)
+	})
+}
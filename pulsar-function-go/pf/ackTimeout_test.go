@@ -0,0 +1,212 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// testSubscription stands for the broker side of a subscription: it
+// redelivers, to the consumer of a restarted instance, the messages the
+// consumer of the previous one did not ack
+type testSubscription struct {
+	messages []*MockMessage
+}
+
+func (s *testSubscription) deliver() chan pulsar.ConsumerMessage {
+	channel := make(chan pulsar.ConsumerMessage, len(s.messages))
+	for _, msg := range s.messages {
+		channel <- pulsar.ConsumerMessage{Message: msg}
+	}
+	return channel
+}
+
+// restart keeps the messages consumer did not ack, as they are redelivered
+func (s *testSubscription) restart(consumer *MockPulsarConsumer) {
+	acked := make(map[pulsar.Message]bool)
+	for _, msg := range consumer.acked {
+		acked[msg] = true
+	}
+	var unacked []*MockMessage
+	for _, msg := range s.messages {
+		if !acked[msg] {
+			msg.redeliveryCount++
+			unacked = append(unacked, msg)
+		}
+	}
+	s.messages = unacked
+}
+
+func TestAckTimeout_RedeliveredAfterRestart(t *testing.T) {
+	subscription := &testSubscription{}
+	for _, payload := range []string{"1", "2", "3"} {
+		subscription.messages = append(subscription.messages, &MockMessage{
+			topic:     "persistent://public/default/topic-01",
+			messageID: &MockMessageID{},
+			payload:   []byte(payload),
+		})
+	}
+	// the output of the last message is still buffered when the instance crashes
+	instance, consumer, producer := newAggregationTestInstance(t, 2, time.Hour)
+	assert.NoError(t, instance.processMessages(subscription.deliver()))
+	assert.Equal(t, []string{"1,2"}, publishedPayloads(producer))
+	assert.Len(t, consumer.acked, 2)
+
+	subscription.restart(consumer)
+	restarted, consumer, producer := newAggregationTestInstance(t, 1, time.Hour)
+	assert.NoError(t, restarted.processMessages(subscription.deliver()))
+
+	assert.Equal(t, []string{"3"}, publishedPayloads(producer))
+	if assert.Len(t, consumer.acked, 1) {
+		assert.Equal(t, []byte("3"), consumer.acked[0].Payload())
+		assert.Equal(t, uint32(1), consumer.acked[0].RedeliveryCount())
+	}
+}
+
+func TestAckTimeout_RedeliversInFlightMessages(t *testing.T) {
+	instance, consumer, _ := newAggregationTestInstance(t, 100, time.Hour)
+	instance.inFlight = newInFlightMessages()
+
+	assert.NoError(t, instance.processMessages(inputMessages("1", "2")))
+	// still in flight, but not for long enough
+	instance.redeliverTimedOutMessages(time.Hour)
+	assert.Empty(t, consumer.nacked)
+
+	time.Sleep(5 * time.Millisecond)
+	instance.redeliverTimedOutMessages(time.Millisecond)
+	assert.Len(t, consumer.nacked, 2)
+	// they are only redelivered once
+	instance.redeliverTimedOutMessages(time.Millisecond)
+	assert.Len(t, consumer.nacked, 2)
+}
+
+func TestAckTimeout_AckedMessagesNotRedelivered(t *testing.T) {
+	instance, consumer, _ := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.inFlight = newInFlightMessages()
+
+	assert.NoError(t, instance.processMessages(inputMessages("1", "2")))
+	time.Sleep(5 * time.Millisecond)
+	instance.redeliverTimedOutMessages(time.Millisecond)
+
+	assert.Len(t, consumer.acked, 2)
+	assert.Empty(t, consumer.nacked)
+	assert.Empty(t, instance.inFlight.messages)
+}
+
+func TestAckTimeout_Sweep(t *testing.T) {
+	instance, _, _ := newAggregationTestInstance(t, 100, time.Hour)
+	instance.context.instanceConf.ackTimeout = 20 * time.Millisecond
+	instance.inFlight = newInFlightMessages()
+	assert.NoError(t, instance.processMessages(inputMessages("1")))
+
+	stop := instance.startAckTimeoutSweep()
+	defer stop()
+	assert.Eventually(t, func() bool {
+		instance.inFlight.mu.Lock()
+		defer instance.inFlight.mu.Unlock()
+		return len(instance.inFlight.messages) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestAckTimeout_TimeoutMarker(t *testing.T) {
+	instance, consumer, _ := newAggregationTestInstance(t, 100, time.Hour)
+	instance.inFlight = newInFlightMessages()
+	instance.context.instanceConf.onTimeoutTopic = "persistent://public/default/timeouts"
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance.client = client
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+		topic:     "persistent://public/default/topic-01",
+		key:       "key-1",
+		messageID: &MockMessageID{},
+		payload:   []byte("slow"),
+	}}
+	assert.NoError(t, instance.processMessages(channel))
+
+	time.Sleep(5 * time.Millisecond)
+	instance.redeliverTimedOutMessages(time.Millisecond)
+
+	producers := client.producers["persistent://public/default/timeouts"]
+	if assert.Len(t, producers, 1) && assert.Len(t, producers[0].messages, 1) {
+		marker := producers[0].messages[0]
+		assert.Equal(t, "key-1", marker.Key)
+		assert.Empty(t, marker.Payload)
+		assert.Equal(t, "persistent://public/default/topic-01", marker.Properties[TimeoutMarkerOriginTopicProperty])
+		assert.Equal(t, "0:0:0:0", marker.Properties[TimeoutMarkerOriginMessageIDProperty])
+		assert.Equal(t, "1", marker.Properties[TimeoutMarkerTimeoutProperty])
+		assert.NotEmpty(t, marker.Properties[TimeoutMarkerTimestampProperty])
+	}
+	assert.Len(t, consumer.nacked, 1)
+
+	// the input is redelivered even when the marker fails to publish
+	producers[0].sendErr = errors.New("producer closed")
+	assert.NoError(t, instance.processMessages(inputMessages("slow")))
+	time.Sleep(5 * time.Millisecond)
+	instance.redeliverTimedOutMessages(time.Millisecond)
+	assert.Len(t, consumer.nacked, 2)
+}
+
+func TestInstanceConf_AckTimeout(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AckTimeoutMs: 30000})
+	assert.Equal(t, 30*time.Second, instanceConf.ackTimeout)
+
+	assert.PanicsWithValue(t, "ackTimeoutMs must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AckTimeoutMs: -1})
+	})
+	assert.PanicsWithValue(t, "onTimeoutTopic requires ackTimeoutMs or timeoutMs to be set.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OnTimeoutTopic: "persistent://public/default/timeouts"})
+	})
+
+	// the ack timeout of the source applies unless ackTimeoutMs is set
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, TimeoutMs: 10000,
+		OnTimeoutTopic: "persistent://public/default/timeouts"})
+	assert.Equal(t, 10*time.Second, instanceConf.ackTimeout)
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, TimeoutMs: 10000, AckTimeoutMs: 30000})
+	assert.Equal(t, 30*time.Second, instanceConf.ackTimeout)
+}
+
+func TestAckTimeout_SourceTimeoutMarker(t *testing.T) {
+	instance, consumer, _ := newAggregationTestInstance(t, 100, time.Hour)
+	loaded := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, TimeoutMs: 20,
+		OnTimeoutTopic: "persistent://public/default/timeouts"})
+	instance.context.instanceConf.funcDetails.Source.TimeoutMs = loaded.funcDetails.Source.TimeoutMs
+	instance.context.instanceConf.ackTimeout = loaded.ackTimeout
+	instance.context.instanceConf.onTimeoutTopic = loaded.onTimeoutTopic
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance.client = client
+	instance.inFlight = newInFlightMessages()
+	assert.NoError(t, instance.processMessages(inputMessages("slow")))
+
+	time.Sleep(25 * time.Millisecond)
+	instance.redeliverTimedOutMessages(instance.context.instanceConf.ackTimeout)
+
+	producers := client.producers["persistent://public/default/timeouts"]
+	if assert.Len(t, producers, 1) && assert.Len(t, producers[0].messages, 1) {
+		assert.Equal(t, "20", producers[0].messages[0].Properties[TimeoutMarkerTimeoutProperty])
+	}
+	assert.Len(t, consumer.nacked, 1)
+}
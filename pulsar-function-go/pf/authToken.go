@@ -0,0 +1,197 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+const (
+	defaultTokenRefreshMargin        = time.Minute
+	defaultTokenRefreshRetryInterval = 10 * time.Second
+)
+
+// tokenSource provides the token the instance authenticates with
+type tokenSource func() (string, error)
+
+// fileTokenSource reads the token from path, which is expected to be rewritten
+// with a new token before the current one expires, e.g. by a mounted secret
+func fileTokenSource(path string) tokenSource {
+	return func() (string, error) {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+}
+
+// staticTokenSource always provides token, which thus cannot be refreshed
+func staticTokenSource(token string) tokenSource {
+	return func() (string, error) {
+		return token, nil
+	}
+}
+
+// tokenExpiry returns the expiry of token, a JWT, from its exp claim, without
+// verifying the token. ok is false when the token is not a JWT or has no exp
+// claim.
+func tokenExpiry(token string) (expiry time.Time, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+	// exp is in seconds, fractions of which are kept to the millisecond
+	return time.UnixMilli(int64(math.Round(*claims.Exp * 1000))), true
+}
+
+// refreshingToken is the token of the client authentication. It is fetched
+// again from its source margin before it expires, so that a source failing to
+// provide a new token is noticed, and retried, while the current token is
+// still valid, rather than once the broker asks for a new one after it expired.
+type refreshingToken struct {
+	source        tokenSource
+	margin        time.Duration
+	retryInterval time.Duration
+	onRefresh     func()
+
+	mu     sync.RWMutex
+	token  string
+	expiry time.Time
+}
+
+// newRefreshingToken fetches the first token of source
+func (gi *goInstance) newRefreshingToken(source tokenSource) (*refreshingToken, error) {
+	margin := gi.context.instanceConf.authTokenRefreshMargin
+	if margin == 0 {
+		margin = defaultTokenRefreshMargin
+	}
+	t := &refreshingToken{
+		source:        source,
+		margin:        margin,
+		retryInterval: defaultTokenRefreshRetryInterval,
+		onRefresh:     gi.stats.incrTotalAuthTokenRefreshes,
+	}
+	if err := t.fetch(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// get is the token supplier of the client authentication
+func (t *refreshingToken) get() (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.token, nil
+}
+
+// getExpiry returns when the current token expires, zero if it does not
+func (t *refreshingToken) getExpiry() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.expiry
+}
+
+func (t *refreshingToken) fetch() error {
+	token, err := t.source()
+	if err != nil {
+		return err
+	}
+	expiry, _ := tokenExpiry(token)
+	t.mu.Lock()
+	t.token, t.expiry = token, expiry
+	t.mu.Unlock()
+	return nil
+}
+
+// refresh fetches the token again and reports whether it was renewed, that is
+// the new one expires later or does not expire
+func (t *refreshingToken) refresh() bool {
+	previous := t.getExpiry()
+	if err := t.fetch(); err != nil {
+		log.Warnf("failed to refresh the auth token expiring at %v: %v", previous, err)
+		return false
+	}
+	expiry := t.getExpiry()
+	if !expiry.IsZero() && !expiry.After(previous) {
+		log.Warnf("the auth token expires at %v and its source did not provide a new one yet", previous)
+		return false
+	}
+	log.Infof("refreshed the auth token expiring at %v, the new one expires at %v", previous, expiry)
+	t.onRefresh()
+	return true
+}
+
+// start refreshes the token margin before each expiry, and every retryInterval
+// until its source provides a new one
+func (t *refreshingToken) start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		delay := time.Until(t.getExpiry().Add(-t.margin))
+		for !t.getExpiry().IsZero() {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if t.refresh() {
+				delay = time.Until(t.getExpiry().Add(-t.margin))
+			} else {
+				delay = t.retryInterval
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startAuthTokenRefresh refreshes the token of the client authentication
+// until stop is called, it does nothing without token authentication
+func (gi *goInstance) startAuthTokenRefresh() (stop func()) {
+	if gi.authToken == nil {
+		return func() {}
+	}
+	return gi.authToken.start()
+}
@@ -0,0 +1,198 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// testToken returns an unsigned JWT expiring at expiry
+func testToken(expiry time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(
+		fmt.Sprintf(`{"sub":"function","exp":%.3f}`, float64(expiry.UnixMilli())/1000)))
+	return header + "." + claims + ".signature"
+}
+
+// fakeTokenSource provides its tokens in turn, repeating the last one, and
+// records when they are fetched
+type fakeTokenSource struct {
+	mu      sync.Mutex
+	tokens  []string
+	err     error
+	fetches []time.Time
+}
+
+func (s *fakeTokenSource) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches = append(s.fetches, time.Now())
+	if s.err != nil && len(s.fetches) > 1 {
+		return "", s.err
+	}
+	token := s.tokens[0]
+	if len(s.tokens) > 1 {
+		s.tokens = s.tokens[1:]
+	}
+	return token, nil
+}
+
+func (s *fakeTokenSource) getFetches() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time{}, s.fetches...)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	expiry := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	parsed, ok := tokenExpiry(testToken(expiry))
+	assert.True(t, ok)
+	assert.Equal(t, expiry, parsed)
+
+	withoutExp := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"function"}`))
+	for _, token := range []string{"opaque-token", "header." + withoutExp + ".signature", "header.!.signature"} {
+		_, ok := tokenExpiry(token)
+		assert.False(t, ok, token)
+	}
+}
+
+func TestRefreshingToken_RefreshesBeforeExpiry(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authTokenRefreshMargin = 200 * time.Millisecond
+	expiry := time.Now().Add(300 * time.Millisecond)
+	first, second := testToken(expiry), testToken(time.Now().Add(time.Hour))
+	source := &fakeTokenSource{tokens: []string{first, second}}
+	token, err := instance.newRefreshingToken(source.token)
+	assert.NoError(t, err)
+	instance.authToken = token
+	refreshes := instance.getTotalAuthTokenRefreshes()
+
+	stop := instance.startAuthTokenRefresh()
+	defer stop()
+	current, _ := token.get()
+	assert.Equal(t, first, current)
+	assert.Eventually(t, func() bool {
+		current, _ := token.get()
+		return current == second
+	}, time.Second, 5*time.Millisecond)
+
+	fetches := source.getFetches()
+	if assert.Len(t, fetches, 2) {
+		assert.True(t, fetches[1].Before(expiry), "refreshed at %v, after the expiry at %v", fetches[1], expiry)
+	}
+	assert.Equal(t, refreshes+1, instance.getTotalAuthTokenRefreshes())
+	tokenExpiry, ok := instance.context.GetAuthTokenExpiry()
+	assert.True(t, ok)
+	assert.True(t, tokenExpiry.After(time.Now().Add(time.Minute)))
+}
+
+func TestRefreshingToken_RetriesUntilRenewed(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authTokenRefreshMargin = time.Hour
+	stale, renewed := testToken(time.Now().Add(time.Minute)), testToken(time.Now().Add(2*time.Hour))
+	// the source keeps providing the stale token twice before the renewed one
+	source := &fakeTokenSource{tokens: []string{stale, stale, stale, renewed}}
+	token, err := instance.newRefreshingToken(source.token)
+	assert.NoError(t, err)
+	token.retryInterval = 10 * time.Millisecond
+	refreshes := instance.getTotalAuthTokenRefreshes()
+
+	stop := token.start()
+	assert.Eventually(t, func() bool {
+		current, _ := token.get()
+		return current == renewed
+	}, time.Second, 5*time.Millisecond)
+	stop()
+
+	assert.Len(t, source.getFetches(), 4)
+	assert.Equal(t, refreshes+1, instance.getTotalAuthTokenRefreshes())
+}
+
+func TestRefreshingToken_KeepsTokenOnError(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authTokenRefreshMargin = time.Hour
+	current := testToken(time.Now().Add(time.Minute))
+	source := &fakeTokenSource{tokens: []string{current}, err: errors.New("connection refused")}
+	token, err := instance.newRefreshingToken(source.token)
+	assert.NoError(t, err)
+	token.retryInterval = 10 * time.Millisecond
+
+	stop := token.start()
+	assert.Eventually(t, func() bool { return len(source.getFetches()) >= 3 }, time.Second, 5*time.Millisecond)
+	stop()
+
+	got, err := token.get()
+	assert.NoError(t, err)
+	assert.Equal(t, current, got)
+}
+
+func TestRefreshingToken_WithoutExpiry(t *testing.T) {
+	instance := newGoInstance()
+	source := &fakeTokenSource{tokens: []string{"opaque-token"}}
+	token, err := instance.newRefreshingToken(source.token)
+	assert.NoError(t, err)
+	instance.authToken = token
+
+	// nothing to refresh, stop returns right away
+	instance.startAuthTokenRefresh()()
+	assert.Len(t, source.getFetches(), 1)
+	_, ok := instance.context.GetAuthTokenExpiry()
+	assert.False(t, ok)
+}
+
+func Test_goInstance_tokenFileClientOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	expiry := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	assert.NoError(t, os.WriteFile(path, []byte(testToken(expiry)+"\n"), 0600))
+	instance := newGoInstance()
+	instance.context.instanceConf.authPlugin = authPluginToken
+	instance.context.instanceConf.authParams = "file://" + path
+
+	clientOpts, err := instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.NotNil(t, clientOpts.Authentication)
+	tokenExpiry, ok := instance.context.GetAuthTokenExpiry()
+	assert.True(t, ok)
+	assert.Equal(t, expiry, tokenExpiry)
+
+	instance.context.instanceConf.authParams = "file://" + filepath.Join(t.TempDir(), "missing")
+	_, err = instance.getClientOptions()
+	assert.ErrorContains(t, err, "failed to read the auth token")
+}
+
+func TestInstanceConf_AuthTokenRefreshMargin(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ClientAuthTokenRefreshMarginMs: 30000})
+	assert.Equal(t, 30*time.Second, instanceConf.authTokenRefreshMargin)
+
+	assert.PanicsWithValue(t, "clientAuthTokenRefreshMarginMs must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ClientAuthTokenRefreshMarginMs: -1})
+	})
+}
@@ -0,0 +1,177 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/rest"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+)
+
+// SchemaTypeAutoConsume is the schema type of inputs whose messages may have
+// been produced with any schema. Their payload is passed to the function as it
+// is, and FunctionContext.GetCurrentRecordGenericFields decodes it with the
+// schema the message was produced with.
+const SchemaTypeAutoConsume = "AUTO_CONSUME"
+
+// schemaVersionRegistry returns the schema a topic had at a given version, or
+// nil when there is none
+type schemaVersionRegistry interface {
+	getSchemaByVersion(topic string, version int64) (*utils.SchemaInfo, error)
+}
+
+func (r *adminSchemaRegistry) getSchemaByVersion(topic string, version int64) (*utils.SchemaInfo, error) {
+	info, err := r.schemas.GetSchemaInfoByVersion(topic, version)
+	var restErr rest.Error
+	if errors.As(err, &restErr) && restErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	return info, err
+}
+
+// hasAutoConsumeInput reports whether any input has the AUTO_CONSUME schema type
+func (ic *instanceConf) hasAutoConsumeInput() bool {
+	for _, spec := range ic.funcDetails.Source.GetInputSpecs() {
+		if spec.SchemaType == SchemaTypeAutoConsume {
+			return true
+		}
+	}
+	return false
+}
+
+// isAutoConsumeInput reports whether topic is, or matches the pattern of, an
+// input with the AUTO_CONSUME schema type
+func (ic *instanceConf) isAutoConsumeInput(topic *TopicName) bool {
+	for input, spec := range ic.funcDetails.Source.GetInputSpecs() {
+		if spec.SchemaType != SchemaTypeAutoConsume {
+			continue
+		}
+		if spec.IsRegexPattern {
+			if pattern, err := regexp.Compile(input); err == nil && pattern.MatchString(topic.NameWithoutPartition()) {
+				return true
+			}
+			continue
+		}
+		if inputTopic, err := ParseTopicName(input); err == nil &&
+			inputTopic.NameWithoutPartition() == topic.NameWithoutPartition() {
+			return true
+		}
+	}
+	return false
+}
+
+type schemaVersionKey struct {
+	topic   string
+	version int64
+}
+
+// autoConsumeSchemas decodes the messages of AUTO_CONSUME inputs with the
+// schema they were produced with, looked up once per topic and version
+type autoConsumeSchemas struct {
+	ic       *instanceConf
+	registry schemaVersionRegistry
+	mu       sync.Mutex
+	// nil for versions whose schema does not describe records
+	schemas map[schemaVersionKey]pulsar.Schema
+}
+
+func newAutoConsumeSchemas(ic *instanceConf, registry schemaVersionRegistry) *autoConsumeSchemas {
+	return &autoConsumeSchemas{
+		ic:       ic,
+		registry: registry,
+		schemas:  make(map[schemaVersionKey]pulsar.Schema),
+	}
+}
+
+// setupAutoConsume lets the function decode the messages of its AUTO_CONSUME
+// inputs, if it has any
+func (gi *goInstance) setupAutoConsume() error {
+	if !gi.context.instanceConf.hasAutoConsumeInput() {
+		return nil
+	}
+	registry, err := newAdminSchemaRegistry(gi.context.instanceConf)
+	if err != nil {
+		return withExitCode(ExitCodeConnectionError, err)
+	}
+	gi.context.genericFields = newAutoConsumeSchemas(gi.context.instanceConf, registry).decode
+	return nil
+}
+
+// decode returns the fields of msg, or nil when it is not from an AUTO_CONSUME
+// input or was not produced with an AVRO or JSON schema
+func (s *autoConsumeSchemas) decode(msg pulsar.Message) (map[string]interface{}, error) {
+	topicName, err := ParseTopicName(msg.Topic())
+	if err != nil || !s.ic.isAutoConsumeInput(topicName) {
+		return nil, nil
+	}
+	// schema versions are 8 bytes long, messages produced without a schema have none
+	schemaVersion := msg.SchemaVersion()
+	if len(schemaVersion) != 8 {
+		return nil, nil
+	}
+	key := schemaVersionKey{
+		topic:   topicName.NameWithoutPartition(),
+		version: int64(binary.BigEndian.Uint64(schemaVersion)),
+	}
+	schema, err := s.schemaOf(key)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := schema.Decode(msg.Payload(), &fields); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode message ID %s with version %d of the schema of %s: %w",
+			ErrDeserialization, messageIDStr(msg), key.version, key.topic, err)
+	}
+	return fields, nil
+}
+
+func (s *autoConsumeSchemas) schemaOf(key schemaVersionKey) (pulsar.Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if schema, ok := s.schemas[key]; ok {
+		return schema, nil
+	}
+	info, err := s.registry.getSchemaByVersion(key.topic, key.version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version %d of the schema of %s: %w", key.version, key.topic, err)
+	}
+	var schema pulsar.Schema
+	if info != nil {
+		switch info.Type {
+		case "AVRO":
+			schema, err = pulsar.NewAvroSchemaWithValidation(string(info.Schema), info.Properties)
+		case "JSON":
+			schema, err = pulsar.NewJSONSchemaWithValidation(string(info.Schema), info.Properties)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version %d of the %s schema of %s: %w",
+				key.version, info.Type, key.topic, err)
+		}
+	}
+	s.schemas[key] = schema
+	return schema, nil
+}
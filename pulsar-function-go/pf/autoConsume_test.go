@@ -0,0 +1,240 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+type fakeSchemaVersions struct {
+	schemas map[schemaVersionKey]*utils.SchemaInfo
+	err     error
+	lookups int
+}
+
+func (r *fakeSchemaVersions) getSchemaByVersion(topic string, version int64) (*utils.SchemaInfo, error) {
+	r.lookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.schemas[schemaVersionKey{topic: topic, version: version}], nil
+}
+
+func schemaVersion(version int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(version))
+}
+
+type autoConsumeExample struct {
+	ID   int    `json:"ID"`
+	Name string `json:"Name"`
+}
+
+// newAutoConsumeTestSchemas returns the schemas of an instance consuming
+// topic-01 with AUTO_CONSUME and topic-02 as it is, with AVRO, JSON and STRING
+// schema versions registered on topic-01
+func newAutoConsumeTestSchemas() (*goInstance, *fakeSchemaVersions) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Source.InputSpecs = map[string]*pb.ConsumerSpec{
+		"persistent://public/default/topic-01": {SchemaType: SchemaTypeAutoConsume},
+		"persistent://public/default/topic-02": {},
+	}
+	topic := "persistent://public/default/topic-01"
+	registry := &fakeSchemaVersions{schemas: map[schemaVersionKey]*utils.SchemaInfo{
+		{topic: topic, version: 0}: {Type: "AVRO", Schema: []byte(testAvroSchemaDefinition)},
+		{topic: topic, version: 1}: {Type: "JSON", Schema: []byte(testAvroSchemaDefinition)},
+		{topic: topic, version: 2}: {Type: "STRING"},
+	}}
+	instance.context.genericFields = newAutoConsumeSchemas(instance.context.instanceConf, registry).decode
+	return instance, registry
+}
+
+// autoConsumeMessage returns a message of topic produced with version of
+// schema, or a plain string when schema is nil
+func autoConsumeMessage(t *testing.T, topic string, version []byte, schema pulsar.Schema) *MockMessage {
+	payload := []byte("seven")
+	if schema != nil {
+		var err error
+		payload, err = schema.Encode(&autoConsumeExample{ID: 7, Name: "seven"})
+		assert.NoError(t, err)
+	}
+	return &MockMessage{topic: topic, messageID: &MockMessageID{}, payload: payload, schemaVersion: version}
+}
+
+func TestAutoConsume_GenericFields(t *testing.T) {
+	instance, _ := newAutoConsumeTestSchemas()
+	tests := []struct {
+		name    string
+		topic   string
+		version int64
+		schema  pulsar.Schema
+	}{
+		{name: "avro", topic: "persistent://public/default/topic-01", version: 0,
+			schema: pulsar.NewAvroSchema(testAvroSchemaDefinition, nil)},
+		{name: "json", topic: "persistent://public/default/topic-01", version: 1,
+			schema: pulsar.NewJSONSchema(testAvroSchemaDefinition, nil)},
+		{name: "partition", topic: "persistent://public/default/topic-01-partition-1", version: 0,
+			schema: pulsar.NewAvroSchema(testAvroSchemaDefinition, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance.context.SetCurrentRecord(autoConsumeMessage(t, tt.topic, schemaVersion(tt.version), tt.schema))
+
+			fields, err := instance.context.GetCurrentRecordGenericFields()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"ID": float64(7), "Name": "seven"}, fields)
+		})
+	}
+}
+
+func TestAutoConsume_FallsBack(t *testing.T) {
+	instance, _ := newAutoConsumeTestSchemas()
+	avro := pulsar.NewAvroSchema(testAvroSchemaDefinition, nil)
+	tests := []struct {
+		name string
+		msg  *MockMessage
+	}{
+		{name: "input without AUTO_CONSUME",
+			msg: autoConsumeMessage(t, "persistent://public/default/topic-02", schemaVersion(0), avro)},
+		{name: "message without schema",
+			msg: autoConsumeMessage(t, "persistent://public/default/topic-01", nil, avro)},
+		{name: "schema without fields",
+			msg: autoConsumeMessage(t, "persistent://public/default/topic-01", schemaVersion(2), nil)},
+		{name: "unregistered schema version",
+			msg: autoConsumeMessage(t, "persistent://public/default/topic-01", schemaVersion(3), avro)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance.context.SetCurrentRecord(tt.msg)
+
+			fields, err := instance.context.GetCurrentRecordGenericFields()
+			assert.NoError(t, err)
+			assert.Nil(t, fields)
+		})
+	}
+
+	// a context without AUTO_CONSUME inputs, or without a record, has no fields either
+	fc := NewFuncContext()
+	fields, err := fc.GetCurrentRecordGenericFields()
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+	fc.SetCurrentRecord(autoConsumeMessage(t, "persistent://public/default/topic-01", schemaVersion(0), avro))
+	fields, err = fc.GetCurrentRecordGenericFields()
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+}
+
+func TestAutoConsume_SchemaLookedUpOncePerVersion(t *testing.T) {
+	instance, registry := newAutoConsumeTestSchemas()
+	avro := pulsar.NewAvroSchema(testAvroSchemaDefinition, nil)
+	for i := 0; i < 3; i++ {
+		instance.context.SetCurrentRecord(autoConsumeMessage(t, "persistent://public/default/topic-01",
+			schemaVersion(0), avro))
+		_, err := instance.context.GetCurrentRecordGenericFields()
+		assert.NoError(t, err)
+	}
+	instance.context.SetCurrentRecord(autoConsumeMessage(t, "persistent://public/default/topic-01",
+		schemaVersion(2), nil))
+	_, err := instance.context.GetCurrentRecordGenericFields()
+	assert.NoError(t, err)
+	_, err = instance.context.GetCurrentRecordGenericFields()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, registry.lookups)
+}
+
+func TestAutoConsume_Errors(t *testing.T) {
+	instance, registry := newAutoConsumeTestSchemas()
+	instance.context.SetCurrentRecord(&MockMessage{topic: "persistent://public/default/topic-01",
+		messageID: &MockMessageID{}, payload: []byte("not avro"), schemaVersion: schemaVersion(0)})
+
+	_, err := instance.context.GetCurrentRecordGenericFields()
+	assert.ErrorIs(t, err, ErrDeserialization)
+
+	registry.err = errors.New("connection refused")
+	instance.context.SetCurrentRecord(&MockMessage{topic: "persistent://public/default/topic-01",
+		messageID: &MockMessageID{}, schemaVersion: schemaVersion(5)})
+	_, err = instance.context.GetCurrentRecordGenericFields()
+	assert.ErrorContains(t, err, "failed to get version 5 of the schema of persistent://public/default/topic-01")
+	assert.NotErrorIs(t, err, ErrDeserialization)
+}
+
+func TestAutoConsume_ProcessMessages(t *testing.T) {
+	var names []interface{}
+	handler := func(ctx context.Context, input []byte) ([]byte, error) {
+		fc, _ := FromContext(ctx)
+		fields, err := fc.GetCurrentRecordGenericFields()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, fields["Name"])
+		return nil, nil
+	}
+	instance, consumer, _ := newOutputValidatorTestInstance(handler, OnProcessErrorSkip)
+	// the generic fields are decoded with the AUTO_CONSUME inputs of the test schemas
+	schemas, _ := newAutoConsumeTestSchemas()
+	instance.context.genericFields = schemas.context.genericFields
+	channel := make(chan pulsar.ConsumerMessage, 2)
+	channel <- pulsar.ConsumerMessage{Message: autoConsumeMessage(t, "persistent://public/default/topic-01",
+		schemaVersion(1), pulsar.NewJSONSchema(testAvroSchemaDefinition, nil))}
+	channel <- pulsar.ConsumerMessage{Message: autoConsumeMessage(t, "persistent://public/default/topic-01",
+		nil, pulsar.NewJSONSchema(testAvroSchemaDefinition, nil))}
+
+	assert.NoError(t, instance.processMessages(channel))
+	assert.Equal(t, []interface{}{"seven", nil}, names)
+	assert.Len(t, consumer.acked, 2)
+}
+
+func TestInstanceConf_AutoConsume(t *testing.T) {
+	autoConsume := map[string]string{"persistent://public/default/topic-01": `{"schemaType": "AUTO_CONSUME"}`}
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: autoConsume,
+		PulsarWebServiceURL: "http://localhost:8080"})
+	assert.True(t, instanceConf.hasAutoConsumeInput())
+
+	assert.PanicsWithValue(t, "The AUTO_CONSUME schema type requires pulsarWebServiceURL to be set, "+
+		"to look up the schemas of the messages.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: autoConsume})
+	})
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: autoConsume,
+			PulsarWebServiceURL:   "http://localhost:8080",
+			InputSchemaDefinition: map[string]string{"topic-01": testAvroSchemaDefinition}})
+	}, "Should have a panic")
+}
+
+func TestDeclaredSchemas_SkipsAutoConsume(t *testing.T) {
+	instance, _ := newAutoConsumeTestSchemas()
+	instance.context.instanceConf.funcDetails.Source.InputSpecs["persistent://public/default/topic-02"].SchemaType =
+		"JSON"
+
+	declared := instance.declaredSchemas()
+	if assert.Len(t, declared, 1) {
+		assert.Equal(t, "persistent://public/default/topic-02", declared[0].topic)
+	}
+}
@@ -0,0 +1,175 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsaradmin"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/admin"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+)
+
+// defaultBacklogCacheInterval is how long a backlog size read from the broker
+// is served before it is queried again
+const defaultBacklogCacheInterval = 10 * time.Second
+
+// backlogStatsSource returns the number of messages in the backlog of a
+// subscription on a topic
+type backlogStatsSource interface {
+	getBacklog(topic, subscription string) (int64, error)
+}
+
+// adminBacklogSource reads subscription backlogs from the topic stats of the
+// broker admin API
+type adminBacklogSource struct {
+	topics admin.Topics
+}
+
+// newAdminClient returns a client of the broker admin API at pulsarWebServiceURL
+func newAdminClient(ic *instanceConf) (pulsaradmin.Client, error) {
+	return pulsaradmin.NewClient(&pulsaradmin.Config{
+		WebServiceURL:                 ic.webServiceURL,
+		TLSTrustCertsFilePath:         ic.tlsTrustCertsPath,
+		TLSAllowInsecureConnection:    ic.tlsAllowInsecure,
+		TLSEnableHostnameVerification: ic.tlsHostnameVerification,
+		AuthPlugin:                    ic.authPlugin,
+		AuthParams:                    ic.authParams,
+	})
+}
+
+func newAdminBacklogSource(ic *instanceConf) (*adminBacklogSource, error) {
+	client, err := newAdminClient(ic)
+	if err != nil {
+		return nil, err
+	}
+	return &adminBacklogSource{topics: client.Topics()}, nil
+}
+
+func (s *adminBacklogSource) getBacklog(topic, subscription string) (int64, error) {
+	topicName, err := utils.GetTopicName(topic)
+	if err != nil {
+		return 0, err
+	}
+	metadata, err := s.topics.GetMetadata(*topicName)
+	if err != nil {
+		return 0, err
+	}
+
+	var subscriptions map[string]utils.SubscriptionStats
+	if metadata.Partitions > 0 {
+		stats, err := s.topics.GetPartitionedStats(*topicName, false)
+		if err != nil {
+			return 0, err
+		}
+		subscriptions = stats.Subscriptions
+	} else {
+		stats, err := s.topics.GetStats(*topicName)
+		if err != nil {
+			return 0, err
+		}
+		subscriptions = stats.Subscriptions
+	}
+
+	stats, ok := subscriptions[subscription]
+	if !ok {
+		return 0, fmt.Errorf("subscription %s not found on topic %s", subscription, topic)
+	}
+	return stats.MsgBacklog, nil
+}
+
+// setupBacklog makes the subscription backlog available through the function
+// context when the admin API endpoint is configured
+func (gi *goInstance) setupBacklog() error {
+	ic := gi.context.instanceConf
+	if ic.webServiceURL == "" {
+		return nil
+	}
+	source, err := newAdminBacklogSource(ic)
+	if err != nil {
+		return err
+	}
+
+	var topics []string
+	for topic, spec := range ic.funcDetails.Source.InputSpecs {
+		if !spec.IsRegexPattern {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	gi.context.backlog = newBacklogCache(source, topics, gi.getSubscriptionName(), ic.backlogCacheInterval)
+	return nil
+}
+
+// backlogCache sums up the backlog of the function subscription over its input
+// topics, and caches the result for interval
+type backlogCache struct {
+	source       backlogStatsSource
+	topics       []string
+	subscription string
+	interval     time.Duration
+
+	mu      sync.Mutex
+	backlog int64
+	expiry  time.Time
+}
+
+func newBacklogCache(source backlogStatsSource, topics []string, subscription string,
+	interval time.Duration) *backlogCache {
+	if interval <= 0 {
+		interval = defaultBacklogCacheInterval
+	}
+	return &backlogCache{
+		source:       source,
+		topics:       topics,
+		subscription: subscription,
+		interval:     interval,
+	}
+}
+
+func (c *backlogCache) get() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(c.expiry) {
+		return c.backlog, nil
+	}
+
+	if len(c.topics) == 0 {
+		return 0, errors.New("no input topics to read the backlog of")
+	}
+	var backlog int64
+	for _, topic := range c.topics {
+		topicBacklog, err := c.source.getBacklog(topic, c.subscription)
+		if err != nil {
+			return 0, err
+		}
+		backlog += topicBacklog
+	}
+
+	c.backlog = backlog
+	c.expiry = now.Add(c.interval)
+	return backlog, nil
+}
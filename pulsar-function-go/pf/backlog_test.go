@@ -0,0 +1,117 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBacklogSource struct {
+	backlogs map[string]int64
+	err      error
+	calls    int
+}
+
+func (s *fakeBacklogSource) getBacklog(topic, subscription string) (int64, error) {
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.backlogs[topic+"@"+subscription], nil
+}
+
+func TestBacklogCache_SumsInputTopics(t *testing.T) {
+	source := &fakeBacklogSource{backlogs: map[string]int64{
+		"topic-01@sub": 3,
+		"topic-02@sub": 4,
+	}}
+	cache := newBacklogCache(source, []string{"topic-01", "topic-02"}, "sub", time.Minute)
+
+	backlog, err := cache.get()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), backlog)
+	assert.Equal(t, 2, source.calls)
+
+	// served from the cache until the interval elapses
+	source.backlogs["topic-01@sub"] = 10
+	backlog, err = cache.get()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), backlog)
+	assert.Equal(t, 2, source.calls)
+
+	cache.expiry = time.Now()
+	backlog, err = cache.get()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(14), backlog)
+	assert.Equal(t, 4, source.calls)
+}
+
+func TestBacklogCache_ErrorsAreNotCached(t *testing.T) {
+	source := &fakeBacklogSource{err: errors.New("admin unavailable")}
+	cache := newBacklogCache(source, []string{"topic-01"}, "sub", 0)
+	assert.Equal(t, defaultBacklogCacheInterval, cache.interval)
+
+	_, err := cache.get()
+	assert.EqualError(t, err, "admin unavailable")
+
+	source.err = nil
+	source.backlogs = map[string]int64{"topic-01@sub": 5}
+	backlog, err := cache.get()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), backlog)
+}
+
+func TestFunctionContext_GetBacklogSize(t *testing.T) {
+	fc := NewFuncContext()
+	_, err := fc.GetBacklogSize()
+	assert.Error(t, err)
+
+	fc.backlog = newBacklogCache(&fakeBacklogSource{backlogs: map[string]int64{"topic-01@sub": 2}},
+		[]string{"topic-01"}, "sub", time.Minute)
+	backlog, err := fc.GetBacklogSize()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), backlog)
+}
+
+func Test_goInstance_setupBacklog(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.webServiceURL = ""
+	assert.NoError(t, instance.setupBacklog())
+	assert.Nil(t, instance.context.backlog)
+
+	instance.context.instanceConf.webServiceURL = "http://localhost:8080"
+	instance.context.instanceConf.authPlugin = ""
+	instance.context.instanceConf.funcDetails.Source.InputSpecs = map[string]*pb.ConsumerSpec{
+		"persistent://public/default/topic-02": {},
+		"persistent://public/default/topic-01": {},
+		"persistent://public/default/topic-.*": {IsRegexPattern: true},
+	}
+	assert.NoError(t, instance.setupBacklog())
+	assert.Equal(t, []string{
+		"persistent://public/default/topic-01",
+		"persistent://public/default/topic-02",
+	}, instance.context.backlog.topics)
+	assert.Equal(t, instance.getSubscriptionName(), instance.context.backlog.subscription)
+}
@@ -0,0 +1,103 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// the client only counts the connections it opens and closes, and the lookups
+// it has made without those still pending
+const (
+	clientConnectionsOpenedMetric = "pulsar_client_connections_opened"
+	clientConnectionsClosedMetric = "pulsar_client_connections_closed"
+	clientLookupsMetric           = "pulsar_client_lookup_count"
+)
+
+const defaultClientStatsInterval = 10 * time.Second
+
+type clientConnectionStats struct {
+	activeConnections float64
+	lookups           float64
+}
+
+// clientStatsSource reads the connection stats of the Pulsar client
+type clientStatsSource func() (clientConnectionStats, error)
+
+// defaultClientStats reads the metrics the client registers with the default
+// registerer, as none is set in its options
+var defaultClientStats = gatherClientStats(prometheus.DefaultGatherer)
+
+func gatherClientStats(gatherer prometheus.Gatherer) clientStatsSource {
+	return func() (clientConnectionStats, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return clientConnectionStats{}, err
+		}
+		// the metrics are labelled by client, sum them up
+		totals := make(map[string]float64)
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				totals[family.GetName()] += metric.GetCounter().GetValue()
+			}
+		}
+		return clientConnectionStats{
+			activeConnections: totals[clientConnectionsOpenedMetric] - totals[clientConnectionsClosedMetric],
+			lookups:           totals[clientLookupsMetric],
+		}, nil
+	}
+}
+
+// startClientStatsPoller copies the client stats to the instance metrics every
+// clientStatsInterval until the returned function is called
+func (gi *goInstance) startClientStatsPoller() (stop func()) {
+	interval := gi.context.instanceConf.clientStatsInterval
+	if interval <= 0 {
+		interval = defaultClientStatsInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			gi.updateClientStats()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+func (gi *goInstance) updateClientStats() {
+	stats, err := gi.clientStats()
+	if err != nil {
+		log.Warnf("failed to read the client stats: %v", err)
+		return
+	}
+	gi.stats.setClientStats(stats)
+}
@@ -0,0 +1,89 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatherClientStats(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counters := map[string]*prometheus.CounterVec{}
+	for _, name := range []string{clientConnectionsOpenedMetric, clientConnectionsClosedMetric, clientLookupsMetric} {
+		counters[name] = prometheus.NewCounterVec(prometheus.CounterOpts{Name: name}, []string{"client"})
+		registry.MustRegister(counters[name])
+	}
+	counters[clientConnectionsOpenedMetric].WithLabelValues("go").Add(5)
+	counters[clientConnectionsOpenedMetric].WithLabelValues("other").Add(2)
+	counters[clientConnectionsClosedMetric].WithLabelValues("go").Add(3)
+	counters[clientLookupsMetric].WithLabelValues("go").Add(12)
+
+	stats, err := gatherClientStats(registry)()
+	assert.NoError(t, err)
+	assert.Equal(t, clientConnectionStats{activeConnections: 4, lookups: 12}, stats)
+
+	// nothing is counted before the client is created
+	stats, err = gatherClientStats(prometheus.NewRegistry())()
+	assert.NoError(t, err)
+	assert.Equal(t, clientConnectionStats{}, stats)
+}
+
+func TestClientStatsPoller(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.clientStatsInterval = 10 * time.Millisecond
+	var polls atomic.Int32
+	instance.clientStats = func() (clientConnectionStats, error) {
+		n := float64(polls.Add(1))
+		return clientConnectionStats{activeConnections: 3, lookups: 10 * n}, nil
+	}
+
+	stop := instance.startClientStatsPoller()
+	assert.Eventually(t, func() bool { return polls.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	stop()
+	// let a tick that raced with stop go through
+	time.Sleep(20 * time.Millisecond)
+	stopped := polls.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, polls.Load())
+
+	assert.Equal(t, float32(3), instance.getClientConnectionsActive())
+	assert.Equal(t, float32(10*stopped), instance.getTotalClientLookups())
+}
+
+func TestClientStatsPoller_KeepsLastStatsOnError(t *testing.T) {
+	instance := newGoInstance()
+	instance.clientStats = func() (clientConnectionStats, error) {
+		return clientConnectionStats{activeConnections: 2, lookups: 7}, nil
+	}
+	instance.updateClientStats()
+	instance.clientStats = func() (clientConnectionStats, error) {
+		return clientConnectionStats{}, errors.New("gather failed")
+	}
+	instance.updateClientStats()
+
+	assert.Equal(t, float32(2), instance.getClientConnectionsActive())
+	assert.Equal(t, float32(7), instance.getTotalClientLookups())
+}
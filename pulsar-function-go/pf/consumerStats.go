@@ -0,0 +1,142 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// the client has no consumer stats, the messages and bytes it has received
+// are counted in its metrics, labelled by topic once its metrics cardinality
+// is MetricsCardinalityTopic
+const (
+	clientMessagesReceivedMetric = "pulsar_client_messages_received"
+	clientBytesReceivedMetric    = "pulsar_client_bytes_received"
+)
+
+type consumerStats struct {
+	messagesReceived float64
+	bytesReceived    float64
+}
+
+// consumerStatsSource reads what the consumers of the Pulsar client have
+// received, by input topic
+type consumerStatsSource func() (map[string]consumerStats, error)
+
+var defaultConsumerStats = gatherConsumerStats(prometheus.DefaultGatherer)
+
+func gatherConsumerStats(gatherer prometheus.Gatherer) consumerStatsSource {
+	return func() (map[string]consumerStats, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return nil, err
+		}
+		stats := make(map[string]consumerStats)
+		for _, family := range families {
+			if family.GetName() != clientMessagesReceivedMetric && family.GetName() != clientBytesReceivedMetric {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				var topic string
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "topic" {
+						topic = label.GetValue()
+					}
+				}
+				// the partitions of a topic are summed up
+				topicName, err := ParseTopicName(topic)
+				if err != nil {
+					continue
+				}
+				topic = topicName.NameWithoutPartition()
+				topicStats := stats[topic]
+				if family.GetName() == clientMessagesReceivedMetric {
+					topicStats.messagesReceived += metric.GetCounter().GetValue()
+				} else {
+					topicStats.bytesReceived += metric.GetCounter().GetValue()
+				}
+				stats[topic] = topicStats
+			}
+		}
+		return stats, nil
+	}
+}
+
+// startConsumerStatsPoller copies the consumer stats to the instance metrics
+// every consumerStatsInterval until the returned function is called. It does
+// nothing when consumerStatsInterval is not set.
+func (gi *goInstance) startConsumerStatsPoller() (stop func()) {
+	interval := gi.context.instanceConf.consumerStatsInterval
+	if interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var previous map[string]consumerStats
+		previousAt := time.Now()
+		for {
+			if stats, ok := gi.updateConsumerStats(previous, time.Since(previousAt)); ok {
+				previous, previousAt = stats, time.Now()
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// updateConsumerStats sets the consumer metrics, the rates being worked out
+// from the previous stats, read elapsed ago. It returns the stats it read.
+func (gi *goInstance) updateConsumerStats(previous map[string]consumerStats,
+	elapsed time.Duration) (map[string]consumerStats, bool) {
+	stats, err := gi.consumerStats()
+	if err != nil {
+		log.Warnf("failed to read the consumer stats: %v", err)
+		return nil, false
+	}
+	for topic, topicStats := range stats {
+		var rateIn, throughputIn float64
+		if last, ok := previous[topic]; ok && elapsed > 0 {
+			rateIn = perSecond(topicStats.messagesReceived-last.messagesReceived, elapsed)
+			throughputIn = perSecond(topicStats.bytesReceived-last.bytesReceived, elapsed)
+		}
+		gi.stats.setConsumerStats(topic, topicStats, rateIn, throughputIn)
+	}
+	return stats, true
+}
+
+// perSecond returns the rate of delta over elapsed, or 0 when a counter went
+// back as it was reset
+func perSecond(delta float64, elapsed time.Duration) float64 {
+	if delta < 0 {
+		return 0
+	}
+	return delta / elapsed.Seconds()
+}
@@ -0,0 +1,161 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// getConsumerStat returns the value of the consumer metric name of topic
+func (gi *goInstance) getConsumerStat(name, topic string) float32 {
+	for _, family := range gi.getFilteredMetricFamilies(metricsPrefix + name) {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["fqfn"] == gi.context.GetTenantAndNamespaceAndName() && labels["topic"] == topic {
+				return float32(metric.GetGauge().GetValue())
+			}
+		}
+	}
+	return 0
+}
+
+func TestGatherConsumerStats(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counters := map[string]*prometheus.CounterVec{}
+	for _, name := range []string{clientMessagesReceivedMetric, clientBytesReceivedMetric} {
+		counters[name] = prometheus.NewCounterVec(prometheus.CounterOpts{Name: name}, []string{"client", "topic"})
+		registry.MustRegister(counters[name])
+	}
+	counters[clientMessagesReceivedMetric].WithLabelValues("go", "persistent://public/default/topic-01").Add(5)
+	counters[clientBytesReceivedMetric].WithLabelValues("go", "persistent://public/default/topic-01").Add(500)
+	counters[clientMessagesReceivedMetric].WithLabelValues("go",
+		"persistent://public/default/topic-02-partition-0").Add(2)
+	counters[clientMessagesReceivedMetric].WithLabelValues("go",
+		"persistent://public/default/topic-02-partition-1").Add(3)
+
+	stats, err := gatherConsumerStats(registry)()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]consumerStats{
+		"persistent://public/default/topic-01": {messagesReceived: 5, bytesReceived: 500},
+		"persistent://public/default/topic-02": {messagesReceived: 5},
+	}, stats)
+}
+
+func TestConsumerStatsPoller(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.consumerStatsInterval = 10 * time.Millisecond
+	var polls atomic.Int32
+	instance.consumerStats = func() (map[string]consumerStats, error) {
+		n := float64(polls.Add(1))
+		return map[string]consumerStats{
+			"persistent://public/default/topic-01": {messagesReceived: 10 * n, bytesReceived: 1000 * n},
+		}, nil
+	}
+
+	stop := instance.startConsumerStatsPoller()
+	assert.Eventually(t, func() bool { return polls.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	stop()
+	// let a tick that raced with stop go through
+	time.Sleep(20 * time.Millisecond)
+	stopped := polls.Load()
+
+	topic := "persistent://public/default/topic-01"
+	assert.Equal(t, float32(10*stopped), instance.getConsumerStat(ConsumerTotalReceived, topic))
+	assert.Equal(t, float32(1000*stopped), instance.getConsumerStat(ConsumerTotalReceivedBytes, topic))
+	assert.Greater(t, instance.getConsumerStat(ConsumerMsgRateIn, topic), float32(0))
+	assert.Greater(t, instance.getConsumerStat(ConsumerMsgThroughputIn, topic), float32(0))
+}
+
+func TestConsumerStatsPoller_Disabled(t *testing.T) {
+	instance := newGoInstance()
+	var polls atomic.Int32
+	instance.consumerStats = func() (map[string]consumerStats, error) {
+		polls.Add(1)
+		return nil, nil
+	}
+
+	stop := instance.startConsumerStatsPoller()
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	assert.Zero(t, polls.Load())
+}
+
+func TestUpdateConsumerStats_Rates(t *testing.T) {
+	instance := newGoInstance()
+	topic := "persistent://public/default/topic-02"
+	instance.consumerStats = func() (map[string]consumerStats, error) {
+		return map[string]consumerStats{topic: {messagesReceived: 30, bytesReceived: 3000}}, nil
+	}
+
+	// the rates are unknown on the first poll
+	previous, ok := instance.updateConsumerStats(nil, time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0), instance.getConsumerStat(ConsumerMsgRateIn, topic))
+
+	instance.consumerStats = func() (map[string]consumerStats, error) {
+		return map[string]consumerStats{topic: {messagesReceived: 50, bytesReceived: 7000}}, nil
+	}
+	_, ok = instance.updateConsumerStats(previous, 2*time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, float32(10), instance.getConsumerStat(ConsumerMsgRateIn, topic))
+	assert.Equal(t, float32(2000), instance.getConsumerStat(ConsumerMsgThroughputIn, topic))
+
+	// the last stats are kept when they cannot be read
+	instance.consumerStats = func() (map[string]consumerStats, error) {
+		return nil, errors.New("gather failed")
+	}
+	_, ok = instance.updateConsumerStats(previous, time.Second)
+	assert.False(t, ok)
+	assert.Equal(t, float32(50), instance.getConsumerStat(ConsumerTotalReceived, topic))
+}
+
+func TestConsumerStats_ClientMetricsByTopic(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authPlugin = ""
+	clientOpts, err := instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, pulsar.MetricsCardinality(0), clientOpts.MetricsCardinality)
+
+	instance.context.instanceConf.consumerStatsInterval = time.Second
+	clientOpts, err = instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, pulsar.MetricsCardinalityTopic, clientOpts.MetricsCardinality)
+}
+
+func TestInstanceConf_ConsumerStatsInterval(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ConsumerStatsIntervalMs: 5000})
+	assert.Equal(t, 5*time.Second, instanceConf.consumerStatsInterval)
+
+	assert.PanicsWithValue(t, "consumerStatsIntervalMs must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ConsumerStatsIntervalMs: -1})
+	})
+}
@@ -0,0 +1,68 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// Ways the consumers hand their messages over to the instance
+const (
+	// ConsumptionModeListener has the client push messages to the instance
+	// through the message channel of the consumers, it is the default
+	ConsumptionModeListener = "listener"
+	// ConsumptionModeReceive has the instance pull every message with an
+	// explicit Receive, the next one only once the previous one is taken for
+	// processing
+	ConsumptionModeReceive = "receive"
+)
+
+func (gi *goInstance) isReceiveMode() bool {
+	return gi.context.instanceConf.consumptionMode == ConsumptionModeReceive
+}
+
+// startReceiving pulls the messages of consumer into channel until
+// stopReceiving is called or the consumer is closed
+func (gi *goInstance) startReceiving(consumer pulsar.Consumer, channel chan<- pulsar.ConsumerMessage) {
+	if gi.receiveCtx == nil {
+		gi.receiveCtx, gi.stopReceiving = context.WithCancel(context.Background())
+	}
+	go receiveMessages(gi.receiveCtx, consumer, channel)
+}
+
+func receiveMessages(ctx context.Context, consumer pulsar.Consumer, channel chan<- pulsar.ConsumerMessage) {
+	for {
+		msg, err := consumer.Receive(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("receive message error, stopped receiving from %s: %v", consumer.Subscription(), err)
+			}
+			return
+		}
+		select {
+		case channel <- pulsar.ConsumerMessage{Consumer: consumer, Message: msg}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
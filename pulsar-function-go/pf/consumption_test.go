@@ -0,0 +1,85 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+// receivingConsumer hands out the messages of its queue through Receive
+type receivingConsumer struct {
+	MockPulsarConsumer
+	queue chan pulsar.Message
+}
+
+func (consumer *receivingConsumer) Receive(ctx context.Context) (pulsar.Message, error) {
+	select {
+	case msg := <-consumer.queue:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func Test_goInstance_consumptionModeOptions(t *testing.T) {
+	instance := newGoInstance()
+	channel := make(chan pulsar.ConsumerMessage)
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01", &pb.ConsumerSpec{}, channel)
+	assert.Equal(t, channel, consumerOpts.MessageChannel)
+
+	instance.context.instanceConf.consumptionMode = ConsumptionModeListener
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01", &pb.ConsumerSpec{}, channel)
+	assert.Equal(t, channel, consumerOpts.MessageChannel)
+
+	instance.context.instanceConf.consumptionMode = ConsumptionModeReceive
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01", &pb.ConsumerSpec{}, channel)
+	assert.Nil(t, consumerOpts.MessageChannel)
+}
+
+func Test_goInstance_receiveMode(t *testing.T) {
+	handler := &countingHandler{}
+	instance := newGoInstance()
+	instance.function = handler
+	instance.context.instanceConf.consumptionMode = ConsumptionModeReceive
+	instance.context.instanceConf.killAfterIdle = 200
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	consumer := &receivingConsumer{queue: make(chan pulsar.Message, 3)}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	for i := 0; i < 3; i++ {
+		consumer.queue <- &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+	}
+	channel := make(chan pulsar.ConsumerMessage)
+	instance.startReceiving(consumer, channel)
+
+	assert.NoError(t, instance.processMessages(channel))
+	assert.Equal(t, int32(3), handler.processed.Load())
+	assert.Len(t, consumer.acked, 3)
+
+	// the receiving goroutine stops with the instance
+	instance.close()
+	assert.ErrorIs(t, instance.receiveCtx.Err(), context.Canceled)
+	assert.True(t, consumer.closed)
+}
@@ -22,11 +22,20 @@ package pf
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 )
 
 // FunctionContext provides contextual information to the executing function.
@@ -34,12 +43,29 @@ import (
 // message, what are our operating constraints, etc can be accessed by the
 // executing function
 type FunctionContext struct {
-	instanceConf  *instanceConf
-	userConfigs   map[string]interface{}
-	logAppender   *LogAppender
-	outputMessage func(topic string) pulsar.Producer
-	userMetrics   sync.Map
-	record        pulsar.Message
+	instanceConf   *instanceConf
+	userConfigs    map[string]interface{}
+	logAppender    *LogAppender
+	secrets        SecretsProvider
+	outputMessage  func(topic string) pulsar.Producer
+	requestRestart func(reason string)
+	registerTimer  func(interval time.Duration, fn func(ctx context.Context))
+	client         func() PulsarClient
+	reconsume      func(delay time.Duration, props map[string]string) error
+	lastSequenceID func(topic string) int64
+	publishDerived func(payload []byte) error
+	publish        func(topic string, payload []byte, options ...PublishOption) error
+	newReader      func(topic string, startMessageID pulsar.MessageID) (pulsar.Reader, error)
+	reconsumed     bool
+	metrics        func() MetricsSnapshot
+	tokenExpiry    func() time.Time
+	backlog        *backlogCache
+	emitted        [][]byte
+	orderingKey    *string
+	userMetrics    sync.Map
+	record         pulsar.Message
+	genericFields  func(msg pulsar.Message) (map[string]interface{}, error)
+	startTime      time.Time
 }
 
 // NewFuncContext returns a new Function context
@@ -51,6 +77,9 @@ func NewFuncContext() *FunctionContext {
 		instanceConf: instanceConf,
 		userConfigs:  userConfigs,
 	}
+	if instanceConf.secretsDirectory != "" {
+		fc.secrets = NewFileSecretsProvider(instanceConf.secretsDirectory)
+	}
 	return fc
 }
 
@@ -78,6 +107,63 @@ func (c *FunctionContext) GetOutputTopic() string {
 	return c.instanceConf.funcDetails.GetSink().Topic
 }
 
+// GetLogTopic returns the topic the pulsar function's logs are published to,
+// or an empty string if no log topic is configured
+func (c *FunctionContext) GetLogTopic() string {
+	return c.instanceConf.funcDetails.GetLogTopic()
+}
+
+// LogToTopic publishes msg at the given level (e.g. "info", "warn") straight to
+// the log topic, regardless of the instance log level. It shares the log topic
+// producer and does nothing but warn when no log topic is configured.
+func (c *FunctionContext) LogToTopic(level, msg string) {
+	if c.logAppender == nil {
+		log.Warnf("no log topic configured, dropping log message: %s", msg)
+		return
+	}
+	logLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		log.Warnf("invalid log level %q, publishing at info level", level)
+		logLevel = logrus.InfoLevel
+	}
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	entry.Time = time.Now()
+	entry.Level = logLevel
+	entry.Message = msg
+	logStr, err := entry.String()
+	if err != nil {
+		log.Errorf("unable to format log message: %v", err)
+		return
+	}
+	c.logAppender.Append([]byte(logStr))
+}
+
+// EmitLogEvent publishes event, encoded as JSON, to the log topic. The message
+// carries the StructuredLogEventProperty so that consumers of the log topic can
+// tell it from free-text log lines. It does nothing but warn when no log topic
+// is configured.
+func (c *FunctionContext) EmitLogEvent(event interface{}) error {
+	if c.logAppender == nil {
+		log.Warnf("no log topic configured, dropping log event")
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("unable to encode log event: %v", err)
+		return err
+	}
+	c.logAppender.AppendWithProperties(payload, map[string]string{StructuredLogEventProperty: "true"})
+	return nil
+}
+
+// EmitMetricsSnapshot publishes the current processed and error counts of the
+// instance to the log topic, as a MetricsLogEvent. Setting
+// logTopicMetricsIntervalMs publishes them periodically. Like EmitLogEvent, it
+// does nothing but warn when no log topic is configured.
+func (c *FunctionContext) EmitMetricsSnapshot() error {
+	return c.EmitLogEvent(newMetricsLogEvent(c.GetMetricsSnapshot(), time.Now()))
+}
+
 // GetTenantAndNamespace returns the tenant and namespace the pulsar function
 // belongs to in the format of `<tenant>/<namespace>`
 func (c *FunctionContext) GetTenantAndNamespace() string {
@@ -115,6 +201,26 @@ func (c *FunctionContext) GetPort() int {
 	return c.instanceConf.port
 }
 
+// GetDebugPort returns the port the handlers registered with
+// RegisterDebugHandler are served on, 0 if there is none
+func (c *FunctionContext) GetDebugPort() int {
+	return c.instanceConf.debugPort
+}
+
+// GetStartTime returns the time the instance was started at
+func (c *FunctionContext) GetStartTime() time.Time {
+	return c.startTime
+}
+
+// GetUptime returns how long the instance has been running for, 0 before it
+// is started
+func (c *FunctionContext) GetUptime() time.Duration {
+	if c.startTime.IsZero() {
+		return 0
+	}
+	return time.Since(c.startTime)
+}
+
 // GetClusterName returns the name of the cluster the pulsar function is running
 // in
 func (c *FunctionContext) GetClusterName() string {
@@ -144,6 +250,25 @@ func (c *FunctionContext) GetFuncVersion() string {
 	return c.instanceConf.funcVersion
 }
 
+// GetProcessingGuarantee returns the processing guarantee the pulsar function
+// runs with, one of ATLEAST_ONCE, ATMOST_ONCE or MANUAL
+func (c *FunctionContext) GetProcessingGuarantee() string {
+	return c.instanceConf.funcDetails.ProcessingGuarantees.String()
+}
+
+// GetMaxMessageRetries returns how many times a message the pulsar function
+// fails on is retried, 0 when retries are not configured
+func (c *FunctionContext) GetMaxMessageRetries() int32 {
+	return c.instanceConf.funcDetails.RetryDetails.GetMaxMessageRetries()
+}
+
+// GetFunctionDetails returns a copy of the FunctionDetails the pulsar function
+// runs with, as resolved from its config. Changing the copy has no effect on
+// the running function.
+func (c *FunctionContext) GetFunctionDetails() *pb.FunctionDetails {
+	return proto.Clone(&c.instanceConf.funcDetails).(*pb.FunctionDetails)
+}
+
 // GetUserConfValue returns the value of a key from the pulsar function's user
 // configuration map
 func (c *FunctionContext) GetUserConfValue(key string) interface{} {
@@ -155,12 +280,251 @@ func (c *FunctionContext) GetUserConfMap() map[string]interface{} {
 	return c.userConfigs
 }
 
+// BindUserConfig sets the fields of the struct out points to from the pulsar
+// function's user configuration map, see the package level BindUserConfig
+func (c *FunctionContext) BindUserConfig(out interface{}) error {
+	return bindUserConfig(c.userConfigs, out)
+}
+
+// GetSecret returns the value of the secret secretName from the secrets
+// provider of the pulsar function
+func (c *FunctionContext) GetSecret(secretName string) (string, error) {
+	if c.secrets == nil {
+		return "", fmt.Errorf("no secrets provider configured, unable to get secret %s", secretName)
+	}
+	return c.secrets.GetSecret(secretName)
+}
+
+// GetSecretNames returns the sorted names of the secrets of the pulsar
+// function, those of its secretsMap along with those its secrets provider
+// lists, if it implements SecretNamesProvider. The values are not read.
+func (c *FunctionContext) GetSecretNames() []string {
+	names := make(map[string]bool)
+	if secretsMap := c.instanceConf.funcDetails.GetSecretsMap(); secretsMap != "" {
+		var secrets map[string]interface{}
+		if err := json.Unmarshal([]byte(secretsMap), &secrets); err != nil {
+			log.Warnf("failed to parse the secrets map: %v", err)
+		}
+		for name := range secrets {
+			names[name] = true
+		}
+	}
+	if provider, ok := c.secrets.(SecretNamesProvider); ok {
+		providerNames, err := provider.GetSecretNames()
+		if err != nil {
+			log.Warnf("failed to list the secrets of the secrets provider: %v", err)
+		}
+		for _, name := range providerNames {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// GetBacklogSize returns the number of messages left in the backlog of the
+// function subscription, summed up over the input topics. Topics patterns are
+// not included. The value is read from the broker admin API at
+// pulsarWebServiceURL and cached for backlogCacheIntervalMs.
+func (c *FunctionContext) GetBacklogSize() (int64, error) {
+	if c.backlog == nil {
+		return 0, errors.New("backlog size is not available, pulsarWebServiceURL is not configured")
+	}
+	return c.backlog.get()
+}
+
 // NewOutputMessage send message to the topic @param topicName: The name of the
-// topic for output message
+// topic for output message. When maxPendingPublishes is set, sends on the
+// returned producer block while that many messages are in flight across all
+// the producers created here.
+//
+// Each call creates a producer of its own, unlike Publish. A goroutine of the
+// function can thus keep a producer to itself, with its own batches and
+// pending queue, at the cost of a producer on the broker per call. Call it once
+// per goroutine and topic rather than per message.
 func (c *FunctionContext) NewOutputMessage(topicName string) pulsar.Producer {
 	return c.outputMessage(topicName)
 }
 
+// GetLastPublishedSequenceID returns the sequence id of the last message
+// published to the output topic, or -1 when none has been published. When a
+// topic is given, the producer created for it through NewOutputMessage is
+// looked up instead, the most recent one if there are several.
+func (c *FunctionContext) GetLastPublishedSequenceID(topic ...string) int64 {
+	if c.lastSequenceID == nil {
+		return -1
+	}
+	if len(topic) > 0 {
+		return c.lastSequenceID(topic[0])
+	}
+	return c.lastSequenceID("")
+}
+
+// PublishToDerivedTopic publishes payload to the topic picked for the current
+// record by the router registered with RegisterTopicRouter, or to the output
+// topic when no router is registered. Producers are created on first use and
+// reused for later messages to the same topic.
+func (c *FunctionContext) PublishToDerivedTopic(payload []byte) error {
+	if c.publishDerived == nil {
+		return errors.New("publishing to derived topics is not supported by this instance")
+	}
+	return c.publishDerived(payload)
+}
+
+// Publish publishes payload to topic, or to the output topic when topic is
+// empty. Producers are created on first use and reused for later messages to
+// the same topic with the same schema, so that WithSchema can publish to a
+// topic under several schemas. The goroutines of the function share these
+// producers, which are safe for concurrent use.
+func (c *FunctionContext) Publish(topic string, payload []byte, options ...PublishOption) error {
+	if c.publish == nil {
+		return errors.New("publishing is not supported by this instance")
+	}
+	return c.publish(topic, payload, options...)
+}
+
+// Emit queues an additional output message for the current input, for
+// functions producing more than one output per input. Emitted messages are
+// published to the output topic in order, ahead of the value returned by the
+// function, and the input is acked only once all of them have been published.
+func (c *FunctionContext) Emit(output []byte) {
+	c.emitted = append(c.emitted, output)
+}
+
+// SetOutputOrderingKey sets the ordering key of the messages published to the
+// output topic for the current input, emitted ones included, in place of the
+// ordering key of the input. It only applies to the current invocation.
+func (c *FunctionContext) SetOutputOrderingKey(key string) {
+	c.orderingKey = &key
+}
+
+// GetOutputOrderingKey returns the ordering key the outputs of the current
+// input are published with, the one given to SetOutputOrderingKey or otherwise
+// the ordering key of the input
+func (c *FunctionContext) GetOutputOrderingKey() string {
+	if c.orderingKey != nil {
+		return *c.orderingKey
+	}
+	if c.record == nil {
+		return ""
+	}
+	return c.record.OrderingKey()
+}
+
+// GetTraceContext returns the W3C trace context carried by the properties of
+// the current message, the zero TraceContext when it carries none
+func (c *FunctionContext) GetTraceContext() TraceContext {
+	if c.record == nil {
+		return TraceContext{}
+	}
+	return traceContextOf(c.record)
+}
+
+// InjectTraceContext adds the trace context of the current message to props,
+// for the messages the function produces itself, and returns them. A nil props
+// is allocated when there is a trace context to add.
+func (c *FunctionContext) InjectTraceContext(props map[string]string) map[string]string {
+	return c.GetTraceContext().inject(props)
+}
+
+// ReconsumeLater sends the current message to the retry letter topic, to be
+// delivered to the function again after delay. Once a message has been
+// reconsumed maxMessageRetries times it goes to the dead letter topic instead.
+// The output of the function is discarded for a reconsumed message.
+func (c *FunctionContext) ReconsumeLater(delay time.Duration) error {
+	return c.ReconsumeLaterWithProperties(delay, nil)
+}
+
+// ReconsumeLaterWithProperties reconsumes the current message as
+// ReconsumeLater does, attaching props to it. They are carried across the
+// redeliveries of the message, merged with those attached before, and read
+// back with GetReconsumeProperties.
+func (c *FunctionContext) ReconsumeLaterWithProperties(delay time.Duration, props map[string]string) error {
+	if c.reconsume == nil {
+		return errors.New("reconsuming messages is not supported by this instance")
+	}
+	return c.reconsume(delay, props)
+}
+
+// GetReconsumeProperties returns the properties attached to the current
+// message with ReconsumeLaterWithProperties when it was reconsumed, or nil
+func (c *FunctionContext) GetReconsumeProperties() map[string]string {
+	if c.record == nil {
+		return nil
+	}
+	var props map[string]string
+	for key, value := range c.record.Properties() {
+		if name, ok := strings.CutPrefix(key, ReconsumePropertyPrefix); ok {
+			if props == nil {
+				props = make(map[string]string)
+			}
+			props[name] = value
+		}
+	}
+	return props
+}
+
+// RequestRestart asks the function manager to restart this instance. The
+// current message is nacked and the instance exits with
+// RestartRequestedExitCode, which the manager interprets as "restart me". It
+// does nothing but warn outside of a running instance.
+func (c *FunctionContext) RequestRestart(reason string) {
+	if c.requestRestart == nil {
+		log.Warnf("restart requested outside of a running instance, ignoring it: %s", reason)
+		return
+	}
+	c.requestRestart(reason)
+}
+
+// RegisterTimer calls fn every interval, independently of the messages being
+// processed, until the instance shuts down. Callbacks run on a dedicated
+// goroutine and never concurrently with another timer callback, but they may
+// run concurrently with the function processing a message. Outside of a
+// running instance, the timer is not registered.
+func (c *FunctionContext) RegisterTimer(interval time.Duration, fn func(ctx context.Context)) {
+	if c.registerTimer == nil {
+		log.Warnf("timers are not supported outside of a running instance, the timer is not registered")
+		return
+	}
+	c.registerTimer(interval, fn)
+}
+
+// PulsarClient is the part of the Pulsar client of the instance a function
+// can use to create its own producers, consumers and readers. Closing the
+// client is left to the instance.
+type PulsarClient interface {
+	CreateProducer(pulsar.ProducerOptions) (pulsar.Producer, error)
+	Subscribe(pulsar.ConsumerOptions) (pulsar.Consumer, error)
+	CreateReader(pulsar.ReaderOptions) (pulsar.Reader, error)
+	TopicPartitions(topic string) ([]string, error)
+}
+
+// NewReader creates a reader of topic with the client of the instance,
+// starting right after startMessageID, or at it when startMessageIDInclusive
+// is set. The function is responsible for closing the reader.
+func (c *FunctionContext) NewReader(topic string, startMessageID pulsar.MessageID) (pulsar.Reader, error) {
+	if c.newReader == nil {
+		return nil, errors.New("creating readers is not supported by this instance")
+	}
+	return c.newReader(topic, startMessageID)
+}
+
+// GetClient returns the Pulsar client of the instance, configured with its
+// authentication and TLS settings, so that what the function creates with it
+// shares the connections of the instance. The function is responsible for
+// closing what it creates, the instance only closes its own producers and
+// consumers. It returns nil outside of a running instance.
+func (c *FunctionContext) GetClient() PulsarClient {
+	if c.client == nil {
+		return nil
+	}
+	return c.client()
+}
+
 // SetCurrentRecord sets the current message into the function context called
 // for each message before executing a handler function
 func (c *FunctionContext) SetCurrentRecord(record pulsar.Message) {
@@ -172,11 +536,60 @@ func (c *FunctionContext) GetCurrentRecord() pulsar.Message {
 	return c.record
 }
 
+// IsCurrentRecordTombstone reports whether the message being processed is a
+// tombstone, which deletes its key from a compacted topic. As in topic
+// compaction, that is a keyed message with an empty payload, a message without
+// a key is never a tombstone.
+func (c *FunctionContext) IsCurrentRecordTombstone() bool {
+	return c.record != nil && isTombstone(c.record)
+}
+
+// GetCurrentRecordGenericFields decodes the message being processed into its
+// fields, with the schema it was produced with, when it comes from an input
+// with the AUTO_CONSUME schema type. It returns nil when the message cannot be
+// decoded that way, because its input is not AUTO_CONSUME or it was not
+// produced with an AVRO or JSON schema, leaving the payload to the function.
+func (c *FunctionContext) GetCurrentRecordGenericFields() (map[string]interface{}, error) {
+	if c.record == nil || c.genericFields == nil {
+		return nil, nil
+	}
+	return c.genericFields(c.record)
+}
+
+// GetCurrentRecordSchemaVersion returns the schema version the message being
+// processed was produced with, or nil when it carries none
+func (c *FunctionContext) GetCurrentRecordSchemaVersion() []byte {
+	if c.record == nil || len(c.record.SchemaVersion()) == 0 {
+		return nil
+	}
+	return c.record.SchemaVersion()
+}
+
 // GetMetricsPort returns the port the pulsar function metrics listen on
 func (c *FunctionContext) GetMetricsPort() int {
 	return c.instanceConf.metricsPort
 }
 
+// GetAuthTokenExpiry returns when the token the instance authenticates with
+// expires, as read from its exp claim. ok is false without token
+// authentication, or when the token does not expire.
+func (c *FunctionContext) GetAuthTokenExpiry() (expiry time.Time, ok bool) {
+	if c.tokenExpiry == nil {
+		return time.Time{}, false
+	}
+	expiry = c.tokenExpiry()
+	return expiry, !expiry.IsZero()
+}
+
+// GetMetricsSnapshot returns the current values of the built-in metrics of
+// the instance, e.g. to adapt the function's behavior to its error rate
+func (c *FunctionContext) GetMetricsSnapshot() MetricsSnapshot {
+	if c.metrics == nil {
+		return MetricsSnapshot{}
+	}
+	return c.metrics()
+}
+
 // RecordMetric records an observation to the user_metric summary with the provided value
 func (c *FunctionContext) RecordMetric(metricName string, metricValue float64) {
 	v, ok := c.userMetrics.Load(metricName)
@@ -26,6 +26,8 @@ import (
 
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
 )
 
 func TestContext(t *testing.T) {
@@ -82,3 +84,204 @@ func TestFunctionContext_NewOutputMessage(t *testing.T) {
 	actualProducer := fc.NewOutputMessage(publishTopic)
 	assert.IsType(t, &MockPulsarProducer{}, actualProducer)
 }
+
+func TestFunctionContext_LogToTopic(t *testing.T) {
+	fc := NewFuncContext()
+	fc.instanceConf.funcDetails.LogTopic = "persistent://public/default/log-topic"
+	producer := &MockPulsarProducer{}
+	fc.logAppender = &LogAppender{logTopic: fc.GetLogTopic(), producer: producer}
+
+	assert.Equal(t, "persistent://public/default/log-topic", fc.GetLogTopic())
+	fc.LogToTopic("warn", "cache rebuilt")
+	fc.LogToTopic("bogus", "state flushed")
+
+	assert.Len(t, producer.messages, 2)
+	assert.Contains(t, string(producer.messages[0].Payload), "[warning] cache rebuilt")
+	assert.Contains(t, string(producer.messages[1].Payload), "[info] state flushed")
+}
+
+func TestFunctionContext_EmitLogEvent(t *testing.T) {
+	fc := NewFuncContext()
+	fc.instanceConf.funcDetails.LogTopic = "persistent://public/default/log-topic"
+	producer := &MockPulsarProducer{}
+	fc.logAppender = &LogAppender{logTopic: fc.GetLogTopic(), producer: producer}
+
+	type auditEvent struct {
+		Action string `json:"action"`
+		Count  int    `json:"count"`
+	}
+	assert.NoError(t, fc.EmitLogEvent(auditEvent{Action: "delete", Count: 2}))
+	fc.LogToTopic("info", "cache rebuilt")
+
+	assert.Len(t, producer.messages, 2)
+	assert.JSONEq(t, `{"action":"delete","count":2}`, string(producer.messages[0].Payload))
+	assert.Equal(t, map[string]string{StructuredLogEventProperty: "true"}, producer.messages[0].Properties)
+	// free-text log lines are not marked
+	assert.Empty(t, producer.messages[1].Properties)
+
+	assert.Error(t, fc.EmitLogEvent(func() {}))
+	assert.Len(t, producer.messages, 2)
+}
+
+func TestFunctionContext_EmitLogEventWithoutLogTopic(t *testing.T) {
+	fc := NewFuncContext()
+	fc.instanceConf.funcDetails.LogTopic = ""
+
+	assert.NoError(t, fc.EmitLogEvent(map[string]string{"action": "delete"}))
+}
+
+func TestFunctionContext_LogToTopicWithoutLogTopic(t *testing.T) {
+	fc := NewFuncContext()
+	fc.instanceConf.funcDetails.LogTopic = ""
+
+	assert.Equal(t, "", fc.GetLogTopic())
+	assert.NotPanics(t, func() {
+		fc.LogToTopic("info", "cache rebuilt")
+	})
+}
+
+func TestFunctionContext_RequestRestartOutsideInstance(t *testing.T) {
+	fc := NewFuncContext()
+
+	assert.NotPanics(t, func() {
+		fc.RequestRestart("external state changed")
+	})
+}
+
+func TestFunctionContext_RegisterTimerOutsideInstance(t *testing.T) {
+	fc := NewFuncContext()
+
+	assert.NotPanics(t, func() {
+		fc.RegisterTimer(time.Millisecond, func(ctx context.Context) {
+			t.Error("the timer of a bare context fired")
+		})
+	})
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestFunctionContext_IsCurrentRecordTombstone(t *testing.T) {
+	fc := NewFuncContext()
+	assert.False(t, fc.IsCurrentRecordTombstone())
+
+	fc.SetCurrentRecord(&MockMessage{key: "key"})
+	assert.True(t, fc.IsCurrentRecordTombstone())
+	fc.SetCurrentRecord(&MockMessage{payload: []byte{}})
+	assert.False(t, fc.IsCurrentRecordTombstone())
+}
+
+func TestFunctionContext_Uptime(t *testing.T) {
+	fc := NewFuncContext()
+	assert.True(t, fc.GetStartTime().IsZero())
+	assert.Zero(t, fc.GetUptime())
+
+	fc.startTime = time.Now()
+	uptime := fc.GetUptime()
+	time.Sleep(20 * time.Millisecond)
+	assert.GreaterOrEqual(t, fc.GetUptime()-uptime, 20*time.Millisecond)
+	assert.Equal(t, fc.startTime, fc.GetStartTime())
+}
+
+func TestFunctionContext_GetCurrentRecordSchemaVersion(t *testing.T) {
+	fc := NewFuncContext()
+	assert.Nil(t, fc.GetCurrentRecordSchemaVersion())
+
+	fc.SetCurrentRecord(&MockMessage{})
+	assert.Nil(t, fc.GetCurrentRecordSchemaVersion())
+	fc.SetCurrentRecord(&MockMessage{schemaVersion: []byte{}})
+	assert.Nil(t, fc.GetCurrentRecordSchemaVersion())
+	fc.SetCurrentRecord(&MockMessage{schemaVersion: []byte{0, 0, 0, 0, 0, 0, 0, 2}})
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 2}, fc.GetCurrentRecordSchemaVersion())
+}
+
+func TestFunctionContext_GetProcessingGuarantee(t *testing.T) {
+	for guarantee, expected := range map[int32]string{
+		0: "ATLEAST_ONCE",
+		1: "ATMOST_ONCE",
+		3: "MANUAL",
+	} {
+		fc := NewFuncContext()
+		fc.instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: guarantee, AutoACK: true})
+		assert.Equal(t, expected, fc.GetProcessingGuarantee())
+	}
+}
+
+func TestFunctionContext_OutputOrderingKey(t *testing.T) {
+	fc := NewFuncContext()
+	assert.Equal(t, "", fc.GetOutputOrderingKey())
+
+	fc.SetCurrentRecord(&MockMessage{orderingKey: "input-key"})
+	assert.Equal(t, "input-key", fc.GetOutputOrderingKey())
+	fc.SetOutputOrderingKey("output-key")
+	assert.Equal(t, "output-key", fc.GetOutputOrderingKey())
+	fc.SetOutputOrderingKey("")
+	assert.Equal(t, "", fc.GetOutputOrderingKey())
+}
+
+func TestFunctionContext_GetMaxMessageRetries(t *testing.T) {
+	fc := NewFuncContext()
+	fc.instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
+	assert.Equal(t, int32(0), fc.GetMaxMessageRetries())
+	fc.instanceConf.funcDetails.RetryDetails = nil
+	assert.Equal(t, int32(0), fc.GetMaxMessageRetries())
+
+	fc.instanceConf = newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		MaxMessageRetries:    5,
+		DeadLetterTopic:      "persistent://public/default/topic-01-DLQ",
+	})
+	assert.Equal(t, int32(5), fc.GetMaxMessageRetries())
+}
+
+func TestFunctionContext_GetFunctionDetails(t *testing.T) {
+	fc := NewFuncContext()
+	fc.instanceConf = newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 0,
+		AutoACK:              true,
+		Tenant:               "public",
+		NameSpace:            "default",
+		Name:                 "details",
+		SinkSpecTopic:        "persistent://public/default/output",
+		SourceTopicsList:     "persistent://public/default/input",
+		MaxMessageRetries:    3,
+		DeadLetterTopic:      "persistent://public/default/input-DLQ",
+		UserConfig:           `{"key":"value"}`,
+	})
+
+	details := fc.GetFunctionDetails()
+	assert.Equal(t, "public", details.Tenant)
+	assert.Equal(t, "default", details.Namespace)
+	assert.Equal(t, "details", details.Name)
+	assert.True(t, details.AutoAck)
+	assert.Equal(t, "persistent://public/default/output", details.Sink.Topic)
+	assert.Contains(t, details.Source.InputSpecs, "persistent://public/default/input")
+	assert.Equal(t, int32(3), details.RetryDetails.MaxMessageRetries)
+	assert.Equal(t, `{"key":"value"}`, details.UserConfig)
+
+	// the copy is the caller's, down to the nested specs
+	details.Name = "changed"
+	details.Sink.Topic = "persistent://public/default/changed"
+	details.Source.InputSpecs["persistent://public/default/input"].SchemaType = "JSON"
+	details.RetryDetails.MaxMessageRetries = 10
+	assert.Equal(t, "details", fc.GetFuncName())
+	assert.Equal(t, "persistent://public/default/output", fc.GetOutputTopic())
+	assert.Empty(t, fc.instanceConf.funcDetails.Source.InputSpecs["persistent://public/default/input"].SchemaType)
+	assert.Equal(t, int32(3), fc.GetMaxMessageRetries())
+	assert.Equal(t, "details", fc.GetFunctionDetails().Name)
+}
+
+func TestFunctionContext_GetClient(t *testing.T) {
+	assert.Nil(t, NewFuncContext().GetClient())
+
+	instance := newGoInstance()
+	// the client is only there once the instance has connected
+	assert.Nil(t, instance.context.GetClient())
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance.client = client
+
+	producer, err := instance.context.GetClient().CreateProducer(pulsar.ProducerOptions{
+		Topic: "persistent://public/default/side-output",
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, producer)
+	assert.Len(t, client.producers["persistent://public/default/side-output"], 1)
+}
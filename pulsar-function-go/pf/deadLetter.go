@@ -0,0 +1,196 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+// Properties attached to every message routed to the dead letter topic
+const (
+	DeadLetterOriginTopicProperty      = "ORIGIN_TOPIC"
+	DeadLetterOriginMessageIDProperty  = "ORIGIN_MESSAGE_ID"
+	DeadLetterSubscriptionProperty     = "ORIGIN_SUBSCRIPTION"
+	DeadLetterRetryCountProperty       = "RETRY_COUNT"
+	DeadLetterErrorProperty            = "LAST_ERROR"
+	DeadLetterFailureTimestampProperty = "FAILURE_TIMESTAMP"
+)
+
+const defaultDeadLetterMaxErrorLength = 1024
+
+// ErrOutputTooLarge is the error recorded on the inputs routed to the dead
+// letter topic because one of their outputs exceeded the max message size
+var ErrOutputTooLarge = errors.New("output too large")
+
+func (gi *goInstance) hasDeadLetterTopic(msg pulsar.Message) bool {
+	return gi.deadLetterTopicOf(msg) != ""
+}
+
+// deadLetterTopicOf returns the dead letter topic msg goes to, that of its
+// input if its spec sets one
+func (gi *goInstance) deadLetterTopicOf(msg pulsar.Message) string {
+	topicName, err := ParseTopicName(msg.Topic())
+	if err != nil {
+		return gi.context.instanceConf.funcDetails.RetryDetails.GetDeadLetterTopic()
+	}
+	return gi.context.instanceConf.deadLetterTopic(topicName)
+}
+
+// getDeadLetterProducer returns the producer of the dead letter topic. It is
+// only created on the first message routed there, so that functions that never
+// dead-letter do not hold a producer for nothing.
+func (gi *goInstance) getDeadLetterProducer() (pulsar.Producer, error) {
+	gi.deadLetterMu.Lock()
+	defer gi.deadLetterMu.Unlock()
+	if gi.deadLetterProducer != nil {
+		return gi.deadLetterProducer, nil
+	}
+	deadLetterTopic := gi.context.instanceConf.funcDetails.RetryDetails.GetDeadLetterTopic()
+	log.Debugf("Setting up dead letter producer for topic %s", deadLetterTopic)
+	producer, err := gi.getProducer(deadLetterTopic)
+	if err != nil {
+		return nil, err
+	}
+	gi.deadLetterProducer = producer
+	return producer, nil
+}
+
+// getDeadLetterProducerOf returns the producer of the dead letter topic of
+// msg. Those of the inputs with their own dead letter topic are kept along
+// with the derived producers, and are not closed as idle before release is
+// called.
+func (gi *goInstance) getDeadLetterProducerOf(msg pulsar.Message) (pulsar.Producer, func(), error) {
+	deadLetterTopic := gi.deadLetterTopicOf(msg)
+	if deadLetterTopic == gi.context.instanceConf.funcDetails.RetryDetails.GetDeadLetterTopic() {
+		producer, err := gi.getDeadLetterProducer()
+		return producer, func() {}, err
+	}
+	return gi.getDerivedProducer(deadLetterTopic)
+}
+
+// closeDeadLetterProducer closes the dead letter producer if it was created,
+// after the messages still pending on it have been persisted
+func (gi *goInstance) closeDeadLetterProducer() {
+	gi.deadLetterMu.Lock()
+	defer gi.deadLetterMu.Unlock()
+	if gi.deadLetterProducer == nil {
+		return
+	}
+	if err := gi.deadLetterProducer.Flush(); err != nil {
+		log.Errorf("failed to flush the dead letter producer: %v", err)
+	}
+	gi.deadLetterProducer.Close()
+	gi.deadLetterProducer = nil
+}
+
+// handleFailedMessage routes a message the function failed to process, after
+// retryCount in-instance retries, to the dead letter topic. The message is
+// acked once it has been persisted there.
+func (gi *goInstance) handleFailedMessage(msg pulsar.Message, processErr error, retryCount int32) {
+	atMostOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATMOST_ONCE
+	producer, release, err := gi.getDeadLetterProducerOf(msg)
+	if err != nil {
+		log.Errorf("setup dead letter producer failed, error is:%v", err)
+		if !atMostOnce {
+			gi.nackInputMessage(msg)
+		}
+		return
+	}
+	producer.SendAsync(context.Background(), gi.newDeadLetterMessage(msg, processErr, retryCount),
+		func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+			release()
+			if err != nil {
+				log.Errorf("failed to send message ID %s to the dead letter topic: %v", messageIDStr(msg), err)
+				gi.stats.incrTotalSysExceptions(err)
+				if !atMostOnce {
+					gi.nackInputMessage(msg)
+				}
+				return
+			}
+			if !atMostOnce {
+				gi.ackInputMessage(msg)
+			}
+		},
+	)
+}
+
+// deadLetterOversizedOutput routes msgInput to the dead letter topic if
+// deadLetterOversizedOutputs is set and its output failed to publish with
+// sendErr as it exceeded the max message size. It reports whether it did.
+func (gi *goInstance) deadLetterOversizedOutput(msgInput pulsar.Message, sendErr error) bool {
+	// the input is only routed when the SDK is entrusted with responding to it
+	if !gi.context.instanceConf.deadLetterOversized || !gi.context.instanceConf.funcDetails.AutoAck ||
+		!errors.Is(sendErr, pulsar.ErrMessageTooLarge) || !gi.hasDeadLetterTopic(msgInput) {
+		return false
+	}
+	log.Warnf("routing message ID %s to the dead letter topic, its output is too large: %v",
+		messageIDStr(msgInput), sendErr)
+	gi.stats.incrTotalOversizedOutputs()
+	gi.handleFailedMessage(msgInput, fmt.Errorf("%w: %v", ErrOutputTooLarge, sendErr), 0)
+	return true
+}
+
+func (gi *goInstance) newDeadLetterMessage(msg pulsar.Message, processErr error,
+	retryCount int32) *pulsar.ProducerMessage {
+	maxErrorLength := gi.context.instanceConf.deadLetterMaxErrorLength
+	if maxErrorLength <= 0 {
+		maxErrorLength = defaultDeadLetterMaxErrorLength
+	}
+
+	properties := make(map[string]string, len(msg.Properties())+6)
+	for k, v := range msg.Properties() {
+		properties[k] = v
+	}
+	properties[DeadLetterOriginTopicProperty] = msg.Topic()
+	properties[DeadLetterOriginMessageIDProperty] = messageIDStr(msg)
+	properties[DeadLetterSubscriptionProperty] = gi.getSubscriptionName()
+	properties[DeadLetterRetryCountProperty] = strconv.FormatInt(int64(retryCount), 10)
+	properties[DeadLetterErrorProperty] = truncateString(processErr.Error(), maxErrorLength)
+	properties[DeadLetterFailureTimestampProperty] = strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	return &pulsar.ProducerMessage{
+		Payload:     msg.Payload(),
+		Key:         msg.Key(),
+		OrderingKey: msg.OrderingKey(),
+		EventTime:   msg.EventTime(),
+		Properties:  properties,
+	}
+}
+
+// truncateString cuts s to at most maxLength bytes without splitting a rune
+func truncateString(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	for maxLength > 0 && !utf8.RuneStart(s[maxLength]) {
+		maxLength--
+	}
+	return s[:maxLength]
+}
@@ -0,0 +1,302 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+func newDeadLetterTestInstance(maxMessageRetries int32) (*goInstance, *MockPulsarConsumer, *MockPulsarProducer) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.context.instanceConf.funcDetails.RetryDetails = &pb.RetryDetails{
+		MaxMessageRetries: maxMessageRetries,
+		DeadLetterTopic:   "persistent://public/default/topic-01-DLQ",
+	}
+	consumer := &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	producer := &MockPulsarProducer{}
+	instance.deadLetterProducer = producer
+	return instance, consumer, producer
+}
+
+func TestDeadLetter_MessageProperties(t *testing.T) {
+	instance, _, _ := newDeadLetterTestInstance(3)
+	instance.context.instanceConf.funcDetails.Source = &pb.SourceSpec{SubscriptionName: "my-subscription"}
+	message := &MockMessage{
+		topic:      "persistent://public/default/topic-01",
+		key:        "key",
+		properties: map[string]string{"user-key": "user-value"},
+		messageID:  &MockMessageID{},
+		payload:    []byte("payload"),
+	}
+
+	deadLetterMessage := instance.newDeadLetterMessage(message, errors.New("boom"), 3)
+
+	assert.Equal(t, []byte("payload"), deadLetterMessage.Payload)
+	assert.Equal(t, "key", deadLetterMessage.Key)
+	properties := deadLetterMessage.Properties
+	assert.Equal(t, "user-value", properties["user-key"])
+	assert.Equal(t, "persistent://public/default/topic-01", properties[DeadLetterOriginTopicProperty])
+	assert.Equal(t, "0:0:0:0", properties[DeadLetterOriginMessageIDProperty])
+	assert.Equal(t, "my-subscription", properties[DeadLetterSubscriptionProperty])
+	assert.Equal(t, "3", properties[DeadLetterRetryCountProperty])
+	assert.Equal(t, "boom", properties[DeadLetterErrorProperty])
+	_, err := strconv.ParseInt(properties[DeadLetterFailureTimestampProperty], 10, 64)
+	assert.NoError(t, err)
+}
+
+func TestDeadLetter_ErrorTruncated(t *testing.T) {
+	instance, _, _ := newDeadLetterTestInstance(0)
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+
+	deadLetterMessage := instance.newDeadLetterMessage(message, errors.New(strings.Repeat("x", 2000)), 0)
+	assert.Equal(t, strings.Repeat("x", defaultDeadLetterMaxErrorLength),
+		deadLetterMessage.Properties[DeadLetterErrorProperty])
+
+	instance.context.instanceConf.deadLetterMaxErrorLength = 5
+	deadLetterMessage = instance.newDeadLetterMessage(message, errors.New("something failed"), 0)
+	assert.Equal(t, "somet", deadLetterMessage.Properties[DeadLetterErrorProperty])
+
+	// never split a multi-byte rune
+	assert.Equal(t, "ab", truncateString("abé", 3))
+}
+
+func TestDeadLetter_HandleFailedMessage(t *testing.T) {
+	instance, consumer, producer := newDeadLetterTestInstance(2)
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+
+	// route to the dead letter topic and ack
+	instance.handleFailedMessage(message, errors.New("boom"), 2)
+	assert.Len(t, producer.messages, 1)
+	assert.Equal(t, "2", producer.messages[0].Properties[DeadLetterRetryCountProperty])
+	assert.Equal(t, []pulsar.Message{message}, consumer.acked)
+	assert.Empty(t, consumer.nacked)
+
+	// send failure: redeliver
+	producer.sendErr = errors.New("send failed")
+	instance.handleFailedMessage(message, errors.New("boom"), 2)
+	assert.Equal(t, []pulsar.Message{message}, consumer.nacked)
+	assert.Len(t, consumer.acked, 1)
+}
+
+func TestDeadLetter_ProducerCreatedOnFirstUse(t *testing.T) {
+	instance, consumer, _ := newDeadLetterTestInstance(0)
+	instance.deadLetterProducer = nil
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance.client = client
+	deadLetterTopic := "persistent://public/default/topic-01-DLQ"
+
+	// a function that never fails never creates the producer
+	instance.respondResult(&MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}, nil)
+	assert.Empty(t, client.producers)
+
+	for i := 0; i < 2; i++ {
+		instance.handleFailedMessage(&MockMessage{
+			topic:     "persistent://public/default/topic-01",
+			messageID: &MockMessageID{},
+			payload:   []byte("payload"),
+		}, errors.New("boom"), 0)
+	}
+	assert.Len(t, client.producers[deadLetterTopic], 1)
+	producer := client.producers[deadLetterTopic][0]
+	assert.Len(t, producer.messages, 2)
+	// the processed message is acked as well as the dead-lettered ones
+	assert.Len(t, consumer.acked, 3)
+
+	instance.close()
+	assert.True(t, producer.closed)
+	assert.Nil(t, instance.deadLetterProducer)
+}
+
+func TestDeadLetter_NoProducerToClose(t *testing.T) {
+	instance, _, _ := newDeadLetterTestInstance(0)
+	instance.deadLetterProducer = nil
+	instance.client = &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+
+	assert.NotPanics(t, instance.close)
+}
+
+func TestDeadLetter_OversizedOutput(t *testing.T) {
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.funcDetails.RetryDetails = &pb.RetryDetails{
+		DeadLetterTopic: "persistent://public/default/topic-01-DLQ",
+	}
+	instance.context.instanceConf.deadLetterOversized = true
+	deadLetterProducer := &MockPulsarProducer{}
+	instance.deadLetterProducer = deadLetterProducer
+	producer.sendErr = pulsar.ErrMessageTooLarge
+	oversized := instance.getTotalOversizedOutputs()
+	sysExceptions := instance.getTotalSysExceptions()
+
+	assert.NoError(t, instance.processMessages(inputMessages("huge")))
+
+	if assert.Len(t, deadLetterProducer.messages, 1) {
+		deadLetterMessage := deadLetterProducer.messages[0]
+		assert.Equal(t, []byte("huge"), deadLetterMessage.Payload)
+		assert.True(t, strings.HasPrefix(deadLetterMessage.Properties[DeadLetterErrorProperty], "output too large: "))
+	}
+	assert.Len(t, consumer.acked, 1)
+	assert.Empty(t, consumer.nacked)
+	assert.Equal(t, oversized+1, instance.getTotalOversizedOutputs())
+	assert.Equal(t, sysExceptions, instance.getTotalSysExceptions())
+
+	// other send errors, or the flag unset, redeliver the input
+	producer.sendErr = pulsar.ErrProducerClosed
+	assert.NoError(t, instance.processMessages(inputMessages("small")))
+	instance.pendingSends.Wait()
+	producer.sendErr = pulsar.ErrMessageTooLarge
+	instance.context.instanceConf.deadLetterOversized = false
+	assert.NoError(t, instance.processMessages(inputMessages("huge")))
+	instance.pendingSends.Wait()
+	assert.Len(t, deadLetterProducer.messages, 1)
+	assert.Len(t, consumer.nacked, 2)
+	assert.Equal(t, oversized+1, instance.getTotalOversizedOutputs())
+}
+
+func TestInstanceConf_DeadLetterOversizedOutputs(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, DeadLetterOversizedOutputs: true,
+		DeadLetterTopic: "persistent://public/default/topic-01-DLQ"})
+	assert.True(t, instanceConf.deadLetterOversized)
+
+	assert.PanicsWithValue(t, "deadLetterOversizedOutputs requires a deadLetterTopic.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, DeadLetterOversizedOutputs: true})
+	})
+}
+
+func TestDeadLetter_PerInputTopic(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf = newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 0,
+		AutoACK:              true,
+		DeadLetterTopic:      "persistent://public/default/function-DLQ",
+		SourceInputSpecs: map[string]string{
+			"persistent://public/default/orders":   `{"deadLetterTopic": "persistent://public/default/orders-DLQ"}`,
+			"persistent://public/default/payments": `{"deadLetterTopic": "persistent://public/default/payments-DLQ"}`,
+			"persistent://public/default/refunds":  `{}`,
+		},
+	})
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance.client = client
+	functionDeadLetterProducer := &MockPulsarProducer{}
+	instance.deadLetterProducer = functionDeadLetterProducer
+	consumers := make(map[string]*MockPulsarConsumer)
+	for _, topic := range []string{"orders", "payments", "refunds"} {
+		consumers[topic] = &MockPulsarConsumer{}
+		instance.consumers["persistent://public/default/"+topic] = consumers[topic]
+	}
+
+	for _, topic := range []string{"orders-partition-1", "payments", "refunds"} {
+		msg := &MockMessage{topic: "persistent://public/default/" + topic, messageID: &MockMessageID{},
+			payload: []byte(topic)}
+		assert.True(t, instance.hasDeadLetterTopic(msg))
+		instance.handleFailedMessage(msg, errors.New("boom"), 0)
+	}
+
+	for topic, payload := range map[string]string{
+		"persistent://public/default/orders-DLQ":   "orders-partition-1",
+		"persistent://public/default/payments-DLQ": "payments",
+	} {
+		if assert.Len(t, client.producers[topic], 1) && assert.Len(t, client.producers[topic][0].messages, 1) {
+			assert.Equal(t, []byte(payload), client.producers[topic][0].messages[0].Payload)
+		}
+	}
+	// an input without its own goes to the dead letter topic of the function
+	if assert.Len(t, functionDeadLetterProducer.messages, 1) {
+		assert.Equal(t, []byte("refunds"), functionDeadLetterProducer.messages[0].Payload)
+	}
+	for _, consumer := range consumers {
+		assert.Len(t, consumer.acked, 1)
+	}
+}
+
+func TestInstanceConf_PerInputDeadLetterTopics(t *testing.T) {
+	specs := map[string]string{
+		"persistent://public/default/orders":   `{"deadLetterTopic": "persistent://public/default/orders-DLQ"}`,
+		"persistent://public/default/payments": `{"deadLetterTopic": "persistent://public/default/payments-DLQ"}`,
+	}
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: specs,
+		OnProcessError: OnProcessErrorDeadLetter})
+	assert.Equal(t, map[string]string{
+		"persistent://public/default/orders":   "persistent://public/default/orders-DLQ",
+		"persistent://public/default/payments": "persistent://public/default/payments-DLQ",
+	}, instanceConf.inputDeadLetterTopics)
+
+	// every input needs a dead letter topic to go to
+	specs["persistent://public/default/refunds"] = `{}`
+	assert.PanicsWithValue(t, "onProcessError dlt requires a deadLetterTopic.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: specs,
+			OnProcessError: OnProcessErrorDeadLetter})
+	})
+}
+
+func TestInstanceConf_DeadLetterTopicPrecedence(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3,
+		DeadLetterTopic: "persistent://public/default/function-DLQ",
+		SourceInputSpecs: map[string]string{
+			"persistent://public/default/orders-.*": `{"isRegexPattern": true,
+				"deadLetterTopic": "persistent://public/default/orders-pattern-DLQ"}`,
+			"persistent://public/default/orders-eu": `{"deadLetterTopic": "persistent://public/default/orders-eu-DLQ"}`,
+			"persistent://public/default/.*-us": `{"isRegexPattern": true,
+				"deadLetterTopic": "persistent://public/default/us-pattern-DLQ"}`,
+		},
+	})
+	tests := []struct {
+		topic           string
+		deadLetterTopic string
+	}{
+		// the input named exactly wins over the patterns matching it too
+		{topic: "persistent://public/default/orders-eu-partition-2",
+			deadLetterTopic: "persistent://public/default/orders-eu-DLQ"},
+		// both patterns match, the first of the specs in order wins
+		{topic: "persistent://public/default/orders-us", deadLetterTopic: "persistent://public/default/us-pattern-DLQ"},
+		{topic: "persistent://public/default/orders-asia",
+			deadLetterTopic: "persistent://public/default/orders-pattern-DLQ"},
+		{topic: "persistent://public/default/refunds", deadLetterTopic: "persistent://public/default/function-DLQ"},
+	}
+	for _, tt := range tests {
+		topicName, err := ParseTopicName(tt.topic)
+		assert.NoError(t, err)
+		// the same every time, whatever the order of the specs map
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, tt.deadLetterTopic, instanceConf.deadLetterTopic(topicName), tt.topic)
+		}
+	}
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: map[string]string{
+			"persistent://public/default/orders": `{"deadLetterTopic": 7}`,
+		}})
+	}, "Should have a panic")
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SourceInputSpecs: map[string]string{
+			"persistent://public/default/orders-(": `{"isRegexPattern": true, "deadLetterTopic": "orders-DLQ"}`,
+		}})
+	}, "Should have a panic")
+}
@@ -0,0 +1,108 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+var (
+	debugHandlersMu sync.Mutex
+	debugHandlers   = make(map[string]http.Handler)
+)
+
+// RegisterDebugHandler serves handler for pattern, as in http.ServeMux, on the
+// debugPort of the instance. Handlers are only served when a debugPort is
+// configured. It must be called before Start.
+func RegisterDebugHandler(pattern string, handler http.Handler) {
+	debugHandlersMu.Lock()
+	defer debugHandlersMu.Unlock()
+	debugHandlers[pattern] = handler
+}
+
+func newDebugMux() *http.ServeMux {
+	debugHandlersMu.Lock()
+	defer debugHandlersMu.Unlock()
+	patterns := make([]string, 0, len(debugHandlers))
+	for pattern := range debugHandlers {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	mux := http.NewServeMux()
+	for _, pattern := range patterns {
+		mux.Handle(pattern, debugHandlers[pattern])
+	}
+	return mux
+}
+
+// debugServer serves the handlers registered with RegisterDebugHandler
+type debugServer struct {
+	server *http.Server
+	// address the server listens on once serving
+	addr net.Addr
+}
+
+func newDebugServer(addr string) *debugServer {
+	return &debugServer{server: &http.Server{Addr: addr, Handler: newDebugMux()}}
+}
+
+// serve binds the debug port and serves the debug handlers in the background
+func (s *debugServer) serve() error {
+	lis, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start debug server: %w", err)
+	}
+	s.addr = lis.Addr()
+	log.Infof("Starting debug server on %s", s.addr)
+	go func() {
+		err := s.server.Serve(lis)
+		switch err {
+		case nil, http.ErrServerClosed:
+		default:
+			log.Errorf("debug server exited with error: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *debugServer) close() {
+	if err := s.server.Close(); err != nil {
+		log.Errorf("failed to close debug server: %v", err)
+	}
+}
+
+// startDebugServer serves the debug handlers on the debugPort, if any
+func (gi *goInstance) startDebugServer() (*debugServer, error) {
+	port := gi.context.GetDebugPort()
+	if port == 0 {
+		return nil, nil
+	}
+	server := newDebugServer(fmt.Sprintf(":%d", port))
+	if err := server.serve(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
@@ -0,0 +1,69 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func registerTestDebugHandler(t *testing.T, pattern string, handler http.HandlerFunc) {
+	RegisterDebugHandler(pattern, handler)
+	t.Cleanup(func() {
+		debugHandlersMu.Lock()
+		defer debugHandlersMu.Unlock()
+		delete(debugHandlers, pattern)
+	})
+}
+
+func TestDebugServer_ServesRegisteredHandlers(t *testing.T) {
+	registerTestDebugHandler(t, "/debug/state", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "cache: warm")
+	})
+	server := newDebugServer("127.0.0.1:0")
+	assert.NoError(t, server.serve())
+	t.Cleanup(server.close)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/state", server.addr))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "cache: warm", string(body))
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/metrics", server.addr))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestDebugServer_DisabledWithoutPort(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.debugPort = 0
+	server, err := instance.startDebugServer()
+	assert.NoError(t, err)
+	assert.Nil(t, server)
+	assert.Equal(t, 0, instance.context.GetDebugPort())
+}
@@ -0,0 +1,163 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// defaultDeduplicationMaxKeys bounds the deduplication cache when
+// deduplicationMaxKeys is not configured
+const defaultDeduplicationMaxKeys = 10000
+
+// DeduplicationKeyExtractor returns the key telling apart the messages
+// processed by the function, typically an ID read from the payload. Messages
+// with an empty key are never duplicates.
+type DeduplicationKeyExtractor func(msg pulsar.Message) string
+
+var (
+	deduplicationKeyExtractorMu         sync.Mutex
+	registeredDeduplicationKeyExtractor DeduplicationKeyExtractor
+)
+
+// RegisterDeduplicationKeyExtractor makes the function skip the messages whose
+// key is the same as that of a message processed successfully in the last
+// deduplicationWindowMs. The duplicates are acked without being processed. Only
+// the deduplicationMaxKeys most recent keys are remembered, so that the memory
+// used is bounded. It must be called before Start.
+func RegisterDeduplicationKeyExtractor(extractor DeduplicationKeyExtractor) {
+	deduplicationKeyExtractorMu.Lock()
+	defer deduplicationKeyExtractorMu.Unlock()
+	registeredDeduplicationKeyExtractor = extractor
+}
+
+func getDeduplicationKeyExtractor() DeduplicationKeyExtractor {
+	deduplicationKeyExtractorMu.Lock()
+	defer deduplicationKeyExtractorMu.Unlock()
+	return registeredDeduplicationKeyExtractor
+}
+
+type deduplicationEntry struct {
+	key    string
+	seenAt time.Time
+}
+
+// deduplicationCache remembers the keys of the processed messages, oldest
+// first, until they are older than window or maxKeys newer ones are
+// remembered
+type deduplicationCache struct {
+	extractor DeduplicationKeyExtractor
+	window    time.Duration
+	maxKeys   int
+
+	mu      sync.Mutex
+	entries *list.List
+	keys    map[string]*list.Element
+}
+
+func newDeduplicationCache(extractor DeduplicationKeyExtractor, window time.Duration,
+	maxKeys int) *deduplicationCache {
+	if maxKeys == 0 {
+		maxKeys = defaultDeduplicationMaxKeys
+	}
+	return &deduplicationCache{
+		extractor: extractor,
+		window:    window,
+		maxKeys:   maxKeys,
+		entries:   list.New(),
+		keys:      map[string]*list.Element{},
+	}
+}
+
+// setupDeduplication remembers the keys of the processed messages when a key
+// extractor is registered
+func (gi *goInstance) setupDeduplication() {
+	extractor := getDeduplicationKeyExtractor()
+	if extractor == nil {
+		return
+	}
+	gi.deduplication = newDeduplicationCache(extractor, gi.context.instanceConf.deduplicationWindow,
+		gi.context.instanceConf.deduplicationMaxKeys)
+}
+
+// isDuplicate tells whether the key of msg is that of a message processed in
+// the window
+func (c *deduplicationCache) isDuplicate(msg pulsar.Message, now time.Time) bool {
+	key := c.extractor(msg)
+	if key == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictExpired(now)
+	_, ok := c.keys[key]
+	return ok
+}
+
+// processed remembers the key of msg, evicting the oldest one beyond maxKeys
+func (c *deduplicationCache) processed(msg pulsar.Message, now time.Time) {
+	key := c.extractor(msg)
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.keys[key]; ok {
+		c.entries.Remove(element)
+	}
+	c.keys[key] = c.entries.PushBack(deduplicationEntry{key: key, seenAt: now})
+	for c.entries.Len() > c.maxKeys {
+		c.remove(c.entries.Front())
+	}
+}
+
+// evictExpired forgets the keys remembered longer ago than window, if set
+func (c *deduplicationCache) evictExpired(now time.Time) {
+	if c.window == 0 {
+		return
+	}
+	for element := c.entries.Front(); element != nil; element = c.entries.Front() {
+		if now.Sub(element.Value.(deduplicationEntry).seenAt) < c.window {
+			return
+		}
+		c.remove(element)
+	}
+}
+
+func (c *deduplicationCache) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.keys, element.Value.(deduplicationEntry).key)
+}
+
+// skipDuplicate tells whether msg is a duplicate to be skipped
+func (gi *goInstance) skipDuplicate(msg pulsar.Message) bool {
+	return gi.deduplication != nil && gi.deduplication.isDuplicate(msg, time.Now())
+}
+
+// rememberProcessed makes the later duplicates of msg skipped
+func (gi *goInstance) rememberProcessed(msg pulsar.Message) {
+	if gi.deduplication != nil {
+		gi.deduplication.processed(msg, time.Now())
+	}
+}
@@ -0,0 +1,131 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+func payloadKey(msg pulsar.Message) string {
+	return string(msg.Payload())
+}
+
+func keyedMessage(key string) pulsar.Message {
+	return &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}, payload: []byte(key)}
+}
+
+func TestDeduplicationCache_Window(t *testing.T) {
+	cache := newDeduplicationCache(payloadKey, time.Minute, 0)
+	start := time.Now()
+	assert.False(t, cache.isDuplicate(keyedMessage("a"), start))
+	cache.processed(keyedMessage("a"), start)
+
+	assert.True(t, cache.isDuplicate(keyedMessage("a"), start.Add(59*time.Second)))
+	assert.False(t, cache.isDuplicate(keyedMessage("b"), start.Add(59*time.Second)))
+	// evicted once the window has passed, the key is processed again
+	assert.False(t, cache.isDuplicate(keyedMessage("a"), start.Add(time.Minute)))
+	assert.Empty(t, cache.keys)
+	assert.Zero(t, cache.entries.Len())
+}
+
+func TestDeduplicationCache_MaxKeys(t *testing.T) {
+	cache := newDeduplicationCache(payloadKey, 0, 2)
+	now := time.Now()
+	for _, key := range []string{"a", "b", "c"} {
+		cache.processed(keyedMessage(key), now)
+	}
+
+	assert.False(t, cache.isDuplicate(keyedMessage("a"), now))
+	assert.True(t, cache.isDuplicate(keyedMessage("b"), now))
+	assert.True(t, cache.isDuplicate(keyedMessage("c"), now))
+	assert.Len(t, cache.keys, 2)
+
+	// processed again, b is the most recent key and c is evicted next
+	cache.processed(keyedMessage("b"), now)
+	cache.processed(keyedMessage("d"), now)
+	assert.False(t, cache.isDuplicate(keyedMessage("c"), now))
+	assert.True(t, cache.isDuplicate(keyedMessage("b"), now))
+
+	assert.Equal(t, defaultDeduplicationMaxKeys, newDeduplicationCache(payloadKey, 0, 0).maxKeys)
+}
+
+func TestDeduplicationCache_EmptyKey(t *testing.T) {
+	cache := newDeduplicationCache(payloadKey, time.Minute, 0)
+	cache.processed(keyedMessage(""), time.Now())
+
+	assert.False(t, cache.isDuplicate(keyedMessage(""), time.Now()))
+	assert.Empty(t, cache.keys)
+}
+
+func TestGoInstance_SkipsDuplicates(t *testing.T) {
+	failed := false
+	handler := func(_ context.Context, input []byte) ([]byte, error) {
+		if string(input) == "c" && !failed {
+			failed = true
+			return nil, errors.New("failed")
+		}
+		return input, nil
+	}
+	instance, consumer, producer := newOutputValidatorTestInstance(handler, OnProcessErrorSkip)
+	instance.deduplication = newDeduplicationCache(payloadKey, time.Minute, 0)
+	duplicates := instance.getTotalDuplicateMessages()
+
+	// a failed message is not remembered, its redelivery is processed
+	assert.NoError(t, instance.processMessages(inputMessages("a", "b", "a", "c", "c", "b")))
+
+	assert.Equal(t, []string{"a", "b", "c"}, publishedPayloads(producer))
+	assert.Len(t, consumer.acked, 6)
+	assert.Equal(t, duplicates+2, instance.getTotalDuplicateMessages())
+}
+
+func TestSetupDeduplication(t *testing.T) {
+	instance := newGoInstance()
+	instance.setupDeduplication()
+	assert.Nil(t, instance.deduplication)
+
+	RegisterDeduplicationKeyExtractor(payloadKey)
+	t.Cleanup(func() { RegisterDeduplicationKeyExtractor(nil) })
+	instance.context.instanceConf.deduplicationWindow = time.Minute
+	instance.context.instanceConf.deduplicationMaxKeys = 5
+	instance.setupDeduplication()
+	if assert.NotNil(t, instance.deduplication) {
+		assert.Equal(t, time.Minute, instance.deduplication.window)
+		assert.Equal(t, 5, instance.deduplication.maxKeys)
+	}
+}
+
+func TestInstanceConf_Deduplication(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, DeduplicationWindowMs: 60000,
+		DeduplicationMaxKeys: 100})
+	assert.Equal(t, time.Minute, instanceConf.deduplicationWindow)
+	assert.Equal(t, 100, instanceConf.deduplicationMaxKeys)
+
+	assert.PanicsWithValue(t, "deduplicationWindowMs and deduplicationMaxKeys must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, DeduplicationMaxKeys: -1})
+	})
+}
@@ -0,0 +1,228 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// TopicRouter picks the topic to publish output derived from record to, for
+// instance from one of its properties. An empty topic selects the output topic
+// of the function.
+type TopicRouter func(record pulsar.Message) string
+
+var (
+	topicRouterMu         sync.Mutex
+	registeredTopicRouter TopicRouter
+)
+
+// RegisterTopicRouter sets the router used by
+// FunctionContext.PublishToDerivedTopic. It must be called before Start.
+func RegisterTopicRouter(router TopicRouter) {
+	topicRouterMu.Lock()
+	defer topicRouterMu.Unlock()
+	registeredTopicRouter = router
+}
+
+func getTopicRouter() TopicRouter {
+	topicRouterMu.Lock()
+	defer topicRouterMu.Unlock()
+	return registeredTopicRouter
+}
+
+// publishToDerivedTopic publishes payload to the topic the registered router
+// picks for the current record, or to the output topic when there is none
+func (gi *goInstance) publishToDerivedTopic(payload []byte) error {
+	record := gi.context.GetCurrentRecord()
+	var topic string
+	if router := getTopicRouter(); router != nil && record != nil {
+		topic = router(record)
+	}
+	producer, release, err := gi.getDerivedProducer(topic)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	msg := &pulsar.ProducerMessage{Payload: payload}
+	if record != nil {
+		msg.EventTime = gi.getOutputEventTime(record)
+	}
+	_, err = producer.Send(context.Background(), msg)
+	return err
+}
+
+// PublishOption customizes the messages published with FunctionContext.Publish
+type PublishOption func(options *publishOptions)
+
+type publishOptions struct {
+	schemaType       string
+	schemaDefinition string
+}
+
+// WithSchema publishes with the AVRO or JSON schema of definition rather than
+// with the schema of the output topic, or none for other topics
+func WithSchema(schemaType, definition string) PublishOption {
+	return func(options *publishOptions) {
+		options.schemaType = schemaType
+		options.schemaDefinition = definition
+	}
+}
+
+// publish publishes payload to topic, or to the output topic when it is empty,
+// with the producer of its schema
+func (gi *goInstance) publish(topic string, payload []byte, options ...PublishOption) error {
+	var schema publishOptions
+	for _, option := range options {
+		option(&schema)
+	}
+	producer, release, err := gi.getSchemaProducer(topic, schema)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	msg := &pulsar.ProducerMessage{Payload: payload}
+	if record := gi.context.GetCurrentRecord(); record != nil {
+		msg.EventTime = gi.getOutputEventTime(record)
+	}
+	_, err = producer.Send(context.Background(), msg)
+	return err
+}
+
+// producerClock tells the time derived producers are used at
+var producerClock = time.Now
+
+// derivedProducer is the producer of a derived topic, along with when it was
+// last used and how many sends it has in progress, which keep it open
+type derivedProducer struct {
+	producer pulsar.Producer
+	lastUsed time.Time
+	sending  int
+}
+
+// derivedProducerKey tells apart the producers of a topic created with
+// different schemas
+type derivedProducerKey struct {
+	topic string
+	publishOptions
+}
+
+// getDerivedProducer returns the producer for topic, creating it on first use.
+// The producer is not closed as idle before release is called.
+func (gi *goInstance) getDerivedProducer(topic string) (producer pulsar.Producer, release func(), err error) {
+	return gi.getSchemaProducer(topic, publishOptions{})
+}
+
+// getSchemaProducer returns the producer for topic and the schema of options,
+// creating it on first use. The producer of the output topic is only reused
+// without a schema.
+func (gi *goInstance) getSchemaProducer(topic string, options publishOptions) (pulsar.Producer, func(), error) {
+	sinkTopic := gi.context.instanceConf.funcDetails.Sink.GetTopic()
+	if topic == "" {
+		topic = sinkTopic
+	}
+	if topic == "" {
+		return nil, nil, errors.New("no topic derived from the record and no output topic configured")
+	}
+	topicName, err := ParseTopicName(topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sinkTopicName, err := ParseTopicName(sinkTopic); err == nil && sinkTopicName.Name == topicName.Name &&
+		gi.producer != nil && options.schemaType == "" {
+		return gi.producer, func() {}, nil
+	}
+	producerOpts := gi.getProducerOptions(topicName.Name)
+	if options.schemaType != "" {
+		producerOpts.Schema, err = newOutputSchema(options.schemaType, options.schemaDefinition, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gi.derivedProducersMu.Lock()
+	defer gi.derivedProducersMu.Unlock()
+	key := derivedProducerKey{topic: topicName.Name, publishOptions: options}
+	derived, ok := gi.derivedProducers[key]
+	if !ok {
+		log.Debugf("Setting up producer for derived topic %s", topicName.Name)
+		producer, err := gi.createProducer(producerOpts)
+		if err != nil {
+			return nil, nil, err
+		}
+		derived = &derivedProducer{producer: producer}
+		gi.derivedProducers[key] = derived
+	}
+	derived.sending++
+	derived.lastUsed = producerClock()
+	return derived.producer, func() {
+		gi.derivedProducersMu.Lock()
+		defer gi.derivedProducersMu.Unlock()
+		derived.sending--
+		derived.lastUsed = producerClock()
+	}, nil
+}
+
+// startIdleProducerSweep closes the derived producers idle for
+// producerIdleTimeout, checking twice per timeout, until the returned function
+// is called
+func (gi *goInstance) startIdleProducerSweep() (stop func()) {
+	timeout := gi.context.instanceConf.producerIdleTimeout
+	if timeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				gi.closeIdleDerivedProducers(timeout)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// closeIdleDerivedProducers closes the derived producers that have not sent
+// for timeout, they are recreated by the next publish to their topic
+func (gi *goInstance) closeIdleDerivedProducers(timeout time.Duration) {
+	gi.derivedProducersMu.Lock()
+	defer gi.derivedProducersMu.Unlock()
+	now := producerClock()
+	for key, derived := range gi.derivedProducers {
+		if derived.sending == 0 && now.Sub(derived.lastUsed) >= timeout {
+			log.Infof("closing the producer for derived topic %s, idle for %v", key.topic, now.Sub(derived.lastUsed))
+			derived.producer.Close()
+			delete(gi.derivedProducers, key)
+		}
+	}
+}
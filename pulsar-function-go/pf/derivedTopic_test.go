@@ -0,0 +1,250 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// producingClient hands out mock producers, one per CreateProducer call
+type producingClient struct {
+	pulsar.Client
+	producers map[string][]*MockPulsarProducer
+	// the options of the producers, in the order they were created
+	options []pulsar.ProducerOptions
+}
+
+func (c *producingClient) CreateProducer(options pulsar.ProducerOptions) (pulsar.Producer, error) {
+	producer := &MockPulsarProducer{}
+	c.producers[options.Topic] = append(c.producers[options.Topic], producer)
+	c.options = append(c.options, options)
+	return producer, nil
+}
+
+func (c *producingClient) Close() {
+}
+
+func registerTestTopicRouter(t *testing.T, router TopicRouter) {
+	RegisterTopicRouter(router)
+	t.Cleanup(func() {
+		RegisterTopicRouter(nil)
+	})
+}
+
+func TestPublishToDerivedTopic_Router(t *testing.T) {
+	registerTestTopicRouter(t, func(record pulsar.Message) string {
+		return record.Properties()["region"]
+	})
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance := newGoInstance()
+	instance.client = client
+	sink := &MockPulsarProducer{}
+	instance.producer = sink
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+
+	for _, region := range []string{"eu", "us", "eu", ""} {
+		instance.context.SetCurrentRecord(&MockMessage{
+			topic:      "persistent://public/default/topic-01",
+			properties: map[string]string{"region": region},
+			messageID:  &MockMessageID{},
+		})
+		assert.NoError(t, instance.context.PublishToDerivedTopic([]byte("output-"+region)))
+	}
+
+	// producers are created once per topic
+	assert.Len(t, client.producers["persistent://public/default/eu"], 1)
+	assert.Len(t, client.producers["persistent://public/default/us"], 1)
+	assert.Len(t, client.producers["persistent://public/default/eu"][0].messages, 2)
+	assert.Equal(t, "output-us", string(client.producers["persistent://public/default/us"][0].messages[0].Payload))
+	// an empty topic falls back to the sink
+	assert.Len(t, sink.messages, 1)
+	assert.Equal(t, "output-", string(sink.messages[0].Payload))
+
+	instance.close()
+	assert.True(t, client.producers["persistent://public/default/eu"][0].closed)
+	assert.True(t, client.producers["persistent://public/default/us"][0].closed)
+}
+
+func TestPublishToDerivedTopic_NoRouter(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	sink := &MockPulsarProducer{}
+	instance.producer = sink
+	instance.context.SetCurrentRecord(&MockMessage{topic: "persistent://public/default/topic-01"})
+
+	assert.NoError(t, instance.context.PublishToDerivedTopic([]byte("output")))
+	assert.Len(t, sink.messages, 1)
+
+	instance.context.instanceConf.funcDetails.Sink.Topic = ""
+	assert.EqualError(t, instance.context.PublishToDerivedTopic([]byte("output")),
+		"no topic derived from the record and no output topic configured")
+}
+
+// useFakeProducerClock makes the derived producers tell the time from the
+// returned clock, which the test moves forward
+func useFakeProducerClock(t *testing.T) *time.Time {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	producerClock = func() time.Time { return now }
+	t.Cleanup(func() { producerClock = time.Now })
+	return &now
+}
+
+func newDerivedTopicTestInstance(t *testing.T) (*goInstance, *producingClient, *MockPulsarProducer) {
+	registerTestTopicRouter(t, func(record pulsar.Message) string {
+		return record.Properties()["region"]
+	})
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance := newGoInstance()
+	instance.client = client
+	sink := &MockPulsarProducer{}
+	instance.producer = sink
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	return instance, client, sink
+}
+
+func publishToRegion(t *testing.T, instance *goInstance, region string) {
+	instance.context.SetCurrentRecord(&MockMessage{
+		topic:      "persistent://public/default/topic-01",
+		properties: map[string]string{"region": region},
+		messageID:  &MockMessageID{},
+	})
+	assert.NoError(t, instance.context.PublishToDerivedTopic([]byte("output-"+region)))
+}
+
+func TestDerivedProducers_IdleClosedAndRecreated(t *testing.T) {
+	now := useFakeProducerClock(t)
+	instance, client, sink := newDerivedTopicTestInstance(t)
+	publishToRegion(t, instance, "eu")
+	publishToRegion(t, instance, "us")
+	publishToRegion(t, instance, "")
+
+	*now = now.Add(30 * time.Second)
+	publishToRegion(t, instance, "us")
+	*now = now.Add(30 * time.Second)
+	instance.closeIdleDerivedProducers(time.Minute)
+
+	// eu has not sent for a minute, us for half of it
+	eu := client.producers["persistent://public/default/eu"]
+	us := client.producers["persistent://public/default/us"]
+	assert.True(t, eu[0].closed)
+	assert.False(t, us[0].closed)
+	// the sink producer is never closed as idle
+	assert.False(t, sink.closed)
+
+	publishToRegion(t, instance, "eu")
+	eu = client.producers["persistent://public/default/eu"]
+	if assert.Len(t, eu, 2) {
+		assert.False(t, eu[1].closed)
+		assert.Equal(t, "output-eu", string(eu[1].messages[0].Payload))
+	}
+	assert.Len(t, client.producers["persistent://public/default/us"], 1)
+	assert.Len(t, sink.messages, 1)
+}
+
+func TestDerivedProducers_SendingNotClosed(t *testing.T) {
+	now := useFakeProducerClock(t)
+	instance, client, _ := newDerivedTopicTestInstance(t)
+
+	_, release, err := instance.getDerivedProducer("persistent://public/default/eu")
+	assert.NoError(t, err)
+	*now = now.Add(time.Hour)
+	instance.closeIdleDerivedProducers(time.Minute)
+	assert.False(t, client.producers["persistent://public/default/eu"][0].closed)
+
+	// the idle time starts over once the send is done
+	release()
+	*now = now.Add(30 * time.Second)
+	instance.closeIdleDerivedProducers(time.Minute)
+	assert.False(t, client.producers["persistent://public/default/eu"][0].closed)
+	*now = now.Add(30 * time.Second)
+	instance.closeIdleDerivedProducers(time.Minute)
+	assert.True(t, client.producers["persistent://public/default/eu"][0].closed)
+}
+
+func TestDerivedProducers_IdleSweep(t *testing.T) {
+	now := useFakeProducerClock(t)
+	instance, client, _ := newDerivedTopicTestInstance(t)
+	publishToRegion(t, instance, "eu")
+	*now = now.Add(time.Hour)
+
+	// disabled by default
+	instance.startIdleProducerSweep()()
+	instance.context.instanceConf.producerIdleTimeout = 20 * time.Millisecond
+	stop := instance.startIdleProducerSweep()
+	defer stop()
+
+	assert.Eventually(t, func() bool {
+		instance.derivedProducersMu.Lock()
+		defer instance.derivedProducersMu.Unlock()
+		return client.producers["persistent://public/default/eu"][0].closed
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPublish_ProducerPerSchema(t *testing.T) {
+	instance, client, sink := newDerivedTopicTestInstance(t)
+	avro := WithSchema("AVRO", testAvroSchemaDefinition)
+	json := WithSchema("JSON", testAvroSchemaDefinition)
+	topic := "persistent://public/default/eu"
+	assert.NoError(t, instance.context.Publish(topic, []byte("avro-1"), avro))
+	assert.NoError(t, instance.context.Publish(topic, []byte("json-1"), json))
+	assert.NoError(t, instance.context.Publish(topic, []byte("avro-2"), avro))
+	assert.NoError(t, instance.context.Publish("eu", []byte("plain")))
+
+	producers := client.producers[topic]
+	if assert.Len(t, producers, 3) {
+		assert.Equal(t, pulsar.AVRO, client.options[0].Schema.GetSchemaInfo().Type)
+		assert.Equal(t, []string{"avro-1", "avro-2"}, publishedPayloads(producers[0]))
+		assert.Equal(t, pulsar.JSON, client.options[1].Schema.GetSchemaInfo().Type)
+		assert.Equal(t, []string{"json-1"}, publishedPayloads(producers[1]))
+		assert.Nil(t, client.options[2].Schema)
+		assert.Equal(t, []string{"plain"}, publishedPayloads(producers[2]))
+	}
+
+	// the producer of the output topic only publishes without a schema
+	assert.NoError(t, instance.context.Publish("", []byte("output")))
+	assert.NoError(t, instance.context.Publish("", []byte("output-avro"), avro))
+	assert.Equal(t, []string{"output"}, publishedPayloads(sink))
+	assert.Equal(t, []string{"output-avro"},
+		publishedPayloads(client.producers["persistent://public/default/topic-02"][0]))
+}
+
+func TestPublish_Errors(t *testing.T) {
+	instance, client, _ := newDerivedTopicTestInstance(t)
+	err := instance.context.Publish("eu", []byte("output"), WithSchema("STRING", testAvroSchemaDefinition))
+	assert.ErrorContains(t, err, `sink schema type "STRING" does not support a schema definition`)
+	assert.Empty(t, client.producers)
+
+	assert.EqualError(t, NewFuncContext().Publish("eu", []byte("output")), "publishing is not supported by this instance")
+}
+
+func TestInstanceConf_ProducerIdleTimeout(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ProducerIdleTimeoutMs: 60000})
+	assert.Equal(t, time.Minute, instanceConf.producerIdleTimeout)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ProducerIdleTimeoutMs: -1})
+	}, "Should have a panic")
+}
@@ -0,0 +1,71 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// ErrDeserialization is returned, wrapped, when the input of a function cannot
+// be deserialized. A function may wrap its own decoding errors with it, these
+// are then counted in the deserialization_errors_total metric and logged as
+// sampled by deserializationErrorLogFirst and deserializationErrorLogEvery.
+var ErrDeserialization = errors.New("deserialization error")
+
+// logSampler decides which of a series of errors are logged: the first ones,
+// then one in every so many
+type logSampler struct {
+	first int64
+	every int64
+	count atomic.Int64
+}
+
+func newLogSampler(first, every int) *logSampler {
+	return &logSampler{first: int64(first), every: int64(every)}
+}
+
+// sample counts one more error and reports whether it is logged, along with
+// the number of errors counted so far
+func (s *logSampler) sample() (bool, int64) {
+	n := s.count.Add(1)
+	if s.first == 0 && s.every == 0 || n <= s.first {
+		return true, n
+	}
+	return s.every > 0 && (n-s.first-1)%s.every == 0, n
+}
+
+// reportDeserializationError counts err when it is a deserialization error of
+// msg, logging it if it is sampled. It reports whether err is one.
+func (gi *goInstance) reportDeserializationError(msg pulsar.Message, err error) bool {
+	if !errors.Is(err, ErrDeserialization) {
+		return false
+	}
+	gi.stats.incrTotalDeserializationErrors()
+	if logged, n := gi.deserializeLogs.sample(); logged {
+		log.Errorf("failed to deserialize message ID %s, %d deserialization errors so far: %v%s",
+			messageIDStr(msg), n, err, gi.payloadPreview(msg))
+	}
+	return true
+}
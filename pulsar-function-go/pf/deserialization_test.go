@@ -0,0 +1,159 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+func TestLogSampler(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  int
+		every  int
+		logged []int64
+	}{
+		{name: "unsampled", logged: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{name: "first only", first: 3, logged: []int64{1, 2, 3}},
+		{name: "every only", every: 4, logged: []int64{1, 5, 9}},
+		{name: "first then every", first: 2, every: 3, logged: []int64{1, 2, 3, 6, 9}},
+		{name: "every one", first: 2, every: 1, logged: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler := newLogSampler(tt.first, tt.every)
+			var logged []int64
+			for i := int64(1); i <= 10; i++ {
+				ok, n := sampler.sample()
+				assert.Equal(t, i, n)
+				if ok {
+					logged = append(logged, n)
+				}
+			}
+			assert.Equal(t, tt.logged, logged)
+		})
+	}
+}
+
+func TestDeserializationErrors_SampledAndCounted(t *testing.T) {
+	hook := recordLogEntries(t)
+	undecodable := func(_ context.Context, input []byte) ([]byte, error) {
+		return nil, fmt.Errorf("%w: %s is not a number", ErrDeserialization, input)
+	}
+	instance, consumer, _ := newOutputValidatorTestInstance(undecodable, OnProcessErrorSkip)
+	instance.deserializeLogs = newLogSampler(2, 3)
+	deserializationErrors := instance.getTotalDeserializationErrors()
+	userExceptions := instance.getTotalUserExceptions()
+
+	payloads := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	assert.NoError(t, instance.processMessages(inputMessages(payloads...)))
+
+	var logged []string
+	for _, entry := range hook.AllEntries() {
+		assert.NotContains(t, entry.Message, "handler message error")
+		assert.NotContains(t, entry.Message, "process function error")
+		if strings.Contains(entry.Message, "failed to deserialize") {
+			logged = append(logged, entry.Message)
+		}
+	}
+	// the first two, then one in three
+	if assert.Len(t, logged, 4) {
+		assert.Contains(t, logged[0], "1 deserialization errors so far: deserialization error: a is not a number")
+		assert.Contains(t, logged[1], "2 deserialization errors so far")
+		assert.Contains(t, logged[2], "3 deserialization errors so far")
+		assert.Contains(t, logged[3], "6 deserialization errors so far")
+	}
+	// every failure is counted nonetheless
+	assert.Equal(t, deserializationErrors+8, instance.getTotalDeserializationErrors())
+	assert.Equal(t, userExceptions+8, instance.getTotalUserExceptions())
+	assert.Len(t, consumer.acked, 8)
+}
+
+func TestDeserializationErrors_CountedOnEachAttempt(t *testing.T) {
+	recordRetrySleeps(t)
+	hook := recordLogEntries(t)
+	undecodable := func(_ context.Context, input []byte) ([]byte, error) {
+		return nil, ErrDeserialization
+	}
+	instance, _, _ := newOutputValidatorTestInstance(undecodable, OnProcessErrorSkip)
+	instance.context.instanceConf.funcDetails.RetryDetails.MaxMessageRetries = 2
+	instance.deserializeLogs = newLogSampler(1, 0)
+	deserializationErrors := instance.getTotalDeserializationErrors()
+
+	assert.NoError(t, instance.processMessages(inputMessages("a")))
+
+	assert.Equal(t, deserializationErrors+3, instance.getTotalDeserializationErrors())
+	var logged int
+	for _, entry := range hook.AllEntries() {
+		assert.NotContains(t, entry.Message, "retrying")
+		if strings.Contains(entry.Message, "failed to deserialize") {
+			logged++
+		}
+	}
+	assert.Equal(t, 1, logged)
+}
+
+func TestDeserializationErrors_OtherErrorsAreLogged(t *testing.T) {
+	hook := recordLogEntries(t)
+	failing := func(_ context.Context, input []byte) ([]byte, error) {
+		return nil, errors.New("downstream unavailable")
+	}
+	instance, _, _ := newOutputValidatorTestInstance(failing, OnProcessErrorSkip)
+	instance.deserializeLogs = newLogSampler(0, 100)
+	deserializationErrors := instance.getTotalDeserializationErrors()
+
+	assert.NoError(t, instance.processMessages(inputMessages("a", "b")))
+
+	var logged int
+	for _, entry := range hook.AllEntries() {
+		if strings.Contains(entry.Message, "handler message error") {
+			logged++
+		}
+	}
+	assert.Equal(t, 2, logged)
+	assert.Equal(t, deserializationErrors, instance.getTotalDeserializationErrors())
+}
+
+func TestProcessTyped_DeserializationError(t *testing.T) {
+	handler := ProcessTyped(func(_ context.Context, n int) (int, error) {
+		return n, nil
+	})
+	_, err := handler(context.Background(), []byte("not a number"))
+	assert.ErrorIs(t, err, ErrDeserialization)
+}
+
+func TestInstanceConf_DeserializationErrorLogSampling(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3,
+		DeserializationErrorLogFirst: 10, DeserializationErrorLogEvery: 100})
+	assert.Equal(t, 10, instanceConf.deserializationLogFirst)
+	assert.Equal(t, 100, instanceConf.deserializationLogEvery)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, DeserializationErrorLogEvery: -1})
+	}, "Should have a panic")
+}
@@ -0,0 +1,99 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// Exit codes of the instance, telling the function manager why it stopped:
+//
+//	0  the instance stopped on its own, after being idle for killAfterIdleMs
+//	1  ExitCodeFatalProcessError, the function failed in a way it cannot recover from
+//	3  RestartRequestedExitCode, the function asked to be restarted
+//	4  ExitCodeConfigError, the instance configuration is invalid
+//	5  ExitCodeConnectionError, the instance could not connect to the cluster
+//
+// A restart does not help with an invalid configuration, unlike with the other
+// failures. Code 2 is left out as it is the one of an unrecovered panic.
+const (
+	ExitCodeFatalProcessError = 1
+	ExitCodeConfigError       = 4
+	ExitCodeConnectionError   = 5
+)
+
+// exitCodeError tags an error with the exit code the instance stops with
+type exitCodeError struct {
+	err  error
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{err: err, code: code}
+}
+
+// exitCodeOf returns the exit code for err, a fatal process error unless it
+// was tagged otherwise
+func exitCodeOf(err error) int {
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return ExitCodeFatalProcessError
+}
+
+// newConfiguredInstance creates an instance with newInstance, the invalid
+// configurations it panics on are returned as config errors
+func newConfiguredInstance(newInstance func() *goInstance) (gi *goInstance, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = withExitCode(ExitCodeConfigError, fmt.Errorf("invalid instance configuration: %v", r))
+		}
+	}()
+	return newInstance(), nil
+}
+
+// runInstance runs function on an instance created by newInstance until it
+// stops, and returns the code the process should exit with
+func runInstance(newInstance func() *goInstance, function function) int {
+	gi, err := newConfiguredInstance(newInstance)
+	if err == nil {
+		err = gi.startFunction(function)
+	}
+	if err != nil {
+		log.Errorf("start function failed: %v", err)
+		return exitCodeOf(err)
+	}
+	return 0
+}
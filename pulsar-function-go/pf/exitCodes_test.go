@@ -0,0 +1,98 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCodeOf(t *testing.T) {
+	failure := errors.New("failure")
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"untagged", failure, ExitCodeFatalProcessError},
+		{"config error", withExitCode(ExitCodeConfigError, failure), ExitCodeConfigError},
+		{"connection error", withExitCode(ExitCodeConnectionError, failure), ExitCodeConnectionError},
+		{"wrapped", fmt.Errorf("setup: %w", withExitCode(ExitCodeConnectionError, failure)), ExitCodeConnectionError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, exitCodeOf(tt.err))
+			assert.ErrorIs(t, tt.err, failure)
+		})
+	}
+	assert.Nil(t, withExitCode(ExitCodeConfigError, nil))
+}
+
+func TestRunInstance_InvalidConfig(t *testing.T) {
+	// as newInstanceConfWithConf does
+	code := runInstance(func() *goInstance {
+		panic("Invalid onProcessError ignore, must be one of retry, skip, dlt or fail.")
+	}, &countingHandler{})
+	assert.Equal(t, ExitCodeConfigError, code)
+}
+
+func TestRunInstance_UnknownUserConfigKeys(t *testing.T) {
+	declareTestUserConfigKeys(t, "batchSize")
+	code := runInstance(func() *goInstance {
+		instance := newGoInstance()
+		instance.context.userConfigs = map[string]interface{}{"bachSize": 5}
+		instance.context.instanceConf.failOnUnknownUserConfigKeys = true
+		return instance
+	}, &countingHandler{})
+	assert.Equal(t, ExitCodeConfigError, code)
+}
+
+func TestRunInstance_ConnectionError(t *testing.T) {
+	code := runInstance(func() *goInstance {
+		instance := newGoInstance()
+		instance.context.instanceConf.port = 0
+		instance.context.instanceConf.metricsPort = 0
+		// the client refuses to be created without a service URL
+		instance.context.instanceConf.pulsarServiceURL = ""
+		return instance
+	}, &countingHandler{})
+	assert.Equal(t, ExitCodeConnectionError, code)
+}
+
+func TestExitCodeOf_ProcessError(t *testing.T) {
+	recordRetrySleeps(t)
+	instance, _, _ := newDeadLetterTestInstance(0)
+	instance.context.instanceConf.onProcessError = OnProcessErrorFail
+	instance.function = &failingHandler{failures: 1}
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+		topic:     "persistent://public/default/topic-01",
+		messageID: &MockMessageID{},
+	}}
+
+	// the error startFunction returns when processing stops on a failure
+	err := instance.processMessages(channel)
+	assert.Error(t, err)
+	assert.Equal(t, ExitCodeFatalProcessError, exitCodeOf(err))
+}
@@ -31,6 +31,7 @@ package pf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 
@@ -46,7 +47,10 @@ type pulsarFunction func(ctx context.Context, input []byte) ([]byte, error)
 func (function pulsarFunction) process(ctx context.Context, input []byte) ([]byte, error) {
 	output, err := function(ctx, input)
 	if err != nil {
-		log.Errorf("process function error:[%s]\n", err.Error())
+		// deserialization errors are logged as sampled by the instance
+		if !errors.Is(err, ErrDeserialization) {
+			log.Errorf("process function error:[%s]\n", err.Error())
+		}
 		return nil, err
 	}
 
@@ -165,13 +169,14 @@ func newFunction(inputFunc interface{}) function {
 //
 // Where "input" and "output" are types compatible with the "encoding/json" standard library.
 // See https://golang.org/pkg/encoding/json/#Unmarshal for how deserialization behaves
+//
+// Messages from topics registered with RegisterTopicHandler are routed to their
+// own handler instead. Functions without input topics register a generator
+// with RegisterGenerator and pass nil here.
 func Start(funcName interface{}) {
-	function := newFunction(funcName)
-	goInstance := newGoInstance()
-	err := goInstance.startFunction(function)
-	if err != nil {
-		log.Fatal(err)
-		panic("start function failed, please check.")
+	function := newTopicDispatcher(newFunction(funcName))
+	if code := runInstance(newGoInstance, function); code != 0 {
+		osExit(code)
 	}
 }
 
@@ -186,3 +191,19 @@ func GetUserConfMap() map[string]interface{} {
 func GetUserConfValue(key string) interface{} {
 	return NewFuncContext().userConfigs[key]
 }
+
+// BindUserConfig sets the fields of the struct out points to from the user
+// config, before initializing the pulsar function. Fields are bound to the key
+// of their tag, the field name when the tag has none:
+//
+//	BatchSize int           `pulsar:"batchSize,default=100"`
+//	Endpoint  string        `pulsar:"endpoint,required"`
+//	Timeout   time.Duration `pulsar:"timeout,default=30s"`
+//
+// A default, which cannot contain a comma, applies when the key is missing.
+// Strings are parsed into numbers, booleans and durations, slices, maps and
+// structs are decoded as JSON. The errors of every missing required key and
+// mismatching value are returned together.
+func BindUserConfig(out interface{}) error {
+	return NewFuncContext().BindUserConfig(out)
+}
@@ -0,0 +1,165 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// defaultGenerateInterval is how often the generator is called when
+// generateIntervalMs is not set
+const defaultGenerateInterval = time.Second
+
+// Generator produces a message for the output topic of a function without
+// input topics, every generateIntervalMs. A []byte value is published as is,
+// other values are encoded with the outputSchemaDefinition of the sink if
+// there is one, or as JSON otherwise, and a nil value publishes nothing.
+// Messages can also be emitted through the function context.
+type Generator func(ctx context.Context) (interface{}, error)
+
+var (
+	generatorMu         sync.Mutex
+	registeredGenerator Generator
+)
+
+// RegisterGenerator sets the generator of a function without input topics, the
+// function given to Start is then never called and can be nil. It must be
+// called before Start.
+func RegisterGenerator(generator Generator) {
+	generatorMu.Lock()
+	defer generatorMu.Unlock()
+	registeredGenerator = generator
+}
+
+func getGenerator() Generator {
+	generatorMu.Lock()
+	defer generatorMu.Unlock()
+	return registeredGenerator
+}
+
+// checkSource returns a config error unless the function either consumes from
+// input topics or has a generator publishing to an output topic
+func (gi *goInstance) checkSource(generator Generator) error {
+	funcDetails := &gi.context.instanceConf.funcDetails
+	hasInputs := len(funcDetails.Source.GetInputSpecs()) > 0
+	var err error
+	switch {
+	case generator == nil && !hasInputs:
+		err = errors.New("no input topics are configured and no generator is registered with RegisterGenerator")
+	case generator != nil && hasInputs:
+		err = errors.New("a generator is registered with RegisterGenerator, input topics cannot be configured")
+	case generator != nil && funcDetails.Sink.GetTopic() == "":
+		err = errors.New("a generator is registered with RegisterGenerator, but no output topic is configured")
+	default:
+		return nil
+	}
+	return withExitCode(ExitCodeConfigError, err)
+}
+
+// runGenerator calls generator every generateIntervalMs until the instance
+// is stopped
+func (gi *goInstance) runGenerator(generator Generator) error {
+	interval := gi.context.instanceConf.generateInterval
+	if interval <= 0 {
+		interval = defaultGenerateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	return gi.generateMessages(generator, ticker.C)
+}
+
+// generateMessages calls generator on each tick, except while paused, until
+// ticks is closed
+func (gi *goInstance) generateMessages(generator Generator, ticks <-chan time.Time) error {
+	// the definition has already been validated when loading the instance config
+	outputSchema, err := newTypedOutputSchema(gi.context)
+	if err != nil {
+		return err
+	}
+	for range ticks {
+		if gi.isPaused() {
+			continue
+		}
+		gi.generateMessage(generator, outputSchema)
+	}
+	return nil
+}
+
+// generateMessage publishes the messages of one call to generator. A failing
+// generator is a user exception, a failing publish a system one, and neither
+// stops the instance as there is no input to redeliver.
+func (gi *goInstance) generateMessage(generator Generator, outputSchema pulsar.Schema) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gi.context.SetCurrentRecord(nil)
+	gi.context.emitted = nil
+	gi.context.orderingKey = nil
+	gi.stats.setLastInvocation()
+	gi.stats.processTimeStart()
+
+	value, err := generator(NewContext(ctx, gi.context))
+	var output []byte
+	if err == nil && value != nil {
+		if payload, ok := value.([]byte); ok {
+			output = payload
+		} else {
+			output, err = encodeTypedOutput(outputSchema, value)
+		}
+	}
+	if err == nil {
+		err = gi.validateOutputs(output)
+	}
+	outputs := gi.context.emitted
+	gi.context.emitted = nil
+	if err != nil {
+		log.Errorf("generator error:%v", err)
+		gi.stats.incrTotalUserExceptions(err)
+		return
+	}
+	gi.stats.processTimeEnd(TraceContext{})
+	if output != nil {
+		outputs = append(outputs, output)
+	}
+
+	var eventTime time.Time
+	if gi.context.instanceConf.outputEventTimeStrategy == OutputEventTimeProcessing {
+		eventTime = time.Now()
+	}
+	for _, payload := range outputs {
+		msg := &pulsar.ProducerMessage{Payload: payload, EventTime: eventTime}
+		if gi.context.orderingKey != nil {
+			msg.OrderingKey = *gi.context.orderingKey
+		}
+		if _, err := gi.producer.Send(ctx, msg); err != nil {
+			log.Errorf("failed to publish generated message: %v", err)
+			gi.stats.incrTotalSysExceptions(err)
+			return
+		}
+	}
+	gi.stats.incrTotalProcessedSuccessfully()
+}
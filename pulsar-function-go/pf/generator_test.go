@@ -0,0 +1,210 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+func newGeneratorTestInstance() (*goInstance, *MockPulsarProducer) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Source = &pb.SourceSpec{}
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/output"
+	producer := &MockPulsarProducer{}
+	instance.producer = producer
+	return instance, producer
+}
+
+// timerTicks forwards n ticks of a ticker firing every interval, then closes
+func timerTicks(n int, interval time.Duration) <-chan time.Time {
+	ticks := make(chan time.Time)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for i := 0; i < n; i++ {
+			ticks <- <-ticker.C
+		}
+		close(ticks)
+	}()
+	return ticks
+}
+
+func TestGenerator_TimerDriven(t *testing.T) {
+	instance, producer := newGeneratorTestInstance()
+	type reading struct {
+		Seq int `json:"seq"`
+	}
+	seq := 0
+	generator := func(ctx context.Context) (interface{}, error) {
+		seq++
+		return reading{Seq: seq}, nil
+	}
+	processed := instance.getTotalProcessedSuccessfully()
+
+	assert.NoError(t, instance.generateMessages(generator, timerTicks(3, 10*time.Millisecond)))
+
+	if assert.Len(t, producer.messages, 3) {
+		assert.Equal(t, `{"seq":1}`, string(producer.messages[0].Payload))
+		assert.Equal(t, `{"seq":2}`, string(producer.messages[1].Payload))
+		assert.Equal(t, `{"seq":3}`, string(producer.messages[2].Payload))
+	}
+	assert.Equal(t, processed+3, instance.getTotalProcessedSuccessfully())
+}
+
+func TestGenerator_Outputs(t *testing.T) {
+	instance, producer := newGeneratorTestInstance()
+	values := []interface{}{[]byte("raw"), nil, "text"}
+	calls := 0
+	generator := func(ctx context.Context) (interface{}, error) {
+		value := values[calls]
+		calls++
+		if calls == 3 {
+			fc, _ := FromContext(ctx)
+			fc.Emit([]byte("emitted"))
+			fc.SetOutputOrderingKey("key")
+		}
+		return value, nil
+	}
+	ticks := make(chan time.Time, 3)
+	for i := 0; i < 3; i++ {
+		ticks <- time.Now()
+	}
+	close(ticks)
+
+	assert.NoError(t, instance.generateMessages(generator, ticks))
+
+	// a nil value publishes nothing, emitted messages go out first
+	var payloads, orderingKeys []string
+	for _, produced := range producer.messages {
+		payloads = append(payloads, string(produced.Payload))
+		orderingKeys = append(orderingKeys, produced.OrderingKey)
+	}
+	assert.Equal(t, []string{"raw", "emitted", `"text"`}, payloads)
+	assert.Equal(t, []string{"", "key", "key"}, orderingKeys)
+}
+
+func TestGenerator_Errors(t *testing.T) {
+	instance, producer := newGeneratorTestInstance()
+	calls := 0
+	generator := func(ctx context.Context) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("sensor unavailable")
+		}
+		return []byte("reading"), nil
+	}
+	userExceptions, sysExceptions := instance.getTotalUserExceptions(), instance.getTotalSysExceptions()
+	ticks := make(chan time.Time, 3)
+	for i := 0; i < 3; i++ {
+		ticks <- time.Now()
+	}
+	close(ticks)
+	producer.sendErr = errors.New("send failed")
+	producer.sendErrAt = 1
+
+	// neither error stops the generator
+	assert.NoError(t, instance.generateMessages(generator, ticks))
+	assert.Equal(t, 3, calls)
+	assert.Len(t, producer.messages, 2)
+	assert.Equal(t, int64(1), producer.published)
+	assert.Equal(t, userExceptions+1, instance.getTotalUserExceptions())
+	assert.Equal(t, sysExceptions+1, instance.getTotalSysExceptions())
+}
+
+func TestGenerator_Paused(t *testing.T) {
+	instance, producer := newGeneratorTestInstance()
+	calls := 0
+	generator := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return []byte("reading"), nil
+	}
+	instance.pause()
+
+	assert.NoError(t, instance.generateMessages(generator, timerTicks(2, 10*time.Millisecond)))
+	assert.Zero(t, calls)
+	assert.Empty(t, producer.messages)
+}
+
+func TestCheckSource(t *testing.T) {
+	generator := func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	}
+	tests := []struct {
+		name      string
+		generator Generator
+		inputs    bool
+		sink      bool
+		err       string
+	}{
+		{name: "inputs", inputs: true},
+		{name: "generator", generator: generator, sink: true},
+		{name: "neither", sink: true,
+			err: "no input topics are configured and no generator is registered with RegisterGenerator"},
+		{name: "both", generator: generator, inputs: true, sink: true,
+			err: "a generator is registered with RegisterGenerator, input topics cannot be configured"},
+		{name: "generator without output topic", generator: generator,
+			err: "a generator is registered with RegisterGenerator, but no output topic is configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newGoInstance()
+			if !tt.inputs {
+				instance.context.instanceConf.funcDetails.Source = &pb.SourceSpec{}
+			}
+			if !tt.sink {
+				instance.context.instanceConf.funcDetails.Sink.Topic = ""
+			}
+
+			err := instance.checkSource(tt.generator)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+				assert.Equal(t, ExitCodeConfigError, exitCodeOf(err))
+			}
+		})
+	}
+}
+
+func TestRegisterGenerator(t *testing.T) {
+	assert.Nil(t, getGenerator())
+	RegisterGenerator(func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	t.Cleanup(func() { RegisterGenerator(nil) })
+	assert.NotNil(t, getGenerator())
+}
+
+func TestInstanceConf_GenerateInterval(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, GenerateIntervalMs: 250})
+	assert.Equal(t, 250*time.Millisecond, instanceConf.generateInterval)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, GenerateIntervalMs: -1})
+	}, "Should have a panic")
+}
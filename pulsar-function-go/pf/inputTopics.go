@@ -0,0 +1,117 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/admin"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+)
+
+// topicLister returns the topics of a namespace: the partitioned topics, and
+// the non-partitioned ones along with the partitions of the others
+type topicLister interface {
+	listTopics(namespace string) ([]string, error)
+}
+
+// adminTopicLister lists the topics of namespaces with the broker admin API
+type adminTopicLister struct {
+	topics admin.Topics
+}
+
+func newAdminTopicLister(ic *instanceConf) (*adminTopicLister, error) {
+	client, err := newAdminClient(ic)
+	if err != nil {
+		return nil, err
+	}
+	return &adminTopicLister{topics: client.Topics()}, nil
+}
+
+func (l *adminTopicLister) listTopics(namespace string) ([]string, error) {
+	namespaceName, err := utils.GetNamespaceName(namespace)
+	if err != nil {
+		return nil, err
+	}
+	partitioned, nonPartitioned, err := l.topics.List(*namespaceName)
+	if err != nil {
+		return nil, err
+	}
+	return append(partitioned, nonPartitioned...), nil
+}
+
+// setupInputTopicsCheck fails when requireInputTopicsExist is set and an
+// input topic does not exist
+func (gi *goInstance) setupInputTopicsCheck() error {
+	if !gi.context.instanceConf.requireInputTopicsExist {
+		return nil
+	}
+	lister, err := newAdminTopicLister(gi.context.instanceConf)
+	if err != nil {
+		return withExitCode(ExitCodeConnectionError, err)
+	}
+	return gi.checkInputTopicsExist(lister)
+}
+
+// checkInputTopicsExist returns an error listing the input topics missing
+// from their namespace. Topics patterns are left out, they may match topics
+// created later on.
+func (gi *goInstance) checkInputTopicsExist(lister topicLister) error {
+	var inputs []string
+	for topic, spec := range gi.context.instanceConf.funcDetails.Source.InputSpecs {
+		if !spec.IsRegexPattern {
+			inputs = append(inputs, topic)
+		}
+	}
+	sort.Strings(inputs)
+
+	namespaces := make(map[string]map[string]bool)
+	var missing []string
+	for _, input := range inputs {
+		topicName, err := ParseTopicName(input)
+		if err != nil {
+			return withExitCode(ExitCodeConfigError, err)
+		}
+		existing, ok := namespaces[topicName.Namespace]
+		if !ok {
+			topics, err := lister.listTopics(topicName.Namespace)
+			if err != nil {
+				return withExitCode(ExitCodeConnectionError,
+					fmt.Errorf("failed to list the topics of namespace %s: %w", topicName.Namespace, err))
+			}
+			existing = make(map[string]bool, len(topics))
+			for _, topic := range topics {
+				existing[topic] = true
+			}
+			namespaces[topicName.Namespace] = existing
+		}
+		// a partition exists when it is listed, or its partitioned topic is
+		if !existing[topicName.Name] && !existing[topicName.NameWithoutPartition()] {
+			missing = append(missing, input)
+		}
+	}
+	if len(missing) > 0 {
+		return withExitCode(ExitCodeConfigError, fmt.Errorf("input topics do not exist: %s, "+
+			"create them beforehand or unset requireInputTopicsExist", strings.Join(missing, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+type fakeTopicLister struct {
+	topics map[string][]string
+	err    error
+	listed []string
+}
+
+func (l *fakeTopicLister) listTopics(namespace string) ([]string, error) {
+	l.listed = append(l.listed, namespace)
+	if l.err != nil {
+		return nil, l.err
+	}
+	return l.topics[namespace], nil
+}
+
+func newInputTopicsTestLister() *fakeTopicLister {
+	return &fakeTopicLister{topics: map[string][]string{
+		"public/default": {
+			"persistent://public/default/partitioned",
+			"persistent://public/default/partitioned-partition-0",
+			"persistent://public/default/partitioned-partition-1",
+			"persistent://public/default/topic-01",
+		},
+		"tenant/ns": {"persistent://tenant/ns/topic-02"},
+	}}
+}
+
+func newInputTopicsTestInstance(inputs map[string]*pb.ConsumerSpec) *goInstance {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Source.InputSpecs = inputs
+	return instance
+}
+
+func TestCheckInputTopicsExist_Existing(t *testing.T) {
+	instance := newInputTopicsTestInstance(map[string]*pb.ConsumerSpec{
+		"topic-01": {},
+		"persistent://public/default/partitioned":             {},
+		"persistent://public/default/partitioned-partition-1": {},
+		"persistent://tenant/ns/topic-02":                     {},
+		"persistent://public/default/missing-.*":              {IsRegexPattern: true},
+	})
+	lister := newInputTopicsTestLister()
+
+	assert.NoError(t, instance.checkInputTopicsExist(lister))
+	// namespaces are listed once
+	assert.ElementsMatch(t, []string{"public/default", "tenant/ns"}, lister.listed)
+}
+
+func TestCheckInputTopicsExist_Missing(t *testing.T) {
+	instance := newInputTopicsTestInstance(map[string]*pb.ConsumerSpec{
+		"topic-01":                        {},
+		"topic-03":                        {},
+		"persistent://tenant/ns/topic-02": {},
+		"persistent://tenant/other/topic": {},
+	})
+
+	err := instance.checkInputTopicsExist(newInputTopicsTestLister())
+	assert.EqualError(t, err, "input topics do not exist: persistent://tenant/other/topic, topic-03, "+
+		"create them beforehand or unset requireInputTopicsExist")
+	assert.Equal(t, ExitCodeConfigError, exitCodeOf(err))
+}
+
+func TestCheckInputTopicsExist_LookupError(t *testing.T) {
+	instance := newInputTopicsTestInstance(map[string]*pb.ConsumerSpec{"topic-01": {}})
+	lister := &fakeTopicLister{err: errors.New("connection refused")}
+
+	err := instance.checkInputTopicsExist(lister)
+	assert.EqualError(t, err, "failed to list the topics of namespace public/default: connection refused")
+	assert.Equal(t, ExitCodeConnectionError, exitCodeOf(err))
+}
+
+func TestCheckInputTopicsExist_OnlyPatterns(t *testing.T) {
+	instance := newInputTopicsTestInstance(map[string]*pb.ConsumerSpec{
+		"persistent://public/default/.*": {IsRegexPattern: true},
+	})
+	lister := &fakeTopicLister{err: errors.New("not called")}
+
+	assert.NoError(t, instance.checkInputTopicsExist(lister))
+	assert.Empty(t, lister.listed)
+}
+
+func TestSetupInputTopicsCheck_Disabled(t *testing.T) {
+	instance := newInputTopicsTestInstance(map[string]*pb.ConsumerSpec{"missing": {}})
+	assert.NoError(t, instance.setupInputTopicsCheck())
+}
+
+func TestInstanceConf_RequireInputTopicsExist(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, RequireInputTopicsExist: true,
+		PulsarWebServiceURL: "http://localhost:8080"})
+	assert.True(t, instanceConf.requireInputTopicsExist)
+
+	assert.PanicsWithValue(t, "requireInputTopicsExist requires pulsarWebServiceURL to be set.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, RequireInputTopicsExist: true})
+	})
+}
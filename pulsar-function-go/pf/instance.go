@@ -21,10 +21,14 @@ package pf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
@@ -36,15 +40,63 @@ import (
 	prometheus_client "github.com/prometheus/client_model/go"
 )
 
+// RestartRequestedExitCode is the exit code used by an instance that asked to
+// be restarted through FunctionContext.RequestRestart. The other exit codes
+// are listed along with ExitCodeConfigError.
+const RestartRequestedExitCode = 3
+
+// Strategies for the event time set on messages produced to the sink
+const (
+	// OutputEventTimeNone leaves the event time of output messages unset
+	OutputEventTimeNone = "none"
+	// OutputEventTimePassthrough copies the event time of the input message
+	OutputEventTimePassthrough = "passthrough"
+	// OutputEventTimeProcessing sets the event time to the time the output is produced
+	OutputEventTimeProcessing = "processing"
+)
+
+// osExit is swapped out in tests
+var osExit = os.Exit
+
 type goInstance struct {
-	function          function
-	context           *FunctionContext
-	producer          pulsar.Producer
-	consumers         map[string]pulsar.Consumer
-	client            pulsar.Client
-	lastHealthCheckTS int64
-	properties        map[string]string
-	stats             StatWithLabelValues
+	function           function
+	context            *FunctionContext
+	producer           pulsar.Producer
+	deadLetterProducer pulsar.Producer
+	deadLetterMu       sync.Mutex
+	outputProducers    map[string]pulsar.Producer
+	outputProducersMu  sync.Mutex
+	derivedProducers   map[derivedProducerKey]*derivedProducer
+	derivedProducersMu sync.Mutex
+	publishSlots       chan struct{}
+	rateLimiter        *rateLimiter
+	clientStats        clientStatsSource
+	consumerStats      consumerStatsSource
+	receiveCtx         context.Context
+	stopReceiving      context.CancelFunc
+	consumers          map[string]pulsar.Consumer
+	client             pulsar.Client
+	lastHealthCheckTS  int64
+	properties         map[string]string
+	stats              StatWithLabelValues
+	ready              atomic.Bool
+	paused             atomic.Bool
+	pauseStateChanged  chan struct{}
+	sendFailed         chan error
+	deserializeLogs    *logSampler
+	aggregator         *outputAggregator
+	deduplication      *deduplicationCache
+	inFlight           *inFlightMessages
+	timersCtx          context.Context
+	stopTimers         context.CancelFunc
+	retriesCtx         context.Context
+	stopRetries        context.CancelFunc
+	timersMu           sync.Mutex
+	pendingAcks        sync.WaitGroup
+	pendingSends       sync.WaitGroup
+	authToken          *refreshingToken
+	seekBoundary       pulsar.MessageID
+	seekMu             sync.Mutex
 }
 
 func (gi *goInstance) getMetricsLabels() []string {
@@ -64,8 +116,14 @@ func (gi *goInstance) getMetricsLabels() []string {
 // newGoInstance init goInstance and init function context
 func newGoInstance() *goInstance {
 	goInstance := &goInstance{
-		context:   NewFuncContext(),
-		consumers: make(map[string]pulsar.Consumer),
+		context:           NewFuncContext(),
+		outputProducers:   make(map[string]pulsar.Producer),
+		derivedProducers:  make(map[derivedProducerKey]*derivedProducer),
+		consumers:         make(map[string]pulsar.Consumer),
+		pauseStateChanged: make(chan struct{}, 1),
+		sendFailed:        make(chan error, 1),
+		clientStats:       defaultClientStats,
+		consumerStats:     defaultConsumerStats,
 	}
 	now := time.Now()
 
@@ -74,8 +132,41 @@ func newGoInstance() *goInstance {
 		if err != nil {
 			log.Fatal(err)
 		}
+		if goInstance.publishSlots != nil {
+			producer = newPendingLimitProducer(producer, goInstance.publishSlots)
+		}
+		goInstance.addOutputProducer(topic, producer)
 		return producer
 	}
+	if limit := goInstance.context.instanceConf.maxPendingPublishes; limit > 0 {
+		goInstance.publishSlots = make(chan struct{}, limit)
+	}
+	goInstance.deserializeLogs = newLogSampler(goInstance.context.instanceConf.deserializationLogFirst,
+		goInstance.context.instanceConf.deserializationLogEvery)
+	if rate := goInstance.context.instanceConf.maxProcessingRate; rate > 0 {
+		goInstance.rateLimiter = newRateLimiter(rate)
+	}
+	goInstance.context.lastSequenceID = goInstance.getLastSequenceID
+	goInstance.context.publishDerived = goInstance.publishToDerivedTopic
+	goInstance.context.publish = goInstance.publish
+	goInstance.context.newReader = goInstance.newReader
+	goInstance.context.requestRestart = goInstance.requestRestart
+	goInstance.context.reconsume = goInstance.reconsumeLater
+	goInstance.timersCtx, goInstance.stopTimers = context.WithCancel(context.Background())
+	goInstance.retriesCtx, goInstance.stopRetries = context.WithCancel(context.Background())
+	goInstance.context.registerTimer = goInstance.registerTimer
+	goInstance.context.client = func() PulsarClient {
+		return goInstance.client
+	}
+	goInstance.context.tokenExpiry = func() time.Time {
+		if goInstance.authToken == nil {
+			return time.Time{}
+		}
+		return goInstance.authToken.getExpiry()
+	}
+	goInstance.context.metrics = func() MetricsSnapshot {
+		return goInstance.stats.snapshot()
+	}
 
 	goInstance.lastHealthCheckTS = now.UnixNano()
 	goInstance.properties = make(map[string]string)
@@ -95,6 +186,40 @@ func (gi *goInstance) processSpawnerHealthCheckTimer(tkr *time.Ticker) {
 	}
 }
 
+// startMetricsServer starts serving metrics. Failing to bind the metrics port
+// is only an error if the instance is not allowed to continue without metrics.
+func (gi *goInstance) startMetricsServer() (*MetricsServicer, error) {
+	// the registry is process-wide, its namespace is set once here, before
+	// the server exposing it is created
+	if namespace := gi.context.instanceConf.metricsNamespace; namespace != "" {
+		setMetricsNamespace(namespace)
+	}
+	metricsServicer := NewMetricsServicer(gi)
+	err := metricsServicer.serve()
+	if err != nil && !gi.context.instanceConf.continueWithoutMetrics {
+		return nil, err
+	}
+	if err != nil {
+		log.Warnf("%v, continuing without metrics", err)
+	}
+	return metricsServicer, nil
+}
+
+func (gi *goInstance) requestRestart(reason string) {
+	log.Warnf("Function requested a restart: %s", reason)
+	gi.stats.incrTotalRestartRequests()
+
+	// nack the in-flight message so it is redelivered to the restarted instance
+	atMostOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATMOST_ONCE
+	if record := gi.context.GetCurrentRecord(); record != nil && !atMostOnce {
+		gi.nackInputMessage(record)
+	}
+
+	gi.closeLogTopic()
+	gi.close()
+	osExit(RestartRequestedExitCode)
+}
+
 func (gi *goInstance) startScheduler() {
 	if gi.context.instanceConf.expectedHealthCheckInterval > 0 {
 		log.Info("Starting Scheduler")
@@ -112,74 +237,247 @@ func (gi *goInstance) startScheduler() {
 func (gi *goInstance) startFunction(function function) error {
 	gi.function = function
 
+	if err := gi.checkUserConfigKeys(); err != nil {
+		return err
+	}
+	generator := getGenerator()
+	if err := gi.checkSource(generator); err != nil {
+		return err
+	}
+
 	// start process spawner health check timer
 	now := time.Now()
 	gi.lastHealthCheckTS = now.UnixNano()
+	gi.context.startTime = now
 
 	gi.startScheduler()
 
-	err := gi.setupClient()
+	// serve health checks and metrics while initializing so that readiness
+	// can be reported before the instance starts processing messages
+	servicer := InstanceControlServicer{goInstance: gi}
+	servicer.serve(gi)
+
+	metricsServicer, err := gi.startMetricsServer()
 	if err != nil {
-		log.Errorf("setup client failed, error is:%v", err)
+		log.Errorf("%v", err)
+		return err
+	}
+	defer metricsServicer.close()
+
+	debugServer, err := gi.startDebugServer()
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+	if debugServer != nil {
+		defer debugServer.close()
+	}
+
+	err = gi.setupSchemaCheck()
+	if err != nil {
+		log.Errorf("schema compatibility check failed, error is:%v", err)
+		return err
+	}
+	err = gi.setupInputTopicsCheck()
+	if err != nil {
+		log.Errorf("input topics check failed, error is:%v", err)
 		return err
 	}
+	err = gi.setupAutoConsume()
+	if err != nil {
+		log.Errorf("setup %s schemas failed, error is:%v", SchemaTypeAutoConsume, err)
+		return err
+	}
+	err = gi.setupClient()
+	if err != nil {
+		log.Errorf("setup client failed, error is:%v", err)
+		return withExitCode(ExitCodeConnectionError, err)
+	}
+	stopAuthTokenRefresh := gi.startAuthTokenRefresh()
+	defer stopAuthTokenRefresh()
+	stopClientStats := gi.startClientStatsPoller()
+	defer stopClientStats()
+	stopConsumerStats := gi.startConsumerStatsPoller()
+	defer stopConsumerStats()
 	err = gi.setupProducer()
 	if err != nil {
 		log.Errorf("setup producer failed, error is:%v", err)
+		return withExitCode(ExitCodeConnectionError, err)
+	}
+	err = gi.setupOutputAggregation()
+	if err != nil {
+		log.Errorf("setup output aggregation failed, error is:%v", err)
 		return err
 	}
+	stopIdleProducerSweep := gi.startIdleProducerSweep()
+	defer stopIdleProducerSweep()
+	gi.setupDeduplication()
+	if gi.context.instanceConf.ackTimeout > 0 {
+		gi.inFlight = newInFlightMessages()
+	}
+	stopAckTimeoutSweep := gi.startAckTimeoutSweep()
+	defer stopAckTimeoutSweep()
+	gi.setupStartPaused()
 	channel, err := gi.setupConsumer()
 	if err != nil {
 		log.Errorf("setup consumer failed, error is:%v", err)
-		return err
+		return withExitCode(ExitCodeConnectionError, err)
 	}
 	err = gi.setupLogHandler()
 	if err != nil {
 		log.Errorf("setup log appender failed, error is:%v", err)
 		return err
 	}
+	gi.startLogTopicMetrics()
+	err = gi.setupBacklog()
+	if err != nil {
+		log.Errorf("setup backlog source failed, error is:%v", err)
+		return err
+	}
+
+	stopWaiting := make(chan struct{})
+	if gi.context.instanceConf.startupWaitForSubscription {
+		go gi.waitUntilReady(stopWaiting)
+	} else {
+		gi.ready.Store(true)
+	}
+	if generator != nil {
+		err = gi.runGenerator(generator)
+	} else {
+		err = gi.processMessages(channel)
+	}
+	close(stopWaiting)
+	if err != nil {
+		return err
+	}
+
+	gi.ready.Store(false)
+	gi.closeLogTopic()
+	gi.close()
+	return nil
+}
 
+// processMessages passes the messages to the function one at a time, in the
+// order they are received, and publishes their outputs through the single sink
+// producer in that order too. The outputs of the messages of a key thus keep
+// their input order without any per-key sequencing, at the cost of processing
+// a single message at a time.
+func (gi *goInstance) processMessages(channel chan pulsar.ConsumerMessage) error {
 	idleDuration := getIdleTimeout(time.Millisecond * gi.context.instanceConf.killAfterIdle)
 	idleTimer := time.NewTimer(idleDuration)
 	defer idleTimer.Stop()
+	maxMessages := gi.context.instanceConf.maxMessagesToProcess
+	var handled int64
 
-	servicer := InstanceControlServicer{goInstance: gi}
-	servicer.serve(gi)
-
-	metricsServicer := NewMetricsServicer(gi)
-	metricsServicer.serve()
-	defer metricsServicer.close()
 CLOSE:
 	for {
+		// while paused, stop taking messages off the consumer channel so that
+		// the receiver queue fills up and the client stops fetching more
+		msgChannel := channel
+		if gi.isPaused() {
+			msgChannel = nil
+		}
 		select {
-		case cm := <-channel:
+		case cm := <-msgChannel:
+			// over maxProcessingRate, hold on to the message and stop taking
+			// more off the channel until the rate allows it
+			if gi.rateLimiter != nil && gi.rateLimiter.wait() {
+				gi.stats.incrTotalThrottled()
+			}
 			msgInput := cm.Message
+			handled++
 			atMostOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATMOST_ONCE
-			atLeastOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATLEAST_ONCE
 			autoAck := gi.context.instanceConf.funcDetails.AutoAck
 			if autoAck && atMostOnce {
 				gi.ackInputMessage(msgInput)
+			} else {
+				gi.trackInFlight(msgInput)
 			}
 			gi.stats.incrTotalReceived()
+			if gi.context.instanceConf.skipTombstones && isTombstone(msgInput) {
+				log.Debugf("skipping tombstone message ID %s", messageIDStr(msgInput))
+				if !(autoAck && atMostOnce) {
+					gi.ackInputMessage(msgInput)
+				}
+				break
+			}
+			if maxAge := gi.context.instanceConf.maxMessageAge; maxAge > 0 && time.Since(msgInput.PublishTime()) > maxAge {
+				log.Debugf("skipping message ID %s published at %v, older than maxMessageAgeMs",
+					messageIDStr(msgInput), msgInput.PublishTime())
+				gi.stats.incrTotalExpiredMessages()
+				if !(autoAck && atMostOnce) {
+					gi.ackInputMessage(msgInput)
+				}
+				break
+			}
+			if gi.skipSeekBoundary(msgInput) {
+				log.Debugf("skipping message ID %s, the subscription was reset to it exclusively",
+					messageIDStr(msgInput))
+				if !(autoAck && atMostOnce) {
+					gi.ackInputMessage(msgInput)
+				}
+				break
+			}
+			if gi.skipDuplicate(msgInput) {
+				log.Debugf("skipping message ID %s, a duplicate of a message processed already", messageIDStr(msgInput))
+				gi.stats.incrTotalDuplicateMessages()
+				if !(autoAck && atMostOnce) {
+					gi.ackInputMessage(msgInput)
+				}
+				break
+			}
 			gi.addLogTopicHandler()
 
 			gi.stats.setLastInvocation()
 			gi.stats.processTimeStart()
 
-			output, err := gi.handlerMsg(msgInput)
-			if err != nil {
-				log.Errorf("handler message error:%v", err)
-				if autoAck && atLeastOnce {
-					gi.nackInputMessage(msgInput)
+			output, retryCount, err := gi.handlerMsgWithRetries(msgInput)
+			if err == nil && !gi.context.reconsumed {
+				// the outputs are the function's, a rejected one is a user exception
+				if err = gi.validateOutputs(output); err != nil {
+					gi.stats.incrTotalUserExceptions(err)
 				}
-				gi.stats.incrTotalUserExceptions(err)
+			}
+			switch {
+			case gi.context.reconsumed:
+				// the message has been handed over to the retry or dead letter topic
+				gi.stats.processTimeEnd(traceContextOf(msgInput))
+			case errors.Is(err, errRetriesInterrupted):
+				// left unacked, the message is redelivered once the consumer is closed
 				return err
+			case err != nil:
+				// deserialization errors have been logged as sampled already
+				if !errors.Is(err, ErrDeserialization) {
+					log.Errorf("handler message error:%v%s", err, gi.payloadPreview(msgInput))
+				}
+				if err := gi.handleProcessError(msgInput, err, retryCount); err != nil {
+					return err
+				}
+			default:
+				gi.stats.processTimeEnd(traceContextOf(msgInput))
+				gi.processResult(msgInput, output)
+				gi.rememberProcessed(msgInput)
 			}
-
-			gi.stats.processTimeEnd()
-			gi.processResult(msgInput, output)
+		case err := <-gi.sendFailed:
+			// onSendFailure fail, the input has been nacked already
+			return err
+		case <-gi.pauseStateChanged:
 		case <-idleTimer.C:
-			close(channel)
+			if gi.isPaused() {
+				// a paused instance is not idle
+				idleTimer.Reset(idleDuration)
+				continue
+			}
+			// in receive mode the channel is left to the receiving goroutines,
+			// which stop once the consumers are closed
+			if !gi.isReceiveMode() {
+				close(channel)
+			}
+			break CLOSE
+		}
+		if maxMessages > 0 && handled >= maxMessages {
+			log.Infof("Processed maxMessagesToProcess=%d messages, exiting", maxMessages)
+			gi.flushProducers()
 			break CLOSE
 		}
 		// reset the idle timer and drain if appropriate before the next loop
@@ -188,18 +486,42 @@ CLOSE:
 		}
 		idleTimer.Reset(idleDuration)
 	}
-
-	gi.closeLogTopic()
-	gi.close()
 	return nil
 }
 
+// defaults for the client connection settings, the same as those of the client
+const (
+	defaultConnectionTimeout = 5 * time.Second
+	defaultKeepAliveInterval = 30 * time.Second
+	defaultOperationTimeout  = 30 * time.Second
+)
+
+// maxConnectionsPerBroker bounds connectionsPerBroker, beyond which the broker
+// is more likely to be overloaded than the function to go faster
+const maxConnectionsPerBroker = 64
+
 const (
 	authPluginToken = "org.apache.pulsar.client.impl.auth.AuthenticationToken"
 	authPluginNone  = ""
 )
 
 func (gi *goInstance) setupClient() error {
+	clientOpts, err := gi.getClientOptions()
+	if err != nil {
+		return err
+	}
+
+	client, err := pulsar.NewClient(clientOpts)
+	if err != nil {
+		log.Errorf("create client error:%v", err)
+		gi.stats.incrTotalSysExceptions(err)
+		return err
+	}
+	gi.client = client
+	return nil
+}
+
+func (gi *goInstance) getClientOptions() (pulsar.ClientOptions, error) {
 	ic := gi.context.instanceConf
 
 	clientOpts := pulsar.ClientOptions{
@@ -207,42 +529,68 @@ func (gi *goInstance) setupClient() error {
 		TLSTrustCertsFilePath:      ic.tlsTrustCertsPath,
 		TLSAllowInsecureConnection: ic.tlsAllowInsecure,
 		TLSValidateHostname:        ic.tlsHostnameVerification,
+		TLSMinVersion:              ic.tlsMinVersion,
+		TLSCipherSuites:            ic.tlsCipherSuites,
+		ConnectionTimeout:          ic.connectionTimeout,
+		KeepAliveInterval:          ic.keepAliveInterval,
+		OperationTimeout:           ic.operationTimeout,
+	}
+	if clientOpts.ConnectionTimeout == 0 {
+		clientOpts.ConnectionTimeout = defaultConnectionTimeout
+	}
+	if clientOpts.KeepAliveInterval == 0 {
+		clientOpts.KeepAliveInterval = defaultKeepAliveInterval
+	}
+	if clientOpts.OperationTimeout == 0 {
+		clientOpts.OperationTimeout = defaultOperationTimeout
+	}
+	clientOpts.MaxConnectionsPerBroker = ic.connectionsPerBroker
+	if ic.consumerStatsInterval > 0 {
+		// the consumer stats are read from the client metrics, by topic
+		clientOpts.MetricsCardinality = pulsar.MetricsCardinalityTopic
 	}
 
 	switch ic.authPlugin {
 	case authPluginToken:
 		switch {
 		case strings.HasPrefix(ic.authParams, "file://"):
-			clientOpts.Authentication = pulsar.NewAuthenticationTokenFromFile(ic.authParams[7:])
+			token, err := gi.newRefreshingToken(fileTokenSource(ic.authParams[7:]))
+			if err != nil {
+				return clientOpts, fmt.Errorf("failed to read the auth token: %w", err)
+			}
+			gi.authToken = token
+			clientOpts.Authentication = pulsar.NewAuthenticationTokenFromSupplier(token.get)
 		case strings.HasPrefix(ic.authParams, "token:"):
+			// the expiry is still read, to warn ahead of it
+			gi.authToken, _ = gi.newRefreshingToken(staticTokenSource(ic.authParams[6:]))
 			clientOpts.Authentication = pulsar.NewAuthenticationToken(ic.authParams[6:])
 		case ic.authParams == "":
-			return fmt.Errorf("auth plugin %s given, but authParams is empty", authPluginToken)
+			return clientOpts, fmt.Errorf("auth plugin %s given, but authParams is empty", authPluginToken)
 		default:
-			return fmt.Errorf(`unknown token format - expecting "file://" or "token:" prefix`)
+			return clientOpts, fmt.Errorf(`unknown token format - expecting "file://" or "token:" prefix`)
 		}
 	case authPluginNone:
 		clientOpts.Authentication, _ = pulsar.NewAuthentication("", "") // ret: auth.NewAuthDisabled()
 	default:
-		return fmt.Errorf("unknown auth provider: %s", ic.authPlugin)
-	}
-
-	client, err := pulsar.NewClient(clientOpts)
-	if err != nil {
-		log.Errorf("create client error:%v", err)
-		gi.stats.incrTotalSysExceptions(err)
-		return err
+		return clientOpts, fmt.Errorf("unknown auth provider: %s", ic.authPlugin)
 	}
-	gi.client = client
-	return nil
+	return clientOpts, nil
 }
 
 func (gi *goInstance) setupProducer() error {
 	if gi.context.instanceConf.funcDetails.Sink.Topic != "" && len(gi.context.instanceConf.funcDetails.Sink.Topic) > 0 {
 		log.Debugf("Setting up producer for topic %s", gi.context.instanceConf.funcDetails.Sink.Topic)
+		if !gi.context.instanceConf.allowAutoTopicCreation {
+			// the client has no such option, the topic is only checked once the producer is created
+			log.Warnf("allowAutoTopicCreation is false, but whether the broker creates a missing sink topic is "+
+				"up to the namespace policy, %s must already exist", gi.context.instanceConf.funcDetails.Sink.Topic)
+		}
+		if err := gi.checkSinkPartition(gi.context.instanceConf.funcDetails.Sink.Topic); err != nil {
+			return err
+		}
 		producer, err := gi.getProducer(gi.context.instanceConf.funcDetails.Sink.Topic)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		gi.producer = producer
@@ -253,6 +601,57 @@ func (gi *goInstance) setupProducer() error {
 }
 
 func (gi *goInstance) getProducer(topicName string) (pulsar.Producer, error) {
+	return gi.createProducer(gi.getProducerOptions(topicName))
+}
+
+func (gi *goInstance) createProducer(producerOpts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	producer, err := gi.client.CreateProducer(producerOpts)
+	if err != nil {
+		err = gi.classifyTopicNotFound(producerOpts.Topic, classifyProducerError(producerOpts.Topic, err))
+		gi.stats.incrTotalSysExceptions(err)
+		log.Errorf("create producer error:%s", err.Error())
+		return nil, err
+	}
+
+	return producer, err
+}
+
+// addOutputProducer keeps track of the latest producer created for topic
+// through the function context
+func (gi *goInstance) addOutputProducer(topic string, producer pulsar.Producer) {
+	if topicName, err := ParseTopicName(topic); err == nil {
+		topic = topicName.Name
+	}
+	gi.outputProducersMu.Lock()
+	defer gi.outputProducersMu.Unlock()
+	gi.outputProducers[topic] = producer
+}
+
+// getLastSequenceID returns the last sequence id published by the producer of
+// topic, or of the sink when topic is empty, or -1 if there is no such producer
+func (gi *goInstance) getLastSequenceID(topic string) int64 {
+	sinkTopic := gi.context.instanceConf.funcDetails.Sink.GetTopic()
+	if topic == "" {
+		topic = sinkTopic
+	}
+	if topicName, err := ParseTopicName(topic); err == nil {
+		topic = topicName.Name
+	}
+	if sinkTopicName, err := ParseTopicName(sinkTopic); err == nil && topic == sinkTopicName.Name &&
+		gi.producer != nil {
+		return gi.producer.LastSequenceID()
+	}
+
+	gi.outputProducersMu.Lock()
+	producer, ok := gi.outputProducers[topic]
+	gi.outputProducersMu.Unlock()
+	if !ok {
+		return -1
+	}
+	return producer.LastSequenceID()
+}
+
+func (gi *goInstance) getProducerOptions(topicName string) pulsar.ProducerOptions {
 	properties := getProperties(getDefaultSubscriptionName(
 		gi.context.instanceConf.funcDetails.Tenant,
 		gi.context.instanceConf.funcDetails.Namespace,
@@ -262,16 +661,7 @@ func (gi *goInstance) getProducer(topicName string) (pulsar.Producer, error) {
 
 	compressionType := pulsar.LZ4
 	if gi.context.instanceConf.funcDetails.Sink.ProducerSpec != nil {
-		switch gi.context.instanceConf.funcDetails.Sink.ProducerSpec.CompressionType {
-		case pb.CompressionType_NONE:
-			compressionType = pulsar.NoCompression
-		case pb.CompressionType_ZLIB:
-			compressionType = pulsar.ZLib
-		case pb.CompressionType_ZSTD:
-			compressionType = pulsar.ZSTD
-		default:
-			compressionType = pulsar.LZ4 // go doesn't support SNAPPY yet
-		}
+		compressionType = getCompressionType(gi.context.instanceConf.funcDetails.Sink.ProducerSpec.CompressionType)
 
 		batchBuilder := gi.context.instanceConf.funcDetails.Sink.ProducerSpec.BatchBuilder
 		if batchBuilder != "" {
@@ -281,44 +671,46 @@ func (gi *goInstance) getProducer(topicName string) (pulsar.Producer, error) {
 		}
 	}
 
-	producer, err := gi.client.CreateProducer(pulsar.ProducerOptions{
+	producerOpts := pulsar.ProducerOptions{
 		Topic:                   topicName,
 		Properties:              properties,
 		CompressionType:         compressionType,
 		BatchingMaxPublishDelay: time.Millisecond * 10,
 		BatcherBuilderType:      batchBuilderType,
+		DisableBatching:         gi.context.instanceConf.disableBatching,
+		EnableChunking:          gi.context.instanceConf.enableChunking,
+		HashingScheme:           gi.context.instanceConf.producerHashingScheme,
 		SendTimeout:             0,
 		// Set send timeout to be infinity to prevent potential deadlock with consumer
 		// that might happen when consumer is blocked due to unacked messages
-	})
-	if err != nil {
-		gi.stats.incrTotalSysExceptions(err)
-		log.Errorf("create producer error:%s", err.Error())
-		return nil, err
 	}
-
-	return producer, err
+	// the routing mode and schema only apply to the sink, not to topics produced to through the context
+	if sink := gi.context.instanceConf.funcDetails.Sink; topicName == sink.Topic {
+		producerOpts.MessageRouter = newSinkMessageRouter(gi.context.instanceConf.sinkPartitionRouting,
+			gi.context.instanceConf.sinkPartition, gi.context.instanceConf.producerHashingScheme)
+		// the definition has already been validated when loading the instance config
+		definition := gi.context.instanceConf.outputSchemaDefinition
+		if gi.context.instanceConf.disableSchemaCreation {
+			producerOpts.DisableMultiSchema = true
+		} else if definition != "" {
+			producerOpts.Schema, _ = newOutputSchema(sink.SchemaType, definition, sink.SchemaProperties)
+		}
+	}
+	return producerOpts
 }
 
 func (gi *goInstance) setupConsumer() (chan pulsar.ConsumerMessage, error) {
-	subscriptionType := pulsar.Shared
-	if int32(gi.context.instanceConf.funcDetails.Source.SubscriptionType) == pb.SubscriptionType_value["FAILOVER"] {
-		subscriptionType = pulsar.Failover
-	}
-
-	funcDetails := gi.context.instanceConf.funcDetails
-	subscriptionName := funcDetails.Tenant + "/" + funcDetails.Namespace + "/" + funcDetails.Name
-	if funcDetails.Source != nil && funcDetails.Source.SubscriptionName != "" {
-		subscriptionName = funcDetails.Source.SubscriptionName
-	}
-
-	properties := getProperties(getDefaultSubscriptionName(
-		funcDetails.Tenant,
-		funcDetails.Namespace,
-		funcDetails.Name), gi.context.instanceConf.instanceID)
+	funcDetails := &gi.context.instanceConf.funcDetails
 
 	channel := make(chan pulsar.ConsumerMessage)
 
+	if gi.context.instanceConf.consumerFailoverDelay > 0 &&
+		funcDetails.Source.SubscriptionType == pb.SubscriptionType_FAILOVER {
+		// the consumer options of the client have no failover delay, only the
+		// broker's activeConsumerFailoverDelayTimeMillis applies
+		log.Warnf("consumerFailoverDelayMs is not supported by the Pulsar client, ignoring it")
+	}
+
 	var (
 		consumer  pulsar.Consumer
 		topicName *TopicName
@@ -331,105 +723,253 @@ func (gi *goInstance) setupConsumer() (chan pulsar.ConsumerMessage, error) {
 			return nil, err
 		}
 
-		log.Debugf("Setting up consumer for topic: %s with subscription name: %s", topicName.Name, subscriptionName)
-		if consumerConf.ReceiverQueueSize != nil {
-			if consumerConf.IsRegexPattern {
-				consumer, err = gi.client.Subscribe(pulsar.ConsumerOptions{
-					TopicsPattern:     topicName.Name,
-					ReceiverQueueSize: int(consumerConf.ReceiverQueueSize.Value),
-					SubscriptionName:  subscriptionName,
-					Properties:        properties,
-					Type:              subscriptionType,
-					MessageChannel:    channel,
-				})
-			} else {
-				consumer, err = gi.client.Subscribe(pulsar.ConsumerOptions{
-					Topic:             topicName.Name,
-					SubscriptionName:  subscriptionName,
-					Properties:        properties,
-					Type:              subscriptionType,
-					ReceiverQueueSize: int(consumerConf.ReceiverQueueSize.Value),
-					MessageChannel:    channel,
-				})
-			}
-		} else {
-			if consumerConf.IsRegexPattern {
-				consumer, err = gi.client.Subscribe(pulsar.ConsumerOptions{
-					TopicsPattern:    topicName.Name,
-					SubscriptionName: subscriptionName,
-					Properties:       properties,
-					Type:             subscriptionType,
-					MessageChannel:   channel,
-				})
-			} else {
-				consumer, err = gi.client.Subscribe(pulsar.ConsumerOptions{
-					Topic:            topicName.Name,
-					SubscriptionName: subscriptionName,
-					Properties:       properties,
-					Type:             subscriptionType,
-					MessageChannel:   channel,
-				})
-
-			}
-		}
-
+		consumerOpts := gi.getConsumerOptions(topicName.Name, consumerConf, channel)
+		log.Debugf("Setting up consumer for topic: %s with subscription name: %s",
+			topicName.Name, consumerOpts.SubscriptionName)
+		consumer, err = gi.client.Subscribe(consumerOpts)
 		if err != nil {
 			log.Errorf("create consumer error:%s", err.Error())
 			gi.stats.incrTotalSysExceptions(err)
 			return nil, err
 		}
 		gi.consumers[topicName.Name] = consumer
+		if err = gi.rollbackConsumer(topicName.Name, consumer, time.Now()); err != nil {
+			gi.stats.incrTotalSysExceptions(err)
+			return nil, err
+		}
+		if gi.isReceiveMode() {
+			gi.startReceiving(consumer, channel)
+		}
+	}
+	// the consumer of the single input topic also receives the messages of the retry letter topic
+	if retryLetterTopic := gi.context.instanceConf.retryLetterTopic; retryLetterTopic != "" && consumer != nil {
+		topicName, err = ParseTopicName(retryLetterTopic)
+		if err != nil {
+			return nil, err
+		}
+		gi.consumers[topicName.NameWithoutPartition()] = consumer
 	}
 	return channel, nil
 }
 
+// rollbackConsumer seeks the subscription of consumer back to
+// startMessageRollbackDuration before now, whatever subscriptionPosition is
+func (gi *goInstance) rollbackConsumer(topic string, consumer pulsar.Consumer, now time.Time) error {
+	rollback := gi.context.instanceConf.startMessageRollback
+	if rollback <= 0 {
+		return nil
+	}
+	start := now.Add(-rollback)
+	log.Infof("Rolling back the subscription of %s to %s", topic, start.Format(time.RFC3339))
+	if err := consumer.SeekByTime(start); err != nil {
+		log.Errorf("failed to roll back the subscription of %s: %v", topic, err)
+		return err
+	}
+	return nil
+}
+
+func (gi *goInstance) getSubscriptionName() string {
+	funcDetails := &gi.context.instanceConf.funcDetails
+	if funcDetails.Source != nil && funcDetails.Source.SubscriptionName != "" {
+		return funcDetails.Source.SubscriptionName
+	}
+	return funcDetails.Tenant + "/" + funcDetails.Namespace + "/" + funcDetails.Name
+}
+
+// defaultAckGroupingMaxSize is the number of acks the client groups at most, as in its own default
+const defaultAckGroupingMaxSize = 1000
+
+func (gi *goInstance) getConsumerOptions(topicName string, consumerConf *pb.ConsumerSpec,
+	channel chan pulsar.ConsumerMessage) pulsar.ConsumerOptions {
+	subscriptionType := pulsar.Shared
+	if int32(gi.context.instanceConf.funcDetails.Source.SubscriptionType) == pb.SubscriptionType_value["FAILOVER"] {
+		subscriptionType = pulsar.Failover
+	}
+
+	funcDetails := &gi.context.instanceConf.funcDetails
+	subscriptionName := gi.getSubscriptionName()
+
+	properties := getProperties(getDefaultSubscriptionName(
+		funcDetails.Tenant,
+		funcDetails.Namespace,
+		funcDetails.Name), gi.context.instanceConf.instanceID)
+
+	consumerOpts := pulsar.ConsumerOptions{
+		SubscriptionName:         subscriptionName,
+		Properties:               properties,
+		Type:                     subscriptionType,
+		MaxPendingChunkedMessage: gi.context.instanceConf.maxPendingChunkedMessage,
+		AutoAckIncompleteChunk:   gi.context.instanceConf.autoAckIncompleteChunk,
+		ReadCompacted:            gi.context.instanceConf.readCompacted,
+		SubscriptionMode:         gi.context.instanceConf.subscriptionMode,
+
+		EnableBatchIndexAcknowledgment: gi.context.instanceConf.batchIndexAckEnabled,
+		ReplicateSubscriptionState:     gi.context.instanceConf.replicateSubscription,
+		AckWithResponse:                gi.context.instanceConf.ackWithResponse,
+	}
+	// in receive mode the messages are pulled into the channel by the instance instead
+	if !gi.isReceiveMode() {
+		consumerOpts.MessageChannel = channel
+	}
+	// non-persistent topics keep no cursor to resume from
+	if topic, err := ParseTopicName(topicName); err == nil && !topic.IsPersistent() {
+		consumerOpts.SubscriptionMode = pulsar.NonDurable
+		consumerOpts.ReadCompacted = false
+		consumerOpts.ReplicateSubscriptionState = false
+	}
+	if consumerConf.IsRegexPattern {
+		consumerOpts.TopicsPattern = topicName
+	} else {
+		consumerOpts.Topic = topicName
+	}
+	// the client groups acks by default, a zero grouping time acks each message immediately
+	consumerOpts.AckGroupingOptions = &pulsar.AckGroupingOptions{}
+	if gi.context.instanceConf.ackGroupingTime > 0 {
+		consumerOpts.AckGroupingOptions.MaxSize = defaultAckGroupingMaxSize
+		consumerOpts.AckGroupingOptions.MaxTime = gi.context.instanceConf.ackGroupingTime
+	}
+	if retryLetterTopic := gi.context.instanceConf.retryLetterTopic; retryLetterTopic != "" {
+		// messages reconsumed more than MaxDeliveries times are sent to the
+		// dead letter topic by the client
+		consumerOpts.RetryEnable = true
+		consumerOpts.DLQ = &pulsar.DLQPolicy{
+			MaxDeliveries:    uint32(funcDetails.RetryDetails.GetMaxMessageRetries()),
+			DeadLetterTopic:  funcDetails.RetryDetails.GetDeadLetterTopic(),
+			RetryLetterTopic: retryLetterTopic,
+		}
+	}
+	if backoffMax := gi.context.instanceConf.negativeAckBackoffMax; backoffMax > 0 {
+		consumerOpts.NackBackoffPolicy = &nackBackoffPolicy{
+			min: gi.context.instanceConf.negativeAckBackoffMin,
+			max: backoffMax,
+		}
+	}
+	if gi.context.instanceConf.autoUpdatePartitions {
+		consumerOpts.AutoDiscoveryPeriod = gi.context.instanceConf.partitionsUpdateInterval
+	}
+	if consumerConf.ReceiverQueueSize != nil {
+		consumerOpts.ReceiverQueueSize = int(consumerConf.ReceiverQueueSize.Value)
+	}
+	// the definition has already been validated when loading the instance config
+	if definition, ok := gi.context.instanceConf.inputSchemaDefinitions[topicName]; ok {
+		consumerOpts.Schema = pulsar.NewAvroSchema(definition, consumerConf.SchemaProperties)
+	}
+	return consumerOpts
+}
+
 func (gi *goInstance) handlerMsg(input pulsar.Message) (output []byte, err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	gi.context.SetCurrentRecord(input)
+	gi.context.emitted = nil
+	gi.context.orderingKey = nil
+	gi.context.reconsumed = false
 
 	ctx = NewContext(ctx, gi.context)
 	msgInput := input.Payload()
 	return gi.function.process(ctx, msgInput)
 }
 
+func (gi *goInstance) getOutputEventTime(msgInput pulsar.Message) time.Time {
+	switch gi.context.instanceConf.outputEventTimeStrategy {
+	case OutputEventTimePassthrough:
+		return msgInput.EventTime()
+	case OutputEventTimeProcessing:
+		return time.Now()
+	default:
+		return time.Time{}
+	}
+}
+
 func (gi *goInstance) processResult(msgInput pulsar.Message, output []byte) {
-	atLeastOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATLEAST_ONCE
-	autoAck := gi.context.instanceConf.funcDetails.AutoAck
+	// messages emitted through the context go out ahead of the returned output
+	outputs := gi.context.emitted
+	gi.context.emitted = nil
+	if output != nil {
+		outputs = append(outputs, output)
+	}
+	// resolved now, the context moves on to the next input before the sends complete
+	orderingKey := msgInput.OrderingKey()
+	if gi.context.orderingKey != nil {
+		orderingKey = *gi.context.orderingKey
+		gi.context.orderingKey = nil
+	}
 
-	// If the function had an output and the user has specified an output topic, the output needs to be sent to the
+	// If the function had outputs and the user has specified an output topic, they need to be sent to the
 	// assigned output topic.
-	if output != nil && gi.context.instanceConf.funcDetails.Sink.Topic != "" {
-		asyncMsg := pulsar.ProducerMessage{
-			Payload: output,
-		}
-		// Dispatch an async send for the message with callback in case of error.
-		gi.producer.SendAsync(context.Background(), &asyncMsg,
-			func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
-				// Callback after message async send:
-				// If there was an error, the SDK is entrusted with responding, and we have at-least-once delivery
-				// semantics, ensure we nack so someone else can get it, in case we are the only handler. Then mark
-				// exception and fail out.
-				if err != nil {
-					if autoAck && atLeastOnce {
-						gi.nackInputMessage(msgInput)
-					}
-					gi.stats.incrTotalSysExceptions(err)
-					log.Fatal(err)
-				}
-				// Otherwise the message succeeded. If the SDK is entrusted with responding and we are using
-				// atLeastOnce delivery semantics, ack the message.
-				if autoAck && atLeastOnce {
-					gi.ackInputMessage(msgInput)
-				}
-				gi.stats.incrTotalProcessedSuccessfully()
-			},
-		)
+	if len(outputs) > 0 && gi.context.instanceConf.funcDetails.Sink.Topic != "" {
+		if gi.aggregator != nil {
+			// responded to once the combined message is published
+			gi.aggregator.add(msgInput, outputs)
+			return
+		}
+		gi.publishOutputs(msgInput, outputs, orderingKey)
 		return
 	}
 
 	// No output from the function or no output topic. Ack if we need to and mark the success before rturning.
+	gi.respondResult(msgInput, nil)
+}
+
+// publishOutputs dispatches an async send for each output, with orderingKey,
+// and responds to the input once all of them are accounted for. Outputs
+// following a failed send are not emitted.
+func (gi *goInstance) publishOutputs(msgInput pulsar.Message, outputs [][]byte, orderingKey string) {
+	var mu sync.Mutex
+	remaining := len(outputs)
+	var sendErr error
+	// done records the outcome of n outputs and responds once none are left
+	done := func(n int, err error) {
+		mu.Lock()
+		remaining -= n
+		if err != nil && sendErr == nil {
+			sendErr = err
+		}
+		finished, result := remaining == 0, sendErr
+		mu.Unlock()
+		if finished {
+			gi.respondResult(msgInput, result)
+		}
+	}
+
+	for i, output := range outputs {
+		mu.Lock()
+		failed := sendErr != nil
+		mu.Unlock()
+		if failed {
+			done(len(outputs)-i, nil)
+			return
+		}
+		asyncMsg := pulsar.ProducerMessage{
+			Payload:     output,
+			EventTime:   gi.getOutputEventTime(msgInput),
+			OrderingKey: orderingKey,
+		}
+		if gi.context.instanceConf.propagateTraceContext {
+			asyncMsg.Properties = traceContextOf(msgInput).inject(nil)
+		}
+		gi.sendToSink(&asyncMsg, func(err error) {
+			done(1, err)
+		})
+	}
+}
+
+func (gi *goInstance) respondResult(msgInput pulsar.Message, err error) {
+	atLeastOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATLEAST_ONCE
+	autoAck := gi.context.instanceConf.funcDetails.AutoAck
+
+	// If there was an error, the SDK is entrusted with responding, and we have at-least-once delivery
+	// semantics, ensure we nack so someone else can get it, in case we are the only handler.
+	if err != nil {
+		if gi.deadLetterOversizedOutput(msgInput, err) {
+			return
+		}
+		gi.handleSendFailure(msgInput, err)
+		return
+	}
+	// Otherwise all outputs succeeded. If the SDK is entrusted with responding and we are using
+	// atLeastOnce delivery semantics, ack the message.
 	if autoAck && atLeastOnce {
 		gi.ackInputMessage(msgInput)
 	}
@@ -442,22 +982,57 @@ func (gi *goInstance) ackInputMessage(inputMessage pulsar.Message) {
 	gi.respondMessage(inputMessage, true)
 }
 
+// ackWithResponse acks inputMessage in the background. Waiting for the broker
+// to confirm the ack would hold up the process loop for a round trip per
+// message, and would never return when called from a send callback, which runs
+// on the connection the confirmation comes back on.
+func (gi *goInstance) ackWithResponse(consumer pulsar.Consumer, inputMessage pulsar.Message) {
+	gi.pendingAcks.Add(1)
+	go func() {
+		defer gi.pendingAcks.Done()
+		if err := consumer.Ack(inputMessage); err != nil {
+			log.Errorf("failed to ack message ID %s: %v", messageIDStr(inputMessage), err)
+			gi.stats.incrTotalSysExceptions(err)
+			return
+		}
+		gi.stats.incrTotalAcks()
+	}()
+}
+
 func (gi *goInstance) nackInputMessage(inputMessage pulsar.Message) {
 	gi.respondMessage(inputMessage, false)
 }
 
 func (gi *goInstance) respondMessage(inputMessage pulsar.Message, ack bool) {
-	topicName, err := ParseTopicName(inputMessage.Topic())
+	if gi.inFlight != nil {
+		gi.inFlight.remove(inputMessage)
+	}
+	consumer, err := gi.getConsumer(inputMessage)
 	if err != nil {
 		log.Errorf("unable respond to message ID %s - invalid topic: %v", messageIDStr(inputMessage), err)
 		return
 	}
-	// consumers are indexed by topic name only (no partition)
+	if ack && gi.context.instanceConf.ackWithResponse {
+		gi.ackWithResponse(consumer, inputMessage)
+		return
+	}
 	if ack {
-		gi.consumers[topicName.NameWithoutPartition()].Ack(inputMessage)
+		consumer.Ack(inputMessage)
+		gi.stats.incrTotalAcks()
 		return
 	}
-	gi.consumers[topicName.NameWithoutPartition()].Nack(inputMessage)
+	consumer.Nack(inputMessage)
+	gi.stats.incrTotalNacks()
+}
+
+// getConsumer returns the consumer the message was received from
+func (gi *goInstance) getConsumer(inputMessage pulsar.Message) (pulsar.Consumer, error) {
+	topicName, err := ParseTopicName(inputMessage.Topic())
+	if err != nil {
+		return nil, err
+	}
+	// consumers are indexed by topic name only (no partition)
+	return gi.consumers[topicName.NameWithoutPartition()], nil
 }
 
 func getIdleTimeout(timeoutMilliSecond time.Duration) time.Duration {
@@ -469,18 +1044,24 @@ func getIdleTimeout(timeoutMilliSecond time.Duration) time.Duration {
 
 func (gi *goInstance) setupLogHandler() error {
 	if gi.context.instanceConf.funcDetails.GetLogTopic() != "" {
-		gi.context.logAppender = NewLogAppender(
-			gi.client, //pulsar client
-			gi.context.instanceConf.funcDetails.GetLogTopic(), //log topic
-			getDefaultSubscriptionName(gi.context.instanceConf.funcDetails.Tenant, //fqn
-				gi.context.instanceConf.funcDetails.Namespace,
-				gi.context.instanceConf.funcDetails.Name),
-		)
+		gi.context.logAppender = gi.newLogAppender()
 		return gi.context.logAppender.Start()
 	}
 	return nil
 }
 
+func (gi *goInstance) newLogAppender() *LogAppender {
+	logAppender := NewLogAppender(
+		gi.client, //pulsar client
+		gi.context.instanceConf.funcDetails.GetLogTopic(), //log topic
+		getDefaultSubscriptionName(gi.context.instanceConf.funcDetails.Tenant, //fqn
+			gi.context.instanceConf.funcDetails.Namespace,
+			gi.context.instanceConf.funcDetails.Name),
+	)
+	logAppender.compressionType = getCompressionType(gi.context.instanceConf.logTopicCompression)
+	return logAppender
+}
+
 func (gi *goInstance) addLogTopicHandler() {
 	// Clear StrEntry regardless gi.context.logAppender is set or not
 	defer func() {
@@ -505,11 +1086,54 @@ func (gi *goInstance) closeLogTopic() {
 	gi.context.logAppender = nil
 }
 
+// flushProducers publishes the outputs still buffered or pending, so that
+// their inputs are acked before the instance closes
+func (gi *goInstance) flushProducers() {
+	if gi.aggregator != nil {
+		gi.aggregator.flush()
+	}
+	if gi.producer != nil {
+		if err := gi.producer.Flush(); err != nil {
+			log.Errorf("failed to flush the producer: %v", err)
+		}
+	}
+	gi.derivedProducersMu.Lock()
+	defer gi.derivedProducersMu.Unlock()
+	for key, derived := range gi.derivedProducers {
+		if err := derived.producer.Flush(); err != nil {
+			log.Errorf("failed to flush the producer for derived topic %s: %v", key.topic, err)
+		}
+	}
+}
+
 func (gi *goInstance) close() {
 	log.Info("closing go instance...")
+	if gi.stopReceiving != nil {
+		gi.stopReceiving()
+	}
+	if gi.stopTimers != nil {
+		gi.stopTimers()
+	}
+	if gi.stopRetries != nil {
+		gi.stopRetries()
+	}
+	if gi.aggregator != nil {
+		gi.aggregator.flush()
+	}
+	// the outputs still being sent need the producer, the retries
+	// stopped above are not sent again
+	gi.waitPendingSends()
 	if gi.producer != nil {
 		gi.producer.Close()
 	}
+	gi.closeDeadLetterProducer()
+	gi.derivedProducersMu.Lock()
+	for _, derived := range gi.derivedProducers {
+		derived.producer.Close()
+	}
+	gi.derivedProducersMu.Unlock()
+	// the acks waiting for the broker to confirm them need their consumer
+	gi.pendingAcks.Wait()
 	if gi.consumers != nil {
 		for _, consumer := range gi.consumers {
 			consumer.Close()
@@ -523,13 +1147,84 @@ func (gi *goInstance) close() {
 func (gi *goInstance) healthCheck() *pb.HealthCheckResult {
 	now := time.Now()
 	gi.lastHealthCheckTS = now.UnixNano()
-	healthCheckResult := pb.HealthCheckResult{Success: true}
+	healthCheckResult := pb.HealthCheckResult{Success: true, Ready: gi.isReady()}
 	return &healthCheckResult
 }
 
+// isReady reports whether the client is connected, the consumers are
+// subscribed and the instance is about to start processing messages.
+func (gi *goInstance) isReady() bool {
+	return gi.ready.Load()
+}
+
+// setupStartPaused pauses message consumption ahead of subscribing when
+// startPaused is set, nothing is processed until Resume is called
+func (gi *goInstance) setupStartPaused() {
+	if gi.context.instanceConf.startPaused {
+		log.Info("starting paused, messages are consumed once resumed")
+		gi.pause()
+	}
+}
+
+func (gi *goInstance) pause() {
+	if gi.paused.CompareAndSwap(false, true) {
+		log.Info("pausing message consumption")
+		gi.notifyPauseStateChanged()
+	}
+}
+
+func (gi *goInstance) resume() {
+	if gi.paused.CompareAndSwap(true, false) {
+		log.Info("resuming message consumption")
+		gi.notifyPauseStateChanged()
+	}
+}
+
+// seekToMessageID resets the subscription of all consumers to msgID, with
+// message consumption paused until every consumer has been reset. Messages
+// already taken off the consumers before the seek are still processed. The
+// message at msgID itself is only processed with startMessageIDInclusive.
+// It is only called once the instance is ready, gi.consumers no longer
+// changes by then.
+func (gi *goInstance) seekToMessageID(msgID pulsar.MessageID) error {
+	if !gi.isPaused() {
+		gi.pause()
+		defer gi.resume()
+	}
+	gi.setSeekBoundary(msgID)
+	// the consumer of the retry letter topic is registered twice
+	seeked := make(map[pulsar.Consumer]bool, len(gi.consumers))
+	for topic, consumer := range gi.consumers {
+		if seeked[consumer] {
+			continue
+		}
+		seeked[consumer] = true
+		log.Infof("seeking the subscription of %s to message ID %s", topic, msgID)
+		if err := consumer.Seek(msgID); err != nil {
+			log.Errorf("failed to seek the subscription of %s to message ID %s: %v", topic, msgID, err)
+			return fmt.Errorf("failed to seek the subscription of %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+func (gi *goInstance) isPaused() bool {
+	return gi.paused.Load()
+}
+
+// notifyPauseStateChanged wakes up the message loop so it picks up the new
+// paused state
+func (gi *goInstance) notifyPauseStateChanged() {
+	select {
+	case gi.pauseStateChanged <- struct{}{}:
+	default:
+	}
+}
+
 func (gi *goInstance) getFunctionStatus() *pb.FunctionStatus {
 	status := pb.FunctionStatus{}
 	status.Running = true
+	status.Paused = gi.isPaused()
 	totalReceived := gi.getTotalReceived()
 	totalProcessedSuccessfully := gi.getTotalProcessedSuccessfully()
 	totalUserExceptions := gi.getTotalUserExceptions()
@@ -542,21 +1237,17 @@ func (gi *goInstance) getFunctionStatus() *pb.FunctionStatus {
 	status.NumUserExceptions = int64(totalUserExceptions)
 	status.InstanceId = strconv.Itoa(gi.context.instanceConf.instanceID)
 
+	latestUserExceptions, latestSysExceptions := gi.stats.latestExceptions()
 	status.NumUserExceptions = int64(totalUserExceptions)
-	for _, exPair := range gi.stats.latestUserException {
-		toAdd := pb.FunctionStatus_ExceptionInformation{}
-		toAdd.ExceptionString = exPair.exception.Error()
-		toAdd.MsSinceEpoch = exPair.timestamp
-		status.LatestUserExceptions = append(status.LatestUserExceptions, &toAdd)
+	for _, exPair := range latestUserExceptions {
+		status.LatestUserExceptions = append(status.LatestUserExceptions, exceptionInformation(exPair))
 	}
 
 	status.NumSystemExceptions = int64(totalSysExceptions)
-	for _, exPair := range gi.stats.latestSysException {
-		toAdd := pb.FunctionStatus_ExceptionInformation{}
-		toAdd.ExceptionString = exPair.exception.Error()
-		toAdd.MsSinceEpoch = exPair.timestamp
-		status.LatestSystemExceptions = append(status.LatestSystemExceptions, &toAdd)
+	for _, exPair := range latestSysExceptions {
+		status.LatestSystemExceptions = append(status.LatestSystemExceptions, exceptionInformation(exPair))
 	}
+	status.LastError = gi.lastErrorInformation()
 	status.AverageLatency = float64(avgProcessLatencyMs)
 	status.LastInvocationTime = int64(lastInvocation)
 	return &status
@@ -617,14 +1308,14 @@ func (gi *goInstance) getMatchingMetricFunc() func(lbl *prometheus_client.LabelP
 	return matchMetricFunc
 }
 
-func (gi *goInstance) getMatchingMetricFromRegistry(metricName string) prometheus_client.Metric {
+func (gi *goInstance) getMatchingMetricFromRegistry(metricName string) *prometheus_client.Metric {
 	filteredMetricFamilies := gi.getFilteredMetricFamilies(metricName)
 	if len(filteredMetricFamilies) == 0 {
-		return prometheus_client.Metric{}
+		return &prometheus_client.Metric{}
 	}
 	metricFunc := gi.getMatchingMetricFunc()
 	matchingMetric := getFirstMatch(filteredMetricFamilies[0].Metric, metricFunc)
-	return *matchingMetric
+	return matchingMetric
 }
 
 func (gi *goInstance) getFilteredMetricFamilies(metricName string) []*prometheus_client.MetricFamily {
@@ -645,34 +1336,34 @@ func (gi *goInstance) getFilteredMetricFamilies(metricName string) []*prometheus
 
 func (gi *goInstance) getTotalReceived() float32 {
 	// "pulsar_function_" + "received_total", NewGaugeVec.
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalReceived)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalReceived)
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalProcessedSuccessfully() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalSuccessfullyProcessed)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalSuccessfullyProcessed)
 	// "pulsar_function_" + "processed_successfully_total", NewGaugeVec.
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalSysExceptions() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalSystemExceptions)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalSystemExceptions)
 	// "pulsar_function_"+ "system_exceptions_total", NewGaugeVec.
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalUserExceptions() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalUserExceptions)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalUserExceptions)
 	// "pulsar_function_" + "user_exceptions_total", NewGaugeVec
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getAvgProcessLatency() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + ProcessLatencyMs)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + ProcessLatencyMs)
 	// "pulsar_function_" + "process_latency_ms", SummaryVec.
 	count := metric.GetSummary().SampleCount
 	sum := metric.GetSummary().SampleSum
@@ -683,43 +1374,141 @@ func (gi *goInstance) getAvgProcessLatency() float32 {
 }
 
 func (gi *goInstance) getLastInvocation() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + LastInvocation)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + LastInvocation)
 	// "pulsar_function_" + "last_invocation", GaugeVec.
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalProcessedSuccessfully1min() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalSuccessfullyProcessed1min)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalSuccessfullyProcessed1min)
 	// "pulsar_function_" + "processed_successfully_total_1min", GaugeVec.
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalSysExceptions1min() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalSystemExceptions1min)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalSystemExceptions1min)
 	// "pulsar_function_" + "system_exceptions_total_1min", GaugeVec
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalUserExceptions1min() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalUserExceptions1min)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalUserExceptions1min)
 	// "pulsar_function_" + "user_exceptions_total_1min", GaugeVec
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
 func (gi *goInstance) getTotalReceived1min() float32 {
-	metric := gi.getMatchingMetricFromRegistry(PulsarFunctionMetricsPrefix + TotalReceived1min)
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalReceived1min)
 	// "pulsar_function_" +  "received_total_1min", GaugeVec
 	val := metric.GetGauge().Value
 	return float32(*val)
 }
 
+func (gi *goInstance) getTotalRestartRequests() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalRestartRequests)
+	// "pulsar_function_" + "restart_requests_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalAcks() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalAcks)
+	// "pulsar_function_" + "acks_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalNacks() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalNacks)
+	// "pulsar_function_" + "nacks_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalThrottled() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalThrottled)
+	// "pulsar_function_" + "throttled_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalDroppedPoisonMessages() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalDroppedPoisonMessages)
+	// "pulsar_function_" + "dropped_poison_messages_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalDeserializationErrors() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalDeserializationErrors)
+	// "pulsar_function_" + "deserialization_errors_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalOversizedOutputs() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalOversizedOutputs)
+	// "pulsar_function_" + "oversized_outputs_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalExpiredMessages() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalExpiredMessages)
+	// "pulsar_function_" + "expired_messages_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalDuplicateMessages() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalDuplicateMessages)
+	// "pulsar_function_" + "duplicate_messages_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalAuthTokenRefreshes() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalAuthTokenRefreshes)
+	// "pulsar_function_" + "auth_token_refreshes_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalRetries() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalRetries)
+	// "pulsar_function_" + "retries_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getClientConnectionsActive() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + ClientConnectionsActive)
+	// "pulsar_function_" + "client_connections_active", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalClientLookups() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalClientLookups)
+	// "pulsar_function_" + "client_lookups_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
+func (gi *goInstance) getTotalUnknownUserConfigKeys() float32 {
+	metric := gi.getMatchingMetricFromRegistry(metricsPrefix + TotalUnknownUserConfigKeys)
+	// "pulsar_function_" + "unknown_user_config_keys_total", GaugeVec
+	val := metric.GetGauge().Value
+	return float32(*val)
+}
+
 func (gi *goInstance) getUserMetricsMap() map[string]float64 {
 	userMetricMap := map[string]float64{}
-	filteredMetricFamilies := gi.getFilteredMetricFamilies(PulsarFunctionMetricsPrefix + UserMetric)
+	filteredMetricFamilies := gi.getFilteredMetricFamilies(metricsPrefix + UserMetric)
 	if len(filteredMetricFamilies) == 0 {
 		return userMetricMap
 	}
@@ -20,10 +20,16 @@
 package pf
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/apache/pulsar-client-go/pulsar"
+
 	"github.com/apache/pulsar/pulsar-function-go/conf"
 	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 )
@@ -39,34 +45,177 @@ type instanceConf struct {
 	port                        int
 	clusterName                 string
 	pulsarServiceURL            string
+	webServiceURL               string
+	backlogCacheInterval        time.Duration
+	startupWaitForSubscription  bool
+	startupBacklogThreshold     int64
+	startPaused                 bool
+	generateInterval            time.Duration
 	killAfterIdle               time.Duration
+	maxMessagesToProcess        int64
 	expectedHealthCheckInterval int32
 	metricsPort                 int
+	debugPort                   int
+	continueWithoutMetrics      bool
+	metricsOpenMetrics          bool
+	metricsStatusEndpoint       bool
+	clientStatsInterval         time.Duration
+	consumerStatsInterval       time.Duration
+	metricsNamespace            string
+	secretsDirectory            string
+	allowSinkInputOverlap       bool
+	failOnUnknownUserConfigKeys bool
 	authPlugin                  string
 	authParams                  string
+	authTokenRefreshMargin      time.Duration
 	tlsTrustCertsPath           string
 	tlsAllowInsecure            bool
 	tlsHostnameVerification     bool
+	tlsMinVersion               uint16
+	tlsCipherSuites             []uint16
+	connectionTimeout           time.Duration
+	keepAliveInterval           time.Duration
+	operationTimeout            time.Duration
+	connectionsPerBroker        int
+	enableChunking              bool
+	disableBatching             bool
+	maxPendingChunkedMessage    int
+	autoAckIncompleteChunk      bool
+	readCompacted               bool
+	skipTombstones              bool
+	maxMessageAge               time.Duration
+	ackTimeout                  time.Duration
+	onTimeoutTopic              string
+	ackGroupingTime             time.Duration
+	ackWithResponse             bool
+	batchIndexAckEnabled        bool
+	subscriptionMode            pulsar.SubscriptionMode
+	replicateSubscription       bool
+	consumptionMode             string
+	startMessageRollback        time.Duration
+	startMessageIDInclusive     bool
+	consumerFailoverDelay       time.Duration
+	autoUpdatePartitions        bool
+	partitionsUpdateInterval    time.Duration
+	logTopicCompression         pb.CompressionType
+	logTopicMetricsInterval     time.Duration
+	inputSchemaDefinitions      map[string]string
+	inputDeadLetterTopics       map[string]string
+	inputDeadLetterRoutes       []deadLetterRoute
+	outputSchemaDefinition      string
+	disableSchemaCreation       bool
+	allowAutoTopicCreation      bool
+	checkSchemaCompatibility    bool
+	requireInputTopicsExist     bool
+	deadLetterMaxErrorLength    int
+	deadLetterOversized         bool
+	retryLetterTopic            string
+	retryBackoffInitial         time.Duration
+	retryBackoffMax             time.Duration
+	negativeAckBackoffMin       time.Duration
+	negativeAckBackoffMax       time.Duration
+	onProcessError              string
+	onSendFailure               string
+	maxSendRetries              int
+	deserializationLogFirst     int
+	deserializationLogEvery     int
+	logPayloadOnError           bool
+	maxPayloadPreviewBytes      int
+	payloadPreviewHex           bool
+	outputEventTimeStrategy     string
+	propagateTraceContext       bool
+	aggregationMaxRecords       int
+	aggregationMaxDelay         time.Duration
+	deduplicationWindow         time.Duration
+	deduplicationMaxKeys        int
+	sinkPartitionRouting        string
+	sinkPartition               int
+	producerHashingScheme       pulsar.HashingScheme
+	maxPendingPublishes         int
+	producerIdleTimeout         time.Duration
+	maxProcessingRate           float64
 }
 
 func newInstanceConfWithConf(cfg *conf.Conf) *instanceConf {
-	inputSpecs := make(map[string]*pb.ConsumerSpec)
+	// the same topic may be given by several sources, the more specific one wins:
+	// the specs map over the single topic over the topics list
+	inputSpecs := newInputSpecs()
+	for _, topic := range strings.Split(cfg.SourceTopicsList, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			inputSpecs.add(topic, &pb.ConsumerSpec{
+				SchemaType: cfg.SourceSchemaType,
+				ReceiverQueueSize: &pb.ConsumerSpec_ReceiverQueueSize{
+					Value: cfg.ReceiverQueueSize,
+				},
+			})
+		}
+	}
 	// for backward compatibility
 	if cfg.SourceSpecTopic != "" {
-		inputSpecs[cfg.SourceSpecTopic] = &pb.ConsumerSpec{
+		inputSpecs.add(cfg.SourceSpecTopic, &pb.ConsumerSpec{
 			SchemaType:     cfg.SourceSchemaType,
 			IsRegexPattern: cfg.IsRegexPatternSubscription,
 			ReceiverQueueSize: &pb.ConsumerSpec_ReceiverQueueSize{
 				Value: cfg.ReceiverQueueSize,
 			},
-		}
+		})
+	}
+	// sorted so that the spelling kept for a topic given twice does not depend on the map order
+	specTopics := make([]string, 0, len(cfg.SourceInputSpecs))
+	for topic := range cfg.SourceInputSpecs {
+		specTopics = append(specTopics, topic)
 	}
-	for topic, value := range cfg.SourceInputSpecs {
+	sort.Strings(specTopics)
+	var inputDeadLetterTopics map[string]string
+	// the inputs named exactly are matched ahead of the patterns
+	var exactDeadLetterRoutes, patternDeadLetterRoutes []deadLetterRoute
+	for _, topic := range specTopics {
 		spec := &pb.ConsumerSpec{}
-		if err := json.Unmarshal([]byte(value), spec); err != nil {
+		if err := json.Unmarshal([]byte(cfg.SourceInputSpecs[topic]), spec); err != nil {
 			panic(fmt.Sprintf("Failed to unmarshal consume specs: %v", err))
 		}
-		inputSpecs[topic] = spec
+		inputSpecs.add(topic, spec)
+		// not a field of ConsumerSpec, read from the same JSON
+		var deadLetter struct {
+			DeadLetterTopic string `json:"deadLetterTopic"`
+		}
+		if err := json.Unmarshal([]byte(cfg.SourceInputSpecs[topic]), &deadLetter); err != nil {
+			panic(fmt.Sprintf("Failed to unmarshal consume specs: %v", err))
+		}
+		if deadLetter.DeadLetterTopic != "" {
+			if inputDeadLetterTopics == nil {
+				inputDeadLetterTopics = make(map[string]string)
+			}
+			inputDeadLetterTopics[topic] = deadLetter.DeadLetterTopic
+			route := deadLetterRoute{topic: topic, deadLetterTopic: deadLetter.DeadLetterTopic}
+			if spec.IsRegexPattern {
+				pattern, err := regexp.Compile(topic)
+				if err != nil {
+					panic(fmt.Sprintf("Invalid input topic pattern %s: %v", topic, err))
+				}
+				route.pattern = pattern
+				patternDeadLetterRoutes = append(patternDeadLetterRoutes, route)
+				continue
+			}
+			if topicName, err := ParseTopicName(topic); err == nil {
+				route.topic = topicName.NameWithoutPartition()
+			}
+			exactDeadLetterRoutes = append(exactDeadLetterRoutes, route)
+		}
+	}
+	var inputSchemaDefinitions map[string]string
+	for topic, definition := range cfg.InputSchemaDefinition {
+		topicName, err := ParseTopicName(topic)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid input schema definition topic %s: %v", topic, err))
+		}
+		if _, err := pulsar.NewAvroSchemaWithValidation(definition, nil); err != nil {
+			panic(fmt.Sprintf("Failed to parse Avro schema definition for topic %s: %v", topic, err))
+		}
+		if inputSchemaDefinitions == nil {
+			inputSchemaDefinitions = make(map[string]string)
+		}
+		inputSchemaDefinitions[topicName.Name] = definition
 	}
 	instanceConf := &instanceConf{
 		instanceID:                  cfg.InstanceID,
@@ -76,9 +225,26 @@ func newInstanceConfWithConf(cfg *conf.Conf) *instanceConf {
 		port:                        cfg.Port,
 		clusterName:                 cfg.ClusterName,
 		pulsarServiceURL:            cfg.PulsarServiceURL,
+		webServiceURL:               cfg.PulsarWebServiceURL,
+		backlogCacheInterval:        time.Duration(cfg.BacklogCacheIntervalMs) * time.Millisecond,
+		startupWaitForSubscription:  cfg.StartupWaitForSubscription,
+		startupBacklogThreshold:     cfg.StartupBacklogThreshold,
+		startPaused:                 cfg.StartPaused,
+		generateInterval:            time.Duration(cfg.GenerateIntervalMs) * time.Millisecond,
 		killAfterIdle:               cfg.KillAfterIdleMs,
+		maxMessagesToProcess:        cfg.MaxMessagesToProcess,
 		expectedHealthCheckInterval: cfg.ExpectedHealthCheckInterval,
 		metricsPort:                 cfg.MetricsPort,
+		debugPort:                   cfg.DebugPort,
+		continueWithoutMetrics:      cfg.ContinueWithoutMetrics,
+		metricsOpenMetrics:          cfg.MetricsOpenMetrics,
+		metricsStatusEndpoint:       cfg.MetricsStatusEndpoint,
+		clientStatsInterval:         time.Duration(cfg.ClientStatsIntervalMs) * time.Millisecond,
+		consumerStatsInterval:       time.Duration(cfg.ConsumerStatsIntervalMs) * time.Millisecond,
+		metricsNamespace:            cfg.MetricsNamespace,
+		secretsDirectory:            cfg.SecretsDirectory,
+		allowSinkInputOverlap:       cfg.AllowSinkInputOverlap,
+		failOnUnknownUserConfigKeys: cfg.FailOnUnknownUserConfigKeys,
 		funcDetails: pb.FunctionDetails{
 			Tenant:               cfg.Tenant,
 			Namespace:            cfg.NameSpace,
@@ -91,7 +257,7 @@ func newInstanceConfWithConf(cfg *conf.Conf) *instanceConf {
 			Parallelism:          cfg.Parallelism,
 			Source: &pb.SourceSpec{
 				SubscriptionType:     pb.SubscriptionType(cfg.SubscriptionType),
-				InputSpecs:           inputSpecs,
+				InputSpecs:           inputSpecs.specs,
 				TimeoutMs:            cfg.TimeoutMs,
 				SubscriptionName:     cfg.SubscriptionName,
 				CleanupSubscription:  cfg.CleanupSubscription,
@@ -112,11 +278,84 @@ func newInstanceConfWithConf(cfg *conf.Conf) *instanceConf {
 			},
 			UserConfig: cfg.UserConfig,
 		},
-		authPlugin:              cfg.ClientAuthenticationPlugin,
-		authParams:              cfg.ClientAuthenticationParameters,
-		tlsTrustCertsPath:       cfg.TLSTrustCertsFilePath,
-		tlsAllowInsecure:        cfg.TLSAllowInsecureConnection,
-		tlsHostnameVerification: cfg.TLSHostnameVerificationEnable,
+		authPlugin:               cfg.ClientAuthenticationPlugin,
+		authParams:               cfg.ClientAuthenticationParameters,
+		authTokenRefreshMargin:   time.Duration(cfg.ClientAuthTokenRefreshMarginMs) * time.Millisecond,
+		tlsTrustCertsPath:        cfg.TLSTrustCertsFilePath,
+		tlsAllowInsecure:         cfg.TLSAllowInsecureConnection,
+		tlsHostnameVerification:  cfg.TLSHostnameVerificationEnable,
+		tlsMinVersion:            parseTLSVersion(cfg.TLSMinVersion),
+		tlsCipherSuites:          parseTLSCipherSuites(cfg.TLSCipherSuites),
+		connectionTimeout:        time.Duration(cfg.ConnectionTimeoutMs) * time.Millisecond,
+		keepAliveInterval:        time.Duration(cfg.KeepAliveIntervalMs) * time.Millisecond,
+		operationTimeout:         time.Duration(cfg.OperationTimeoutMs) * time.Millisecond,
+		connectionsPerBroker:     cfg.ConnectionsPerBroker,
+		enableChunking:           cfg.EnableChunking,
+		disableBatching:          cfg.DisableBatching,
+		maxPendingChunkedMessage: cfg.MaxPendingChunkedMessage,
+		autoAckIncompleteChunk:   cfg.AutoAckIncompleteChunk,
+		readCompacted:            cfg.ReadCompacted,
+		skipTombstones:           cfg.SkipTombstones,
+		maxMessageAge:            time.Duration(cfg.MaxMessageAgeMs) * time.Millisecond,
+		ackTimeout:               time.Duration(cfg.AckTimeoutMs) * time.Millisecond,
+		onTimeoutTopic:           cfg.OnTimeoutTopic,
+		ackGroupingTime:          time.Duration(cfg.AckGroupingTimeMs) * time.Millisecond,
+		ackWithResponse:          cfg.AckWithResponse,
+		batchIndexAckEnabled:     cfg.BatchIndexAckEnabled,
+		subscriptionMode:         parseSubscriptionMode(cfg.SubscriptionMode),
+		replicateSubscription:    cfg.ReplicateSubscriptionState,
+		consumptionMode:          cfg.ConsumptionMode,
+		startMessageRollback:     time.Duration(cfg.StartMessageRollbackDurationMs) * time.Millisecond,
+		startMessageIDInclusive:  cfg.StartMessageIDInclusive,
+		consumerFailoverDelay:    time.Duration(cfg.ConsumerFailoverDelayMs) * time.Millisecond,
+		autoUpdatePartitions:     cfg.AutoUpdatePartitions,
+		partitionsUpdateInterval: time.Duration(cfg.AutoUpdatePartitionsIntervalMs) * time.Millisecond,
+		logTopicCompression:      parseCompressionType(cfg.LogTopicCompression),
+		logTopicMetricsInterval:  time.Duration(cfg.LogTopicMetricsIntervalMs) * time.Millisecond,
+		inputSchemaDefinitions:   inputSchemaDefinitions,
+		inputDeadLetterTopics:    inputDeadLetterTopics,
+		inputDeadLetterRoutes:    append(exactDeadLetterRoutes, patternDeadLetterRoutes...),
+		outputSchemaDefinition:   cfg.OutputSchemaDefinition,
+		disableSchemaCreation:    cfg.DisableSchemaCreation,
+		allowAutoTopicCreation:   cfg.AllowAutoTopicCreation == nil || *cfg.AllowAutoTopicCreation,
+		checkSchemaCompatibility: cfg.CheckSchemaCompatibility,
+		requireInputTopicsExist:  cfg.RequireInputTopicsExist,
+		deadLetterMaxErrorLength: cfg.DeadLetterMaxErrorLength,
+		deadLetterOversized:      cfg.DeadLetterOversizedOutputs,
+		retryLetterTopic:         cfg.RetryLetterTopic,
+		retryBackoffInitial:      time.Duration(cfg.RetryBackoffInitialMs) * time.Millisecond,
+		retryBackoffMax:          time.Duration(cfg.RetryBackoffMaxMs) * time.Millisecond,
+		negativeAckBackoffMin:    time.Duration(cfg.NegativeAckBackoffMinMs) * time.Millisecond,
+		negativeAckBackoffMax:    time.Duration(cfg.NegativeAckBackoffMaxMs) * time.Millisecond,
+		onProcessError:           cfg.OnProcessError,
+		onSendFailure:            cfg.OnSendFailure,
+		maxSendRetries:           cfg.MaxSendRetries,
+		deserializationLogFirst:  cfg.DeserializationErrorLogFirst,
+		deserializationLogEvery:  cfg.DeserializationErrorLogEvery,
+		logPayloadOnError:        cfg.LogPayloadOnError,
+		maxPayloadPreviewBytes:   cfg.MaxPayloadPreviewBytes,
+		payloadPreviewHex:        cfg.PayloadPreviewHex,
+		outputEventTimeStrategy:  cfg.OutputEventTimeStrategy,
+		propagateTraceContext:    cfg.PropagateTraceContext,
+		aggregationMaxRecords:    cfg.OutputAggregationMaxRecords,
+		aggregationMaxDelay:      time.Duration(cfg.OutputAggregationMaxDelayMs) * time.Millisecond,
+		deduplicationWindow:      time.Duration(cfg.DeduplicationWindowMs) * time.Millisecond,
+		deduplicationMaxKeys:     cfg.DeduplicationMaxKeys,
+		sinkPartitionRouting:     cfg.SinkPartitionRouting,
+		sinkPartition:            cfg.SinkPartition,
+		producerHashingScheme:    parseHashingScheme(cfg.ProducerHashingScheme),
+		maxPendingPublishes:      cfg.MaxPendingPublishes,
+		producerIdleTimeout:      time.Duration(cfg.ProducerIdleTimeoutMs) * time.Millisecond,
+		maxProcessingRate:        cfg.MaxProcessingRate,
+	}
+
+	nonPersistentInput := findNonPersistentInput(&instanceConf.funcDetails)
+	if nonPersistentInput != "" && instanceConf.readCompacted {
+		panic("readCompacted is not supported with the non-persistent input " + nonPersistentInput + ".")
+	}
+
+	if instanceConf.replicateSubscription && instanceConf.subscriptionMode == pulsar.NonDurable {
+		panic("replicateSubscriptionState can only be enabled for Durable subscriptions.")
 	}
 
 	if instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_EFFECTIVELY_ONCE {
@@ -133,9 +372,403 @@ func newInstanceConfWithConf(cfg *conf.Conf) *instanceConf {
 			" Please refer to PIP: https://github.com/apache/pulsar/issues/15560")
 	}
 
+	if instanceConf.outputSchemaDefinition != "" {
+		if _, err := newOutputSchema(instanceConf.funcDetails.Sink.SchemaType, instanceConf.outputSchemaDefinition,
+			nil); err != nil {
+			panic(fmt.Sprintf("Invalid output schema definition: %v", err))
+		}
+	}
+
+	if instanceConf.enableChunking && !instanceConf.disableBatching {
+		panic("Chunking and batching cannot be enabled at the same time on the producer." +
+			" Please set disableBatching to true when enableChunking is true.")
+	}
+
+	if instanceConf.retryLetterTopic != "" {
+		validateRetryLetterTopic(instanceConf)
+	}
+
+	if instanceConf.retryBackoffMax > 0 && instanceConf.retryBackoffInitial > instanceConf.retryBackoffMax {
+		panic("retryBackoffInitialMs must not be greater than retryBackoffMaxMs.")
+	}
+
+	nackBackoffMin, nackBackoffMax := instanceConf.negativeAckBackoffMin, instanceConf.negativeAckBackoffMax
+	if (nackBackoffMin != 0 || nackBackoffMax != 0) && (nackBackoffMin <= 0 || nackBackoffMin > nackBackoffMax) {
+		panic("negativeAckBackoffMinMs must be positive and not greater than negativeAckBackoffMaxMs.")
+	}
+
+	switch instanceConf.outputEventTimeStrategy {
+	case "", OutputEventTimeNone, OutputEventTimePassthrough, OutputEventTimeProcessing:
+	default:
+		panic("Invalid output event time strategy " + instanceConf.outputEventTimeStrategy +
+			", must be one of passthrough, processing or none.")
+	}
+
+	if instanceConf.debugPort < 0 {
+		panic("debugPort must not be negative.")
+	}
+	if instanceConf.debugPort != 0 &&
+		(instanceConf.debugPort == instanceConf.metricsPort || instanceConf.debugPort == instanceConf.port) {
+		panic(fmt.Sprintf("debugPort %d conflicts with the instance port or the metrics port.", instanceConf.debugPort))
+	}
+
+	switch instanceConf.consumptionMode {
+	case "", ConsumptionModeListener, ConsumptionModeReceive:
+	default:
+		panic("Invalid consumption mode " + instanceConf.consumptionMode + ", must be one of listener or receive.")
+	}
+
+	switch instanceConf.sinkPartitionRouting {
+	case "", SinkRoutingRoundRobin, SinkRoutingKeyBased:
+	case SinkRoutingSinglePartition:
+		if instanceConf.sinkPartition < 0 {
+			panic(fmt.Sprintf("Invalid sink partition %d, must not be negative.", instanceConf.sinkPartition))
+		}
+	default:
+		panic("Invalid sink partition routing " + instanceConf.sinkPartitionRouting +
+			", must be one of roundRobin, keyBased or singlePartition.")
+	}
+
+	if instanceConf.clientStatsInterval < 0 {
+		panic("clientStatsIntervalMs must not be negative.")
+	}
+	if instanceConf.consumerStatsInterval < 0 {
+		panic("consumerStatsIntervalMs must not be negative.")
+	}
+
+	if instanceConf.metricsNamespace != "" && !metricsNamePattern.MatchString(instanceConf.metricsNamespace) {
+		panic("Invalid metrics namespace " + instanceConf.metricsNamespace +
+			", must match the Prometheus metric name pattern " + metricsNamePattern.String() + ".")
+	}
+
+	if instanceConf.connectionTimeout < 0 || instanceConf.keepAliveInterval < 0 || instanceConf.operationTimeout < 0 {
+		panic("connectionTimeoutMs, keepAliveIntervalMs and operationTimeoutMs must not be negative.")
+	}
+
+	if instanceConf.connectionsPerBroker < 0 || instanceConf.connectionsPerBroker > maxConnectionsPerBroker {
+		panic(fmt.Sprintf("connectionsPerBroker must be between 0 and %d.", maxConnectionsPerBroker))
+	}
+
+	if instanceConf.startupBacklogThreshold < 0 {
+		panic("startupBacklogThreshold must not be negative.")
+	}
+	if instanceConf.startupBacklogThreshold > 0 &&
+		(!instanceConf.startupWaitForSubscription || instanceConf.webServiceURL == "") {
+		panic("startupBacklogThreshold requires startupWaitForSubscription and pulsarWebServiceURL to be set.")
+	}
+	if instanceConf.generateInterval < 0 {
+		panic("generateIntervalMs must not be negative.")
+	}
+
+	if instanceConf.startupBacklogThreshold > 0 && instanceConf.startPaused {
+		panic("startupBacklogThreshold cannot be used with startPaused, the backlog is not consumed until resumed.")
+	}
+
+	if instanceConf.checkSchemaCompatibility && instanceConf.webServiceURL == "" {
+		panic("checkSchemaCompatibility requires pulsarWebServiceURL to be set.")
+	}
+	if instanceConf.requireInputTopicsExist && instanceConf.webServiceURL == "" {
+		panic("requireInputTopicsExist requires pulsarWebServiceURL to be set.")
+	}
+	if instanceConf.hasAutoConsumeInput() && instanceConf.webServiceURL == "" {
+		panic("The " + SchemaTypeAutoConsume + " schema type requires pulsarWebServiceURL to be set, " +
+			"to look up the schemas of the messages.")
+	}
+	for topic, spec := range instanceConf.funcDetails.Source.GetInputSpecs() {
+		if spec.SchemaType != SchemaTypeAutoConsume || spec.IsRegexPattern {
+			continue
+		}
+		if topicName, err := ParseTopicName(topic); err == nil {
+			if _, ok := instanceConf.inputSchemaDefinitions[topicName.Name]; ok {
+				panic("Input topic " + topic + " cannot have both an inputSchemaDefinition and the " +
+					SchemaTypeAutoConsume + " schema type.")
+			}
+		}
+	}
+
+	switch instanceConf.onProcessError {
+	case "", OnProcessErrorRetry, OnProcessErrorSkip, OnProcessErrorFail:
+	case OnProcessErrorDeadLetter:
+		if !instanceConf.hasDeadLetterTopics() {
+			panic("onProcessError dlt requires a deadLetterTopic.")
+		}
+	default:
+		panic("Invalid onProcessError " + instanceConf.onProcessError + ", must be one of retry, skip, dlt or fail.")
+	}
+
+	switch instanceConf.onSendFailure {
+	case "", OnSendFailureRetry, OnSendFailureNack, OnSendFailureFail:
+	default:
+		panic("Invalid onSendFailure " + instanceConf.onSendFailure + ", must be one of retry, nack or fail.")
+	}
+	if instanceConf.maxSendRetries < 0 {
+		panic("maxSendRetries must not be negative.")
+	}
+
+	if instanceConf.deadLetterOversized && !instanceConf.hasDeadLetterTopics() {
+		panic("deadLetterOversizedOutputs requires a deadLetterTopic.")
+	}
+
+	if instanceConf.deserializationLogFirst < 0 || instanceConf.deserializationLogEvery < 0 {
+		panic("deserializationErrorLogFirst and deserializationErrorLogEvery must not be negative.")
+	}
+
+	if instanceConf.startMessageRollback < 0 {
+		panic("startMessageRollbackDurationMs must not be negative.")
+	}
+	if instanceConf.startMessageRollback > 0 {
+		for topic, spec := range instanceConf.funcDetails.Source.GetInputSpecs() {
+			if spec.IsRegexPattern {
+				panic("startMessageRollbackDurationMs is not supported with the topics pattern " + topic +
+					", the client cannot seek a pattern subscription.")
+			}
+		}
+	}
+
+	if instanceConf.consumerFailoverDelay < 0 {
+		panic("consumerFailoverDelayMs must not be negative.")
+	}
+
+	if instanceConf.maxPayloadPreviewBytes < 0 {
+		panic("maxPayloadPreviewBytes must not be negative.")
+	}
+	if instanceConf.maxMessagesToProcess < 0 {
+		panic("maxMessagesToProcess must not be negative.")
+	}
+	if instanceConf.authTokenRefreshMargin < 0 {
+		panic("clientAuthTokenRefreshMarginMs must not be negative.")
+	}
+	if instanceConf.logTopicMetricsInterval < 0 {
+		panic("logTopicMetricsIntervalMs must not be negative.")
+	}
+	if instanceConf.maxPendingPublishes < 0 {
+		panic("maxPendingPublishes must not be negative.")
+	}
+	if instanceConf.aggregationMaxRecords < 0 || instanceConf.aggregationMaxDelay < 0 {
+		panic("outputAggregationMaxRecords and outputAggregationMaxDelayMs must not be negative.")
+	}
+	if instanceConf.deduplicationWindow < 0 || instanceConf.deduplicationMaxKeys < 0 {
+		panic("deduplicationWindowMs and deduplicationMaxKeys must not be negative.")
+	}
+	if instanceConf.ackTimeout < 0 {
+		panic("ackTimeoutMs must not be negative.")
+	}
+	// the ack timeout of the source applies unless ackTimeoutMs overrides it
+	if instanceConf.ackTimeout == 0 {
+		instanceConf.ackTimeout = time.Duration(instanceConf.funcDetails.Source.TimeoutMs) * time.Millisecond
+	}
+	if instanceConf.onTimeoutTopic != "" && instanceConf.ackTimeout == 0 {
+		panic("onTimeoutTopic requires ackTimeoutMs or timeoutMs to be set.")
+	}
+	if instanceConf.maxMessageAge < 0 {
+		panic("maxMessageAgeMs must not be negative.")
+	}
+	if instanceConf.producerIdleTimeout < 0 {
+		panic("producerIdleTimeoutMs must not be negative.")
+	}
+
+	if instanceConf.maxProcessingRate < 0 {
+		panic("maxProcessingRate must not be negative.")
+	}
+
+	if instanceConf.ackGroupingTime < 0 {
+		panic("ackGroupingTimeMs must not be negative.")
+	}
+	if instanceConf.ackWithResponse && instanceConf.ackGroupingTime > 0 {
+		panic("ackGroupingTimeMs cannot be set with ackWithResponse, the acks are not grouped when waiting " +
+			"for the broker to confirm them.")
+	}
+
+	if instanceConf.autoUpdatePartitions && instanceConf.partitionsUpdateInterval <= 0 {
+		panic("autoUpdatePartitionsIntervalMs must be positive when autoUpdatePartitions is enabled.")
+	}
+
+	if instanceConf.readCompacted &&
+		instanceConf.funcDetails.Source.SubscriptionType != pb.SubscriptionType_FAILOVER {
+		panic("readCompacted is only supported with Exclusive or Failover subscriptions," +
+			" got " + instanceConf.funcDetails.Source.SubscriptionType.String() + ".")
+	}
+
+	if !instanceConf.allowSinkInputOverlap {
+		if topic := findSinkInputOverlap(&instanceConf.funcDetails); topic != "" {
+			panic("Sink topic " + instanceConf.funcDetails.Sink.Topic + " is also consumed through input " + topic +
+				", every output would be processed again in an infinite loop." +
+				" Set allowSinkInputOverlap to true if the loop is intentional.")
+		}
+	}
+
 	return instanceConf
 }
 
+// findNonPersistentInput returns the first non-persistent input topic, or
+// topics pattern, or an empty string if all inputs are persistent
+func findNonPersistentInput(funcDetails *pb.FunctionDetails) string {
+	topics := make([]string, 0, len(funcDetails.Source.GetInputSpecs()))
+	for topic := range funcDetails.Source.GetInputSpecs() {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		if topicName, err := ParseTopicName(topic); err == nil && !topicName.IsPersistent() {
+			return topic
+		}
+	}
+	return ""
+}
+
+// validateRetryLetterTopic panics unless the function has a single input topic
+// that messages can be reconsumed from
+func validateRetryLetterTopic(instanceConf *instanceConf) {
+	if instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATMOST_ONCE {
+		panic("retryLetterTopic cannot be used with ATMOST_ONCE processing guarantees," +
+			" messages are acked before they are processed.")
+	}
+	if instanceConf.funcDetails.RetryDetails.MaxMessageRetries <= 0 {
+		panic("maxMessageRetries must be positive when retryLetterTopic is set.")
+	}
+	inputSpecs := instanceConf.funcDetails.Source.InputSpecs
+	if len(inputSpecs) != 1 {
+		panic(fmt.Sprintf("retryLetterTopic requires exactly one input topic, got %d.", len(inputSpecs)))
+	}
+	for topic, spec := range inputSpecs {
+		if spec.IsRegexPattern {
+			panic("retryLetterTopic cannot be used with the topics pattern " + topic + ".")
+		}
+	}
+}
+
+// findSinkInputOverlap returns the input topic, or topics pattern, that the
+// sink topic is part of, or an empty string if there is none
+func findSinkInputOverlap(funcDetails *pb.FunctionDetails) string {
+	if funcDetails.Sink.GetTopic() == "" {
+		return ""
+	}
+	sinkTopic, err := ParseTopicName(funcDetails.Sink.Topic)
+	if err != nil {
+		return ""
+	}
+	for topic, spec := range funcDetails.Source.GetInputSpecs() {
+		if spec.IsRegexPattern {
+			if pattern, err := regexp.Compile(topic); err == nil &&
+				(pattern.MatchString(sinkTopic.Name) || pattern.MatchString(funcDetails.Sink.Topic)) {
+				return topic
+			}
+			continue
+		}
+		inputTopic, err := ParseTopicName(topic)
+		if err == nil && inputTopic.NameWithoutPartition() == sinkTopic.NameWithoutPartition() {
+			return topic
+		}
+	}
+	return ""
+}
+
+// newOutputSchema builds the schema of the sink from its Avro schema definition,
+// for the AVRO or JSON schema type
+func newOutputSchema(schemaType, definition string, properties map[string]string) (pulsar.Schema, error) {
+	var (
+		schema pulsar.Schema
+		err    error
+	)
+	switch strings.ToUpper(schemaType) {
+	case "AVRO":
+		schema, err = pulsar.NewAvroSchemaWithValidation(definition, properties)
+	case "JSON":
+		schema, err = pulsar.NewJSONSchemaWithValidation(definition, properties)
+	default:
+		return nil, fmt.Errorf("sink schema type %q does not support a schema definition, use AVRO or JSON",
+			schemaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func parseSubscriptionMode(mode string) pulsar.SubscriptionMode {
+	switch mode {
+	case "", "Durable":
+		return pulsar.Durable
+	case "NonDurable":
+		return pulsar.NonDurable
+	default:
+		panic("Invalid subscription mode " + mode + ", must be Durable or NonDurable.")
+	}
+}
+
+var tlsVersions = map[string]uint16{
+	"TLSv1.0": tls.VersionTLS10,
+	"TLSv1.1": tls.VersionTLS11,
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
+
+func parseTLSVersion(version string) uint16 {
+	if version == "" {
+		return 0
+	}
+	if v, ok := tlsVersions[version]; ok {
+		return v
+	}
+	// also accept the bare version number, e.g. 1.2
+	if v, ok := tlsVersions["TLSv"+version]; ok {
+		return v
+	}
+	panic("Invalid TLS version " + version + ", must be one of TLSv1.0, TLSv1.1, TLSv1.2 or TLSv1.3.")
+}
+
+func parseTLSCipherSuites(cipherSuites string) []uint16 {
+	if strings.TrimSpace(cipherSuites) == "" {
+		return nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+	var ids []uint16
+	var unknown []string
+	for _, name := range strings.Split(cipherSuites, ",") {
+		name = strings.TrimSpace(name)
+		if id, ok := known[name]; ok {
+			ids = append(ids, id)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		supported := make([]string, 0, len(known))
+		for name := range known {
+			supported = append(supported, name)
+		}
+		sort.Strings(supported)
+		panic(fmt.Sprintf("Unknown TLS cipher suites %s, supported cipher suites are: %s",
+			strings.Join(unknown, ", "), strings.Join(supported, ", ")))
+	}
+	return ids
+}
+
+func parseHashingScheme(scheme string) pulsar.HashingScheme {
+	switch scheme {
+	case "", "JavaStringHash":
+		return pulsar.JavaStringHash
+	case "Murmur3_32Hash":
+		return pulsar.Murmur3_32Hash
+	default:
+		panic("Invalid producer hashing scheme " + scheme + ", must be one of JavaStringHash or Murmur3_32Hash.")
+	}
+}
+
+func parseCompressionType(compressionType string) pb.CompressionType {
+	if compressionType == "" {
+		return pb.CompressionType_LZ4
+	}
+	value, ok := pb.CompressionType_value[compressionType]
+	if !ok || pb.CompressionType(value) == pb.CompressionType_SNAPPY {
+		panic("Invalid compression type " + compressionType + ", must be one of LZ4, NONE, ZLIB or ZSTD.")
+	}
+	return pb.CompressionType(value)
+}
+
 func newInstanceConf() *instanceConf {
 	config := &conf.Conf{}
 	cfg := config.GetConf()
@@ -145,6 +778,84 @@ func newInstanceConf() *instanceConf {
 	return newInstanceConfWithConf(cfg)
 }
 
+// hasDeadLetterTopics reports whether the messages of every input have a dead
+// letter topic to go to, that of the function or that of their input
+func (ic *instanceConf) hasDeadLetterTopics() bool {
+	if ic.funcDetails.RetryDetails.GetDeadLetterTopic() != "" {
+		return true
+	}
+	inputs := ic.funcDetails.Source.GetInputSpecs()
+	if len(inputs) == 0 {
+		return false
+	}
+	for input := range inputs {
+		if ic.inputDeadLetterTopics[input] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// deadLetterRoute sends the messages of an input to its own dead letter topic.
+// The input is matched by name, without partition, or with pattern for the
+// regex inputs.
+type deadLetterRoute struct {
+	topic           string
+	pattern         *regexp.Regexp
+	deadLetterTopic string
+}
+
+func (r deadLetterRoute) matches(topic string) bool {
+	if r.pattern != nil {
+		return r.pattern.MatchString(topic)
+	}
+	return r.topic == topic
+}
+
+// deadLetterTopic returns the dead letter topic of the messages of topic: that
+// of the input topic belongs to, or else that of the function. An input named
+// exactly wins over the patterns, which are tried in the order of their specs.
+func (ic *instanceConf) deadLetterTopic(topic *TopicName) string {
+	name := topic.NameWithoutPartition()
+	for _, route := range ic.inputDeadLetterRoutes {
+		if route.matches(name) {
+			return route.deadLetterTopic
+		}
+	}
+	return ic.funcDetails.RetryDetails.GetDeadLetterTopic()
+}
+
 func (ic *instanceConf) getInstanceName() string {
 	return "" + fmt.Sprintf("%d", ic.instanceID)
 }
+
+// inputSpecs collects the consumer specs of the input topics, so that a topic
+// given more than once, possibly spelled differently, is subscribed to once
+type inputSpecs struct {
+	specs map[string]*pb.ConsumerSpec
+	// the key in specs of each topic, by its fully qualified name
+	keys map[string]string
+}
+
+func newInputSpecs() *inputSpecs {
+	return &inputSpecs{
+		specs: make(map[string]*pb.ConsumerSpec),
+		keys:  make(map[string]string),
+	}
+}
+
+// add sets the spec of topic, replacing the one it was given before, if any
+func (s *inputSpecs) add(topic string, spec *pb.ConsumerSpec) {
+	name := topic
+	// patterns are kept as they are
+	if !spec.IsRegexPattern {
+		if topicName, err := ParseTopicName(topic); err == nil {
+			name = topicName.Name
+		}
+	}
+	if key, ok := s.keys[name]; ok {
+		delete(s.specs, key)
+	}
+	s.keys[name] = topic
+	s.specs[topic] = spec
+}
@@ -20,7 +20,12 @@
 package pf
 
 import (
+	"crypto/tls"
+	"fmt"
 	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
 
 	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
 
@@ -42,6 +47,7 @@ func Test_newInstanceConf(t *testing.T) {
 			killAfterIdle:               50000,
 			expectedHealthCheckInterval: 3,
 			metricsPort:                 50001,
+			allowAutoTopicCreation:      true,
 			funcDetails: pb.FunctionDetails{Tenant: "",
 				Namespace:            "",
 				Name:                 "go-function",
@@ -113,3 +119,626 @@ func TestInstanceConf_Fail(t *testing.T) {
 		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
 	}, "Should have a panic")
 }
+
+func TestInstanceConf_ChunkingWithBatching(t *testing.T) {
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, EnableChunking: true})
+	}, "Should have a panic")
+	assert.NotPanics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, EnableChunking: true, DisableBatching: true})
+	}, "Should not have a panic")
+}
+
+func TestInstanceConf_ConnectionSettings(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		ConnectionTimeoutMs:  2000,
+		KeepAliveIntervalMs:  10000,
+		OperationTimeoutMs:   60000,
+	})
+	assert.Equal(t, 2*time.Second, instanceConf.connectionTimeout)
+	assert.Equal(t, 10*time.Second, instanceConf.keepAliveInterval)
+	assert.Equal(t, time.Minute, instanceConf.operationTimeout)
+
+	for _, c := range []*cfg.Conf{
+		{ProcessingGuarantees: 3, ConnectionTimeoutMs: -1},
+		{ProcessingGuarantees: 3, KeepAliveIntervalMs: -1},
+		{ProcessingGuarantees: 3, OperationTimeoutMs: -1},
+	} {
+		assert.Panics(t, func() { newInstanceConfWithConf(c) }, "Should have a panic")
+	}
+}
+
+func TestInstanceConf_StartupWait(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:       3,
+		PulsarWebServiceURL:        "http://localhost:8080",
+		StartupWaitForSubscription: true,
+		StartupBacklogThreshold:    100,
+	})
+	assert.True(t, instanceConf.startupWaitForSubscription)
+	assert.Equal(t, int64(100), instanceConf.startupBacklogThreshold)
+
+	for _, c := range []*cfg.Conf{
+		{ProcessingGuarantees: 3, StartupWaitForSubscription: true, StartupBacklogThreshold: -1},
+		{ProcessingGuarantees: 3, StartupWaitForSubscription: true, StartupBacklogThreshold: 100},
+		{ProcessingGuarantees: 3, PulsarWebServiceURL: "http://localhost:8080", StartupBacklogThreshold: 100},
+	} {
+		assert.Panics(t, func() { newInstanceConfWithConf(c) }, "Should have a panic")
+	}
+}
+
+func TestInstanceConf_BatchIndexAck(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, BatchIndexAckEnabled: true})
+	assert.True(t, instanceConf.batchIndexAckEnabled)
+
+	assert.PanicsWithValue(t, "Go instance current not support EFFECTIVELY_ONCE processing guarantees.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 2, BatchIndexAckEnabled: true})
+	})
+}
+
+func TestInstanceConf_AckGroupingTime(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		AckGroupingTimeMs:    100,
+	})
+	assert.Equal(t, 100*time.Millisecond, instanceConf.ackGroupingTime)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			AckGroupingTimeMs:    -1,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_MaxMessagesToProcess(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MaxMessagesToProcess: 10})
+	assert.Equal(t, int64(10), instanceConf.maxMessagesToProcess)
+
+	assert.PanicsWithValue(t, "maxMessagesToProcess must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MaxMessagesToProcess: -1})
+	})
+}
+
+func TestInstanceConf_AckWithResponse(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AckWithResponse: true})
+	assert.True(t, instanceConf.ackWithResponse)
+
+	assert.PanicsWithValue(t, "ackGroupingTimeMs cannot be set with ackWithResponse, the acks are not grouped "+
+		"when waiting for the broker to confirm them.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AckWithResponse: true, AckGroupingTimeMs: 100})
+	})
+}
+
+func TestInstanceConf_ConsumerFailoverDelay(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:    3,
+		SubscriptionType:        int32(pb.SubscriptionType_FAILOVER),
+		ConsumerFailoverDelayMs: 500,
+	})
+	assert.Equal(t, 500*time.Millisecond, instanceConf.consumerFailoverDelay)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees:    3,
+			ConsumerFailoverDelayMs: -1,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_ConsumptionMode(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		ConsumptionMode:      "receive",
+	})
+	assert.Equal(t, ConsumptionModeReceive, instanceConf.consumptionMode)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			ConsumptionMode:      "poll",
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_DebugPort(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		Port:                 9093,
+		MetricsPort:          9094,
+		DebugPort:            9095,
+	})
+	assert.Equal(t, 9095, instanceConf.debugPort)
+
+	for _, debugPort := range []int{-1, 9093, 9094} {
+		assert.Panics(t, func() {
+			newInstanceConfWithConf(&cfg.Conf{
+				ProcessingGuarantees: 3,
+				Port:                 9093,
+				MetricsPort:          9094,
+				DebugPort:            debugPort,
+			})
+		}, "Should have a panic for debugPort %d", debugPort)
+	}
+}
+
+func TestInstanceConf_StartMessageRollback(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:           3,
+		StartMessageRollbackDurationMs: 60000,
+	})
+	assert.Equal(t, time.Minute, instanceConf.startMessageRollback)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees:           3,
+			StartMessageRollbackDurationMs: -1,
+		})
+	}, "Should have a panic")
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees:           3,
+			SourceInputSpecs:               map[string]string{"persistent://public/default/.*": `{"isRegexPattern": true}`},
+			StartMessageRollbackDurationMs: 60000,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_MaxPendingPublishes(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		MaxPendingPublishes:  10,
+	})
+	assert.Equal(t, 10, instanceConf.maxPendingPublishes)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			MaxPendingPublishes:  -1,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_MaxProcessingRate(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		MaxProcessingRate:    2.5,
+	})
+	assert.Equal(t, 2.5, instanceConf.maxProcessingRate)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			MaxProcessingRate:    -1,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_NegativeAckBackoff(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:    3,
+		NegativeAckBackoffMinMs: 100,
+		NegativeAckBackoffMaxMs: 5000,
+	})
+	assert.Equal(t, 100*time.Millisecond, instanceConf.negativeAckBackoffMin)
+	assert.Equal(t, 5*time.Second, instanceConf.negativeAckBackoffMax)
+
+	for _, backoff := range [][2]int64{{5000, 100}, {0, 5000}, {100, 0}, {-100, 5000}} {
+		assert.Panics(t, func() {
+			newInstanceConfWithConf(&cfg.Conf{
+				ProcessingGuarantees:    3,
+				NegativeAckBackoffMinMs: backoff[0],
+				NegativeAckBackoffMaxMs: backoff[1],
+			})
+		}, "Should have a panic")
+	}
+}
+
+func TestInstanceConf_SourceTopicsList(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		SourceTopicsList:     "topic-01, persistent://public/default/topic-02,,topic-03",
+		SourceSchemaType:     "JSON",
+		ReceiverQueueSize:    50,
+	})
+	shared := &pb.ConsumerSpec{
+		SchemaType:        "JSON",
+		ReceiverQueueSize: &pb.ConsumerSpec_ReceiverQueueSize{Value: 50},
+	}
+	assert.Equal(t, map[string]*pb.ConsumerSpec{
+		"topic-01":                             shared,
+		"persistent://public/default/topic-02": shared,
+		"topic-03":                             shared,
+	}, instanceConf.funcDetails.Source.InputSpecs)
+}
+
+func TestInstanceConf_MergedInputSources(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		SourceTopicsList:     "topic-01,topic-02,topic-03",
+		SourceSpecTopic:      "persistent://public/default/topic-02",
+		SourceSchemaType:     "JSON",
+		SourceInputSpecs: map[string]string{
+			"persistent://public/default/topic-03": `{"schemaType": "AVRO"}`,
+			"public/default/topic-04":              `{}`,
+			"topic-04":                             `{"schemaType": "STRING"}`,
+		},
+	})
+	inputSpecs := instanceConf.funcDetails.Source.InputSpecs
+	// each topic is subscribed to once, with the spec of its most specific source
+	assert.Len(t, inputSpecs, 4)
+	assert.Equal(t, "JSON", inputSpecs["topic-01"].SchemaType)
+	assert.Equal(t, "JSON", inputSpecs["persistent://public/default/topic-02"].SchemaType)
+	assert.Equal(t, "AVRO", inputSpecs["persistent://public/default/topic-03"].SchemaType)
+	assert.Equal(t, "STRING", inputSpecs["topic-04"].SchemaType)
+}
+
+func TestInstanceConf_ConnectionLimits(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		ConnectionsPerBroker: 4,
+	})
+	assert.Equal(t, 4, instanceConf.connectionsPerBroker)
+
+	for _, c := range []*cfg.Conf{
+		{ProcessingGuarantees: 3, ConnectionsPerBroker: -1},
+		{ProcessingGuarantees: 3, ConnectionsPerBroker: maxConnectionsPerBroker + 1},
+	} {
+		assert.Panics(t, func() {
+			newInstanceConfWithConf(c)
+		}, "Should have a panic")
+	}
+}
+
+func TestInstanceConf_ProducerHashingScheme(t *testing.T) {
+	for scheme, expected := range map[string]pulsar.HashingScheme{
+		"":               pulsar.JavaStringHash,
+		"JavaStringHash": pulsar.JavaStringHash,
+		"Murmur3_32Hash": pulsar.Murmur3_32Hash,
+	} {
+		instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ProducerHashingScheme: scheme})
+		assert.Equal(t, expected, instanceConf.producerHashingScheme)
+	}
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ProducerHashingScheme: "murmur3"})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_ClientStatsInterval(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:  3,
+		ClientStatsIntervalMs: 500,
+	})
+	assert.Equal(t, 500*time.Millisecond, instanceConf.clientStatsInterval)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees:  3,
+			ClientStatsIntervalMs: -1,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_ReadCompacted(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		SubscriptionType:     int32(pb.SubscriptionType_FAILOVER),
+		ReadCompacted:        true,
+	})
+	assert.True(t, instanceConf.readCompacted)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			SubscriptionType:     int32(pb.SubscriptionType_SHARED),
+			ReadCompacted:        true,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_SubscriptionMode(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
+	assert.Equal(t, pulsar.Durable, instanceConf.subscriptionMode)
+
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SubscriptionMode: "Durable"})
+	assert.Equal(t, pulsar.Durable, instanceConf.subscriptionMode)
+
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SubscriptionMode: "NonDurable"})
+	assert.Equal(t, pulsar.NonDurable, instanceConf.subscriptionMode)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SubscriptionMode: "Ephemeral"})
+	}, "Should have a panic")
+	assert.PanicsWithValue(t, "Go instance current not support EFFECTIVELY_ONCE processing guarantees.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 2, SubscriptionMode: "NonDurable"})
+	})
+}
+
+func TestInstanceConf_ReplicateSubscriptionState(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ReplicateSubscriptionState: true})
+	assert.True(t, instanceConf.replicateSubscription)
+
+	assert.PanicsWithValue(t, "replicateSubscriptionState can only be enabled for Durable subscriptions.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, ReplicateSubscriptionState: true,
+			SubscriptionMode: "NonDurable"})
+	})
+}
+
+const testAvroSchemaDefinition = `{"type":"record","name":"Example","namespace":"test",` +
+	`"fields":[{"name":"ID","type":"int"},{"name":"Name","type":"string"}]}`
+
+func TestInstanceConf_InputSchemaDefinition(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		InputSchemaDefinition: map[string]string{
+			"topic-01": testAvroSchemaDefinition,
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"persistent://public/default/topic-01": testAvroSchemaDefinition,
+	}, instanceConf.inputSchemaDefinitions)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			InputSchemaDefinition: map[string]string{
+				"topic-01": `{"type":"record","name":"Example","fields":[{"name":"ID"}]}`,
+			},
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_OutputSchemaDefinition(t *testing.T) {
+	for _, schemaType := range []string{"AVRO", "json"} {
+		instanceConf := newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees:   3,
+			SinkSchemaType:         schemaType,
+			OutputSchemaDefinition: testAvroSchemaDefinition,
+		})
+		assert.Equal(t, testAvroSchemaDefinition, instanceConf.outputSchemaDefinition)
+	}
+
+	for _, c := range []*cfg.Conf{
+		{
+			ProcessingGuarantees:   3,
+			SinkSchemaType:         "AVRO",
+			OutputSchemaDefinition: `{"type":"record","name":"Example","fields":[{"name":"ID"}]}`,
+		},
+		{ProcessingGuarantees: 3, SinkSchemaType: "JSON", OutputSchemaDefinition: `not json`},
+		{ProcessingGuarantees: 3, SinkSchemaType: "STRING", OutputSchemaDefinition: testAvroSchemaDefinition},
+	} {
+		assert.Panics(t, func() { newInstanceConfWithConf(c) }, "Should have a panic")
+	}
+}
+
+func TestInstanceConf_LogTopicCompression(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
+	assert.Equal(t, pb.CompressionType_LZ4, instanceConf.logTopicCompression)
+
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, LogTopicCompression: "ZSTD"})
+	assert.Equal(t, pb.CompressionType_ZSTD, instanceConf.logTopicCompression)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, LogTopicCompression: "SNAPPY"})
+	}, "Should have a panic")
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, LogTopicCompression: "gzip"})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_RetryBackoff(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:  3,
+		RetryBackoffInitialMs: 100,
+		RetryBackoffMaxMs:     5000,
+	})
+	assert.Equal(t, 100*time.Millisecond, instanceConf.retryBackoffInitial)
+	assert.Equal(t, 5*time.Second, instanceConf.retryBackoffMax)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, RetryBackoffInitialMs: 500, RetryBackoffMaxMs: 100})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_OutputEventTimeStrategy(t *testing.T) {
+	for _, strategy := range []string{"", "none", "passthrough", "processing"} {
+		instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OutputEventTimeStrategy: strategy})
+		assert.Equal(t, strategy, instanceConf.outputEventTimeStrategy)
+	}
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OutputEventTimeStrategy: "ingestion"})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_NonPersistentInput(t *testing.T) {
+	inputSpec := map[string]string{"non-persistent://public/default/topic-01": `{}`}
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		SourceInputSpecs:     inputSpec,
+		SinkSpecTopic:        "non-persistent://public/default/topic-02",
+	})
+	assert.Equal(t, "non-persistent://public/default/topic-01", findNonPersistentInput(&instanceConf.funcDetails))
+
+	assert.PanicsWithValue(t, "Go instance current not support EFFECTIVELY_ONCE processing guarantees.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 2, SourceInputSpecs: inputSpec})
+	})
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			SourceInputSpecs:     inputSpec,
+			SubscriptionType:     int32(pb.SubscriptionType_FAILOVER),
+			ReadCompacted:        true,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_RetryLetterTopic(t *testing.T) {
+	inputSpec := map[string]string{"topic-01": `{"isRegexPattern": false}`}
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		SourceInputSpecs:     inputSpec,
+		MaxMessageRetries:    3,
+		RetryLetterTopic:     "topic-01-RETRY",
+	})
+	assert.Equal(t, "topic-01-RETRY", instanceConf.retryLetterTopic)
+
+	for name, c := range map[string]*cfg.Conf{
+		"no retries": {ProcessingGuarantees: 3, SourceInputSpecs: inputSpec},
+		"at most once": {
+			ProcessingGuarantees: 1, AutoACK: true, SourceInputSpecs: inputSpec, MaxMessageRetries: 3,
+		},
+		"two inputs": {
+			ProcessingGuarantees: 3, MaxMessageRetries: 3,
+			SourceInputSpecs: map[string]string{"topic-01": `{}`, "topic-02": `{}`},
+		},
+		"topics pattern": {
+			ProcessingGuarantees: 3, MaxMessageRetries: 3,
+			SourceInputSpecs: map[string]string{"topic-.*": `{"isRegexPattern": true}`},
+		},
+	} {
+		c.RetryLetterTopic = "topic-01-RETRY"
+		assert.Panics(t, func() { newInstanceConfWithConf(c) }, "Should have a panic: "+name)
+	}
+}
+
+func TestInstanceConf_SinkPartitionRouting(t *testing.T) {
+	for _, routing := range []string{"", "roundRobin", "keyBased", "singlePartition"} {
+		instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SinkPartitionRouting: routing})
+		assert.Equal(t, routing, instanceConf.sinkPartitionRouting)
+	}
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, SinkPartitionRouting: "random"})
+	}, "Should have a panic")
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			SinkPartitionRouting: "singlePartition",
+			SinkPartition:        -1,
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_AutoUpdatePartitions(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:           3,
+		AutoUpdatePartitions:           true,
+		AutoUpdatePartitionsIntervalMs: 30000,
+	})
+	assert.True(t, instanceConf.autoUpdatePartitions)
+	assert.Equal(t, 30*time.Second, instanceConf.partitionsUpdateInterval)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AutoUpdatePartitions: true})
+	}, "Should have a panic")
+	assert.NotPanics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AutoUpdatePartitionsIntervalMs: -1})
+	}, "Should not have a panic")
+}
+
+func TestInstanceConf_TLSMinVersion(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
+	assert.Equal(t, uint16(0), instanceConf.tlsMinVersion)
+
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, TLSMinVersion: "TLSv1.2"})
+	assert.Equal(t, uint16(tls.VersionTLS12), instanceConf.tlsMinVersion)
+
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, TLSMinVersion: "1.3"})
+	assert.Equal(t, uint16(tls.VersionTLS13), instanceConf.tlsMinVersion)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, TLSMinVersion: "SSLv3"})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_TLSCipherSuites(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		TLSCipherSuites:      "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	})
+	assert.Equal(t, []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}, instanceConf.tlsCipherSuites)
+
+	defer func() {
+		msg := fmt.Sprint(recover())
+		assert.Contains(t, msg, "Unknown TLS cipher suites TLS_FAKE_CIPHER")
+		// the error lists the supported cipher suites
+		assert.Contains(t, msg, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	}()
+	newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		TLSCipherSuites:      "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_FAKE_CIPHER",
+	})
+	t.Error("Should have a panic")
+}
+
+func TestInstanceConf_MetricsNamespace(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MetricsNamespace: "my_tenant"})
+	assert.Equal(t, "my_tenant", instanceConf.metricsNamespace)
+
+	for _, namespace := range []string{"1tenant", "my-tenant", "my tenant"} {
+		assert.Panics(t, func() {
+			newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MetricsNamespace: namespace})
+		}, "Should have a panic for %q", namespace)
+	}
+}
+
+func TestInstanceConf_SinkInputOverlap(t *testing.T) {
+	inputSpec := `{"isRegexPattern": false}`
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			SourceInputSpecs:     map[string]string{"topic-01": inputSpec},
+			SinkSpecTopic:        "persistent://public/default/topic-01",
+		})
+	}, "Should have a panic")
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			SourceInputSpecs:     map[string]string{"persistent://public/default/topic-.*": `{"isRegexPattern": true}`},
+			SinkSpecTopic:        "topic-02",
+		})
+	}, "Should have a panic")
+	assert.NotPanics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees: 3,
+			SourceInputSpecs:     map[string]string{"topic-01": inputSpec},
+			SinkSpecTopic:        "topic-02",
+		})
+	}, "Should not have a panic")
+
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:  3,
+		SourceInputSpecs:      map[string]string{"topic-01": inputSpec},
+		SinkSpecTopic:         "topic-01",
+		AllowSinkInputOverlap: true,
+	})
+	assert.True(t, instanceConf.allowSinkInputOverlap)
+}
+
+func TestInstanceConf_StartPaused(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, StartPaused: true})
+	assert.True(t, instanceConf.startPaused)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{
+			ProcessingGuarantees:       3,
+			StartPaused:                true,
+			StartupWaitForSubscription: true,
+			StartupBacklogThreshold:    10,
+			PulsarWebServiceURL:        "http://localhost:8080",
+		})
+	}, "Should have a panic")
+}
+
+func TestInstanceConf_MaxMessageAge(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MaxMessageAgeMs: 90000})
+	assert.Equal(t, 90*time.Second, instanceConf.maxMessageAge)
+
+	assert.PanicsWithValue(t, "maxMessageAgeMs must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MaxMessageAgeMs: -1})
+	})
+}
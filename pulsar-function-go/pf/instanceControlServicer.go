@@ -23,13 +23,25 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
 	log "github.com/apache/pulsar/pulsar-function-go/logutil"
 	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 	"github.com/golang/protobuf/ptypes/empty"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
+// healthWatchInterval is how often a health Watch call checks for a change of
+// the readiness, it is shortened in tests
+var healthWatchInterval = time.Second
+
+// instanceControlServiceName is the fully qualified name of the instance
+// control service, as asked for in health checks
+const instanceControlServiceName = "proto.InstanceControl"
+
 type InstanceControlServicer struct {
 	goInstance *goInstance
 }
@@ -56,6 +68,33 @@ func (icServicer *InstanceControlServicer) HealthCheck(
 	return icServicer.goInstance.healthCheck(), nil
 }
 
+func (icServicer *InstanceControlServicer) Pause(
+	ctx context.Context, req *empty.Empty) (*empty.Empty, error) {
+	icServicer.goInstance.pause()
+	return &empty.Empty{}, nil
+}
+func (icServicer *InstanceControlServicer) Resume(
+	ctx context.Context, req *empty.Empty) (*empty.Empty, error) {
+	icServicer.goInstance.resume()
+	return &empty.Empty{}, nil
+}
+
+func (icServicer *InstanceControlServicer) SeekToMessageID(
+	ctx context.Context, req *pb.SeekToMessageIDRequest) (*empty.Empty, error) {
+	msgID, err := parseMessageID(req.GetMessageId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// the consumers are only all set up, and no longer changing, once ready
+	if !icServicer.goInstance.isReady() {
+		return nil, status.Error(codes.Unavailable, "the instance is not ready yet")
+	}
+	if err := icServicer.goInstance.seekToMessageID(msgID); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &empty.Empty{}, nil
+}
+
 func (icServicer *InstanceControlServicer) serve(goInstance *goInstance) *grpc.Server {
 	// create a listener on TCP port
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", goInstance.context.GetPort()))
@@ -65,7 +104,7 @@ func (icServicer *InstanceControlServicer) serve(goInstance *goInstance) *grpc.S
 	// create a gRPC server object
 	grpcServer := grpc.NewServer()
 	// must register before we start the service.
-	pb.RegisterInstanceControlServer(grpcServer, icServicer)
+	icServicer.register(grpcServer)
 	// start the server
 	log.Infof("Serving InstanceCommunication on port %d", goInstance.context.GetPort())
 	go func() {
@@ -75,3 +114,56 @@ func (icServicer *InstanceControlServicer) serve(goInstance *goInstance) *grpc.S
 	}()
 	return grpcServer
 }
+
+// register adds the instance control service, and the standard health service
+// reporting the readiness of the instance, to grpcServer
+func (icServicer *InstanceControlServicer) register(grpcServer *grpc.Server) {
+	pb.RegisterInstanceControlServer(grpcServer, icServicer)
+	healthpb.RegisterHealthServer(grpcServer, &healthServicer{goInstance: icServicer.goInstance})
+}
+
+// healthServicer implements grpc.health.v1.Health for both the server as a
+// whole and the instance control service, which are SERVING once the instance
+// is ready, the same state HealthCheck reports
+type healthServicer struct {
+	healthpb.UnimplementedHealthServer
+	goInstance *goInstance
+}
+
+func (hs *healthServicer) servingStatus(service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	if service != "" && service != instanceControlServiceName {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	if hs.goInstance.isReady() {
+		return healthpb.HealthCheckResponse_SERVING, true
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING, true
+}
+
+func (hs *healthServicer) Check(
+	ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	servingStatus, known := hs.servingStatus(req.GetService())
+	if !known {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: servingStatus}, nil
+}
+
+func (hs *healthServicer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+	var last healthpb.HealthCheckResponse_ServingStatus = -1
+	for {
+		if servingStatus, _ := hs.servingStatus(req.GetService()); servingStatus != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: servingStatus}); err != nil {
+				return err
+			}
+			last = servingStatus
+		}
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
@@ -24,13 +24,20 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
 
 	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -50,7 +57,7 @@ func TestInstanceControlServicer_serve_creates_valid_instance(t *testing.T) {
 	instance := newGoInstance()
 	servicer := InstanceControlServicer{instance}
 	// must register before we start the service.
-	pb.RegisterInstanceControlServer(grpcServer, &servicer)
+	servicer.register(grpcServer)
 	// start the server
 	log.Printf("Serving InstanceCommunication on port %d", instance.context.GetPort())
 
@@ -82,6 +89,11 @@ func TestInstanceControlServicer_serve_creates_valid_instance(t *testing.T) {
 
 func instanceCommunicationClient(t *testing.T, instance *goInstance) pb.InstanceControlClient {
 	t.Helper()
+	return pb.NewInstanceControlClient(instanceCommunicationConn(t, instance))
+}
+
+func instanceCommunicationConn(t *testing.T, instance *goInstance) *grpc.ClientConn {
+	t.Helper()
 
 	if instance == nil {
 		t.Fatalf("cannot create communication client for nil instance")
@@ -109,7 +121,7 @@ func instanceCommunicationClient(t *testing.T, instance *goInstance) pb.Instance
 
 	servicer := InstanceControlServicer{instance}
 	// must register before we start the service.
-	pb.RegisterInstanceControlServer(grpcServer, &servicer)
+	servicer.register(grpcServer)
 
 	// start the server
 	t.Logf("Serving InstanceCommunication on port %d", instance.context.GetPort())
@@ -129,6 +141,232 @@ func instanceCommunicationClient(t *testing.T, instance *goInstance) pb.Instance
 	t.Cleanup(func() {
 		conn.Close()
 	})
-	client := pb.NewInstanceControlClient(conn)
-	return client
+	return conn
+}
+
+func TestInstanceControlServicer_HealthCheckReadiness(t *testing.T) {
+	instance := newGoInstance()
+	client := instanceCommunicationClient(t, instance)
+
+	resp, err := client.HealthCheck(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.False(t, resp.Ready)
+
+	instance.ready.Store(true)
+
+	resp, err = client.HealthCheck(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.True(t, resp.Ready)
+}
+
+type countingHandler struct {
+	processed atomic.Int32
+}
+
+func (h *countingHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	h.processed.Add(1)
+	return nil, nil
+}
+
+func TestInstanceControlServicer_PauseResume(t *testing.T) {
+	handler := &countingHandler{}
+	instance := newGoInstance()
+	instance.function = handler
+	instance.context.instanceConf.killAfterIdle = 200
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	client := instanceCommunicationClient(t, instance)
+
+	_, err := client.Pause(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	status, err := client.GetFunctionStatus(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	done := make(chan error)
+	go func() {
+		done <- instance.processMessages(channel)
+	}()
+	channel <- pulsar.ConsumerMessage{
+		Message: &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}},
+	}
+
+	// nothing is processed, and the idle timeout does not fire, while paused
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(0), handler.processed.Load())
+	health, err := client.HealthCheck(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.True(t, health.Success)
+
+	_, err = client.Resume(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	status, err = client.GetFunctionStatus(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.False(t, status.Paused)
+
+	assert.Eventually(t, func() bool {
+		return handler.processed.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	// once resumed, the instance stops after being idle
+	assert.NoError(t, <-done)
+}
+
+func TestInstanceControlServicer_StartPaused(t *testing.T) {
+	handler := &countingHandler{}
+	instance := newGoInstance()
+	instance.function = handler
+	instance.context.instanceConf.killAfterIdle = 200
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.context.instanceConf.startPaused = true
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	client := instanceCommunicationClient(t, instance)
+
+	instance.setupStartPaused()
+	// as startFunction does once subscribed, a paused instance is ready all the same
+	instance.ready.Store(true)
+	status, err := client.GetFunctionStatus(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+
+	channel := make(chan pulsar.ConsumerMessage, 2)
+	done := make(chan error)
+	go func() {
+		done <- instance.processMessages(channel)
+	}()
+	for i := 0; i < 2; i++ {
+		channel <- pulsar.ConsumerMessage{
+			Message: &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}},
+		}
+	}
+
+	// ready, but nothing is processed until resumed
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(0), handler.processed.Load())
+	assert.True(t, instance.isReady())
+	assert.Len(t, channel, 2)
+
+	_, err = client.Resume(context.Background(), &empty.Empty{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return handler.processed.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, <-done)
+}
+
+func TestInstanceControlServicer_StandardHealthCheck(t *testing.T) {
+	instance := newGoInstance()
+	client := healthpb.NewHealthClient(instanceCommunicationConn(t, instance))
+
+	for _, service := range []string{"", "proto.InstanceControl"} {
+		instance.ready.Store(false)
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+		instance.ready.Store(true)
+		resp, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	}
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestInstanceControlServicer_StandardHealthWatch(t *testing.T) {
+	healthWatchInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		healthWatchInterval = time.Second
+	})
+	instance := newGoInstance()
+	client := healthpb.NewHealthClient(instanceCommunicationConn(t, instance))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	instance.ready.Store(true)
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	instance.ready.Store(false)
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
+
+// pauseRecordingConsumer records whether the instance was paused at each seek
+type pauseRecordingConsumer struct {
+	MockPulsarConsumer
+	instance     *goInstance
+	pausedOnSeek []bool
+}
+
+func (consumer *pauseRecordingConsumer) Seek(msgID pulsar.MessageID) error {
+	consumer.pausedOnSeek = append(consumer.pausedOnSeek, consumer.instance.isPaused())
+	return consumer.MockPulsarConsumer.Seek(msgID)
+}
+
+func TestInstanceControlServicer_SeekToMessageID(t *testing.T) {
+	instance := newGoInstance()
+	first := &pauseRecordingConsumer{instance: instance}
+	second := &pauseRecordingConsumer{instance: instance}
+	instance.consumers["persistent://public/default/topic-01"] = first
+	instance.consumers["persistent://public/default/topic-02"] = second
+	// the retry letter topic shares the consumer of its input
+	instance.consumers["persistent://public/default/topic-01-retry"] = first
+	instance.ready.Store(true)
+	client := instanceCommunicationClient(t, instance)
+
+	_, err := client.SeekToMessageID(context.Background(), &pb.SeekToMessageIDRequest{MessageId: "12:34:0:-1"})
+	assert.NoError(t, err)
+	for _, consumer := range []*pauseRecordingConsumer{first, second} {
+		assert.Len(t, consumer.seeked, 1)
+		assert.Equal(t, int64(12), consumer.seeked[0].LedgerID())
+		assert.Equal(t, int64(34), consumer.seeked[0].EntryID())
+		assert.Equal(t, []bool{true}, consumer.pausedOnSeek)
+	}
+	// processing resumes after the seek
+	assert.False(t, instance.isPaused())
+
+	// an instance paused beforehand stays paused
+	instance.pause()
+	_, err = client.SeekToMessageID(context.Background(), &pb.SeekToMessageIDRequest{MessageId: "12:35"})
+	assert.NoError(t, err)
+	assert.True(t, instance.isPaused())
+	instance.resume()
+}
+
+func TestInstanceControlServicer_SeekToMessageIDErrors(t *testing.T) {
+	instance := newGoInstance()
+	consumer := &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	client := instanceCommunicationClient(t, instance)
+
+	_, err := client.SeekToMessageID(context.Background(), &pb.SeekToMessageIDRequest{MessageId: "not-an-id"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, consumer.seeked)
+
+	// the consumers may still be being set up
+	_, err = client.SeekToMessageID(context.Background(), &pb.SeekToMessageIDRequest{MessageId: "12:34"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Empty(t, consumer.seeked)
+
+	instance.ready.Store(true)
+	consumer.seekErr = fmt.Errorf("seek failed")
+	_, err = client.SeekToMessageID(context.Background(), &pb.SeekToMessageIDRequest{MessageId: "12:34"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Len(t, consumer.seeked, 1)
+	assert.False(t, instance.isPaused())
 }
@@ -21,12 +21,19 @@ package pf
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/stretchr/testify/assert"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 )
 
 func testProcessSpawnerHealthCheckTimer(
@@ -115,3 +122,619 @@ func Test_goInstance_handlerMsg(t *testing.T) {
 	assert.Equal(t, "output", string(output))
 	assert.Equal(t, message, fc.record)
 }
+
+func Test_goInstance_chunkingOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.enableChunking = true
+	instance.context.instanceConf.disableBatching = true
+	instance.context.instanceConf.maxPendingChunkedMessage = 50
+	instance.context.instanceConf.autoAckIncompleteChunk = true
+
+	producerOpts := instance.getProducerOptions("persistent://public/default/topic-02")
+	assert.True(t, producerOpts.EnableChunking)
+	assert.True(t, producerOpts.DisableBatching)
+
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, 50, consumerOpts.MaxPendingChunkedMessage)
+	assert.True(t, consumerOpts.AutoAckIncompleteChunk)
+}
+
+func Test_goInstance_requestRestart(t *testing.T) {
+	exitCode := 0
+	osExit = func(code int) {
+		exitCode = code
+	}
+	t.Cleanup(func() {
+		osExit = os.Exit
+	})
+
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	consumer := &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+	instance.context.SetCurrentRecord(message)
+	restartRequests := instance.getTotalRestartRequests()
+
+	instance.context.RequestRestart("external state changed")
+
+	assert.Equal(t, RestartRequestedExitCode, exitCode)
+	assert.Equal(t, []pulsar.Message{message}, consumer.nacked)
+	assert.True(t, consumer.closed)
+	assert.Equal(t, restartRequests+1, instance.getTotalRestartRequests())
+}
+
+func Test_goInstance_inputSchemaDefinitionOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.inputSchemaDefinitions = map[string]string{
+		"persistent://public/default/topic-01": testAvroSchemaDefinition,
+	}
+
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{SchemaType: "AVRO"}, make(chan pulsar.ConsumerMessage))
+	assert.NotNil(t, consumerOpts.Schema)
+	assert.Equal(t, pulsar.AVRO, consumerOpts.Schema.GetSchemaInfo().Type)
+	assert.Equal(t, testAvroSchemaDefinition, consumerOpts.Schema.GetSchemaInfo().Schema)
+
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-03",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Nil(t, consumerOpts.Schema)
+}
+
+func Test_goInstance_outputSchemaDefinitionOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.context.instanceConf.funcDetails.Sink.SchemaType = "JSON"
+	instance.context.instanceConf.outputSchemaDefinition = testAvroSchemaDefinition
+
+	producerOpts := instance.getProducerOptions("persistent://public/default/topic-02")
+	assert.NotNil(t, producerOpts.Schema)
+	assert.Equal(t, pulsar.JSON, producerOpts.Schema.GetSchemaInfo().Type)
+	assert.Equal(t, testAvroSchemaDefinition, producerOpts.Schema.GetSchemaInfo().Schema)
+
+	// topics produced to through the context have no schema
+	producerOpts = instance.getProducerOptions("persistent://public/default/other")
+	assert.Nil(t, producerOpts.Schema)
+
+	instance.context.instanceConf.outputSchemaDefinition = ""
+	producerOpts = instance.getProducerOptions("persistent://public/default/topic-02")
+	assert.Nil(t, producerOpts.Schema)
+}
+
+func Test_goInstance_batchIndexAckOptions(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.False(t, consumerOpts.EnableBatchIndexAcknowledgment)
+
+	instance.context.instanceConf.batchIndexAckEnabled = true
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.True(t, consumerOpts.EnableBatchIndexAcknowledgment)
+}
+
+func Test_goInstance_readCompactedOptions(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.False(t, consumerOpts.ReadCompacted)
+
+	instance.context.instanceConf.funcDetails.Source.SubscriptionType = pb.SubscriptionType_FAILOVER
+	instance.context.instanceConf.readCompacted = true
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.True(t, consumerOpts.ReadCompacted)
+	assert.Equal(t, pulsar.Failover, consumerOpts.Type)
+}
+
+func Test_goInstance_subscriptionModeOptions(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, pulsar.Durable, consumerOpts.SubscriptionMode)
+
+	instance.context.instanceConf.subscriptionMode = pulsar.NonDurable
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, pulsar.NonDurable, consumerOpts.SubscriptionMode)
+}
+
+func Test_goInstance_replicateSubscriptionStateOptions(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.False(t, consumerOpts.ReplicateSubscriptionState)
+
+	instance.context.instanceConf.replicateSubscription = true
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.True(t, consumerOpts.ReplicateSubscriptionState)
+
+	// non-persistent topics have NonDurable subscriptions, with no cursor to replicate
+	consumerOpts = instance.getConsumerOptions("non-persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.False(t, consumerOpts.ReplicateSubscriptionState)
+}
+
+func Test_goInstance_nackBackoffOptions(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Nil(t, consumerOpts.NackBackoffPolicy)
+
+	instance.context.instanceConf.negativeAckBackoffMin = 100 * time.Millisecond
+	instance.context.instanceConf.negativeAckBackoffMax = 5 * time.Second
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, &nackBackoffPolicy{min: 100 * time.Millisecond, max: 5 * time.Second},
+		consumerOpts.NackBackoffPolicy)
+}
+
+func Test_goInstance_logTopicCompression(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.LogTopic = "persistent://public/default/log-topic"
+	producerOpts := instance.newLogAppender().producerOptions()
+	assert.Equal(t, "persistent://public/default/log-topic", producerOpts.Topic)
+	assert.Equal(t, pulsar.LZ4, producerOpts.CompressionType)
+
+	instance.context.instanceConf.logTopicCompression = pb.CompressionType_ZLIB
+	producerOpts = instance.newLogAppender().producerOptions()
+	assert.Equal(t, pulsar.ZLib, producerOpts.CompressionType)
+}
+
+func Test_goInstance_outputEventTimeStrategy(t *testing.T) {
+	eventTime := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		strategy string
+		check    func(t *testing.T, produced time.Time)
+	}{
+		{"", func(t *testing.T, produced time.Time) { assert.True(t, produced.IsZero()) }},
+		{OutputEventTimeNone, func(t *testing.T, produced time.Time) { assert.True(t, produced.IsZero()) }},
+		{OutputEventTimePassthrough, func(t *testing.T, produced time.Time) { assert.Equal(t, eventTime, produced) }},
+		{OutputEventTimeProcessing, func(t *testing.T, produced time.Time) {
+			assert.WithinDuration(t, time.Now(), produced, time.Minute)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			instance := newGoInstance()
+			instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/output"
+			instance.context.instanceConf.outputEventTimeStrategy = tt.strategy
+			producer := &MockPulsarProducer{}
+			instance.producer = producer
+			instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+			message := &MockMessage{
+				topic:     "persistent://public/default/topic-01",
+				messageID: &MockMessageID{},
+				eventTime: eventTime,
+			}
+
+			instance.processResult(message, []byte("output"))
+
+			assert.Len(t, producer.messages, 1)
+			tt.check(t, producer.messages[0].EventTime)
+		})
+	}
+}
+
+func Test_goInstance_multipleOutputs(t *testing.T) {
+	tests := []struct {
+		name      string
+		emitted   []string
+		output    []byte
+		sendErrAt int
+		published int
+		acked     bool
+	}{
+		{name: "zero outputs", acked: true},
+		{name: "one output", output: []byte("out-1"), published: 1, acked: true},
+		{name: "three outputs", emitted: []string{"out-1", "out-2"}, output: []byte("out-3"), published: 3, acked: true},
+		{name: "mid-emit failure", emitted: []string{"out-1", "out-2"}, output: []byte("out-3"), sendErrAt: 2,
+			published: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newGoInstance()
+			instance.context.instanceConf.funcDetails.AutoAck = true
+			instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+			instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/output"
+			// the failed output is not sent again
+			instance.context.instanceConf.onSendFailure = OnSendFailureNack
+			producer := &MockPulsarProducer{sendErrAt: tt.sendErrAt}
+			if tt.sendErrAt > 0 {
+				producer.sendErr = fmt.Errorf("send failed")
+			}
+			instance.producer = producer
+			consumer := &MockPulsarConsumer{}
+			instance.consumers["persistent://public/default/topic-01"] = consumer
+			message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+
+			for _, emitted := range tt.emitted {
+				instance.context.Emit([]byte(emitted))
+			}
+			instance.processResult(message, tt.output)
+
+			// outputs after the failed one are not emitted
+			assert.Len(t, producer.messages, tt.published)
+			for i, produced := range producer.messages {
+				assert.Equal(t, fmt.Sprintf("out-%d", i+1), string(produced.Payload))
+			}
+			if tt.acked {
+				assert.Equal(t, []pulsar.Message{message}, consumer.acked)
+				assert.Empty(t, consumer.nacked)
+			} else {
+				assert.Empty(t, consumer.acked)
+				assert.Equal(t, []pulsar.Message{message}, consumer.nacked)
+			}
+			assert.Nil(t, instance.context.emitted)
+		})
+	}
+}
+
+func Test_goInstance_outputOrderingKey(t *testing.T) {
+	rekey := func(ctx context.Context, input []byte) ([]byte, error) {
+		fc, _ := FromContext(ctx)
+		switch string(input) {
+		case "rekeyed":
+			fc.SetOutputOrderingKey("new-key")
+		case "emitting":
+			fc.Emit([]byte("emitting-1"))
+			fc.SetOutputOrderingKey("")
+		}
+		return input, nil
+	}
+	instance, _, producer := newOutputValidatorTestInstance(rekey, "")
+	channel := make(chan pulsar.ConsumerMessage, 4)
+	for _, payload := range []string{"rekeyed", "passthrough", "emitting", "passthrough"} {
+		channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+			topic:       "persistent://public/default/topic-01",
+			orderingKey: "key-" + payload,
+			messageID:   &MockMessageID{},
+			payload:     []byte(payload),
+		}}
+	}
+
+	assert.NoError(t, instance.processMessages(channel))
+
+	var orderingKeys []string
+	for _, produced := range producer.messages {
+		orderingKeys = append(orderingKeys, string(produced.Payload)+"="+produced.OrderingKey)
+	}
+	// a key set for an input applies to all of its outputs and to none of the next inputs
+	assert.Equal(t, []string{
+		"rekeyed=new-key",
+		"passthrough=key-passthrough",
+		"emitting-1=",
+		"emitting=",
+		"passthrough=key-passthrough",
+	}, orderingKeys)
+	assert.Nil(t, instance.context.orderingKey)
+}
+
+func Test_goInstance_autoUpdatePartitionsOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.partitionsUpdateInterval = 30 * time.Second
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, time.Duration(0), consumerOpts.AutoDiscoveryPeriod)
+
+	instance.context.instanceConf.autoUpdatePartitions = true
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, 30*time.Second, consumerOpts.AutoDiscoveryPeriod)
+}
+
+func Test_goInstance_lastPublishedSequenceID(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	assert.Equal(t, int64(-1), instance.context.GetLastPublishedSequenceID())
+
+	producer := &MockPulsarProducer{}
+	instance.producer = producer
+	assert.Equal(t, int64(-1), instance.context.GetLastPublishedSequenceID())
+
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+	instance.processResult(message, []byte("first"))
+	instance.processResult(message, []byte("second"))
+	assert.Equal(t, int64(1), instance.context.GetLastPublishedSequenceID())
+	assert.Equal(t, int64(1), instance.context.GetLastPublishedSequenceID("topic-02"))
+
+	// producers created through the context are looked up by topic
+	other := &MockPulsarProducer{}
+	instance.addOutputProducer("topic-03", other)
+	assert.Equal(t, int64(-1), instance.context.GetLastPublishedSequenceID("topic-03"))
+	other.SendAsync(context.Background(), &pulsar.ProducerMessage{},
+		func(pulsar.MessageID, *pulsar.ProducerMessage, error) {})
+	assert.Equal(t, int64(0), instance.context.GetLastPublishedSequenceID("persistent://public/default/topic-03"))
+	assert.Equal(t, int64(-1), instance.context.GetLastPublishedSequenceID("topic-04"))
+}
+
+func Test_goInstance_startMessageRollback(t *testing.T) {
+	instance := newGoInstance()
+	consumer := &MockPulsarConsumer{}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, instance.rollbackConsumer("persistent://public/default/topic-01", consumer, now))
+	assert.Empty(t, consumer.seekedTimes)
+
+	// the rollback applies whatever the initial position
+	instance.context.instanceConf.funcDetails.Source.SubscriptionPosition = pb.SubscriptionPosition_EARLIEST
+	instance.context.instanceConf.startMessageRollback = 90 * time.Minute
+	assert.NoError(t, instance.rollbackConsumer("persistent://public/default/topic-01", consumer, now))
+	assert.Equal(t, []time.Time{time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}, consumer.seekedTimes)
+
+	consumer.seekErr = fmt.Errorf("seek failed")
+	assert.Error(t, instance.rollbackConsumer("persistent://public/default/topic-01", consumer, now))
+}
+
+func Test_goInstance_nonPersistentConsumerOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.readCompacted = true
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, pulsar.Durable, consumerOpts.SubscriptionMode)
+	assert.True(t, consumerOpts.ReadCompacted)
+
+	consumerOpts = instance.getConsumerOptions("non-persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, pulsar.NonDurable, consumerOpts.SubscriptionMode)
+	assert.False(t, consumerOpts.ReadCompacted)
+
+	consumerOpts = instance.getConsumerOptions("non-persistent://public/default/topic-.*",
+		&pb.ConsumerSpec{IsRegexPattern: true}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, pulsar.NonDurable, consumerOpts.SubscriptionMode)
+}
+
+func Test_goInstance_ackGroupingOptions(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, &pulsar.AckGroupingOptions{}, consumerOpts.AckGroupingOptions)
+
+	instance.context.instanceConf.ackGroupingTime = 50 * time.Millisecond
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.Equal(t, &pulsar.AckGroupingOptions{
+		MaxSize: defaultAckGroupingMaxSize,
+		MaxTime: 50 * time.Millisecond,
+	}, consumerOpts.AckGroupingOptions)
+}
+
+func Test_goInstance_maxMessagesToProcess(t *testing.T) {
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.maxMessagesToProcess = 2
+	channel := inputMessages("a", "b", "c")
+
+	// stops right after the second message rather than once idle
+	start := time.Now()
+	assert.NoError(t, instance.processMessages(channel))
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+	assert.Len(t, channel, 1)
+	assert.Equal(t, []string{"a", "b"}, publishedPayloads(producer))
+	assert.Len(t, consumer.acked, 2)
+	assert.Equal(t, 1, producer.flushes)
+}
+
+func Test_goInstance_maxMessagesToProcessCountsSkipped(t *testing.T) {
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.maxMessagesToProcess = 2
+	instance.deduplication = newDeduplicationCache(payloadKey, time.Minute, 0)
+
+	assert.NoError(t, instance.processMessages(inputMessages("a", "a", "b")))
+	assert.Equal(t, []string{"a"}, publishedPayloads(producer))
+	assert.Len(t, consumer.acked, 2)
+}
+
+func Test_goInstance_ackWithResponseOption(t *testing.T) {
+	instance := newGoInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.False(t, consumerOpts.AckWithResponse)
+
+	instance.context.instanceConf.ackWithResponse = true
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.True(t, consumerOpts.AckWithResponse)
+}
+
+// confirmingConsumer holds each ack until the broker confirmation is released
+type confirmingConsumer struct {
+	MockPulsarConsumer
+	confirm chan error
+	mu      sync.Mutex
+	acked   []pulsar.Message
+}
+
+func (c *confirmingConsumer) Ack(msg pulsar.Message) error {
+	if err := <-c.confirm; err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.acked = append(c.acked, msg)
+	return nil
+}
+
+func Test_goInstance_ackWithResponse(t *testing.T) {
+	instance, _, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.ackWithResponse = true
+	consumer := &confirmingConsumer{confirm: make(chan error)}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	sysExceptions := instance.getTotalSysExceptions()
+
+	// the process loop goes on while the acks wait for their confirmation
+	assert.NoError(t, instance.processMessages(inputMessages("a", "b")))
+	assert.Equal(t, []string{"a", "b"}, publishedPayloads(producer))
+
+	consumer.confirm <- nil
+	consumer.confirm <- errors.New("broker failed to ack")
+	instance.pendingAcks.Wait()
+	assert.Len(t, consumer.acked, 1)
+	assert.Equal(t, sysExceptions+1, instance.getTotalSysExceptions())
+}
+
+func Test_goInstance_connectionClientOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authPlugin = ""
+
+	clientOpts, err := instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, defaultConnectionTimeout, clientOpts.ConnectionTimeout)
+	assert.Equal(t, defaultKeepAliveInterval, clientOpts.KeepAliveInterval)
+	assert.Equal(t, defaultOperationTimeout, clientOpts.OperationTimeout)
+
+	instance.context.instanceConf.connectionTimeout = 2 * time.Second
+	instance.context.instanceConf.keepAliveInterval = 10 * time.Second
+	instance.context.instanceConf.operationTimeout = time.Minute
+	clientOpts, err = instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, clientOpts.ConnectionTimeout)
+	assert.Equal(t, 10*time.Second, clientOpts.KeepAliveInterval)
+	assert.Equal(t, time.Minute, clientOpts.OperationTimeout)
+}
+
+func Test_goInstance_connectionsPerBrokerClientOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authPlugin = ""
+	clientOpts, err := instance.getClientOptions()
+	assert.NoError(t, err)
+	// the client opens a single connection per broker when unset
+	assert.Equal(t, 0, clientOpts.MaxConnectionsPerBroker)
+
+	instance.context.instanceConf.connectionsPerBroker = 4
+	clientOpts, err = instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, clientOpts.MaxConnectionsPerBroker)
+}
+
+func Test_goInstance_tlsClientOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.authPlugin = ""
+	instance.context.instanceConf.tlsMinVersion = tls.VersionTLS12
+	instance.context.instanceConf.tlsCipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+
+	clientOpts, err := instance.getClientOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), clientOpts.TLSMinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, clientOpts.TLSCipherSuites)
+}
+
+// tombstoneHandler records, for every message it processes, whether the
+// context reported it as a tombstone
+type tombstoneHandler struct {
+	tombstones []bool
+}
+
+func (h *tombstoneHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	fc, _ := FromContext(ctx)
+	h.tombstones = append(h.tombstones, fc.IsCurrentRecordTombstone())
+	return nil, nil
+}
+
+func Test_goInstance_tombstones(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		t.Run(fmt.Sprintf("skipTombstones=%t", skip), func(t *testing.T) {
+			handler := &tombstoneHandler{}
+			instance := newGoInstance()
+			instance.function = handler
+			instance.context.instanceConf.killAfterIdle = 200
+			instance.context.instanceConf.skipTombstones = skip
+			instance.context.instanceConf.funcDetails.AutoAck = true
+			instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+			consumer := &MockPulsarConsumer{}
+			instance.consumers["persistent://public/default/topic-01"] = consumer
+			messages := []*MockMessage{
+				{topic: "persistent://public/default/topic-01", key: "deleted", messageID: &MockMessageID{}},
+				{topic: "persistent://public/default/topic-01", payload: []byte{}, messageID: &MockMessageID{}},
+				{topic: "persistent://public/default/topic-01", key: "kept", payload: []byte("value"),
+					messageID: &MockMessageID{}},
+			}
+			channel := make(chan pulsar.ConsumerMessage, len(messages))
+			for _, message := range messages {
+				channel <- pulsar.ConsumerMessage{Message: message}
+			}
+
+			assert.NoError(t, instance.processMessages(channel))
+			// skipped tombstones are acked all the same
+			assert.Len(t, consumer.acked, 3)
+			if skip {
+				assert.Equal(t, []bool{false, false}, handler.tombstones)
+			} else {
+				assert.Equal(t, []bool{true, false, false}, handler.tombstones)
+			}
+		})
+	}
+}
+
+func Test_goInstance_sameKeyOutputsKeepInputOrder(t *testing.T) {
+	instance, _, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	channel := make(chan pulsar.ConsumerMessage, 6)
+	for i, key := range []string{"a", "b", "a", "a", "b", "a"} {
+		channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+			topic:       "persistent://public/default/topic-01",
+			orderingKey: key,
+			messageID:   &MockMessageID{},
+			payload:     []byte(fmt.Sprintf("%s-%d", key, i)),
+		}}
+	}
+
+	assert.NoError(t, instance.processMessages(channel))
+
+	published := make(map[string][]string)
+	for _, msg := range producer.messages {
+		published[msg.OrderingKey] = append(published[msg.OrderingKey], string(msg.Payload))
+	}
+	assert.Equal(t, map[string][]string{
+		"a": {"a-0", "a-2", "a-3", "a-5"},
+		"b": {"b-1", "b-4"},
+	}, published)
+}
+
+func Test_goInstance_maxMessageAge(t *testing.T) {
+	var processed []string
+	handler := func(_ context.Context, input []byte) ([]byte, error) {
+		processed = append(processed, string(input))
+		return nil, nil
+	}
+	for _, maxAge := range []time.Duration{0, time.Minute} {
+		t.Run(fmt.Sprintf("maxMessageAge=%v", maxAge), func(t *testing.T) {
+			processed = nil
+			instance := newGoInstance()
+			instance.function = newFunction(handler)
+			instance.context.instanceConf.killAfterIdle = 200
+			instance.context.instanceConf.maxMessageAge = maxAge
+			instance.context.instanceConf.funcDetails.AutoAck = true
+			instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+			consumer := &MockPulsarConsumer{}
+			instance.consumers["persistent://public/default/topic-01"] = consumer
+			expired := instance.getTotalExpiredMessages()
+			now := time.Now()
+			messages := []*MockMessage{
+				{payload: []byte("stale"), publishTime: now.Add(-time.Hour)},
+				{payload: []byte("fresh"), publishTime: now.Add(-time.Second)},
+				{payload: []byte("borderline"), publishTime: now.Add(-time.Minute + 10*time.Second)},
+			}
+			channel := make(chan pulsar.ConsumerMessage, len(messages))
+			for _, message := range messages {
+				message.topic = "persistent://public/default/topic-01"
+				message.messageID = &MockMessageID{}
+				channel <- pulsar.ConsumerMessage{Message: message}
+			}
+
+			assert.NoError(t, instance.processMessages(channel))
+			// skipped messages are acked all the same
+			assert.Len(t, consumer.acked, 3)
+			if maxAge > 0 {
+				assert.Equal(t, []string{"fresh", "borderline"}, processed)
+				assert.Equal(t, expired+1, instance.getTotalExpiredMessages())
+			} else {
+				assert.Equal(t, []string{"stale", "fresh", "borderline"}, processed)
+				assert.Equal(t, expired, instance.getTotalExpiredMessages())
+			}
+		})
+	}
+}
@@ -27,31 +27,30 @@ import (
 	log "github.com/apache/pulsar/pulsar-function-go/logutil"
 )
 
+// StructuredLogEventProperty marks the log topic messages published by
+// FunctionContext.EmitLogEvent, their payload is a JSON encoded event
+const StructuredLogEventProperty = "STRUCTURED_EVENT"
+
 type LogAppender struct {
-	pulsarClient pulsar.Client
-	logTopic     string
-	fqn          string
-	producer     pulsar.Producer
+	pulsarClient    pulsar.Client
+	logTopic        string
+	fqn             string
+	compressionType pulsar.CompressionType
+	producer        pulsar.Producer
 }
 
 func NewLogAppender(client pulsar.Client, logTopic, fqn string) *LogAppender {
 	logAppender := &LogAppender{
-		pulsarClient: client,
-		logTopic:     logTopic,
-		fqn:          fqn,
+		pulsarClient:    client,
+		logTopic:        logTopic,
+		fqn:             fqn,
+		compressionType: pulsar.LZ4,
 	}
 	return logAppender
 }
 
 func (la *LogAppender) Start() error {
-	producer, err := la.pulsarClient.CreateProducer(pulsar.ProducerOptions{
-		Topic:                   la.logTopic,
-		CompressionType:         pulsar.LZ4,
-		BatchingMaxPublishDelay: 100 * time.Millisecond,
-		Properties: map[string]string{
-			"function": la.fqn,
-		},
-	})
+	producer, err := la.pulsarClient.CreateProducer(la.producerOptions())
 	if err != nil {
 		log.Errorf("create producer error:%s", err.Error())
 		return err
@@ -60,10 +59,28 @@ func (la *LogAppender) Start() error {
 	return nil
 }
 
+func (la *LogAppender) producerOptions() pulsar.ProducerOptions {
+	return pulsar.ProducerOptions{
+		Topic:                   la.logTopic,
+		CompressionType:         la.compressionType,
+		BatchingMaxPublishDelay: 100 * time.Millisecond,
+		Properties: map[string]string{
+			"function": la.fqn,
+		},
+	}
+}
+
 func (la *LogAppender) Append(logByte []byte) {
+	la.AppendWithProperties(logByte, nil)
+}
+
+// AppendWithProperties publishes logByte to the log topic with the given
+// message properties
+func (la *LogAppender) AppendWithProperties(logByte []byte, properties map[string]string) {
 	ctx := context.Background()
 	asyncMsg := pulsar.ProducerMessage{
-		Payload: logByte,
+		Payload:    logByte,
+		Properties: properties,
 	}
 	la.producer.SendAsync(ctx, &asyncMsg, func(id pulsar.MessageID, message *pulsar.ProducerMessage, err error) {
 		if err != nil {
@@ -0,0 +1,70 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"time"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// MetricsLogEventType is the event of the metrics snapshots published to the
+// log topic
+const MetricsLogEventType = "metrics"
+
+// MetricsLogEvent is the structured log event of a metrics snapshot, for
+// deployments that watch the log topic rather than scrape the metrics port
+type MetricsLogEvent struct {
+	Event                      string  `json:"event"`
+	Timestamp                  int64   `json:"timestamp"`
+	TotalReceived              int64   `json:"totalReceived"`
+	TotalProcessedSuccessfully int64   `json:"totalProcessedSuccessfully"`
+	TotalUserExceptions        int64   `json:"totalUserExceptions"`
+	TotalSysExceptions         int64   `json:"totalSysExceptions"`
+	AvgProcessLatencyMs        float64 `json:"avgProcessLatencyMs"`
+}
+
+func newMetricsLogEvent(snapshot MetricsSnapshot, now time.Time) MetricsLogEvent {
+	return MetricsLogEvent{
+		Event:                      MetricsLogEventType,
+		Timestamp:                  now.UnixMilli(),
+		TotalReceived:              snapshot.TotalReceived,
+		TotalProcessedSuccessfully: snapshot.TotalProcessedSuccessfully,
+		TotalUserExceptions:        snapshot.TotalUserExceptions,
+		TotalSysExceptions:         snapshot.TotalSysExceptions,
+		AvgProcessLatencyMs:        snapshot.AvgProcessLatencyMs,
+	}
+}
+
+// startLogTopicMetrics publishes a metrics snapshot to the log topic every
+// logTopicMetricsInterval, on the timers of the instance so that the
+// snapshots stop with them
+func (gi *goInstance) startLogTopicMetrics() {
+	interval := gi.context.instanceConf.logTopicMetricsInterval
+	if interval == 0 || gi.context.logAppender == nil {
+		return
+	}
+	gi.registerTimer(interval, func(ctx context.Context) {
+		if err := gi.context.EmitMetricsSnapshot(); err != nil {
+			log.Warnf("failed to publish the metrics snapshot to the log topic: %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// newLogTopicMetricsTestInstance returns an instance whose log topic is
+// published to producer
+func newLogTopicMetricsTestInstance(interval time.Duration) (*goInstance, *MockPulsarProducer) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.LogTopic = "persistent://public/default/log-topic"
+	instance.context.instanceConf.logTopicMetricsInterval = interval
+	producer := &MockPulsarProducer{}
+	instance.context.logAppender = &LogAppender{logTopic: instance.context.GetLogTopic(), producer: producer}
+	return instance, producer
+}
+
+func TestFunctionContext_EmitMetricsSnapshot(t *testing.T) {
+	instance, producer := newLogTopicMetricsTestInstance(0)
+	// the metrics are shared by all instances with the same labels
+	before := instance.context.GetMetricsSnapshot()
+	instance.stats.incrTotalReceived()
+	instance.stats.incrTotalReceived()
+	instance.stats.incrTotalProcessedSuccessfully()
+	instance.stats.incrTotalUserExceptions(errors.New("boom"))
+
+	assert.NoError(t, instance.context.EmitMetricsSnapshot())
+
+	if assert.Len(t, producer.messages, 1) {
+		assert.Equal(t, map[string]string{StructuredLogEventProperty: "true"}, producer.messages[0].Properties)
+		var event MetricsLogEvent
+		assert.NoError(t, json.Unmarshal(producer.messages[0].Payload, &event))
+		assert.Equal(t, MetricsLogEventType, event.Event)
+		assert.WithinDuration(t, time.Now(), time.UnixMilli(event.Timestamp), time.Second)
+		assert.Equal(t, int64(2), event.TotalReceived-before.TotalReceived)
+		assert.Equal(t, int64(1), event.TotalProcessedSuccessfully-before.TotalProcessedSuccessfully)
+		assert.Equal(t, int64(1), event.TotalUserExceptions-before.TotalUserExceptions)
+		assert.Equal(t, before.TotalSysExceptions, event.TotalSysExceptions)
+	}
+}
+
+func TestLogTopicMetrics_PublishedPeriodically(t *testing.T) {
+	instance, producer := newLogTopicMetricsTestInstance(10 * time.Millisecond)
+	defer instance.close()
+	instance.startLogTopicMetrics()
+
+	// the snapshots are published on the timers, which hold timersMu
+	published := func() int {
+		instance.timersMu.Lock()
+		defer instance.timersMu.Unlock()
+		return len(producer.messages)
+	}
+	assert.Eventually(t, func() bool { return published() >= 3 }, time.Second, 5*time.Millisecond)
+
+	instance.timersMu.Lock()
+	defer instance.timersMu.Unlock()
+	for _, msg := range producer.messages {
+		var event MetricsLogEvent
+		assert.NoError(t, json.Unmarshal(msg.Payload, &event))
+		assert.Equal(t, MetricsLogEventType, event.Event)
+	}
+}
+
+func TestLogTopicMetrics_Disabled(t *testing.T) {
+	instance, producer := newLogTopicMetricsTestInstance(0)
+	defer instance.close()
+	instance.startLogTopicMetrics()
+
+	// without a log topic the snapshots are not published anywhere
+	withoutLogTopic := newGoInstance()
+	defer withoutLogTopic.close()
+	withoutLogTopic.context.instanceConf.funcDetails.LogTopic = ""
+	withoutLogTopic.context.instanceConf.logTopicMetricsInterval = 10 * time.Millisecond
+	withoutLogTopic.startLogTopicMetrics()
+	assert.NoError(t, withoutLogTopic.context.EmitMetricsSnapshot())
+
+	time.Sleep(50 * time.Millisecond)
+	instance.timersMu.Lock()
+	defer instance.timersMu.Unlock()
+	assert.Empty(t, producer.messages)
+}
+
+func TestInstanceConf_LogTopicMetricsInterval(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, LogTopicMetricsIntervalMs: 30000})
+	assert.Equal(t, 30*time.Second, instanceConf.logTopicMetricsInterval)
+
+	assert.PanicsWithValue(t, "logTopicMetricsIntervalMs must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, LogTopicMetricsIntervalMs: -1})
+	})
+}
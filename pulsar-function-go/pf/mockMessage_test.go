@@ -21,19 +21,27 @@ package pf
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
 )
 
 type MockMessage struct {
-	properties map[string]string
-	messageID  *MockMessageID
-	payload    []byte
+	topic           string
+	key             string
+	orderingKey     string
+	properties      map[string]string
+	messageID       *MockMessageID
+	payload         []byte
+	redeliveryCount uint32
+	eventTime       time.Time
+	schemaVersion   []byte
+	publishTime     time.Time
 }
 
 func (m *MockMessage) Topic() string {
-	return ""
+	return m.topic
 }
 
 func (m *MockMessage) ProducerName() string {
@@ -53,23 +61,26 @@ func (m *MockMessage) ID() pulsar.MessageID {
 }
 
 func (m *MockMessage) PublishTime() time.Time {
-	return time.Now()
+	if m.publishTime.IsZero() {
+		return time.Now()
+	}
+	return m.publishTime
 }
 
 func (m *MockMessage) EventTime() time.Time {
-	return time.Now()
+	return m.eventTime
 }
 
 func (m *MockMessage) Key() string {
-	return "key"
+	return m.key
 }
 
 func (m *MockMessage) OrderingKey() string {
-	return "orderingKey"
+	return m.orderingKey
 }
 
 func (m *MockMessage) RedeliveryCount() uint32 {
-	return 1
+	return m.redeliveryCount
 }
 
 func (m *MockMessage) IsReplicated() bool {
@@ -84,22 +95,37 @@ func (m *MockMessage) GetSchemaValue(v interface{}) error {
 	return nil
 }
 
+func (m *MockMessage) SchemaVersion() []byte {
+	return m.schemaVersion
+}
+
 func (m *MockMessage) GetEncryptionContext() *pulsar.EncryptionContext {
 	return nil
 }
 
-type MockMessageID struct{}
+func (m *MockMessage) Index() *uint64 {
+	return nil
+}
+
+func (m *MockMessage) BrokerPublishTime() *time.Time {
+	return nil
+}
+
+type MockMessageID struct {
+	ledgerID int64
+	entryID  int64
+}
 
 func (m *MockMessageID) Serialize() []byte {
 	return []byte(`message-id`)
 }
 
 func (m *MockMessageID) LedgerID() int64 {
-	return 0
+	return m.ledgerID
 }
 
 func (m *MockMessageID) EntryID() int64 {
-	return 0
+	return m.entryID
 }
 
 func (m *MockMessageID) BatchIdx() int32 {
@@ -110,7 +136,25 @@ func (m *MockMessageID) PartitionIdx() int32 {
 	return 0
 }
 
-type MockPulsarProducer struct{}
+func (m *MockMessageID) BatchSize() int32 {
+	return 0
+}
+
+func (m *MockMessageID) String() string {
+	return "0:0:0"
+}
+
+type MockPulsarProducer struct {
+	// guards the fields against the sends retried in the background
+	mu       sync.Mutex
+	messages []*pulsar.ProducerMessage
+	sendErr  error
+	// when set, only the sendErrAt-th (1-based) send fails with sendErr
+	sendErrAt int
+	published int64
+	flushes   int
+	closed    bool
+}
 
 func (producer *MockPulsarProducer) Topic() string {
 	return "publish-topic"
@@ -120,21 +164,135 @@ func (producer *MockPulsarProducer) Name() string {
 	return "publish-producer"
 }
 
-func (producer *MockPulsarProducer) Send(context.Context, *pulsar.ProducerMessage) (pulsar.MessageID, error) {
-	return nil, nil
+func (producer *MockPulsarProducer) Send(_ context.Context, msg *pulsar.ProducerMessage) (pulsar.MessageID, error) {
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	producer.messages = append(producer.messages, msg)
+	if producer.sendErr != nil && (producer.sendErrAt == 0 || producer.sendErrAt == len(producer.messages)) {
+		return nil, producer.sendErr
+	}
+	producer.published++
+	return &MockMessageID{}, nil
 }
 
-func (producer *MockPulsarProducer) SendAsync(context.Context, *pulsar.ProducerMessage,
-	func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+func (producer *MockPulsarProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage,
+	callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	producer.mu.Lock()
+	producer.messages = append(producer.messages, msg)
+	if producer.sendErr != nil && (producer.sendErrAt == 0 || producer.sendErrAt == len(producer.messages)) {
+		sendErr := producer.sendErr
+		producer.mu.Unlock()
+		callback(nil, msg, sendErr)
+		return
+	}
+	producer.published++
+	producer.mu.Unlock()
+	callback(&MockMessageID{}, msg, nil)
 }
 
+// LastSequenceID follows the client, the first sequence id is 0 and -1 means nothing has been published
 func (producer *MockPulsarProducer) LastSequenceID() int64 {
-	return int64(10)
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	return producer.published - 1
 }
 
 func (producer *MockPulsarProducer) Flush() error {
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	producer.flushes++
 	return nil
 }
 
 func (producer *MockPulsarProducer) Close() {
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	producer.closed = true
+}
+
+type MockPulsarConsumer struct {
+	acked           []pulsar.Message
+	nacked          []pulsar.Message
+	reconsumed      []pulsar.Message
+	reconsumeDelays []time.Duration
+	reconsumeProps  []map[string]string
+	seeked          []pulsar.MessageID
+	seekedTimes     []time.Time
+	seekErr         error
+	closed          bool
+}
+
+func (consumer *MockPulsarConsumer) Subscription() string {
+	return "mock-subscription"
+}
+
+func (consumer *MockPulsarConsumer) Unsubscribe() error {
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) Receive(context.Context) (pulsar.Message, error) {
+	return nil, nil
+}
+
+func (consumer *MockPulsarConsumer) Chan() <-chan pulsar.ConsumerMessage {
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) Ack(msg pulsar.Message) error {
+	consumer.acked = append(consumer.acked, msg)
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) AckID(pulsar.MessageID) error {
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) AckWithTxn(pulsar.Message, pulsar.Transaction) error {
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) AckCumulative(pulsar.Message) error {
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) AckIDCumulative(pulsar.MessageID) error {
+	return nil
+}
+
+func (consumer *MockPulsarConsumer) ReconsumeLater(msg pulsar.Message, delay time.Duration) {
+	consumer.reconsumed = append(consumer.reconsumed, msg)
+	consumer.reconsumeDelays = append(consumer.reconsumeDelays, delay)
+	consumer.reconsumeProps = append(consumer.reconsumeProps, nil)
+}
+
+func (consumer *MockPulsarConsumer) ReconsumeLaterWithCustomProperties(msg pulsar.Message,
+	customProperties map[string]string, delay time.Duration) {
+	consumer.reconsumed = append(consumer.reconsumed, msg)
+	consumer.reconsumeDelays = append(consumer.reconsumeDelays, delay)
+	consumer.reconsumeProps = append(consumer.reconsumeProps, customProperties)
+}
+
+func (consumer *MockPulsarConsumer) Nack(msg pulsar.Message) {
+	consumer.nacked = append(consumer.nacked, msg)
+}
+
+func (consumer *MockPulsarConsumer) NackID(pulsar.MessageID) {
+}
+
+func (consumer *MockPulsarConsumer) Close() {
+	consumer.closed = true
+}
+
+func (consumer *MockPulsarConsumer) Seek(msgID pulsar.MessageID) error {
+	consumer.seeked = append(consumer.seeked, msgID)
+	return consumer.seekErr
+}
+
+func (consumer *MockPulsarConsumer) SeekByTime(t time.Time) error {
+	consumer.seekedTimes = append(consumer.seekedTimes, t)
+	return consumer.seekErr
+}
+
+func (consumer *MockPulsarConsumer) Name() string {
+	return "mock-consumer"
 }
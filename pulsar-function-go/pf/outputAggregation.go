@@ -0,0 +1,176 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// The thresholds flushing the aggregated outputs when they are not configured
+const (
+	defaultAggregationMaxRecords = 100
+	defaultAggregationMaxDelay   = time.Second
+)
+
+// Record is an output of the function, along with the input it was produced for
+type Record struct {
+	Input  pulsar.Message
+	Output []byte
+}
+
+// OutputCombiner combines buffered outputs into the payload of a single
+// message published to the output topic. Returning nil publishes nothing.
+type OutputCombiner func(records []Record) []byte
+
+var (
+	outputCombinerMu         sync.Mutex
+	registeredOutputCombiner OutputCombiner
+)
+
+// RegisterOutputCombiner makes the function buffer its outputs rather than
+// publishing them one by one. Once outputAggregationMaxRecords outputs are
+// buffered, or the oldest one has waited for outputAggregationMaxDelayMs, the
+// combiner turns them into a single message. The inputs are acked once that
+// message is published. It must be called before Start.
+func RegisterOutputCombiner(combiner OutputCombiner) {
+	outputCombinerMu.Lock()
+	defer outputCombinerMu.Unlock()
+	registeredOutputCombiner = combiner
+}
+
+func getOutputCombiner() OutputCombiner {
+	outputCombinerMu.Lock()
+	defer outputCombinerMu.Unlock()
+	return registeredOutputCombiner
+}
+
+// outputAggregator buffers the outputs of the function until they are
+// combined and published
+type outputAggregator struct {
+	gi         *goInstance
+	combiner   OutputCombiner
+	maxRecords int
+	maxDelay   time.Duration
+
+	mu      sync.Mutex
+	records []Record
+	// the inputs of the buffered records, each one once
+	inputs []pulsar.Message
+	timer  *time.Timer
+	// tells apart the batches, a timer only flushes the one it was set for
+	batch int
+}
+
+// setupOutputAggregation buffers the outputs when an output combiner is
+// registered
+func (gi *goInstance) setupOutputAggregation() error {
+	combiner := getOutputCombiner()
+	if combiner == nil {
+		return nil
+	}
+	if gi.context.instanceConf.funcDetails.Sink.Topic == "" {
+		return withExitCode(ExitCodeConfigError,
+			errors.New("an output combiner is registered with RegisterOutputCombiner, but no output topic is configured"))
+	}
+	gi.aggregator = newOutputAggregator(gi, combiner)
+	return nil
+}
+
+func newOutputAggregator(gi *goInstance, combiner OutputCombiner) *outputAggregator {
+	aggregator := &outputAggregator{
+		gi:         gi,
+		combiner:   combiner,
+		maxRecords: gi.context.instanceConf.aggregationMaxRecords,
+		maxDelay:   gi.context.instanceConf.aggregationMaxDelay,
+	}
+	if aggregator.maxRecords == 0 {
+		aggregator.maxRecords = defaultAggregationMaxRecords
+	}
+	if aggregator.maxDelay == 0 {
+		aggregator.maxDelay = defaultAggregationMaxDelay
+	}
+	return aggregator
+}
+
+// add buffers the outputs of input, flushing them if they make up
+// maxRecords, or else once the first buffered one has waited for maxDelay
+func (a *outputAggregator) add(input pulsar.Message, outputs [][]byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, output := range outputs {
+		a.records = append(a.records, Record{Input: input, Output: output})
+	}
+	a.inputs = append(a.inputs, input)
+	if len(a.records) >= a.maxRecords {
+		a.flushLocked()
+		return
+	}
+	if a.timer == nil {
+		batch := a.batch
+		a.timer = time.AfterFunc(a.maxDelay, func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.batch == batch {
+				a.flushLocked()
+			}
+		})
+	}
+}
+
+// flush publishes the buffered outputs, if there are any
+func (a *outputAggregator) flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.flushLocked()
+}
+
+func (a *outputAggregator) flushLocked() {
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	a.batch++
+	records, inputs := a.records, a.inputs
+	a.records, a.inputs = nil, nil
+	if len(inputs) == 0 {
+		return
+	}
+
+	payload := a.combiner(records)
+	if payload == nil {
+		for _, input := range inputs {
+			a.gi.respondResult(input, nil)
+		}
+		return
+	}
+	msg := &pulsar.ProducerMessage{
+		Payload:   payload,
+		EventTime: a.gi.getOutputEventTime(inputs[len(inputs)-1]),
+	}
+	a.gi.sendToSink(msg, func(err error) {
+		for _, input := range inputs {
+			a.gi.respondResult(input, err)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// joinOutputs combines the outputs separated by commas
+func joinOutputs(records []Record) []byte {
+	outputs := make([][]byte, 0, len(records))
+	for _, record := range records {
+		outputs = append(outputs, record.Output)
+	}
+	return bytes.Join(outputs, []byte(","))
+}
+
+func newAggregationTestInstance(t *testing.T, maxRecords int,
+	maxDelay time.Duration) (*goInstance, *MockPulsarConsumer, *MockPulsarProducer) {
+	RegisterOutputCombiner(joinOutputs)
+	t.Cleanup(func() { RegisterOutputCombiner(nil) })
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.aggregationMaxRecords = maxRecords
+	instance.context.instanceConf.aggregationMaxDelay = maxDelay
+	assert.NoError(t, instance.setupOutputAggregation())
+	return instance, consumer, producer
+}
+
+func publishedPayloads(producer *MockPulsarProducer) []string {
+	var payloads []string
+	for _, msg := range producer.messages {
+		payloads = append(payloads, string(msg.Payload))
+	}
+	return payloads
+}
+
+func TestOutputAggregation_FlushesOnRecordCount(t *testing.T) {
+	instance, consumer, producer := newAggregationTestInstance(t, 3, time.Hour)
+
+	assert.NoError(t, instance.processMessages(inputMessages("1", "2", "3", "4", "5", "6", "7")))
+
+	assert.Equal(t, []string{"1,2,3", "4,5,6"}, publishedPayloads(producer))
+	// the last input is acked only once its output is published
+	assert.Len(t, consumer.acked, 6)
+
+	instance.aggregator.flush()
+	assert.Equal(t, []string{"1,2,3", "4,5,6", "7"}, publishedPayloads(producer))
+	assert.Len(t, consumer.acked, 7)
+}
+
+func TestOutputAggregation_FlushesAfterMaxDelay(t *testing.T) {
+	instance, consumer, producer := newAggregationTestInstance(t, 100, 20*time.Millisecond)
+
+	assert.NoError(t, instance.processMessages(inputMessages("1", "2")))
+
+	assert.Eventually(t, func() bool {
+		instance.aggregator.mu.Lock()
+		defer instance.aggregator.mu.Unlock()
+		return len(producer.messages) == 1 && len(consumer.acked) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1,2"}, publishedPayloads(producer))
+}
+
+func TestOutputAggregation_SendErrorNacksInputs(t *testing.T) {
+	instance, consumer, producer := newAggregationTestInstance(t, 2, time.Hour)
+	producer.sendErr = errors.New("producer closed")
+	instance.context.instanceConf.onSendFailure = OnSendFailureNack
+
+	assert.NoError(t, instance.processMessages(inputMessages("1", "2")))
+
+	assert.Empty(t, consumer.acked)
+	assert.Len(t, consumer.nacked, 2)
+}
+
+func TestOutputAggregation_NothingCombined(t *testing.T) {
+	RegisterOutputCombiner(func(records []Record) []byte { return nil })
+	t.Cleanup(func() { RegisterOutputCombiner(nil) })
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.aggregationMaxRecords = 2
+	assert.NoError(t, instance.setupOutputAggregation())
+
+	assert.NoError(t, instance.processMessages(inputMessages("1", "2")))
+
+	assert.Empty(t, producer.messages)
+	assert.Len(t, consumer.acked, 2)
+}
+
+func TestOutputAggregation_RequiresOutputTopic(t *testing.T) {
+	RegisterOutputCombiner(joinOutputs)
+	t.Cleanup(func() { RegisterOutputCombiner(nil) })
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Sink.Topic = ""
+
+	err := instance.setupOutputAggregation()
+	assert.ErrorContains(t, err, "no output topic is configured")
+	assert.Equal(t, ExitCodeConfigError, exitCodeOf(err))
+}
+
+func TestInstanceConf_OutputAggregation(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OutputAggregationMaxRecords: 10,
+		OutputAggregationMaxDelayMs: 500})
+	assert.Equal(t, 10, instanceConf.aggregationMaxRecords)
+	assert.Equal(t, 500*time.Millisecond, instanceConf.aggregationMaxDelay)
+
+	assert.PanicsWithValue(t, "outputAggregationMaxRecords and outputAggregationMaxDelayMs must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OutputAggregationMaxDelayMs: -1})
+	})
+}
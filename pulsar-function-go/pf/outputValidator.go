@@ -0,0 +1,70 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OutputValidator checks a payload before it is published to the output topic.
+// A returned error keeps the payload from being published and fails the input
+// message, which is then handled as set by onProcessError.
+type OutputValidator func(payload []byte) error
+
+var (
+	outputValidatorMu         sync.Mutex
+	registeredOutputValidator OutputValidator
+)
+
+// RegisterOutputValidator sets the validator run on every output of the
+// function before it is published. It must be called before Start.
+func RegisterOutputValidator(validator OutputValidator) {
+	outputValidatorMu.Lock()
+	defer outputValidatorMu.Unlock()
+	registeredOutputValidator = validator
+}
+
+func getOutputValidator() OutputValidator {
+	outputValidatorMu.Lock()
+	defer outputValidatorMu.Unlock()
+	return registeredOutputValidator
+}
+
+// validateOutputs runs the registered validator on the outputs of the current
+// input that are going to be published, the emitted ones included. None of them
+// is published when one fails.
+func (gi *goInstance) validateOutputs(output []byte) error {
+	validator := getOutputValidator()
+	if validator == nil || gi.context.instanceConf.funcDetails.Sink.Topic == "" {
+		return nil
+	}
+	outputs := gi.context.emitted
+	if output != nil {
+		outputs = append(outputs[:len(outputs):len(outputs)], output)
+	}
+	for _, payload := range outputs {
+		if err := validator(payload); err != nil {
+			gi.context.emitted = nil
+			return fmt.Errorf("output validation failed: %w", err)
+		}
+	}
+	return nil
+}
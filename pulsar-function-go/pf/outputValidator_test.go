@@ -0,0 +1,120 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+func registerTestOutputValidator(t *testing.T) {
+	RegisterOutputValidator(func(payload []byte) error {
+		if bytes.HasPrefix(payload, []byte("bad")) {
+			return errors.New("malformed output")
+		}
+		return nil
+	})
+	t.Cleanup(func() { RegisterOutputValidator(nil) })
+}
+
+func newOutputValidatorTestInstance(handler func(ctx context.Context, input []byte) ([]byte, error),
+	onProcessError string) (*goInstance, *MockPulsarConsumer, *MockPulsarProducer) {
+	instance := newGoInstance()
+	instance.function = newFunction(handler)
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/output"
+	instance.context.instanceConf.onProcessError = onProcessError
+	instance.context.instanceConf.killAfterIdle = 200
+	producer := &MockPulsarProducer{}
+	instance.producer = producer
+	consumer := &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	return instance, consumer, producer
+}
+
+func inputMessages(payloads ...string) chan pulsar.ConsumerMessage {
+	channel := make(chan pulsar.ConsumerMessage, len(payloads))
+	for _, payload := range payloads {
+		channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+			topic:     "persistent://public/default/topic-01",
+			messageID: &MockMessageID{},
+			payload:   []byte(payload),
+		}}
+	}
+	return channel
+}
+
+func echo(_ context.Context, input []byte) ([]byte, error) {
+	return input, nil
+}
+
+func TestOutputValidator_RejectedOutputsAreNotPublished(t *testing.T) {
+	registerTestOutputValidator(t)
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	skipped := instance.getTotalProcessErrors(OnProcessErrorSkip)
+	userExceptions, sysExceptions := instance.getTotalUserExceptions(), instance.getTotalSysExceptions()
+
+	assert.NoError(t, instance.processMessages(inputMessages("good-1", "bad-1", "good-2")))
+
+	assert.Len(t, producer.messages, 2)
+	assert.Equal(t, "good-1", string(producer.messages[0].Payload))
+	assert.Equal(t, "good-2", string(producer.messages[1].Payload))
+	// the rejected input is skipped, and acked as the others
+	assert.Len(t, consumer.acked, 3)
+	assert.Equal(t, skipped+1, instance.getTotalProcessErrors(OnProcessErrorSkip))
+	// the output is the function's, its rejection is a user exception
+	assert.Equal(t, userExceptions+1, instance.getTotalUserExceptions())
+	assert.Equal(t, sysExceptions, instance.getTotalSysExceptions())
+}
+
+func TestOutputValidator_RejectedEmitFailsAllOutputs(t *testing.T) {
+	registerTestOutputValidator(t)
+	emitBad := func(ctx context.Context, input []byte) ([]byte, error) {
+		fc, _ := FromContext(ctx)
+		fc.Emit([]byte("bad-emit"))
+		return input, nil
+	}
+	instance, consumer, producer := newOutputValidatorTestInstance(emitBad, OnProcessErrorFail)
+
+	err := instance.processMessages(inputMessages("good-1"))
+
+	assert.EqualError(t, err, "output validation failed: malformed output")
+	assert.Empty(t, producer.messages)
+	assert.Empty(t, consumer.acked)
+	assert.Len(t, consumer.nacked, 1)
+}
+
+func TestOutputValidator_NotRunWithoutOutputTopic(t *testing.T) {
+	registerTestOutputValidator(t)
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorFail)
+	instance.context.instanceConf.funcDetails.Sink.Topic = ""
+
+	assert.NoError(t, instance.processMessages(inputMessages("bad-1")))
+	assert.Empty(t, producer.messages)
+	assert.Len(t, consumer.acked, 1)
+}
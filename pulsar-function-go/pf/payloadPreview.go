@@ -0,0 +1,58 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// defaultMaxPayloadPreviewBytes bounds the payload previews when
+// maxPayloadPreviewBytes is not configured
+const defaultMaxPayloadPreviewBytes = 256
+
+// payloadPreview returns the start of the payload of msg, to be appended to the
+// error logs about it, or an empty string unless logPayloadOnError is set.
+// The payload is quoted, or hex encoded with payloadPreviewHex.
+func (gi *goInstance) payloadPreview(msg pulsar.Message) string {
+	if !gi.context.instanceConf.logPayloadOnError || msg == nil {
+		return ""
+	}
+	payload := msg.Payload()
+	limit := gi.context.instanceConf.maxPayloadPreviewBytes
+	if limit == 0 {
+		limit = defaultMaxPayloadPreviewBytes
+	}
+	size := len(payload)
+	if size > limit {
+		payload = payload[:limit]
+	}
+	preview := strconv.Quote(string(payload))
+	if gi.context.instanceConf.payloadPreviewHex {
+		preview = hex.EncodeToString(payload)
+	}
+	if size > limit {
+		return fmt.Sprintf(", payload (first %d of %d bytes): %s", limit, size, preview)
+	}
+	return ", payload: " + preview
+}
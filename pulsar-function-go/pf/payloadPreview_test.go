@@ -0,0 +1,99 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+func TestPayloadPreview(t *testing.T) {
+	msg := &MockMessage{payload: []byte("card=4111\n")}
+	tests := []struct {
+		name     string
+		enabled  bool
+		maxBytes int
+		hex      bool
+		want     string
+	}{
+		{name: "disabled by default", want: ""},
+		{name: "quoted", enabled: true, want: `, payload: "card=4111\n"`},
+		{name: "truncated", enabled: true, maxBytes: 4, want: `, payload (first 4 of 10 bytes): "card"`},
+		{name: "hex", enabled: true, hex: true, want: ", payload: 636172643d343131310a"},
+		{name: "hex truncated", enabled: true, maxBytes: 2, hex: true, want: ", payload (first 2 of 10 bytes): 6361"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newGoInstance()
+			instance.context.instanceConf.logPayloadOnError = tt.enabled
+			instance.context.instanceConf.maxPayloadPreviewBytes = tt.maxBytes
+			instance.context.instanceConf.payloadPreviewHex = tt.hex
+			assert.Equal(t, tt.want, instance.payloadPreview(msg))
+		})
+	}
+
+	large := &MockMessage{payload: []byte(strings.Repeat("a", 1000))}
+	instance := newGoInstance()
+	instance.context.instanceConf.logPayloadOnError = true
+	assert.Equal(t, `, payload (first 256 of 1000 bytes): "`+strings.Repeat("a", 256)+`"`,
+		instance.payloadPreview(large))
+}
+
+func TestPayloadPreview_ErrorLogs(t *testing.T) {
+	failing := func(_ context.Context, input []byte) ([]byte, error) {
+		return nil, errors.New("downstream unavailable")
+	}
+	for _, enabled := range []bool{false, true} {
+		hook := recordLogEntries(t)
+		instance, _, _ := newOutputValidatorTestInstance(failing, OnProcessErrorSkip)
+		instance.context.instanceConf.logPayloadOnError = enabled
+
+		assert.NoError(t, instance.processMessages(inputMessages("secret-order-42")))
+		var logged []string
+		for _, entry := range hook.AllEntries() {
+			if strings.HasPrefix(entry.Message, "handler message error") {
+				logged = append(logged, entry.Message)
+			}
+		}
+		if enabled {
+			assert.Equal(t, []string{`handler message error:downstream unavailable, payload: "secret-order-42"`}, logged)
+		} else {
+			assert.Equal(t, []string{"handler message error:downstream unavailable"}, logged)
+		}
+	}
+}
+
+func TestInstanceConf_PayloadPreview(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, LogPayloadOnError: true,
+		MaxPayloadPreviewBytes: 64, PayloadPreviewHex: true})
+	assert.True(t, instanceConf.logPayloadOnError)
+	assert.Equal(t, 64, instanceConf.maxPayloadPreviewBytes)
+	assert.True(t, instanceConf.payloadPreviewHex)
+
+	assert.PanicsWithValue(t, "maxPayloadPreviewBytes must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MaxPayloadPreviewBytes: -1})
+	})
+}
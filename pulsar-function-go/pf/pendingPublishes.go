@@ -0,0 +1,73 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// pendingLimitProducer bounds the number of messages in flight on a producer
+// created through the function context. The slots are shared by all such
+// producers of the instance, a send blocks until one is free.
+type pendingLimitProducer struct {
+	pulsar.Producer
+	slots chan struct{}
+}
+
+func newPendingLimitProducer(producer pulsar.Producer, slots chan struct{}) pulsar.Producer {
+	return &pendingLimitProducer{Producer: producer, slots: slots}
+}
+
+// acquire waits for a free slot, or for ctx to be done
+func (p *pendingLimitProducer) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *pendingLimitProducer) release() {
+	<-p.slots
+}
+
+func (p *pendingLimitProducer) Send(ctx context.Context, msg *pulsar.ProducerMessage) (pulsar.MessageID, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer p.release()
+	return p.Producer.Send(ctx, msg)
+}
+
+// SendAsync holds the slot until the send completes
+func (p *pendingLimitProducer) SendAsync(ctx context.Context, msg *pulsar.ProducerMessage,
+	callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	if err := p.acquire(ctx); err != nil {
+		callback(nil, msg, err)
+		return
+	}
+	p.Producer.SendAsync(ctx, msg, func(id pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
+		p.release()
+		callback(id, msg, err)
+	})
+}
@@ -0,0 +1,142 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+// deferredProducer completes its async sends only when asked to
+type deferredProducer struct {
+	MockPulsarProducer
+	mu      sync.Mutex
+	pending []func()
+}
+
+func (p *deferredProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage,
+	callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, func() {
+		callback(&MockMessageID{}, msg, nil)
+	})
+}
+
+// complete finishes the oldest pending send
+func (p *deferredProducer) complete() {
+	p.mu.Lock()
+	next := p.pending[0]
+	p.pending = p.pending[1:]
+	p.mu.Unlock()
+	next()
+}
+
+func sendAsyncInBackground(producer pulsar.Producer) <-chan struct{} {
+	sent := make(chan struct{})
+	go func() {
+		producer.SendAsync(context.Background(), &pulsar.ProducerMessage{},
+			func(pulsar.MessageID, *pulsar.ProducerMessage, error) {})
+		close(sent)
+	}()
+	return sent
+}
+
+func TestPendingLimitProducer_BlocksAtLimit(t *testing.T) {
+	slots := make(chan struct{}, 2)
+	first, second := &deferredProducer{}, &deferredProducer{}
+	// the limit is shared by all producers of the instance
+	limitedFirst := newPendingLimitProducer(first, slots)
+	limitedSecond := newPendingLimitProducer(second, slots)
+
+	<-sendAsyncInBackground(limitedFirst)
+	<-sendAsyncInBackground(limitedSecond)
+
+	blocked := sendAsyncInBackground(limitedFirst)
+	select {
+	case <-blocked:
+		t.Fatal("send should block while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	second.complete()
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("send should unblock once a pending send completes")
+	}
+	assert.Len(t, slots, 2)
+
+	first.complete()
+	first.complete()
+	assert.Len(t, slots, 0)
+}
+
+func TestPendingLimitProducer_ContextDone(t *testing.T) {
+	slots := make(chan struct{}, 1)
+	producer := &deferredProducer{}
+	limited := newPendingLimitProducer(producer, slots)
+	<-sendAsyncInBackground(limited)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var sendErr error
+	limited.SendAsync(ctx, &pulsar.ProducerMessage{}, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+		sendErr = err
+	})
+	assert.ErrorIs(t, sendErr, context.Canceled)
+	_, err := limited.Send(ctx, &pulsar.ProducerMessage{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, producer.pending, 1)
+}
+
+func TestPendingLimitProducer_SyncSendReleases(t *testing.T) {
+	slots := make(chan struct{}, 1)
+	producer := &MockPulsarProducer{}
+	limited := newPendingLimitProducer(producer, slots)
+	for i := 0; i < 3; i++ {
+		_, err := limited.Send(context.Background(), &pulsar.ProducerMessage{})
+		assert.NoError(t, err)
+	}
+	assert.Len(t, producer.messages, 3)
+	assert.Len(t, slots, 0)
+}
+
+func TestNewOutputMessage_MaxPendingPublishes(t *testing.T) {
+	client := &producingClient{producers: make(map[string][]*MockPulsarProducer)}
+	instance := newGoInstance()
+	instance.client = client
+	producer := instance.context.NewOutputMessage("persistent://public/default/unbounded")
+	assert.IsType(t, &MockPulsarProducer{}, producer)
+
+	instance.publishSlots = make(chan struct{}, 1)
+	producer = instance.context.NewOutputMessage("persistent://public/default/bounded")
+	assert.IsType(t, &pendingLimitProducer{}, producer)
+	_, err := producer.Send(context.Background(), &pulsar.ProducerMessage{Payload: []byte("out")})
+	assert.NoError(t, err)
+	assert.Len(t, client.producers["persistent://public/default/bounded"][0].messages, 1)
+	// the wrapped producer still reports its sequence ids
+	assert.Equal(t, int64(0), instance.context.GetLastPublishedSequenceID("persistent://public/default/bounded"))
+}
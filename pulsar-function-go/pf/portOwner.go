@@ -0,0 +1,86 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const tcpListenState = "0A"
+
+// findListeningPID returns the id of the process listening on the given TCP
+// port. The lookup relies on procfs and returns 0 when the owner cannot be
+// determined, e.g. on platforms other than Linux or without permission to
+// inspect other processes.
+func findListeningPID(port int) int {
+	inodes := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, inode := range listeningSocketInodes(table, port) {
+			inodes[inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return 0
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			// /proc/<pid>/fd/<fd>
+			pid, err := strconv.Atoi(filepath.Base(filepath.Dir(filepath.Dir(fd))))
+			if err == nil {
+				return pid
+			}
+		}
+	}
+	return 0
+}
+
+// listeningSocketInodes parses a /proc/net/tcp style table and returns the
+// inodes of the sockets listening on port
+func listeningSocketInodes(table string, port int) []string {
+	file, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	portSuffix := fmt.Sprintf(":%04X", port)
+	var inodes []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || !strings.HasSuffix(fields[1], portSuffix) || fields[3] != tcpListenState {
+			continue
+		}
+		inodes = append(inodes, fields[9])
+	}
+	return inodes
+}
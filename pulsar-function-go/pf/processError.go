@@ -0,0 +1,91 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+// Ways to handle a message the function failed on, once the retries are
+// exhausted. They are also the outcome label of the process_errors_total metric.
+const (
+	// OnProcessErrorRetry sends the message to the dead letter topic if there
+	// is one. Without one, a message that exhausted maxMessageRetries is
+	// dropped as a poison message, that is acked as with OnProcessErrorSkip,
+	// and otherwise it is nacked for redelivery and the instance stops. It is
+	// the default.
+	OnProcessErrorRetry = "retry"
+	// OnProcessErrorSkip acks the message and carries on with the next one
+	OnProcessErrorSkip = "skip"
+	// OnProcessErrorDeadLetter sends the message to the dead letter topic,
+	// which must be configured, and carries on with the next one
+	OnProcessErrorDeadLetter = "dlt"
+	// OnProcessErrorFail nacks the message and stops the instance, even when
+	// there is a dead letter topic
+	OnProcessErrorFail = "fail"
+)
+
+// handleProcessError handles msg, which the function failed on with
+// processErr, as onProcessError says. It returns processErr when the instance
+// must stop.
+func (gi *goInstance) handleProcessError(msg pulsar.Message, processErr error, retryCount int32) error {
+	atLeastOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATLEAST_ONCE
+	autoAck := gi.context.instanceConf.funcDetails.AutoAck
+
+	outcome := gi.context.instanceConf.onProcessError
+	if outcome == "" {
+		outcome = OnProcessErrorRetry
+	}
+	poison := false
+	if outcome == OnProcessErrorRetry {
+		switch {
+		case gi.hasDeadLetterTopic(msg):
+			outcome = OnProcessErrorDeadLetter
+		case gi.context.instanceConf.funcDetails.RetryDetails.GetMaxMessageRetries() > 0:
+			outcome = OnProcessErrorSkip
+			poison = true
+		}
+	}
+	gi.stats.incrTotalProcessErrors(outcome)
+
+	switch outcome {
+	case OnProcessErrorDeadLetter:
+		gi.handleFailedMessage(msg, processErr, retryCount)
+		return nil
+	case OnProcessErrorSkip:
+		if poison {
+			log.Warnf("dropped poison message ID %s after %d retries, there is no dead letter topic: %v%s",
+				messageIDStr(msg), retryCount, processErr, gi.payloadPreview(msg))
+			gi.stats.incrTotalDroppedPoisonMessages()
+		}
+		if autoAck && atLeastOnce {
+			gi.ackInputMessage(msg)
+		}
+		return nil
+	default:
+		if autoAck && atLeastOnce {
+			gi.nackInputMessage(msg)
+		}
+		return processErr
+	}
+}
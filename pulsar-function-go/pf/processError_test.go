@@ -0,0 +1,202 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// getTotalProcessErrors returns the process_errors_total metric of gi for outcome
+func (gi *goInstance) getTotalProcessErrors(outcome string) float32 {
+	for _, family := range gi.getFilteredMetricFamilies(metricsPrefix + TotalProcessErrors) {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["fqfn"] == gi.context.GetTenantAndNamespaceAndName() && labels["outcome"] == outcome {
+				return float32(metric.GetGauge().GetValue())
+			}
+		}
+	}
+	return 0
+}
+
+// recordLogEntries returns a hook recording what is logged until the end of t
+func recordLogEntries(t *testing.T) *test.Hook {
+	logger := logrus.StandardLogger()
+	hook := new(test.Hook)
+	hooks := make(logrus.LevelHooks)
+	for level, levelHooks := range logger.Hooks {
+		hooks[level] = append([]logrus.Hook{}, levelHooks...)
+	}
+	hooks.Add(hook)
+	original := logger.ReplaceHooks(hooks)
+	t.Cleanup(func() { logger.ReplaceHooks(original) })
+	return hook
+}
+
+func TestHandleProcessError(t *testing.T) {
+	tests := []struct {
+		name           string
+		onProcessError string
+		deadLetter     bool
+		outcome        string
+		stops          bool
+		acked          bool
+	}{
+		{name: "default without dead letter topic", outcome: OnProcessErrorSkip, acked: true},
+		{name: "default with dead letter topic", deadLetter: true, outcome: OnProcessErrorDeadLetter, acked: true},
+		{name: "retry without dead letter topic", onProcessError: OnProcessErrorRetry, outcome: OnProcessErrorSkip,
+			acked: true},
+		{name: "retry with dead letter topic", onProcessError: OnProcessErrorRetry, deadLetter: true,
+			outcome: OnProcessErrorDeadLetter, acked: true},
+		{name: "skip", onProcessError: OnProcessErrorSkip, deadLetter: true, outcome: OnProcessErrorSkip, acked: true},
+		{name: "dlt", onProcessError: OnProcessErrorDeadLetter, deadLetter: true, outcome: OnProcessErrorDeadLetter,
+			acked: true},
+		{name: "fail", onProcessError: OnProcessErrorFail, deadLetter: true, outcome: OnProcessErrorFail, stops: true},
+		{name: "fail without dead letter topic", onProcessError: OnProcessErrorFail, outcome: OnProcessErrorFail,
+			stops: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordRetrySleeps(t)
+			instance, consumer, producer := newDeadLetterTestInstance(1)
+			if !tt.deadLetter {
+				instance.context.instanceConf.funcDetails.RetryDetails.DeadLetterTopic = ""
+			}
+			instance.context.instanceConf.onProcessError = tt.onProcessError
+			instance.context.instanceConf.funcDetails.AutoAck = true
+			instance.context.instanceConf.killAfterIdle = 200
+			handler := &failingHandler{failures: 100}
+			instance.function = handler
+			before := instance.getTotalProcessErrors(tt.outcome)
+
+			channel := make(chan pulsar.ConsumerMessage, 1)
+			channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+				topic:     "persistent://public/default/topic-01",
+				messageID: &MockMessageID{},
+				payload:   []byte("input"),
+			}}
+			err := instance.processMessages(channel)
+
+			// the retries come first whatever the mode
+			assert.Equal(t, 2, handler.calls)
+			if tt.stops {
+				assert.EqualError(t, err, "boom")
+			} else {
+				assert.NoError(t, err)
+			}
+			if tt.acked {
+				assert.Len(t, consumer.acked, 1)
+				assert.Empty(t, consumer.nacked)
+			} else {
+				assert.Empty(t, consumer.acked)
+				assert.Len(t, consumer.nacked, 1)
+			}
+			if tt.outcome == OnProcessErrorDeadLetter {
+				assert.Len(t, producer.messages, 1)
+			} else {
+				assert.Empty(t, producer.messages)
+			}
+			assert.Equal(t, before+1, instance.getTotalProcessErrors(tt.outcome))
+		})
+	}
+}
+
+func TestInstanceConf_OnProcessError(t *testing.T) {
+	for _, mode := range []string{"", "retry", "skip", "fail"} {
+		instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OnProcessError: mode})
+		assert.Equal(t, mode, instanceConf.onProcessError)
+	}
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees: 3,
+		DeadLetterTopic:      "persistent://public/default/topic-01-DLQ",
+		OnProcessError:       "dlt",
+	})
+	assert.Equal(t, OnProcessErrorDeadLetter, instanceConf.onProcessError)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OnProcessError: "dlt"})
+	}, "Should have a panic")
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OnProcessError: "ignore"})
+	}, "Should have a panic")
+}
+
+func TestHandleProcessError_DropsPoisonMessage(t *testing.T) {
+	recordRetrySleeps(t)
+	entries := recordLogEntries(t)
+	instance, consumer, _ := newDeadLetterTestInstance(2)
+	instance.context.instanceConf.funcDetails.RetryDetails.DeadLetterTopic = ""
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.killAfterIdle = 200
+	handler := &failingHandler{failures: 100}
+	instance.function = handler
+	dropped := instance.getTotalDroppedPoisonMessages()
+
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+		topic:     "persistent://public/default/topic-01",
+		messageID: &MockMessageID{},
+		payload:   []byte("input"),
+	}}
+
+	assert.NoError(t, instance.processMessages(channel))
+	assert.Equal(t, 3, handler.calls)
+	assert.Len(t, consumer.acked, 1)
+	assert.Empty(t, consumer.nacked)
+	assert.Equal(t, dropped+1, instance.getTotalDroppedPoisonMessages())
+
+	var warnings []string
+	for _, entry := range entries.AllEntries() {
+		if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "dropped poison message") {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		"dropped poison message ID 0:0:0:0 after 2 retries, there is no dead letter topic: boom",
+	}, warnings)
+}
+
+func TestHandleProcessError_KeepsMessageWithoutRetries(t *testing.T) {
+	instance, consumer, _ := newDeadLetterTestInstance(0)
+	instance.context.instanceConf.funcDetails.RetryDetails.DeadLetterTopic = ""
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	dropped := instance.getTotalDroppedPoisonMessages()
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+
+	err := instance.handleProcessError(message, errors.New("boom"), 0)
+
+	// without maxMessageRetries there is nothing exhausted, the message is redelivered
+	assert.EqualError(t, err, "boom")
+	assert.Empty(t, consumer.acked)
+	assert.Len(t, consumer.nacked, 1)
+	assert.Equal(t, dropped, instance.getTotalDroppedPoisonMessages())
+}
@@ -0,0 +1,76 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket capping the number of messages processed per
+// second. The bucket holds a single token so that messages are spread evenly
+// rather than let through in bursts at the start of every second.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		tokens:   1,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the caller has
+// to wait before the token is available
+func (r *rateLimiter) reserve() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if !r.last.IsZero() {
+		r.tokens += float64(now.Sub(r.last)) / float64(r.interval)
+		if r.tokens > 1 {
+			r.tokens = 1
+		}
+	}
+	r.last = now
+	r.tokens--
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens * float64(r.interval))
+}
+
+// wait blocks until a token is available, it reports whether it had to
+func (r *rateLimiter) wait() bool {
+	delay := r.reserve()
+	if delay <= 0 {
+		return false
+	}
+	r.sleep(delay)
+	return true
+}
@@ -0,0 +1,94 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+// timingHandler records when each message was processed
+type timingHandler struct {
+	times []time.Time
+}
+
+func (h *timingHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	h.times = append(h.times, time.Now())
+	return nil, nil
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(4)
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+	var slept []time.Duration
+	limiter.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+
+	// the first message goes through, the next ones are spread 250ms apart
+	assert.False(t, limiter.wait())
+	assert.True(t, limiter.wait())
+	assert.True(t, limiter.wait())
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, slept)
+
+	// tokens accumulate while idle, but no more than one
+	now = now.Add(10 * time.Second)
+	assert.False(t, limiter.wait())
+	assert.True(t, limiter.wait())
+	assert.Equal(t, 250*time.Millisecond, slept[2])
+
+	slept = nil
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, limiter.wait())
+	assert.Equal(t, []time.Duration{150 * time.Millisecond}, slept)
+}
+
+func Test_goInstance_maxProcessingRate(t *testing.T) {
+	const rate, messages = 50, 20
+	handler := &timingHandler{}
+	instance := newGoInstance()
+	instance.function = handler
+	instance.context.instanceConf.killAfterIdle = 200
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.rateLimiter = newRateLimiter(rate)
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	throttled := instance.getTotalThrottled()
+
+	channel := make(chan pulsar.ConsumerMessage, messages)
+	for i := 0; i < messages; i++ {
+		channel <- pulsar.ConsumerMessage{
+			Message: &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}},
+		}
+	}
+	assert.NoError(t, instance.processMessages(channel))
+
+	assert.Len(t, handler.times, messages)
+	elapsed := handler.times[messages-1].Sub(handler.times[0])
+	effectiveRate := float64(messages-1) / elapsed.Seconds()
+	assert.LessOrEqual(t, effectiveRate, float64(rate)*1.05)
+	assert.Equal(t, float32(messages-1), instance.getTotalThrottled()-throttled)
+}
@@ -0,0 +1,74 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// newReader creates a reader of topic starting at startMessageID, which is
+// only read first with startMessageIDInclusive
+func (gi *goInstance) newReader(topic string, startMessageID pulsar.MessageID) (pulsar.Reader, error) {
+	if gi.client == nil {
+		return nil, errors.New("the client of the instance is not set up yet")
+	}
+	reader, err := gi.client.CreateReader(pulsar.ReaderOptions{
+		Topic:                   topic,
+		StartMessageID:          startMessageID,
+		StartMessageIDInclusive: gi.context.instanceConf.startMessageIDInclusive,
+	})
+	if err != nil {
+		log.Errorf("failed to create a reader of %s: %v", topic, err)
+		return nil, err
+	}
+	return reader, nil
+}
+
+// setSeekBoundary makes the message at msgID, which the subscriptions have
+// been reset to, skipped when it is redelivered, unless
+// startMessageIDInclusive is set
+func (gi *goInstance) setSeekBoundary(msgID pulsar.MessageID) {
+	gi.seekMu.Lock()
+	defer gi.seekMu.Unlock()
+	gi.seekBoundary = nil
+	if !gi.context.instanceConf.startMessageIDInclusive {
+		gi.seekBoundary = msgID
+	}
+}
+
+// skipSeekBoundary tells whether msg is the message the subscriptions were
+// last reset to, all of its batch unless a batch index was given
+func (gi *goInstance) skipSeekBoundary(msg pulsar.Message) bool {
+	gi.seekMu.Lock()
+	defer gi.seekMu.Unlock()
+	return gi.seekBoundary != nil && sameMessageID(gi.seekBoundary, msg.ID())
+}
+
+// sameMessageID tells whether id is the message target identifies, target
+// leaving out its partition and batch indexes when they are -1
+func sameMessageID(target, id pulsar.MessageID) bool {
+	return target.LedgerID() == id.LedgerID() && target.EntryID() == id.EntryID() &&
+		(target.PartitionIdx() < 0 || target.PartitionIdx() == id.PartitionIdx()) &&
+		(target.BatchIdx() < 0 || target.BatchIdx() == id.BatchIdx())
+}
@@ -0,0 +1,110 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// readerClient records the options of the readers it is asked to create
+type readerClient struct {
+	pulsar.Client
+	options []pulsar.ReaderOptions
+}
+
+func (c *readerClient) CreateReader(options pulsar.ReaderOptions) (pulsar.Reader, error) {
+	c.options = append(c.options, options)
+	return nil, nil
+}
+
+func TestNewReader_StartMessageIDInclusive(t *testing.T) {
+	instance := newGoInstance()
+	client := &readerClient{}
+	instance.client = client
+	start := pulsar.NewMessageID(12, 34, -1, 0)
+
+	_, err := instance.context.NewReader("persistent://public/default/topic-01", start)
+	assert.NoError(t, err)
+	instance.context.instanceConf.startMessageIDInclusive = true
+	_, err = instance.context.NewReader("persistent://public/default/topic-01", start)
+	assert.NoError(t, err)
+
+	if assert.Len(t, client.options, 2) {
+		assert.Equal(t, "persistent://public/default/topic-01", client.options[0].Topic)
+		assert.Equal(t, start, client.options[0].StartMessageID)
+		assert.False(t, client.options[0].StartMessageIDInclusive)
+		assert.True(t, client.options[1].StartMessageIDInclusive)
+	}
+
+	_, err = NewFuncContext().NewReader("persistent://public/default/topic-01", start)
+	assert.EqualError(t, err, "creating readers is not supported by this instance")
+}
+
+func TestSeekToMessageID_Boundary(t *testing.T) {
+	boundary := func(payload string, entryID int64) pulsar.ConsumerMessage {
+		return pulsar.ConsumerMessage{Message: &MockMessage{topic: "persistent://public/default/topic-01",
+			messageID: &MockMessageID{ledgerID: 12, entryID: entryID}, payload: []byte(payload)}}
+	}
+	tests := []struct {
+		name      string
+		inclusive bool
+		published []string
+	}{
+		{name: "exclusive", published: []string{"35"}},
+		{name: "inclusive", inclusive: true, published: []string{"34", "35"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+			instance.context.instanceConf.startMessageIDInclusive = tt.inclusive
+			assert.NoError(t, instance.seekToMessageID(pulsar.NewMessageID(12, 34, -1, -1)))
+
+			// the subscription is redelivered from the message it was reset to
+			channel := make(chan pulsar.ConsumerMessage, 2)
+			channel <- boundary("34", 34)
+			channel <- boundary("35", 35)
+			assert.NoError(t, instance.processMessages(channel))
+
+			assert.Equal(t, tt.published, publishedPayloads(producer))
+			assert.Len(t, consumer.acked, 2)
+		})
+	}
+}
+
+func TestSameMessageID(t *testing.T) {
+	id := pulsar.NewMessageID(12, 34, 2, 1)
+	assert.True(t, sameMessageID(pulsar.NewMessageID(12, 34, -1, -1), id))
+	assert.True(t, sameMessageID(pulsar.NewMessageID(12, 34, 2, 1), id))
+	assert.False(t, sameMessageID(pulsar.NewMessageID(12, 34, 3, 1), id))
+	assert.False(t, sameMessageID(pulsar.NewMessageID(12, 34, 2, 0), id))
+	assert.False(t, sameMessageID(pulsar.NewMessageID(12, 35, -1, -1), id))
+}
+
+func TestInstanceConf_StartMessageIDInclusive(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
+	assert.False(t, instanceConf.startMessageIDInclusive)
+	instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, StartMessageIDInclusive: true})
+	assert.True(t, instanceConf.startMessageIDInclusive)
+}
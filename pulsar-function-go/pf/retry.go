@@ -0,0 +1,194 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+const (
+	defaultRetryBackoffInitial = 100 * time.Millisecond
+	defaultRetryBackoffMax     = 10 * time.Second
+)
+
+// errRetriesInterrupted is returned for a message the instance was closed
+// while waiting to retry
+var errRetriesInterrupted = errors.New("the instance closed while waiting to retry the message")
+
+// retrySleep waits for d, or until ctx is done, and reports whether it waited d
+// out. It is swapped out in tests so that backoff delays can be observed.
+var retrySleep = func(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryBackoff computes exponentially increasing delays, capped at max
+type retryBackoff struct {
+	next time.Duration
+	max  time.Duration
+}
+
+func newRetryBackoff(initial, max time.Duration) *retryBackoff {
+	if initial <= 0 {
+		initial = defaultRetryBackoffInitial
+	}
+	if max <= 0 {
+		max = defaultRetryBackoffMax
+	}
+	if initial > max {
+		initial = max
+	}
+	return &retryBackoff{next: initial, max: max}
+}
+
+func (b *retryBackoff) Next() time.Duration {
+	delay := b.next
+	b.next *= 2
+	if b.next > b.max {
+		b.next = b.max
+	}
+	return delay
+}
+
+// nackBackoffPolicy delays the redelivery of nacked messages exponentially with
+// the number of times they have been redelivered already, from min up to max
+type nackBackoffPolicy struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (p *nackBackoffPolicy) Next(redeliveryCount uint32) time.Duration {
+	delay := p.min
+	for i := uint32(0); i < redeliveryCount && delay < p.max; i++ {
+		delay *= 2
+	}
+	if delay > p.max {
+		delay = p.max
+	}
+	return delay
+}
+
+// handlerMsgWithRetries invokes the function on input, retrying it up to
+// maxMessageRetries times with exponential backoff between attempts. It returns
+// the number of retries made along with the result of the last attempt.
+//
+// When a dead letter topic is configured, the redelivery count kept by the
+// broker counts as retries already made, so that poison messages are detected
+// across instance restarts: the in-instance retries only make up for the
+// remaining ones, and a message redelivered more than maxMessageRetries times
+// is not processed again but reported as failed right away.
+func (gi *goInstance) handlerMsgWithRetries(input pulsar.Message) (output []byte, retryCount int32, err error) {
+	maxMessageRetries := gi.context.instanceConf.funcDetails.RetryDetails.GetMaxMessageRetries()
+	if gi.hasDeadLetterTopic(input) {
+		retryCount = int32(input.RedeliveryCount())
+		if retryCount > maxMessageRetries {
+			return nil, retryCount, fmt.Errorf("message delivered %d times, exceeding maxMessageRetries %d",
+				retryCount+1, maxMessageRetries)
+		}
+	}
+	// the backoff state belongs to this message only
+	backoff := newRetryBackoff(gi.context.instanceConf.retryBackoffInitial, gi.context.instanceConf.retryBackoffMax)
+	for {
+		output, err = gi.handlerMsg(input)
+		if err == nil || gi.context.reconsumed {
+			return output, retryCount, nil
+		}
+		deserialization := gi.reportDeserializationError(input, err)
+		if retryCount >= maxMessageRetries {
+			// a message counts once, however many attempts it took
+			gi.stats.incrTotalUserExceptions(err)
+			return nil, retryCount, err
+		}
+		retryCount++
+		gi.stats.incrTotalRetries()
+		delay := backoff.Next()
+		if !deserialization {
+			log.Warnf("handler message ID %s error:%v, retrying (%d/%d) in %v%s",
+				messageIDStr(input), err, retryCount, maxMessageRetries, delay, gi.payloadPreview(input))
+		}
+		if !retrySleep(gi.retriesCtx, delay) {
+			return nil, retryCount, fmt.Errorf("%w, message ID %s", errRetriesInterrupted, messageIDStr(input))
+		}
+	}
+}
+
+// ReconsumePropertyPrefix is prepended to the names of the properties attached
+// with FunctionContext.ReconsumeLaterWithProperties, to tell them apart from
+// those the message was published with
+const ReconsumePropertyPrefix = "RECONSUME_PROPERTY_"
+
+// reconsumeLater sends the message being processed to the retry letter topic,
+// along with props, to be delivered again after delay. A message that has
+// already been reconsumed maxMessageRetries times goes to the dead letter
+// topic instead.
+func (gi *goInstance) reconsumeLater(delay time.Duration, props map[string]string) error {
+	if gi.context.instanceConf.retryLetterTopic == "" {
+		return errors.New("no retry letter topic configured")
+	}
+	msg := gi.context.GetCurrentRecord()
+	if msg == nil {
+		return errors.New("no message is being processed")
+	}
+	if gi.context.reconsumed {
+		return fmt.Errorf("message ID %s is already reconsumed", messageIDStr(msg))
+	}
+	consumer, err := gi.getConsumer(msg)
+	if err != nil {
+		return err
+	}
+	if consumer == nil {
+		return fmt.Errorf("no consumer for topic %s", msg.Topic())
+	}
+
+	maxMessageRetries := gi.context.instanceConf.funcDetails.RetryDetails.GetMaxMessageRetries()
+	reconsumeTimes, _ := strconv.Atoi(msg.Properties()[pulsar.SysPropertyReconsumeTimes])
+	gi.context.reconsumed = true
+	if int32(reconsumeTimes) >= maxMessageRetries && gi.hasDeadLetterTopic(msg) {
+		gi.handleFailedMessage(msg,
+			fmt.Errorf("message reconsumed %d times, exceeding maxMessageRetries", reconsumeTimes),
+			int32(reconsumeTimes))
+		return nil
+	}
+	if len(props) == 0 {
+		consumer.ReconsumeLater(msg, delay)
+		return nil
+	}
+	// the client merges them with the properties of the message
+	customProperties := make(map[string]string, len(props))
+	for key, value := range props {
+		customProperties[ReconsumePropertyPrefix+key] = value
+	}
+	consumer.ReconsumeLaterWithCustomProperties(msg, customProperties, delay)
+	return nil
+}
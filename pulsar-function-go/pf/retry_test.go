@@ -0,0 +1,411 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+type failingHandler struct {
+	failures int
+	calls    int
+}
+
+func (h *failingHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	h.calls++
+	if h.calls <= h.failures {
+		return nil, errors.New("boom")
+	}
+	return []byte(`output`), nil
+}
+
+func recordRetrySleeps(t *testing.T) *[]time.Duration {
+	delays := &[]time.Duration{}
+	original := retrySleep
+	retrySleep = func(_ context.Context, d time.Duration) bool {
+		*delays = append(*delays, d)
+		return true
+	}
+	t.Cleanup(func() {
+		retrySleep = original
+	})
+	return delays
+}
+
+func TestRetryBackoff_Next(t *testing.T) {
+	backoff := newRetryBackoff(100*time.Millisecond, 500*time.Millisecond)
+	assert.Equal(t, 100*time.Millisecond, backoff.Next())
+	assert.Equal(t, 200*time.Millisecond, backoff.Next())
+	assert.Equal(t, 400*time.Millisecond, backoff.Next())
+	assert.Equal(t, 500*time.Millisecond, backoff.Next())
+	assert.Equal(t, 500*time.Millisecond, backoff.Next())
+
+	backoff = newRetryBackoff(0, 0)
+	assert.Equal(t, defaultRetryBackoffInitial, backoff.Next())
+}
+
+func TestNackBackoffPolicy_Next(t *testing.T) {
+	policy := &nackBackoffPolicy{min: 100 * time.Millisecond, max: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.Next(0))
+	assert.Equal(t, 200*time.Millisecond, policy.Next(1))
+	assert.Equal(t, 800*time.Millisecond, policy.Next(3))
+	assert.Equal(t, time.Second, policy.Next(4))
+	assert.Equal(t, time.Second, policy.Next(math.MaxUint32))
+}
+
+func TestRetry_SucceedsAfterBackoff(t *testing.T) {
+	delays := recordRetrySleeps(t)
+	instance, _, _ := newDeadLetterTestInstance(5)
+	instance.context.instanceConf.retryBackoffInitial = 10 * time.Millisecond
+	instance.context.instanceConf.retryBackoffMax = time.Second
+	handler := &failingHandler{failures: 3}
+	instance.function = handler
+
+	output, retryCount, err := instance.handlerMsgWithRetries(&MockMessage{messageID: &MockMessageID{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "output", string(output))
+	assert.Equal(t, int32(3), retryCount)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond}, *delays)
+
+	// the next message starts again from the initial backoff
+	*delays = nil
+	handler.calls = 0
+	_, _, err = instance.handlerMsgWithRetries(&MockMessage{messageID: &MockMessageID{}})
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, (*delays)[0])
+}
+
+func TestRetry_DeadLetterAtMaxRetries(t *testing.T) {
+	delays := recordRetrySleeps(t)
+	instance, consumer, producer := newDeadLetterTestInstance(2)
+	instance.context.instanceConf.retryBackoffInitial = 10 * time.Millisecond
+	handler := &failingHandler{failures: 10}
+	instance.function = handler
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+	userExceptions, retries := instance.getTotalUserExceptions(), instance.getTotalRetries()
+
+	_, retryCount, err := instance.handlerMsgWithRetries(message)
+	assert.Error(t, err)
+	assert.Equal(t, 3, handler.calls)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, *delays)
+	// the message is a single user exception, the attempts after the first are retries
+	assert.Equal(t, userExceptions+1, instance.getTotalUserExceptions())
+	assert.Equal(t, retries+2, instance.getTotalRetries())
+
+	instance.handleFailedMessage(message, err, retryCount)
+	assert.Len(t, producer.messages, 1)
+	assert.Equal(t, "2", producer.messages[0].Properties[DeadLetterRetryCountProperty])
+	assert.Len(t, consumer.acked, 1)
+}
+
+// blockedHandler fails every message, signalling each attempt
+type blockedHandler struct {
+	attempts chan struct{}
+}
+
+func (h *blockedHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	h.attempts <- struct{}{}
+	return nil, errors.New("boom")
+}
+
+func TestRetry_CloseInterruptsBackoff(t *testing.T) {
+	instance, consumer, producer := newDeadLetterTestInstance(5)
+	instance.context.instanceConf.retryBackoffInitial = time.Hour
+	handler := &blockedHandler{attempts: make(chan struct{}, 1)}
+	instance.function = handler
+
+	processed := make(chan error, 1)
+	go func() {
+		processed <- instance.processMessages(inputMessages("1"))
+	}()
+	<-handler.attempts
+	instance.close()
+
+	select {
+	case err := <-processed:
+		assert.ErrorIs(t, err, errRetriesInterrupted)
+	case <-time.After(5 * time.Second):
+		t.Fatal("close did not interrupt the retry backoff")
+	}
+	// the message is neither dead-lettered nor acked, the broker redelivers it
+	assert.Empty(t, producer.messages)
+	assert.Empty(t, consumer.acked)
+	assert.Empty(t, consumer.nacked)
+	assert.True(t, consumer.closed)
+}
+
+func TestRetry_BrokerRedeliveriesCountAsRetries(t *testing.T) {
+	delays := recordRetrySleeps(t)
+	instance, _, _ := newDeadLetterTestInstance(3)
+	instance.context.instanceConf.retryBackoffInitial = 10 * time.Millisecond
+	handler := &failingHandler{failures: 10}
+	instance.function = handler
+	message := &MockMessage{
+		topic:           "persistent://public/default/topic-01",
+		messageID:       &MockMessageID{},
+		redeliveryCount: 2,
+	}
+
+	// 2 retries were made by earlier deliveries, one is left
+	_, retryCount, err := instance.handlerMsgWithRetries(message)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), retryCount)
+	assert.Equal(t, 2, handler.calls)
+	assert.Len(t, *delays, 1)
+}
+
+func TestRetry_PoisonMessageGoesStraightToDeadLetter(t *testing.T) {
+	recordRetrySleeps(t)
+	instance, consumer, producer := newDeadLetterTestInstance(3)
+	instance.context.instanceConf.killAfterIdle = 200
+	handler := &failingHandler{}
+	instance.function = handler
+	message := &MockMessage{
+		topic:           "persistent://public/default/topic-01",
+		messageID:       &MockMessageID{},
+		redeliveryCount: 4,
+	}
+
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	channel <- pulsar.ConsumerMessage{Message: message}
+	assert.NoError(t, instance.processMessages(channel))
+
+	assert.Equal(t, 0, handler.calls)
+	assert.Len(t, producer.messages, 1)
+	assert.Equal(t, "4", producer.messages[0].Properties[DeadLetterRetryCountProperty])
+	assert.Equal(t, "message delivered 5 times, exceeding maxMessageRetries 3",
+		producer.messages[0].Properties[DeadLetterErrorProperty])
+	assert.Equal(t, []pulsar.Message{message}, consumer.acked)
+}
+
+func TestRetry_BrokerRedeliveriesWithoutDeadLetterTopic(t *testing.T) {
+	recordRetrySleeps(t)
+	instance, _, _ := newDeadLetterTestInstance(3)
+	instance.context.instanceConf.funcDetails.RetryDetails.DeadLetterTopic = ""
+	handler := &failingHandler{failures: 10}
+	instance.function = handler
+
+	// without a dead letter topic there is nowhere to route poison messages to
+	_, retryCount, err := instance.handlerMsgWithRetries(&MockMessage{messageID: &MockMessageID{}, redeliveryCount: 5})
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), retryCount)
+	assert.Equal(t, 4, handler.calls)
+}
+
+func TestRetry_NoRetriesConfigured(t *testing.T) {
+	delays := recordRetrySleeps(t)
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.RetryDetails = &pb.RetryDetails{}
+	handler := &failingHandler{failures: 1}
+	instance.function = handler
+
+	_, retryCount, err := instance.handlerMsgWithRetries(&MockMessage{messageID: &MockMessageID{}})
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), retryCount)
+	assert.Equal(t, 1, handler.calls)
+	assert.Empty(t, *delays)
+}
+
+type reconsumingHandler struct {
+	delay time.Duration
+	err   error
+	calls int
+}
+
+func (h *reconsumingHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	h.calls++
+	fc, _ := FromContext(ctx)
+	h.err = fc.ReconsumeLater(h.delay)
+	return []byte(`output`), errors.New("retry later")
+}
+
+func newRetryLetterTestInstance() (*goInstance, *MockPulsarConsumer, *MockPulsarProducer) {
+	instance, consumer, deadLetterProducer := newDeadLetterTestInstance(3)
+	instance.context.instanceConf.retryLetterTopic = "persistent://public/default/topic-01-RETRY"
+	instance.consumers["persistent://public/default/topic-01-RETRY"] = consumer
+	return instance, consumer, deadLetterProducer
+}
+
+func TestReconsumeLater_RoutesToRetryLetterTopic(t *testing.T) {
+	delays := recordRetrySleeps(t)
+	instance, consumer, deadLetterProducer := newRetryLetterTestInstance()
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.context.instanceConf.killAfterIdle = 200
+	producer := &MockPulsarProducer{}
+	instance.producer = producer
+	handler := &reconsumingHandler{delay: 5 * time.Second}
+	instance.function = handler
+
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+	channel <- pulsar.ConsumerMessage{Message: message}
+	assert.NoError(t, instance.processMessages(channel))
+
+	assert.NoError(t, handler.err)
+	// the error returned along with reconsuming does not trigger in-instance retries
+	assert.Equal(t, 1, handler.calls)
+	assert.Empty(t, *delays)
+	assert.Equal(t, []pulsar.Message{message}, consumer.reconsumed)
+	assert.Equal(t, []time.Duration{5 * time.Second}, consumer.reconsumeDelays)
+	// the message is neither acked, nacked nor its output published
+	assert.Empty(t, consumer.acked)
+	assert.Empty(t, consumer.nacked)
+	assert.Empty(t, producer.messages)
+	assert.Empty(t, deadLetterProducer.messages)
+}
+
+func TestReconsumeLater_DeadLetterAtMaxRetries(t *testing.T) {
+	instance, consumer, deadLetterProducer := newRetryLetterTestInstance()
+	handler := &reconsumingHandler{}
+	instance.function = handler
+
+	// delivered from the retry letter topic for the second time
+	message := &MockMessage{
+		topic:      "persistent://public/default/topic-01-RETRY",
+		properties: map[string]string{pulsar.SysPropertyReconsumeTimes: "2"},
+		messageID:  &MockMessageID{},
+	}
+	_, _, _ = instance.handlerMsgWithRetries(message)
+	assert.NoError(t, handler.err)
+	assert.Len(t, consumer.reconsumed, 1)
+	assert.Empty(t, deadLetterProducer.messages)
+
+	// reconsumed maxMessageRetries times already
+	message.properties[pulsar.SysPropertyReconsumeTimes] = "3"
+	_, _, _ = instance.handlerMsgWithRetries(message)
+	assert.NoError(t, handler.err)
+	assert.Len(t, consumer.reconsumed, 1)
+	assert.Len(t, deadLetterProducer.messages, 1)
+	assert.Equal(t, "3", deadLetterProducer.messages[0].Properties[DeadLetterRetryCountProperty])
+	assert.Equal(t, []pulsar.Message{message}, consumer.acked)
+}
+
+// progressHandler reconsumes each message, counting its deliveries in a
+// reconsume property
+type progressHandler struct {
+	seen []map[string]string
+}
+
+func (h *progressHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	fc, _ := FromContext(ctx)
+	props := fc.GetReconsumeProperties()
+	h.seen = append(h.seen, props)
+	progress, _ := strconv.Atoi(props["progress"])
+	return nil, fc.ReconsumeLaterWithProperties(time.Second, map[string]string{
+		"progress": strconv.Itoa(progress + 1),
+	})
+}
+
+// redeliver returns msg as delivered again from the retry letter topic, with
+// the properties merged as the client does
+func redeliver(msg pulsar.Message, customProperties map[string]string) *MockMessage {
+	properties := make(map[string]string)
+	for key, value := range msg.Properties() {
+		properties[key] = value
+	}
+	for key, value := range customProperties {
+		properties[key] = value
+	}
+	reconsumeTimes, _ := strconv.Atoi(properties[pulsar.SysPropertyReconsumeTimes])
+	properties[pulsar.SysPropertyReconsumeTimes] = strconv.Itoa(reconsumeTimes + 1)
+	return &MockMessage{
+		topic:      "persistent://public/default/topic-01-RETRY",
+		properties: properties,
+		messageID:  &MockMessageID{},
+	}
+}
+
+func TestReconsumeLater_PropertiesRoundTrip(t *testing.T) {
+	instance, consumer, _ := newRetryLetterTestInstance()
+	handler := &progressHandler{}
+	instance.function = handler
+
+	var message pulsar.Message = &MockMessage{
+		topic:      "persistent://public/default/topic-01",
+		properties: map[string]string{"progress": "published"},
+		messageID:  &MockMessageID{},
+	}
+	for i := 0; i < 3; i++ {
+		_, _, err := instance.handlerMsgWithRetries(message)
+		assert.NoError(t, err)
+		message = redeliver(message, consumer.reconsumeProps[i])
+	}
+
+	// the properties the message was published with are not reconsume properties
+	assert.Equal(t, []map[string]string{nil, {"progress": "1"}, {"progress": "2"}}, handler.seen)
+	assert.Equal(t, map[string]string{ReconsumePropertyPrefix + "progress": "3"}, consumer.reconsumeProps[2])
+	assert.Equal(t, "published", message.Properties()["progress"])
+	assert.Equal(t, []time.Duration{time.Second, time.Second, time.Second}, consumer.reconsumeDelays)
+}
+
+func TestReconsumeLater_WithoutPropertiesUsesPlainReconsume(t *testing.T) {
+	instance, consumer, _ := newRetryLetterTestInstance()
+	instance.function = &reconsumingHandler{delay: time.Second}
+
+	_, _, _ = instance.handlerMsgWithRetries(
+		&MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}})
+	assert.Len(t, consumer.reconsumed, 1)
+	assert.Equal(t, []map[string]string{nil}, consumer.reconsumeProps)
+	assert.Nil(t, instance.context.GetReconsumeProperties())
+}
+
+func TestReconsumeLater_Unconfigured(t *testing.T) {
+	instance, consumer, _ := newDeadLetterTestInstance(3)
+	handler := &reconsumingHandler{}
+	instance.function = handler
+
+	_, _, err := instance.handlerMsgWithRetries(
+		&MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}})
+	assert.Error(t, err)
+	assert.EqualError(t, handler.err, "no retry letter topic configured")
+	assert.False(t, instance.context.reconsumed)
+	assert.Empty(t, consumer.reconsumed)
+	// in-instance retries apply as usual
+	assert.Equal(t, 4, handler.calls)
+}
+
+func Test_goInstance_retryLetterConsumerOptions(t *testing.T) {
+	instance, _, _ := newRetryLetterTestInstance()
+	consumerOpts := instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.True(t, consumerOpts.RetryEnable)
+	assert.Equal(t, &pulsar.DLQPolicy{
+		MaxDeliveries:    3,
+		DeadLetterTopic:  "persistent://public/default/topic-01-DLQ",
+		RetryLetterTopic: "persistent://public/default/topic-01-RETRY",
+	}, consumerOpts.DLQ)
+
+	instance.context.instanceConf.retryLetterTopic = ""
+	consumerOpts = instance.getConsumerOptions("persistent://public/default/topic-01",
+		&pb.ConsumerSpec{}, make(chan pulsar.ConsumerMessage))
+	assert.False(t, consumerOpts.RetryEnable)
+	assert.Nil(t, consumerOpts.DLQ)
+}
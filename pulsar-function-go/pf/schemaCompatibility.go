@@ -0,0 +1,215 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/admin"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/rest"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+)
+
+// schemaRegistry returns the schema registered on a topic, or nil when there
+// is none
+type schemaRegistry interface {
+	getSchema(topic string) (*utils.SchemaInfo, error)
+}
+
+// adminSchemaRegistry reads the schemas registered on topics from the broker
+// admin API
+type adminSchemaRegistry struct {
+	schemas admin.Schema
+}
+
+func newAdminSchemaRegistry(ic *instanceConf) (*adminSchemaRegistry, error) {
+	client, err := newAdminClient(ic)
+	if err != nil {
+		return nil, err
+	}
+	return &adminSchemaRegistry{schemas: client.Schemas()}, nil
+}
+
+func (r *adminSchemaRegistry) getSchema(topic string) (*utils.SchemaInfo, error) {
+	info, err := r.schemas.GetSchemaInfo(topic)
+	var restErr rest.Error
+	if errors.As(err, &restErr) && restErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	return info, err
+}
+
+// declaredSchema is the schema the function declares for one of its topics
+type declaredSchema struct {
+	topic      string
+	schemaType string
+	definition string
+	// the function consumes from the topic, rather than producing to it
+	input bool
+}
+
+// declaredSchemas returns the schemas declared for the inputs, sorted by
+// topic, followed by the one of the sink. Regex inputs, AUTO_CONSUME inputs,
+// which take any schema, and topics without a schema type are left out.
+func (gi *goInstance) declaredSchemas() []declaredSchema {
+	ic := gi.context.instanceConf
+	var schemas []declaredSchema
+	for topic, spec := range ic.funcDetails.Source.InputSpecs {
+		if spec.IsRegexPattern {
+			continue
+		}
+		declared := declaredSchema{topic: topic, schemaType: spec.SchemaType, input: true}
+		if topicName, err := ParseTopicName(topic); err == nil {
+			// the consumer decodes the inputs with the definition as an Avro schema
+			if definition, ok := ic.inputSchemaDefinitions[topicName.Name]; ok {
+				declared.schemaType, declared.definition = "AVRO", definition
+			}
+		}
+		if declared.schemaType != "" && declared.schemaType != SchemaTypeAutoConsume {
+			schemas = append(schemas, declared)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].topic < schemas[j].topic })
+
+	if sink := ic.funcDetails.Sink; sink.Topic != "" && sink.SchemaType != "" {
+		schemas = append(schemas, declaredSchema{
+			topic:      sink.Topic,
+			schemaType: sink.SchemaType,
+			definition: ic.outputSchemaDefinition,
+		})
+	}
+	return schemas
+}
+
+// setupSchemaCheck checks the declared schemas against the registered ones
+// when checkSchemaCompatibility is set
+func (gi *goInstance) setupSchemaCheck() error {
+	if !gi.context.instanceConf.checkSchemaCompatibility {
+		return nil
+	}
+	registry, err := newAdminSchemaRegistry(gi.context.instanceConf)
+	if err != nil {
+		return withExitCode(ExitCodeConnectionError, err)
+	}
+	return gi.checkSchemaCompatibility(registry)
+}
+
+// checkSchemaCompatibility returns an error describing the first declared
+// schema that does not agree with the one registered on its topic. A topic
+// without a registered schema agrees with any.
+func (gi *goInstance) checkSchemaCompatibility(registry schemaRegistry) error {
+	for _, declared := range gi.declaredSchemas() {
+		registered, err := registry.getSchema(declared.topic)
+		if err != nil {
+			return withExitCode(ExitCodeConnectionError,
+				fmt.Errorf("failed to get the schema of topic %s: %w", declared.topic, err))
+		}
+		if registered == nil {
+			continue
+		}
+		if err := compareSchemas(declared, registered); err != nil {
+			kind := "output"
+			if declared.input {
+				kind = "input"
+			}
+			return withExitCode(ExitCodeConfigError,
+				fmt.Errorf("%s schema of topic %s is incompatible with the registered one: %w", kind, declared.topic, err))
+		}
+	}
+	return nil
+}
+
+// compareSchemas reports how declared disagrees with registered. The types must
+// match. Record definitions must be compatible as Avro requires it of a reader
+// schema with a writer schema, the registered schema being the writer of the
+// inputs and the reader of the outputs.
+func compareSchemas(declared declaredSchema, registered *utils.SchemaInfo) error {
+	if !strings.EqualFold(declared.schemaType, registered.Type) {
+		return fmt.Errorf("the registered schema type is %s, %s is declared", registered.Type, declared.schemaType)
+	}
+	if declared.definition == "" || len(registered.Schema) == 0 {
+		return nil
+	}
+
+	var declaredDefinition, registeredDefinition map[string]interface{}
+	if err := json.Unmarshal([]byte(declared.definition), &declaredDefinition); err != nil {
+		return fmt.Errorf("invalid declared schema definition: %v", err)
+	}
+	if err := json.Unmarshal(registered.Schema, &registeredDefinition); err != nil {
+		return fmt.Errorf("invalid registered schema definition: %v", err)
+	}
+	if declaredDefinition["type"] != "record" || registeredDefinition["type"] != "record" {
+		if !reflect.DeepEqual(declaredDefinition, registeredDefinition) {
+			return errors.New("the registered schema definition differs from the declared one")
+		}
+		return nil
+	}
+
+	reader, writer, readerName, writerName := declaredDefinition, registeredDefinition, "declared", "registered"
+	if !declared.input {
+		reader, writer, readerName, writerName = registeredDefinition, declaredDefinition, "registered", "declared"
+	}
+	writerFields := recordFields(writer)
+	for _, field := range recordFieldList(reader) {
+		name, _ := field["name"].(string)
+		writerField, ok := writerFields[name]
+		if !ok {
+			if _, hasDefault := field["default"]; !hasDefault {
+				return fmt.Errorf("field %s of the %s schema has no default and is missing from the %s schema",
+					name, readerName, writerName)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(field["type"], writerField["type"]) {
+			return fmt.Errorf("field %s has type %v in the %s schema and %v in the %s schema",
+				name, field["type"], readerName, writerField["type"], writerName)
+		}
+	}
+	return nil
+}
+
+// recordFieldList returns the fields of a record definition
+func recordFieldList(definition map[string]interface{}) []map[string]interface{} {
+	list, _ := definition["fields"].([]interface{})
+	fields := make([]map[string]interface{}, 0, len(list))
+	for _, field := range list {
+		if field, ok := field.(map[string]interface{}); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// recordFields returns the fields of a record definition by name
+func recordFields(definition map[string]interface{}) map[string]map[string]interface{} {
+	fields := make(map[string]map[string]interface{})
+	for _, field := range recordFieldList(definition) {
+		if name, ok := field["name"].(string); ok {
+			fields[name] = field
+		}
+	}
+	return fields
+}
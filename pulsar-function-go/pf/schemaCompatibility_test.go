@@ -0,0 +1,201 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// fakeSchemaRegistry serves the schemas registered on topics, or err
+type fakeSchemaRegistry struct {
+	schemas map[string]*utils.SchemaInfo
+	err     error
+	queried []string
+}
+
+func (r *fakeSchemaRegistry) getSchema(topic string) (*utils.SchemaInfo, error) {
+	r.queried = append(r.queried, topic)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.schemas[topic], nil
+}
+
+// the test definition with an Email field, with or without a default
+const (
+	testAvroSchemaDefinitionEmail = `{"type":"record","name":"Example","namespace":"test",` +
+		`"fields":[{"name":"ID","type":"int"},{"name":"Name","type":"string"},{"name":"Email","type":"string"}]}`
+	testAvroSchemaDefinitionEmailDefault = `{"type":"record","name":"Example","namespace":"test",` +
+		`"fields":[{"name":"ID","type":"int"},{"name":"Name","type":"string"},` +
+		`{"name":"Email","type":"string","default":""}]}`
+	testAvroSchemaDefinitionLongID = `{"type":"record","name":"Example","namespace":"test",` +
+		`"fields":[{"name":"ID","type":"long"},{"name":"Name","type":"string"}]}`
+)
+
+func newSchemaCompatibilityTestInstance(inputDefinition, outputDefinition string) *goInstance {
+	instance := newGoInstance()
+	instance.context.instanceConf.inputSchemaDefinitions = map[string]string{
+		"persistent://public/default/topic-01": inputDefinition,
+	}
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.context.instanceConf.funcDetails.Sink.SchemaType = "JSON"
+	instance.context.instanceConf.outputSchemaDefinition = outputDefinition
+	return instance
+}
+
+func registeredSchemas(input, output *utils.SchemaInfo) *fakeSchemaRegistry {
+	return &fakeSchemaRegistry{schemas: map[string]*utils.SchemaInfo{
+		"persistent://public/default/topic-01": input,
+		"persistent://public/default/topic-02": output,
+	}}
+}
+
+func TestSchemaCompatibility_Compatible(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		output   string
+		registry *fakeSchemaRegistry
+	}{
+		{
+			name:   "same definitions",
+			input:  testAvroSchemaDefinition,
+			output: testAvroSchemaDefinition,
+			registry: registeredSchemas(
+				&utils.SchemaInfo{Type: "AVRO", Schema: []byte(testAvroSchemaDefinition)},
+				&utils.SchemaInfo{Type: "JSON", Schema: []byte(testAvroSchemaDefinition)}),
+		},
+		{
+			// the inputs are read without the field they carry, the outputs miss one with a default
+			name:   "added fields with defaults",
+			input:  testAvroSchemaDefinition,
+			output: testAvroSchemaDefinition,
+			registry: registeredSchemas(
+				&utils.SchemaInfo{Type: "AVRO", Schema: []byte(testAvroSchemaDefinitionEmail)},
+				&utils.SchemaInfo{Type: "JSON", Schema: []byte(testAvroSchemaDefinitionEmailDefault)}),
+		},
+		{
+			name:     "nothing registered",
+			input:    testAvroSchemaDefinitionEmail,
+			output:   testAvroSchemaDefinition,
+			registry: &fakeSchemaRegistry{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newSchemaCompatibilityTestInstance(tt.input, tt.output)
+
+			assert.NoError(t, instance.checkSchemaCompatibility(tt.registry))
+			assert.Equal(t, []string{"persistent://public/default/topic-01", "persistent://public/default/topic-02"},
+				tt.registry.queried)
+		})
+	}
+}
+
+func TestSchemaCompatibility_Incompatible(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		output   string
+		registry *fakeSchemaRegistry
+		err      string
+	}{
+		{
+			name:   "schema type",
+			input:  testAvroSchemaDefinition,
+			output: testAvroSchemaDefinition,
+			registry: registeredSchemas(nil,
+				&utils.SchemaInfo{Type: "STRING"}),
+			err: "output schema of topic persistent://public/default/topic-02 is incompatible with the registered " +
+				"one: the registered schema type is STRING, JSON is declared",
+		},
+		{
+			name:   "input field without default",
+			input:  testAvroSchemaDefinitionEmail,
+			output: testAvroSchemaDefinition,
+			registry: registeredSchemas(
+				&utils.SchemaInfo{Type: "AVRO", Schema: []byte(testAvroSchemaDefinition)}, nil),
+			err: "input schema of topic persistent://public/default/topic-01 is incompatible with the registered " +
+				"one: field Email of the declared schema has no default and is missing from the registered schema",
+		},
+		{
+			name:   "output field without default",
+			input:  testAvroSchemaDefinition,
+			output: testAvroSchemaDefinition,
+			registry: registeredSchemas(nil,
+				&utils.SchemaInfo{Type: "JSON", Schema: []byte(testAvroSchemaDefinitionEmail)}),
+			err: "output schema of topic persistent://public/default/topic-02 is incompatible with the registered " +
+				"one: field Email of the registered schema has no default and is missing from the declared schema",
+		},
+		{
+			name:   "field type",
+			input:  testAvroSchemaDefinition,
+			output: testAvroSchemaDefinition,
+			registry: registeredSchemas(
+				&utils.SchemaInfo{Type: "AVRO", Schema: []byte(testAvroSchemaDefinitionLongID)}, nil),
+			err: "input schema of topic persistent://public/default/topic-01 is incompatible with the registered " +
+				"one: field ID has type int in the declared schema and long in the registered schema",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newSchemaCompatibilityTestInstance(tt.input, tt.output)
+
+			err := instance.checkSchemaCompatibility(tt.registry)
+			assert.EqualError(t, err, tt.err)
+			assert.Equal(t, ExitCodeConfigError, exitCodeOf(err))
+		})
+	}
+}
+
+func TestSchemaCompatibility_RegistryError(t *testing.T) {
+	instance := newSchemaCompatibilityTestInstance(testAvroSchemaDefinition, testAvroSchemaDefinition)
+
+	err := instance.checkSchemaCompatibility(&fakeSchemaRegistry{err: errors.New("connection refused")})
+	assert.EqualError(t, err, "failed to get the schema of topic persistent://public/default/topic-01: "+
+		"connection refused")
+	assert.Equal(t, ExitCodeConnectionError, exitCodeOf(err))
+}
+
+func TestSchemaCompatibility_Disabled(t *testing.T) {
+	instance := newSchemaCompatibilityTestInstance(testAvroSchemaDefinition, testAvroSchemaDefinition)
+
+	// no admin client is created unless the check is enabled
+	assert.NoError(t, instance.setupSchemaCheck())
+}
+
+func TestInstanceConf_CheckSchemaCompatibility(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{
+		ProcessingGuarantees:     3,
+		CheckSchemaCompatibility: true,
+		PulsarWebServiceURL:      "http://localhost:8080",
+	})
+	assert.True(t, instanceConf.checkSchemaCompatibility)
+
+	assert.Panics(t, func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, CheckSchemaCompatibility: true})
+	}, "Should have a panic")
+}
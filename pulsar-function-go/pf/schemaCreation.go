@@ -0,0 +1,45 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSchemaRejected is returned, wrapped, when a producer cannot be created
+// because the broker rejected its schema, for instance as the namespace does
+// not allow clients to create schemas. A connectivity error is returned as is.
+var ErrSchemaRejected = errors.New("schema rejected by the broker")
+
+// the client reports broker errors by their name only, as it does itself to
+// tell them apart
+const errMsgIncompatibleSchema = "IncompatibleSchema"
+
+// classifyProducerError wraps err with ErrSchemaRejected when the broker
+// refused the schema of the producer for topic
+func classifyProducerError(topic string, err error) error {
+	if !strings.Contains(err.Error(), errMsgIncompatibleSchema) {
+		return err
+	}
+	return fmt.Errorf("%w for topic %s, register the schema beforehand or set disableSchemaCreation: %v",
+		ErrSchemaRejected, topic, err)
+}
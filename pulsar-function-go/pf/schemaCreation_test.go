@@ -0,0 +1,89 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+// schemaRejectingClient fails to create producers with a schema as a broker
+// not allowing clients to create schemas does, or all of them with connErr
+type schemaRejectingClient struct {
+	pulsar.Client
+	connErr error
+	created []pulsar.ProducerOptions
+}
+
+func (c *schemaRejectingClient) CreateProducer(options pulsar.ProducerOptions) (pulsar.Producer, error) {
+	if c.connErr != nil {
+		return nil, c.connErr
+	}
+	if options.Schema != nil {
+		return nil, errors.New("server error: IncompatibleSchema: " +
+			"org.apache.pulsar.broker.service.schema.exceptions.IncompatibleSchemaException: " +
+			"Schema not found and schema auto updating is disabled.")
+	}
+	c.created = append(c.created, options)
+	return &MockPulsarProducer{}, nil
+}
+
+func newSchemaCreationTestInstance(client pulsar.Client) *goInstance {
+	instance := newGoInstance()
+	instance.client = client
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.context.instanceConf.funcDetails.Sink.SchemaType = "JSON"
+	instance.context.instanceConf.outputSchemaDefinition = testAvroSchemaDefinition
+	return instance
+}
+
+func TestSchemaCreation_Rejected(t *testing.T) {
+	instance := newSchemaCreationTestInstance(&schemaRejectingClient{})
+
+	_, err := instance.getProducer("persistent://public/default/topic-02")
+	assert.ErrorIs(t, err, ErrSchemaRejected)
+	assert.ErrorContains(t, err, "persistent://public/default/topic-02")
+	assert.ErrorContains(t, err, "Schema not found and schema auto updating is disabled.")
+}
+
+func TestSchemaCreation_ConnectivityErrorNotMistakenForRejection(t *testing.T) {
+	connErr := errors.New("connection error")
+	instance := newSchemaCreationTestInstance(&schemaRejectingClient{connErr: connErr})
+
+	_, err := instance.getProducer("persistent://public/default/topic-02")
+	assert.Equal(t, connErr, err)
+	assert.NotErrorIs(t, err, ErrSchemaRejected)
+}
+
+func TestSchemaCreation_Disabled(t *testing.T) {
+	client := &schemaRejectingClient{}
+	instance := newSchemaCreationTestInstance(client)
+	instance.context.instanceConf.disableSchemaCreation = true
+
+	producer, err := instance.getProducer("persistent://public/default/topic-02")
+	assert.NoError(t, err)
+	assert.NotNil(t, producer)
+	assert.Len(t, client.created, 1)
+	assert.Nil(t, client.created[0].Schema)
+	assert.True(t, client.created[0].DisableMultiSchema)
+}
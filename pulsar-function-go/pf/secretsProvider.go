@@ -0,0 +1,104 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretsProvider resolves the secrets available to a function by name
+type SecretsProvider interface {
+	GetSecret(secretName string) (string, error)
+}
+
+// SecretNamesProvider is implemented by the secrets providers that can list
+// the names of the secrets they serve
+type SecretNamesProvider interface {
+	GetSecretNames() ([]string, error)
+}
+
+// FileSecretsProvider serves secrets mounted as files in a directory, such as
+// a Kubernetes secret volume, where each file name is a secret name and the
+// file content is the secret value. Files are read on first access and cached.
+type FileSecretsProvider struct {
+	directory string
+	mu        sync.Mutex
+	cache     map[string]string
+}
+
+// NewFileSecretsProvider returns a FileSecretsProvider reading secrets from
+// directory
+func NewFileSecretsProvider(directory string) *FileSecretsProvider {
+	return &FileSecretsProvider{
+		directory: directory,
+		cache:     make(map[string]string),
+	}
+}
+
+// GetSecret returns the content of the file named secretName in the secrets
+// directory
+func (p *FileSecretsProvider) GetSecret(secretName string) (string, error) {
+	if secretName == "" || secretName == "." || secretName == ".." ||
+		strings.ContainsAny(secretName, `/\`) {
+		return "", fmt.Errorf("invalid secret name %q", secretName)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if secret, ok := p.cache[secretName]; ok {
+		return secret, nil
+	}
+	path := filepath.Join(p.directory, secretName)
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("secret %s not found: %s does not exist", secretName, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from %s: %v", secretName, path, err)
+	}
+	secret := string(content)
+	p.cache[secretName] = secret
+	return secret, nil
+}
+
+// GetSecretNames returns the sorted names of the files in the secrets
+// directory. Hidden files are left out, like the ones Kubernetes keeps the
+// versions of a secret volume in.
+func (p *FileSecretsProvider) GetSecretNames() ([]string, error) {
+	entries, err := os.ReadDir(p.directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in %s: %v", p.directory, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
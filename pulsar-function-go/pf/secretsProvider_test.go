@@ -0,0 +1,85 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSecretsProvider_LazyReadAndCache(t *testing.T) {
+	dir := t.TempDir()
+	provider := NewFileSecretsProvider(dir)
+
+	// files written after the provider is created are picked up
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "db-password"), []byte("s3cr3t"), 0600))
+	secret, err := provider.GetSecret("db-password")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", secret)
+
+	// subsequent reads are served from the cache
+	assert.NoError(t, os.Remove(filepath.Join(dir, "db-password")))
+	secret, err = provider.GetSecret("db-password")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", secret)
+}
+
+func TestFileSecretsProvider_MissingSecret(t *testing.T) {
+	dir := t.TempDir()
+	provider := NewFileSecretsProvider(dir)
+
+	_, err := provider.GetSecret("api-key")
+	assert.EqualError(t, err, "secret api-key not found: "+filepath.Join(dir, "api-key")+" does not exist")
+
+	_, err = provider.GetSecret("../api-key")
+	assert.Error(t, err)
+}
+
+func TestFunctionContext_GetSecret(t *testing.T) {
+	fc := NewFuncContext()
+	_, err := fc.GetSecret("db-password")
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "db-password"), []byte("s3cr3t"), 0600))
+	fc.secrets = NewFileSecretsProvider(dir)
+	secret, err := fc.GetSecret("db-password")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", secret)
+}
+
+func TestFunctionContext_GetSecretNames(t *testing.T) {
+	fc := NewFuncContext()
+	assert.Empty(t, fc.GetSecretNames())
+
+	fc.instanceConf.funcDetails.SecretsMap = `{"db-password": {"path": "db", "key": "password"}, "api-key": "api"}`
+	assert.Equal(t, []string{"api-key", "db-password"}, fc.GetSecretNames())
+
+	dir := t.TempDir()
+	for _, name := range []string{"tls-key", "db-password", ".hidden"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("s3cr3t"), 0600))
+	}
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0700))
+	fc.secrets = NewFileSecretsProvider(dir)
+	assert.Equal(t, []string{"api-key", "db-password", "tls-key"}, fc.GetSecretNames())
+}
@@ -0,0 +1,156 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+)
+
+// Ways to handle a message once the sink producer failed to send one of its
+// outputs. They are also the outcome label of the send_failures_total metric.
+const (
+	// OnSendFailureRetry sends the output again, with exponential backoff from
+	// retryBackoffInitialMs up to retryBackoffMaxMs, up to maxSendRetries
+	// times. Once they are exhausted the message is nacked as with
+	// OnSendFailureNack, so that it is still delivered at least once. It is
+	// the default.
+	OnSendFailureRetry = "retry"
+	// OnSendFailureNack nacks the message for redelivery and carries on with
+	// the next one
+	OnSendFailureNack = "nack"
+	// OnSendFailureFail nacks the message and stops the instance
+	OnSendFailureFail = "fail"
+)
+
+const defaultMaxSendRetries = 5
+
+// sendToSink sends msg with the sink producer and calls done with the outcome,
+// once a failed send has been retried as onSendFailure says. A retried output
+// may be published after those of the messages that followed its input. The
+// send counts in pendingSends until done is called, so that close waits
+// for it before closing the producer. A retry still waiting once the
+// instance is closing is not sent, and done gets the last error.
+func (gi *goInstance) sendToSink(msg *pulsar.ProducerMessage, done func(err error)) {
+	backoff := newRetryBackoff(gi.context.instanceConf.retryBackoffInitial, gi.context.instanceConf.retryBackoffMax)
+	gi.pendingSends.Add(1)
+	gi.sendWithRetries(msg, 0, backoff, func(err error) {
+		defer gi.pendingSends.Done()
+		done(err)
+	})
+}
+
+// waitPendingSends waits for the sends counted in pendingSends to settle, for
+// up to operationTimeoutMs. The producer is created without a send timeout, so
+// a send the broker never acknowledges would otherwise hold up close forever.
+func (gi *goInstance) waitPendingSends() {
+	timeout := gi.context.instanceConf.operationTimeout
+	if timeout == 0 {
+		timeout = defaultOperationTimeout
+	}
+	settled := make(chan struct{})
+	go func() {
+		gi.pendingSends.Wait()
+		close(settled)
+	}()
+	select {
+	case <-settled:
+	case <-time.After(timeout):
+		log.Warnf("outputs still unsettled after %v, closing the producer fails them", timeout)
+	}
+}
+
+func (gi *goInstance) sendWithRetries(msg *pulsar.ProducerMessage, retryCount int, backoff *retryBackoff,
+	done func(err error)) {
+	gi.producer.SendAsync(context.Background(), msg, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+		if err == nil || !gi.retrySend(err, retryCount) {
+			done(err)
+			return
+		}
+		gi.stats.incrTotalSendFailures(OnSendFailureRetry)
+		delay := backoff.Next()
+		log.Warnf("failed to publish output: %v, retrying (%d/%d) in %v", err, retryCount+1, gi.maxSendRetries(), delay)
+		// the callback runs on the connection of the producer, which must not
+		// wait for the backoff
+		go func() {
+			// the instance is closing, the send settles with the last error
+			if !retrySleep(gi.retriesCtx, delay) {
+				done(err)
+				return
+			}
+			gi.sendWithRetries(msg, retryCount+1, backoff, done)
+		}()
+	})
+}
+
+func (gi *goInstance) maxSendRetries() int {
+	if gi.context.instanceConf.maxSendRetries > 0 {
+		return gi.context.instanceConf.maxSendRetries
+	}
+	return defaultMaxSendRetries
+}
+
+// retrySend reports whether an output that failed to send with sendErr, after
+// retryCount retries already, is sent again. Nothing is sent again once the
+// instance is closing.
+func (gi *goInstance) retrySend(sendErr error, retryCount int) bool {
+	onSendFailure := gi.context.instanceConf.onSendFailure
+	if (onSendFailure != "" && onSendFailure != OnSendFailureRetry) || retryCount >= gi.maxSendRetries() {
+		return false
+	}
+	if gi.retriesCtx.Err() != nil {
+		return false
+	}
+	// an oversized output never goes through, and a closed producer means the
+	// instance is closing
+	return !errors.Is(sendErr, pulsar.ErrMessageTooLarge) && !errors.Is(sendErr, pulsar.ErrProducerClosed)
+}
+
+// handleSendFailure nacks msgInput, an output of which failed to send with
+// sendErr, and stops the instance with onSendFailure fail
+func (gi *goInstance) handleSendFailure(msgInput pulsar.Message, sendErr error) {
+	atLeastOnce := gi.context.instanceConf.funcDetails.ProcessingGuarantees == pb.ProcessingGuarantees_ATLEAST_ONCE
+	autoAck := gi.context.instanceConf.funcDetails.AutoAck
+
+	outcome := OnSendFailureNack
+	if gi.context.instanceConf.onSendFailure == OnSendFailureFail {
+		outcome = OnSendFailureFail
+	}
+	gi.stats.incrTotalSendFailures(outcome)
+	log.Errorf("failed to publish output of message ID %s: %v", messageIDStr(msgInput), sendErr)
+	if autoAck && atLeastOnce {
+		gi.nackInputMessage(msgInput)
+	}
+	gi.stats.incrTotalSysExceptions(sendErr)
+	if outcome == OnSendFailureFail {
+		// the first failure stops the instance, the process loop may be gone
+		// by the next one
+		select {
+		case gi.sendFailed <- sendErr:
+		default:
+		}
+	}
+}
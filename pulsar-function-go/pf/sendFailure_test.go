@@ -0,0 +1,226 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// getTotalSendFailures returns the send_failures_total metric of gi for outcome
+func (gi *goInstance) getTotalSendFailures(outcome string) float32 {
+	for _, family := range gi.getFilteredMetricFamilies(metricsPrefix + TotalSendFailures) {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["fqfn"] == gi.context.GetTenantAndNamespaceAndName() && labels["outcome"] == outcome {
+				return float32(metric.GetGauge().GetValue())
+			}
+		}
+	}
+	return 0
+}
+
+func TestSendFailure(t *testing.T) {
+	tests := []struct {
+		name           string
+		onSendFailure  string
+		maxSendRetries int
+		sendErr        error
+		sendErrAt      int
+		sends          int
+		retries        float32
+		outcome        string
+		stops          bool
+		acked          bool
+		delays         []time.Duration
+	}{
+		{name: "default retries until sent", sendErr: errors.New("topic full"), sendErrAt: 1, sends: 2, retries: 1,
+			acked: true, delays: []time.Duration{100 * time.Millisecond}},
+		{name: "retry until exhausted", onSendFailure: OnSendFailureRetry, sendErr: errors.New("topic full"),
+			maxSendRetries: 2, sends: 3, retries: 2, outcome: OnSendFailureNack,
+			delays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{name: "retry skips oversized outputs", sendErr: pulsar.ErrMessageTooLarge, sends: 1,
+			outcome: OnSendFailureNack},
+		{name: "nack", onSendFailure: OnSendFailureNack, sendErr: errors.New("topic full"), sends: 1,
+			outcome: OnSendFailureNack},
+		{name: "fail", onSendFailure: OnSendFailureFail, sendErr: errors.New("topic full"), sends: 1,
+			outcome: OnSendFailureFail, stops: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays := recordRetrySleeps(t)
+			instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+			instance.context.instanceConf.onSendFailure = tt.onSendFailure
+			instance.context.instanceConf.maxSendRetries = tt.maxSendRetries
+			producer.sendErr, producer.sendErrAt = tt.sendErr, tt.sendErrAt
+			retries := instance.getTotalSendFailures(OnSendFailureRetry)
+			failures := instance.getTotalSendFailures(tt.outcome)
+
+			err := instance.processMessages(inputMessages("input"))
+			instance.pendingSends.Wait()
+
+			if tt.stops {
+				assert.ErrorIs(t, err, tt.sendErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, producer.messages, tt.sends)
+			if tt.delays == nil {
+				assert.Empty(t, *delays)
+			} else {
+				assert.Equal(t, tt.delays, *delays)
+			}
+			assert.Equal(t, retries+tt.retries, instance.getTotalSendFailures(OnSendFailureRetry))
+			if tt.acked {
+				assert.Len(t, consumer.acked, 1)
+				assert.Empty(t, consumer.nacked)
+			} else {
+				assert.Empty(t, consumer.acked)
+				assert.Len(t, consumer.nacked, 1)
+				assert.Equal(t, failures+1, instance.getTotalSendFailures(tt.outcome))
+			}
+		})
+	}
+}
+
+func TestSendFailure_StopsRetryingOnceClosed(t *testing.T) {
+	recordRetrySleeps(t)
+	instance, consumer, producer := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	producer.sendErr = pulsar.ErrProducerClosed
+
+	assert.NoError(t, instance.processMessages(inputMessages("input")))
+	instance.pendingSends.Wait()
+
+	assert.Len(t, producer.messages, 1)
+	assert.Len(t, consumer.nacked, 1)
+}
+
+// heldProducer completes its async sends only when asked to, with the given
+// error
+type heldProducer struct {
+	MockPulsarProducer
+	mu      sync.Mutex
+	pending []func(err error)
+}
+
+func (p *heldProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage,
+	callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, func(err error) {
+		callback(&MockMessageID{}, msg, err)
+	})
+}
+
+func (p *heldProducer) inFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// complete finishes the oldest pending send with err
+func (p *heldProducer) complete(err error) {
+	p.mu.Lock()
+	next := p.pending[0]
+	p.pending = p.pending[1:]
+	p.mu.Unlock()
+	next(err)
+}
+
+func TestSendFailure_CloseWaitsForInFlightSends(t *testing.T) {
+	recordRetrySleeps(t)
+	instance, _, _ := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	producer := &heldProducer{}
+	instance.producer = producer
+	result := make(chan error, 1)
+	instance.sendToSink(&pulsar.ProducerMessage{Payload: []byte("output")}, func(err error) { result <- err })
+
+	closed := make(chan struct{})
+	go func() {
+		instance.close()
+		close(closed)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-closed:
+		t.Fatal("the instance closed with a send in flight")
+	default:
+	}
+
+	// the attempt fails while the instance is closing, and is not sent again
+	producer.complete(errors.New("topic full"))
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the instance did not close once the send completed")
+	}
+	assert.EqualError(t, <-result, "topic full")
+	assert.Zero(t, producer.inFlight())
+	assert.True(t, producer.closed)
+}
+
+func TestSendFailure_CloseStopsWaitingForUnsettledSends(t *testing.T) {
+	instance, _, _ := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	instance.context.instanceConf.operationTimeout = 50 * time.Millisecond
+	producer := &heldProducer{}
+	instance.producer = producer
+	instance.sendToSink(&pulsar.ProducerMessage{Payload: []byte("output")}, func(err error) {})
+
+	// the broker never acknowledges the send
+	closed := make(chan struct{})
+	go func() {
+		instance.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close waited for a send that never settles")
+	}
+	assert.True(t, producer.closed)
+	assert.Equal(t, 1, producer.inFlight())
+}
+
+func TestInstanceConf_OnSendFailure(t *testing.T) {
+	for _, mode := range []string{"", "retry", "nack", "fail"} {
+		instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OnSendFailure: mode,
+			MaxSendRetries: 3})
+		assert.Equal(t, mode, instanceConf.onSendFailure)
+		assert.Equal(t, 3, instanceConf.maxSendRetries)
+	}
+
+	assert.PanicsWithValue(t, "Invalid onSendFailure drop, must be one of retry, nack or fail.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, OnSendFailure: "drop"})
+	})
+	assert.PanicsWithValue(t, "maxSendRetries must not be negative.", func() {
+		newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, MaxSendRetries: -1})
+	})
+}
@@ -0,0 +1,108 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/spaolacci/murmur3"
+)
+
+// Routing modes for the partitions of the sink topic
+const (
+	// SinkRoutingRoundRobin spreads output messages over all partitions in turn
+	SinkRoutingRoundRobin = "roundRobin"
+	// SinkRoutingKeyBased sends output messages with the same key to the same
+	// partition, messages without a key are spread in turn
+	SinkRoutingKeyBased = "keyBased"
+	// SinkRoutingSinglePartition sends all output messages to the configured
+	// sinkPartition
+	SinkRoutingSinglePartition = "singlePartition"
+)
+
+// newSinkMessageRouter returns the producer message router for the routing
+// mode, or nil to let the client route with its default policy. Keys are
+// hashed with scheme, as the client does.
+func newSinkMessageRouter(mode string, partition int,
+	scheme pulsar.HashingScheme) func(*pulsar.ProducerMessage, pulsar.TopicMetadata) int {
+	hash := javaStringHash
+	if scheme == pulsar.Murmur3_32Hash {
+		hash = murmur3Hash
+	}
+	var next uint32
+	roundRobin := func(metadata pulsar.TopicMetadata) int {
+		return int((atomic.AddUint32(&next, 1) - 1) % metadata.NumPartitions())
+	}
+
+	switch mode {
+	case SinkRoutingRoundRobin:
+		return func(_ *pulsar.ProducerMessage, metadata pulsar.TopicMetadata) int {
+			return roundRobin(metadata)
+		}
+	case SinkRoutingKeyBased:
+		return func(msg *pulsar.ProducerMessage, metadata pulsar.TopicMetadata) int {
+			if msg.Key == "" {
+				return roundRobin(metadata)
+			}
+			return int(hash(msg.Key) % metadata.NumPartitions())
+		}
+	case SinkRoutingSinglePartition:
+		return func(*pulsar.ProducerMessage, pulsar.TopicMetadata) int {
+			return partition
+		}
+	default:
+		return nil
+	}
+}
+
+// javaStringHash hashes keys the same way as the client default router does
+func javaStringHash(s string) uint32 {
+	var h uint32
+	for i := 0; i < len(s); i++ {
+		h = 31*h + uint32(s[i])
+	}
+	return h
+}
+
+// murmur3Hash hashes keys the same way as the client does with Murmur3_32Hash,
+// which keeps the hash positive for compatibility with the Java client
+func murmur3Hash(s string) uint32 {
+	return murmur3.Sum32([]byte(s)) & 0x7fffffff
+}
+
+// checkSinkPartition verifies that the partition configured for the
+// singlePartition routing mode exists on the sink topic
+func (gi *goInstance) checkSinkPartition(topicName string) error {
+	ic := gi.context.instanceConf
+	if ic.sinkPartitionRouting != SinkRoutingSinglePartition {
+		return nil
+	}
+	partitions, err := gi.client.TopicPartitions(topicName)
+	if err != nil {
+		return err
+	}
+	if ic.sinkPartition >= len(partitions) {
+		return fmt.Errorf("sinkPartition %d is out of range, topic %s has %d partition(s)",
+			ic.sinkPartition, topicName, len(partitions))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+type partitionsMetadata uint32
+
+func (m partitionsMetadata) NumPartitions() uint32 {
+	return uint32(m)
+}
+
+// partitionsClient only answers partition lookups
+type partitionsClient struct {
+	pulsar.Client
+	partitions []string
+}
+
+func (c *partitionsClient) TopicPartitions(string) ([]string, error) {
+	return c.partitions, nil
+}
+
+func TestSinkMessageRouter_RoundRobin(t *testing.T) {
+	router := newSinkMessageRouter(SinkRoutingRoundRobin, 0, pulsar.JavaStringHash)
+	var partitions []int
+	for i := 0; i < 4; i++ {
+		partitions = append(partitions, router(&pulsar.ProducerMessage{Key: "key"}, partitionsMetadata(3)))
+	}
+	assert.Equal(t, []int{0, 1, 2, 0}, partitions)
+}
+
+func TestSinkMessageRouter_KeyBased(t *testing.T) {
+	router := newSinkMessageRouter(SinkRoutingKeyBased, 0, pulsar.JavaStringHash)
+	partition := router(&pulsar.ProducerMessage{Key: "key-1"}, partitionsMetadata(8))
+	assert.Equal(t, int(javaStringHash("key-1")%8), partition)
+	assert.Equal(t, partition, router(&pulsar.ProducerMessage{Key: "key-1"}, partitionsMetadata(8)))
+
+	// messages without a key are spread in turn
+	assert.Equal(t, 0, router(&pulsar.ProducerMessage{}, partitionsMetadata(2)))
+	assert.Equal(t, 1, router(&pulsar.ProducerMessage{}, partitionsMetadata(2)))
+}
+
+func TestSinkMessageRouter_KeyBasedMurmur3(t *testing.T) {
+	// MurmurHash3_x86_32 of "hello" with a zero seed
+	assert.Equal(t, uint32(0x248bfa47), murmur3Hash("hello"))
+	// the sign bit is dropped as in the Java client
+	for _, key := range []string{"key-1", "key-2", "key-3", "key-4"} {
+		assert.Zero(t, murmur3Hash(key)&0x80000000)
+	}
+
+	router := newSinkMessageRouter(SinkRoutingKeyBased, 0, pulsar.Murmur3_32Hash)
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		assert.Equal(t, int(murmur3Hash(key)%7), router(&pulsar.ProducerMessage{Key: key}, partitionsMetadata(7)))
+	}
+}
+
+func TestSinkMessageRouter_SinglePartition(t *testing.T) {
+	router := newSinkMessageRouter(SinkRoutingSinglePartition, 2, pulsar.JavaStringHash)
+	for _, key := range []string{"", "key-1", "key-2"} {
+		assert.Equal(t, 2, router(&pulsar.ProducerMessage{Key: key}, partitionsMetadata(4)))
+	}
+}
+
+func TestSinkMessageRouter_Default(t *testing.T) {
+	assert.Nil(t, newSinkMessageRouter("", 0, pulsar.JavaStringHash))
+}
+
+func Test_goInstance_sinkRoutingProducerOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.context.instanceConf.sinkPartitionRouting = SinkRoutingSinglePartition
+	instance.context.instanceConf.sinkPartition = 1
+
+	producerOpts := instance.getProducerOptions("persistent://public/default/topic-02")
+	assert.NotNil(t, producerOpts.MessageRouter)
+	assert.Equal(t, 1, producerOpts.MessageRouter(&pulsar.ProducerMessage{}, partitionsMetadata(2)))
+
+	// topics produced to through the context keep the default routing, with the same hashing
+	producerOpts = instance.getProducerOptions("persistent://public/default/other")
+	assert.Nil(t, producerOpts.MessageRouter)
+}
+
+func Test_goInstance_hashingSchemeProducerOptions(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	producerOpts := instance.getProducerOptions("persistent://public/default/topic-02")
+	assert.Equal(t, pulsar.JavaStringHash, producerOpts.HashingScheme)
+
+	instance.context.instanceConf.producerHashingScheme = pulsar.Murmur3_32Hash
+	for _, topic := range []string{"persistent://public/default/topic-02", "persistent://public/default/other"} {
+		producerOpts = instance.getProducerOptions(topic)
+		assert.Equal(t, pulsar.Murmur3_32Hash, producerOpts.HashingScheme)
+	}
+}
+
+func Test_goInstance_checkSinkPartition(t *testing.T) {
+	instance := newGoInstance()
+	instance.client = &partitionsClient{partitions: []string{"topic-02-partition-0", "topic-02-partition-1"}}
+	instance.context.instanceConf.sinkPartitionRouting = SinkRoutingSinglePartition
+
+	instance.context.instanceConf.sinkPartition = 1
+	assert.NoError(t, instance.checkSinkPartition("topic-02"))
+
+	instance.context.instanceConf.sinkPartition = 2
+	assert.EqualError(t, instance.checkSinkPartition("topic-02"),
+		"sinkPartition 2 is out of range, topic topic-02 has 2 partition(s)")
+
+	instance.context.instanceConf.sinkPartitionRouting = SinkRoutingRoundRobin
+	assert.NoError(t, instance.checkSinkPartition("topic-02"))
+}
@@ -0,0 +1,85 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"time"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// readinessPollInterval is how often the startup conditions are checked, it
+// is shortened in tests
+var readinessPollInterval = time.Second
+
+// waitUntilReady marks the instance ready once all input topics have been
+// subscribed to and, when startupBacklogThreshold is set, the backlog of the
+// subscription has gone down to the threshold. Messages are processed in the
+// meantime so that the instance can catch up.
+func (gi *goInstance) waitUntilReady(stop <-chan struct{}) {
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+	for {
+		if gi.startupComplete() {
+			select {
+			case <-stop:
+				// processing has already ended
+				return
+			default:
+			}
+			log.Info("startup complete, the instance is ready")
+			gi.ready.Store(true)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (gi *goInstance) startupComplete() bool {
+	for topic := range gi.context.instanceConf.funcDetails.Source.GetInputSpecs() {
+		topicName, err := ParseTopicName(topic)
+		if err != nil {
+			return false
+		}
+		if _, ok := gi.consumers[topicName.Name]; !ok {
+			log.Debugf("waiting for the subscription to topic %s", topic)
+			return false
+		}
+	}
+
+	threshold := gi.context.instanceConf.startupBacklogThreshold
+	if threshold <= 0 {
+		return true
+	}
+	backlog, err := gi.context.GetBacklogSize()
+	if err != nil {
+		log.Warnf("unable to check the backlog before reporting ready: %v", err)
+		return false
+	}
+	if backlog > threshold {
+		log.Debugf("waiting for the backlog of %d messages to go down to %d", backlog, threshold)
+		return false
+	}
+	return true
+}
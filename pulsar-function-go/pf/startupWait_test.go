@@ -0,0 +1,98 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStartupWaitTestInstance(t *testing.T) *goInstance {
+	readinessPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		readinessPollInterval = time.Second
+	})
+	instance := newGoInstance()
+	instance.context.instanceConf.startupWaitForSubscription = true
+	instance.context.instanceConf.funcDetails.Source.InputSpecs = map[string]*pb.ConsumerSpec{
+		"persistent://public/default/topic-01": {},
+		"topic-02":                             {},
+	}
+	return instance
+}
+
+func TestStartupComplete_Subscriptions(t *testing.T) {
+	instance := newStartupWaitTestInstance(t)
+	assert.False(t, instance.startupComplete())
+
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	assert.False(t, instance.startupComplete())
+
+	instance.consumers["persistent://public/default/topic-02"] = &MockPulsarConsumer{}
+	assert.True(t, instance.startupComplete())
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	instance := newStartupWaitTestInstance(t)
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-02"] = &MockPulsarConsumer{}
+	assert.False(t, instance.isReady())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go instance.waitUntilReady(stop)
+	assert.Eventually(t, instance.isReady, time.Second, 10*time.Millisecond)
+}
+
+func TestStartupComplete_BacklogThreshold(t *testing.T) {
+	instance := newStartupWaitTestInstance(t)
+	instance.context.instanceConf.startupBacklogThreshold = 10
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-02"] = &MockPulsarConsumer{}
+	source := &fakeBacklogSource{backlogs: map[string]int64{"topic-01@sub": 11}}
+	instance.context.backlog = newBacklogCache(source, []string{"topic-01"}, "sub", time.Nanosecond)
+
+	assert.False(t, instance.startupComplete())
+
+	source.backlogs["topic-01@sub"] = 10
+	assert.True(t, instance.startupComplete())
+
+	// not ready while the backlog cannot be read
+	source.err = errors.New("admin unavailable")
+	assert.False(t, instance.startupComplete())
+}
+
+func TestWaitUntilReady_Stopped(t *testing.T) {
+	instance := newStartupWaitTestInstance(t)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		instance.waitUntilReady(stop)
+		close(done)
+	}()
+	close(stop)
+	<-done
+	assert.False(t, instance.isReady())
+}
@@ -21,7 +21,10 @@ package pf
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"regexp"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -37,6 +40,10 @@ var (
 	exceptionMetricsLabelNames = append(metricsLabelNames, exceptionLabelNames...)
 	userLabelNames             = []string{"metric"}
 	userMetricLabelNames       = append(metricsLabelNames, userLabelNames...)
+	outcomeLabelNames          = []string{"outcome"}
+	outcomeMetricsLabelNames   = append(metricsLabelNames, outcomeLabelNames...)
+	topicLabelNames            = []string{"topic"}
+	topicMetricsLabelNames     = append(metricsLabelNames, topicLabelNames...)
 )
 
 const (
@@ -46,6 +53,7 @@ const (
 	TotalSystemExceptions      = "system_exceptions_total"
 	TotalUserExceptions        = "user_exceptions_total"
 	ProcessLatencyMs           = "process_latency_ms"
+	ProcessLatencyMsHistogram  = "process_latency_ms_histogram"
 	LastInvocation             = "last_invocation"
 	TotalReceived              = "received_total"
 
@@ -55,6 +63,34 @@ const (
 	ProcessLatencyMs1min           = "process_latency_ms_1min"
 	TotalReceived1min              = "received_total_1min"
 
+	TotalRestartRequests = "restart_requests_total"
+
+	TotalAcks  = "acks_total"
+	TotalNacks = "nacks_total"
+
+	TotalUnknownUserConfigKeys = "unknown_user_config_keys_total"
+
+	TotalProcessErrors         = "process_errors_total"
+	TotalDroppedPoisonMessages = "dropped_poison_messages_total"
+	TotalSendFailures          = "send_failures_total"
+
+	TotalDeserializationErrors = "deserialization_errors_total"
+	TotalExpiredMessages       = "expired_messages_total"
+	TotalOversizedOutputs      = "oversized_outputs_total"
+	TotalDuplicateMessages     = "duplicate_messages_total"
+	TotalAuthTokenRefreshes    = "auth_token_refreshes_total"
+	TotalRetries               = "retries_total"
+
+	ConsumerTotalReceived      = "consumer_received_total"
+	ConsumerTotalReceivedBytes = "consumer_received_bytes_total"
+	ConsumerMsgRateIn          = "consumer_msg_rate_in"
+	ConsumerMsgThroughputIn    = "consumer_msg_throughput_in"
+
+	TotalThrottled = "throttled_total"
+
+	ClientConnectionsActive = "client_connections_active"
+	TotalClientLookups      = "client_lookups_total"
+
 	UserMetric = "user_metric"
 )
 
@@ -62,74 +98,191 @@ const (
 var (
 	statTotalProcessedSuccessfully = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalSuccessfullyProcessed,
+			Name: TotalSuccessfullyProcessed,
 			Help: "Total number of messages processed successfully."},
 		metricsLabelNames)
 	statTotalSysExceptions = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalSystemExceptions,
+			Name: TotalSystemExceptions,
 			Help: "Total number of system exceptions."},
 		metricsLabelNames)
 	statTotalUserExceptions = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalUserExceptions,
+			Name: TotalUserExceptions,
 			Help: "Total number of user exceptions."},
 		metricsLabelNames)
 
 	statProcessLatencyMs = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
-			Name: PulsarFunctionMetricsPrefix + ProcessLatencyMs,
+			Name: ProcessLatencyMs,
 			Help: "Process latency in milliseconds."}, metricsLabelNames)
+	// carries the trace id of the messages as exemplars, which only the OpenMetrics format exposes
+	statProcessLatencyMsHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    ProcessLatencyMsHistogram,
+			Help:    "Process latency in milliseconds.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 16)}, metricsLabelNames)
 
 	statLastInvocation = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + LastInvocation,
+			Name: LastInvocation,
 			Help: "The timestamp of the last invocation of the function."}, metricsLabelNames)
 
 	statTotalReceived = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalReceived,
+			Name: TotalReceived,
 			Help: "Total number of messages received from source."}, metricsLabelNames)
 
 	// 1min windowed metrics
 	statTotalProcessedSuccessfully1min = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalSuccessfullyProcessed1min,
+			Name: TotalSuccessfullyProcessed1min,
 			Help: "Total number of messages processed successfully in the last 1 minute."}, metricsLabelNames)
 	statTotalSysExceptions1min = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalSystemExceptions1min,
+			Name: TotalSystemExceptions1min,
 			Help: "Total number of system exceptions in the last 1 minute."},
 		metricsLabelNames)
 	statTotalUserExceptions1min = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalUserExceptions1min,
+			Name: TotalUserExceptions1min,
 			Help: "Total number of user exceptions in the last 1 minute."},
 		metricsLabelNames)
 
 	statProcessLatencyMs1min = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
-			Name: PulsarFunctionMetricsPrefix + ProcessLatencyMs1min,
+			Name: ProcessLatencyMs1min,
 			Help: "Process latency in milliseconds in the last 1 minute."}, metricsLabelNames)
 
 	statTotalReceived1min = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + TotalReceived1min,
+			Name: TotalReceived1min,
 			Help: "Total number of messages received from source in the last 1 minute."}, metricsLabelNames)
 
+	statTotalRestartRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalRestartRequests,
+			Help: "Total number of instance restarts requested by the function."}, metricsLabelNames)
+
+	statTotalAcks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalAcks,
+			Help: "Total number of input messages acked."}, metricsLabelNames)
+	statTotalNacks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalNacks,
+			Help: "Total number of input messages nacked for redelivery."}, metricsLabelNames)
+
+	statClientConnectionsActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: ClientConnectionsActive,
+			Help: "Number of broker connections currently open by the Pulsar client."}, metricsLabelNames)
+	statTotalClientLookups = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalClientLookups,
+			Help: "Total number of topic lookups made by the Pulsar client."}, metricsLabelNames)
+
+	statTotalThrottled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalThrottled,
+			Help: "Total number of messages held back by maxProcessingRate."}, metricsLabelNames)
+
+	statTotalUnknownUserConfigKeys = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalUnknownUserConfigKeys,
+			Help: "Total number of user config keys that were not declared by the function."}, metricsLabelNames)
+
+	statTotalDroppedPoisonMessages = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalDroppedPoisonMessages,
+			Help: "Total number of messages dropped after exhausting their retries without a dead letter topic."},
+		metricsLabelNames)
+
+	statTotalDeserializationErrors = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalDeserializationErrors,
+			Help: "Total number of times the input of the function failed to deserialize."},
+		metricsLabelNames)
+
+	statTotalExpiredMessages = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalExpiredMessages,
+			Help: "Total number of messages skipped as they were published longer ago than maxMessageAgeMs."},
+		metricsLabelNames)
+
+	statTotalOversizedOutputs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalOversizedOutputs,
+			Help: "Total number of messages routed to the dead letter topic as their output was too large."},
+		metricsLabelNames)
+
+	statTotalDuplicateMessages = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalDuplicateMessages,
+			Help: "Total number of messages skipped as duplicates of a message processed in the deduplication window."},
+		metricsLabelNames)
+
+	statTotalAuthTokenRefreshes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalAuthTokenRefreshes,
+			Help: "Total number of times the auth token was refreshed ahead of its expiry."},
+		metricsLabelNames)
+
+	statTotalRetries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalRetries,
+			Help: "Total number of times the function was invoked again on a message it failed on."},
+		metricsLabelNames)
+
+	statConsumerTotalReceived = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: ConsumerTotalReceived,
+			Help: "Total number of messages received from the input topic."},
+		topicMetricsLabelNames)
+
+	statConsumerTotalReceivedBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: ConsumerTotalReceivedBytes,
+			Help: "Total number of bytes received from the input topic."},
+		topicMetricsLabelNames)
+
+	statConsumerMsgRateIn = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: ConsumerMsgRateIn,
+			Help: "Messages received per second from the input topic, over the last consumerStatsIntervalMs."},
+		topicMetricsLabelNames)
+
+	statConsumerMsgThroughputIn = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: ConsumerMsgThroughputIn,
+			Help: "Bytes received per second from the input topic, over the last consumerStatsIntervalMs."},
+		topicMetricsLabelNames)
+
+	statTotalProcessErrors = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalProcessErrors,
+			Help: "Total number of messages the function failed on, by how the failure was handled."},
+		outcomeMetricsLabelNames)
+
+	statTotalSendFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TotalSendFailures,
+			Help: "Total number of outputs the sink producer failed to send, by how the failure was handled."},
+		outcomeMetricsLabelNames)
+
 	userExceptions = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + "user_exception",
+			Name: "user_exception",
 			Help: "Exception from user code."}, exceptionMetricsLabelNames)
 
 	systemExceptions = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: PulsarFunctionMetricsPrefix + "system_exception",
+			Name: "system_exception",
 			Help: "Exception from system code."}, exceptionMetricsLabelNames)
 
 	userMetricSummary = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
-			Name: PulsarFunctionMetricsPrefix + UserMetric,
+			Name: UserMetric,
 			Help: "User defined metric.",
 			Objectives: map[float64]float64{
 				0.5:   0.01,
@@ -145,25 +298,66 @@ type MetricsServicer struct {
 	server     *http.Server
 }
 
-var reg *prometheus.Registry
+var (
+	reg *prometheus.Registry
+	// prefix of the names of all metrics exposed by reg
+	metricsPrefix string
+)
+
+// metricsNamePattern is the set of valid Prometheus metric names
+var metricsNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
 
 func init() {
+	registerMetrics(PulsarFunctionMetricsPrefix)
+}
+
+// registerMetrics registers all metrics in a new registry, with names
+// starting with prefix
+func registerMetrics(prefix string) {
 	reg = prometheus.NewRegistry()
-	reg.MustRegister(statTotalProcessedSuccessfully)
-	reg.MustRegister(statTotalSysExceptions)
-	reg.MustRegister(statTotalUserExceptions)
-	reg.MustRegister(statProcessLatencyMs)
-	reg.MustRegister(statLastInvocation)
-	reg.MustRegister(statTotalReceived)
-	reg.MustRegister(statTotalProcessedSuccessfully1min)
-	reg.MustRegister(statTotalSysExceptions1min)
-	reg.MustRegister(statTotalUserExceptions1min)
-	reg.MustRegister(statProcessLatencyMs1min)
-	reg.MustRegister(statTotalReceived1min)
-	reg.MustRegister(userExceptions)
-	reg.MustRegister(systemExceptions)
-	reg.MustRegister(userMetricSummary)
+	metricsPrefix = prefix
+	registerer := prometheus.WrapRegistererWithPrefix(prefix, reg)
+	registerer.MustRegister(statTotalProcessedSuccessfully)
+	registerer.MustRegister(statTotalSysExceptions)
+	registerer.MustRegister(statTotalUserExceptions)
+	registerer.MustRegister(statProcessLatencyMs)
+	registerer.MustRegister(statProcessLatencyMsHistogram)
+	registerer.MustRegister(statLastInvocation)
+	registerer.MustRegister(statTotalReceived)
+	registerer.MustRegister(statTotalProcessedSuccessfully1min)
+	registerer.MustRegister(statTotalSysExceptions1min)
+	registerer.MustRegister(statTotalUserExceptions1min)
+	registerer.MustRegister(statProcessLatencyMs1min)
+	registerer.MustRegister(statTotalReceived1min)
+	registerer.MustRegister(statTotalRestartRequests)
+	registerer.MustRegister(statTotalAcks)
+	registerer.MustRegister(statTotalNacks)
+	registerer.MustRegister(statTotalThrottled)
+	registerer.MustRegister(statClientConnectionsActive)
+	registerer.MustRegister(statTotalClientLookups)
+	registerer.MustRegister(statTotalUnknownUserConfigKeys)
+	registerer.MustRegister(statTotalProcessErrors)
+	registerer.MustRegister(statTotalDroppedPoisonMessages)
+	registerer.MustRegister(statTotalSendFailures)
+	registerer.MustRegister(statTotalDeserializationErrors)
+	registerer.MustRegister(statTotalExpiredMessages)
+	registerer.MustRegister(statTotalOversizedOutputs)
+	registerer.MustRegister(statTotalDuplicateMessages)
+	registerer.MustRegister(statTotalAuthTokenRefreshes)
+	registerer.MustRegister(statTotalRetries)
+	registerer.MustRegister(statConsumerTotalReceived)
+	registerer.MustRegister(statConsumerTotalReceivedBytes)
+	registerer.MustRegister(statConsumerMsgRateIn)
+	registerer.MustRegister(statConsumerMsgThroughputIn)
+	registerer.MustRegister(userExceptions)
+	registerer.MustRegister(systemExceptions)
+	registerer.MustRegister(userMetricSummary)
+}
 
+// setMetricsNamespace replaces the default pulsar_function prefix of all
+// built-in and user metrics with namespace
+func setMetricsNamespace(namespace string) {
+	registerMetrics(namespace + "_")
 }
 
 type LatestException struct {
@@ -177,16 +371,35 @@ type StatWithLabelValues struct {
 	statTotalSysExceptions             prometheus.Gauge
 	statTotalUserExceptions            prometheus.Gauge
 	statProcessLatencyMs               prometheus.Observer
+	statProcessLatencyMsHistogram      prometheus.Observer
 	statLastInvocation                 prometheus.Gauge
 	statTotalReceived                  prometheus.Gauge
 	statTotalProcessedSuccessfully1min prometheus.Gauge
 	statTotalSysExceptions1min         prometheus.Gauge
 	statTotalUserExceptions1min        prometheus.Gauge
 	statTotalReceived1min              prometheus.Gauge
+	statTotalRestartRequests           prometheus.Gauge
+	statTotalAcks                      prometheus.Gauge
+	statTotalNacks                     prometheus.Gauge
+	statTotalThrottled                 prometheus.Gauge
+	statClientConnectionsActive        prometheus.Gauge
+	statTotalClientLookups             prometheus.Gauge
+	statTotalUnknownUserConfigKeys     prometheus.Gauge
+	statTotalDroppedPoisonMessages     prometheus.Gauge
+	statTotalDeserializationErrors     prometheus.Gauge
+	statTotalExpiredMessages           prometheus.Gauge
+	statTotalOversizedOutputs          prometheus.Gauge
+	statTotalDuplicateMessages         prometheus.Gauge
+	statTotalAuthTokenRefreshes        prometheus.Gauge
+	statTotalRetries                   prometheus.Gauge
 	latestUserException                []LatestException
 	latestSysException                 []LatestException
+	lastError                          *LatestException
+	lastErrorReset                     bool
 	processStartTime                   int64
 	metricsLabels                      []string
+	// guards the counters so that snapshots are consistent
+	mu *sync.RWMutex
 }
 
 func NewStatWithLabelValues(metricsLabels ...string) StatWithLabelValues {
@@ -195,6 +408,7 @@ func NewStatWithLabelValues(metricsLabels ...string) StatWithLabelValues {
 	var statTotalSysExceptions = statTotalSysExceptions.WithLabelValues(metricsLabels...)
 	var statTotalUserExceptions = statTotalUserExceptions.WithLabelValues(metricsLabels...)
 	var statProcessLatencyMs = statProcessLatencyMs.WithLabelValues(metricsLabels...)
+	var statProcessLatencyMsHistogram = statProcessLatencyMsHistogram.WithLabelValues(metricsLabels...)
 	var statLastInvocation = statLastInvocation.WithLabelValues(metricsLabels...)
 	var statTotalReceived = statTotalReceived.WithLabelValues(metricsLabels...)
 	var statTotalProcessedSuccessfully1min = statTotalProcessedSuccessfully1min.WithLabelValues(metricsLabels...)
@@ -202,22 +416,54 @@ func NewStatWithLabelValues(metricsLabels ...string) StatWithLabelValues {
 	var statTotalUserExceptions1min = statTotalUserExceptions1min.WithLabelValues(metricsLabels...)
 	//var _stat_process_latency_ms_1min = stat_process_latency_ms_1min.WithLabelValues(metrics_labels...)
 	var statTotalReceived1min = statTotalReceived1min.WithLabelValues(metricsLabels...)
+	var statTotalRestartRequests = statTotalRestartRequests.WithLabelValues(metricsLabels...)
+	var statTotalAcks = statTotalAcks.WithLabelValues(metricsLabels...)
+	var statTotalNacks = statTotalNacks.WithLabelValues(metricsLabels...)
+	var statTotalThrottled = statTotalThrottled.WithLabelValues(metricsLabels...)
+	var statClientConnectionsActive = statClientConnectionsActive.WithLabelValues(metricsLabels...)
+	var statTotalClientLookups = statTotalClientLookups.WithLabelValues(metricsLabels...)
+	var statTotalUnknownUserConfigKeys = statTotalUnknownUserConfigKeys.WithLabelValues(metricsLabels...)
+	var statTotalDroppedPoisonMessages = statTotalDroppedPoisonMessages.WithLabelValues(metricsLabels...)
+	var statTotalDeserializationErrors = statTotalDeserializationErrors.WithLabelValues(metricsLabels...)
+	var statTotalExpiredMessages = statTotalExpiredMessages.WithLabelValues(metricsLabels...)
+	var statTotalOversizedOutputs = statTotalOversizedOutputs.WithLabelValues(metricsLabels...)
+	var statTotalDuplicateMessages = statTotalDuplicateMessages.WithLabelValues(metricsLabels...)
+	var statTotalAuthTokenRefreshes = statTotalAuthTokenRefreshes.WithLabelValues(metricsLabels...)
+	var statTotalRetries = statTotalRetries.WithLabelValues(metricsLabels...)
 
 	statObj := StatWithLabelValues{
 		statTotalProcessedSuccessfully,
 		statTotalSysExceptions,
 		statTotalUserExceptions,
 		statProcessLatencyMs,
+		statProcessLatencyMsHistogram,
 		statLastInvocation,
 		statTotalReceived,
 		statTotalProcessedSuccessfully1min,
 		statTotalSysExceptions1min,
 		statTotalUserExceptions1min,
 		statTotalReceived1min,
+		statTotalRestartRequests,
+		statTotalAcks,
+		statTotalNacks,
+		statTotalThrottled,
+		statClientConnectionsActive,
+		statTotalClientLookups,
+		statTotalUnknownUserConfigKeys,
+		statTotalDroppedPoisonMessages,
+		statTotalDeserializationErrors,
+		statTotalExpiredMessages,
+		statTotalOversizedOutputs,
+		statTotalDuplicateMessages,
+		statTotalAuthTokenRefreshes,
+		statTotalRetries,
 		[]LatestException{},
 		[]LatestException{},
+		nil,
+		false,
 		0,
 		metricsLabels,
+		&sync.RWMutex{},
 	}
 	return statObj
 }
@@ -256,15 +502,27 @@ func (stat *StatWithLabelValues) processTimeStart() {
 	stat.processStartTime = now.UnixNano()
 }
 
-func (stat *StatWithLabelValues) processTimeEnd() {
+// processTimeEnd records the latency of the message being processed, with
+// the trace id of traceContext as the exemplar of the histogram if it is valid
+func (stat *StatWithLabelValues) processTimeEnd(traceContext TraceContext) {
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
 	if stat.processStartTime != 0 {
 		now := time.Now()
 		duration := now.UnixNano() - stat.processStartTime
-		stat.statProcessLatencyMs.Observe(float64(duration) / 1e6)
+		latencyMs := float64(duration) / 1e6
+		stat.statProcessLatencyMs.Observe(latencyMs)
+		if observer, ok := stat.statProcessLatencyMsHistogram.(prometheus.ExemplarObserver); ok && traceContext.IsValid() {
+			observer.ObserveWithExemplar(latencyMs, prometheus.Labels{traceIDExemplarLabel: traceContext.TraceID()})
+		} else {
+			stat.statProcessLatencyMsHistogram.Observe(latencyMs)
+		}
 	}
 }
 
 func (stat *StatWithLabelValues) incrTotalUserExceptions(err error) {
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
 	stat.statTotalUserExceptions.Inc()
 	stat.statTotalUserExceptions1min.Inc()
 	stat.addUserException(err)
@@ -278,6 +536,7 @@ func (stat *StatWithLabelValues) addUserException(err error) {
 	if len(stat.latestUserException) > 10 {
 		stat.latestUserException = stat.latestUserException[1:]
 	}
+	stat.setLastError(errorTS)
 	// report exception via prometheus
 	stat.reportUserExceptionPrometheus(err)
 }
@@ -290,11 +549,19 @@ func (stat *StatWithLabelValues) reportUserExceptionPrometheus(exception error)
 }
 
 func (stat *StatWithLabelValues) incrTotalProcessedSuccessfully() {
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
 	stat.statTotalProcessedSuccessfully.Inc()
 	stat.statTotalProcessedSuccessfully1min.Inc()
+	if stat.lastErrorReset {
+		stat.lastError = nil
+		stat.lastErrorReset = false
+	}
 }
 
 func (stat *StatWithLabelValues) incrTotalSysExceptions(exception error) {
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
 	stat.statTotalSysExceptions.Inc()
 	stat.statTotalSysExceptions1min.Inc()
 	stat.addSysException(exception)
@@ -308,6 +575,7 @@ func (stat *StatWithLabelValues) addSysException(exception error) {
 	if len(stat.latestSysException) > 10 {
 		stat.latestSysException = stat.latestSysException[1:]
 	}
+	stat.setLastError(errorTS)
 	// report exception via prometheus
 	stat.reportSystemExceptionPrometheus(exception)
 }
@@ -320,27 +588,135 @@ func (stat *StatWithLabelValues) reportSystemExceptionPrometheus(exception error
 }
 
 func (stat *StatWithLabelValues) incrTotalReceived() {
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
 	stat.statTotalReceived.Inc()
 	stat.statTotalReceived1min.Inc()
 }
 
+func (stat *StatWithLabelValues) incrTotalRestartRequests() {
+	stat.statTotalRestartRequests.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalAcks() {
+	stat.statTotalAcks.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalNacks() {
+	stat.statTotalNacks.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalThrottled() {
+	stat.statTotalThrottled.Inc()
+}
+
+func (stat *StatWithLabelValues) setClientStats(stats clientConnectionStats) {
+	stat.statClientConnectionsActive.Set(stats.activeConnections)
+	stat.statTotalClientLookups.Set(stats.lookups)
+}
+
+func (stat *StatWithLabelValues) setConsumerStats(topic string, stats consumerStats, rateIn, throughputIn float64) {
+	topicMetricLabels := append(append([]string{}, stat.metricsLabels...), topic)
+	statConsumerTotalReceived.WithLabelValues(topicMetricLabels...).Set(stats.messagesReceived)
+	statConsumerTotalReceivedBytes.WithLabelValues(topicMetricLabels...).Set(stats.bytesReceived)
+	statConsumerMsgRateIn.WithLabelValues(topicMetricLabels...).Set(rateIn)
+	statConsumerMsgThroughputIn.WithLabelValues(topicMetricLabels...).Set(throughputIn)
+}
+
+func (stat *StatWithLabelValues) incrTotalDroppedPoisonMessages() {
+	stat.statTotalDroppedPoisonMessages.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalDeserializationErrors() {
+	stat.statTotalDeserializationErrors.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalExpiredMessages() {
+	stat.statTotalExpiredMessages.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalOversizedOutputs() {
+	stat.statTotalOversizedOutputs.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalDuplicateMessages() {
+	stat.statTotalDuplicateMessages.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalAuthTokenRefreshes() {
+	stat.statTotalAuthTokenRefreshes.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalRetries() {
+	stat.statTotalRetries.Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalProcessErrors(outcome string) {
+	outcomeMetricLabels := append(append([]string{}, stat.metricsLabels...), outcome)
+	statTotalProcessErrors.WithLabelValues(outcomeMetricLabels...).Inc()
+}
+
+func (stat *StatWithLabelValues) incrTotalSendFailures(outcome string) {
+	outcomeMetricLabels := append(append([]string{}, stat.metricsLabels...), outcome)
+	statTotalSendFailures.WithLabelValues(outcomeMetricLabels...).Inc()
+}
+
+func (stat *StatWithLabelValues) setLastError(exception LatestException) {
+	stat.lastError = &exception
+	stat.lastErrorReset = false
+}
+
+func (stat *StatWithLabelValues) addTotalUnknownUserConfigKeys(count int) {
+	stat.statTotalUnknownUserConfigKeys.Add(float64(count))
+}
+
 func (stat *StatWithLabelValues) reset() {
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
 	stat.statTotalProcessedSuccessfully1min.Set(0.0)
 	stat.statTotalUserExceptions1min.Set(0.0)
 	stat.statTotalSysExceptions1min.Set(0.0)
 	stat.statTotalReceived1min.Set(0.0)
+	// the last error is reported until the next successful process
+	stat.lastErrorReset = stat.lastError != nil
+}
+
+// openMetricsAccept is the Accept header promhttp answers in the OpenMetrics format
+const openMetricsAccept = "application/openmetrics-text"
+
+// openMetricsHandler serves the metrics of handler in the OpenMetrics format,
+// whatever format the scraper asked for
+func openMetricsHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		r.Header.Set("Accept", openMetricsAccept)
+		handler.ServeHTTP(w, r)
+	})
 }
 
 func NewMetricsServicer(goInstance *goInstance) *MetricsServicer {
 	serveMux := http.NewServeMux()
-	pHandler := promhttp.HandlerFor(
+	var pHandler http.Handler = promhttp.HandlerFor(
 		reg,
 		promhttp.HandlerOpts{
 			EnableOpenMetrics: true,
 		},
 	)
+	if goInstance.context.instanceConf.metricsOpenMetrics {
+		pHandler = openMetricsHandler(pHandler)
+	}
 	serveMux.Handle("/", pHandler)
 	serveMux.Handle("/metrics", pHandler)
+	if goInstance.context.instanceConf.metricsStatusEndpoint {
+		serveMux.Handle("/status", statusHandler(goInstance))
+	}
+	serveMux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if !goInstance.isReady() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", goInstance.context.GetMetricsPort()),
 		Handler: serveMux,
@@ -351,17 +727,47 @@ func NewMetricsServicer(goInstance *goInstance) *MetricsServicer {
 	}
 }
 
-func (s *MetricsServicer) serve() {
+// MetricsBindError is returned when the metrics server cannot listen on the
+// configured metrics port, typically because another process already uses it.
+type MetricsBindError struct {
+	Port int
+	// PID of the process listening on Port, 0 if unknown
+	PID int
+	Err error
+}
+
+func (e *MetricsBindError) Error() string {
+	owner := "another process"
+	if e.PID > 0 {
+		owner = fmt.Sprintf("process %d", e.PID)
+	}
+	return fmt.Sprintf("failed to start metrics server: port %d is already in use by %s (%v), "+
+		"configure a free metricsPort or set continueWithoutMetrics to run without metrics", e.Port, owner, e.Err)
+}
+
+func (e *MetricsBindError) Unwrap() error {
+	return e.Err
+}
+
+// serve binds the metrics port and serves metrics in the background. It
+// returns a *MetricsBindError if the port cannot be bound.
+func (s *MetricsServicer) serve() error {
+	// create a listener on metrics port
+	port := s.goInstance.context.GetMetricsPort()
+	lis, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return &MetricsBindError{Port: port, PID: findListeningPID(port), Err: err}
+	}
+	log.Infof("Starting metrics server on port %d", port)
 	go func() {
-		// create a listener on metrics port
-		log.Infof("Starting metrics server on port %d", s.goInstance.context.GetMetricsPort())
-		err := s.server.ListenAndServe()
+		err := s.server.Serve(lis)
 		switch err {
 		case nil, http.ErrServerClosed:
 		default:
 			log.Fatalf("failed to start metrics server: %v", err)
 		}
 	}()
+	return nil
 }
 
 func (s *MetricsServicer) close() {
@@ -370,3 +776,70 @@ func (s *MetricsServicer) close() {
 		log.Fatalf("failed to close metrics server: %v", err)
 	}
 }
+
+// MetricsSnapshot holds the values of the built-in metrics of an instance at a
+// point in time
+type MetricsSnapshot struct {
+	TotalReceived              int64
+	TotalProcessedSuccessfully int64
+	TotalUserExceptions        int64
+	TotalSysExceptions         int64
+	AvgProcessLatencyMs        float64
+}
+
+// ErrorRate returns the share of received messages that failed with a user or
+// system exception
+func (s MetricsSnapshot) ErrorRate() float64 {
+	if s.TotalReceived == 0 {
+		return 0
+	}
+	return float64(s.TotalUserExceptions+s.TotalSysExceptions) / float64(s.TotalReceived)
+}
+
+// latestExceptions returns copies of the latest user and system exceptions,
+// oldest first
+func (stat *StatWithLabelValues) latestExceptions() (user, sys []LatestException) {
+	stat.mu.RLock()
+	defer stat.mu.RUnlock()
+	return append([]LatestException(nil), stat.latestUserException...),
+		append([]LatestException(nil), stat.latestSysException...)
+}
+
+// getLastError returns the latest user or system exception, nil if there was
+// none or a successful process followed reset
+func (stat *StatWithLabelValues) getLastError() *LatestException {
+	stat.mu.RLock()
+	defer stat.mu.RUnlock()
+	if stat.lastError == nil {
+		return nil
+	}
+	lastError := *stat.lastError
+	return &lastError
+}
+
+func (stat *StatWithLabelValues) snapshot() MetricsSnapshot {
+	stat.mu.RLock()
+	defer stat.mu.RUnlock()
+
+	snapshot := MetricsSnapshot{
+		TotalReceived:              int64(gaugeValue(stat.statTotalReceived)),
+		TotalProcessedSuccessfully: int64(gaugeValue(stat.statTotalProcessedSuccessfully)),
+		TotalUserExceptions:        int64(gaugeValue(stat.statTotalUserExceptions)),
+		TotalSysExceptions:         int64(gaugeValue(stat.statTotalSysExceptions)),
+	}
+	if summary, ok := stat.statProcessLatencyMs.(prometheus.Metric); ok {
+		metric := &prometheus_client.Metric{}
+		if err := summary.Write(metric); err == nil && metric.GetSummary().GetSampleCount() > 0 {
+			snapshot.AvgProcessLatencyMs = metric.GetSummary().GetSampleSum() / float64(metric.GetSummary().GetSampleCount())
+		}
+	}
+	return snapshot
+}
+
+func gaugeValue(gauge prometheus.Gauge) float64 {
+	metric := &prometheus_client.Metric{}
+	if err := gauge.Write(metric); err != nil {
+		return 0
+	}
+	return metric.GetGauge().GetValue()
+}
@@ -21,19 +21,27 @@ package pf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/encoding/prototext"
 
 	prometheus_client "github.com/prometheus/client_model/go"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 )
 
 func TestExampleSummaryVec(t *testing.T) {
@@ -146,7 +154,8 @@ func TestExampleSummaryVec(t *testing.T) {
 
 	r, err := prototext.MarshalOptions{Indent: "  "}.Marshal(metricFamilies[0])
 	assert.NoError(t, err)
-	assert.Equal(t, expectedValue, string(r))
+	// prototext output is deliberately unstable in its whitespace, so only compare the tokens
+	assert.Equal(t, strings.Fields(expectedValue), strings.Fields(string(r)))
 }
 func TestExampleSummaryVec_Pulsar(t *testing.T) {
 	_statProcessLatencyMs1 := prometheus.NewSummaryVec(
@@ -222,6 +231,26 @@ func TestMetricsServer(t *testing.T) {
 	metricsServicer.close()
 }
 
+func TestMetricsServerReadiness(t *testing.T) {
+	gi := newGoInstance()
+	metricsServicer := NewMetricsServicer(gi)
+	metricsServicer.serve()
+	t.Cleanup(metricsServicer.close)
+	time.Sleep(time.Second * 1)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/ready", gi.context.GetMetricsPort()))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp.Body.Close()
+
+	gi.ready.Store(true)
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/ready", gi.context.GetMetricsPort()))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
 // nolint
 func TestUserMetrics(t *testing.T) {
 	gi := newGoInstance()
@@ -287,3 +316,260 @@ func TestInstanceControlMetrics(t *testing.T) {
 		assert.EqualValuesf(t, value+1, metrics.UserMetrics[label], "user metric %s != %d", label, value+1)
 	}
 }
+
+func TestMetricsServer_PortInUse(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		lis.Close()
+	})
+	port := lis.Addr().(*net.TCPAddr).Port
+
+	gi := newGoInstance()
+	gi.context.instanceConf.metricsPort = port
+
+	metricsServicer, err := gi.startMetricsServer()
+	assert.Nil(t, metricsServicer)
+	var bindErr *MetricsBindError
+	assert.ErrorAs(t, err, &bindErr)
+	assert.Equal(t, port, bindErr.Port)
+	assert.Contains(t, err.Error(), fmt.Sprintf("port %d is already in use", port))
+	if pid := findListeningPID(port); pid > 0 {
+		// the port is held by the test process itself
+		assert.Equal(t, os.Getpid(), bindErr.PID)
+		assert.Contains(t, err.Error(), fmt.Sprintf("process %d", os.Getpid()))
+	}
+
+	gi.context.instanceConf.continueWithoutMetrics = true
+	metricsServicer, err = gi.startMetricsServer()
+	assert.NoError(t, err)
+	assert.NotNil(t, metricsServicer)
+	metricsServicer.close()
+}
+
+type failOnPayloadHandler struct{}
+
+func (h *failOnPayloadHandler) process(ctx context.Context, input []byte) ([]byte, error) {
+	if string(input) == "fail" {
+		return nil, errors.New("boom")
+	}
+	return nil, nil
+}
+
+func TestGetMetricsSnapshot(t *testing.T) {
+	instance := newGoInstance()
+	instance.function = &failOnPayloadHandler{}
+	instance.context.instanceConf.killAfterIdle = 100
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.context.instanceConf.funcDetails.RetryDetails = &pb.RetryDetails{DeadLetterTopic: "topic-01-DLQ"}
+	instance.deadLetterProducer = &MockPulsarProducer{}
+	instance.consumers["persistent://public/default/topic-01"] = &MockPulsarConsumer{}
+	// the metrics are shared by all instances with the same labels
+	before := instance.context.GetMetricsSnapshot()
+
+	channel := make(chan pulsar.ConsumerMessage, 5)
+	for _, payload := range []string{"ok", "fail", "ok", "fail", "ok"} {
+		channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+			topic:     "persistent://public/default/topic-01",
+			messageID: &MockMessageID{},
+			payload:   []byte(payload),
+		}}
+	}
+	assert.NoError(t, instance.processMessages(channel))
+
+	after := instance.context.GetMetricsSnapshot()
+	assert.Equal(t, int64(5), after.TotalReceived-before.TotalReceived)
+	assert.Equal(t, int64(3), after.TotalProcessedSuccessfully-before.TotalProcessedSuccessfully)
+	assert.Equal(t, int64(2), after.TotalUserExceptions-before.TotalUserExceptions)
+	assert.Equal(t, 0.4, MetricsSnapshot{TotalReceived: 5, TotalUserExceptions: 2}.ErrorRate())
+}
+
+func TestGetMetricsSnapshot_ConcurrentUpdates(t *testing.T) {
+	instance := newGoInstance()
+	before := instance.context.GetMetricsSnapshot()
+	assertConsistent := func(snapshot MetricsSnapshot) {
+		processed := snapshot.TotalProcessedSuccessfully - before.TotalProcessedSuccessfully
+		received := snapshot.TotalReceived - before.TotalReceived
+		assert.LessOrEqual(t, processed, received)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			instance.stats.incrTotalReceived()
+			instance.stats.incrTotalProcessedSuccessfully()
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			assertConsistent(instance.context.GetMetricsSnapshot())
+			return
+		default:
+			assertConsistent(instance.context.GetMetricsSnapshot())
+		}
+	}
+}
+
+func TestMetricsNamespace(t *testing.T) {
+	setMetricsNamespace("my_tenant_functions")
+	t.Cleanup(func() {
+		registerMetrics(PulsarFunctionMetricsPrefix)
+	})
+
+	gi := newGoInstance()
+	before := gi.getTotalReceived()
+	gi.stats.incrTotalReceived()
+	gi.context.RecordMetric("namespaced", 1)
+	assert.Equal(t, before+1, gi.getTotalReceived())
+
+	metricFamilies, err := reg.Gather()
+	assert.NoError(t, err)
+	names := make([]string, 0, len(metricFamilies))
+	for _, metricFamily := range metricFamilies {
+		names = append(names, metricFamily.GetName())
+		assert.True(t, strings.HasPrefix(metricFamily.GetName(), "my_tenant_functions_"), metricFamily.GetName())
+	}
+	assert.Contains(t, names, "my_tenant_functions_received_total")
+	assert.Contains(t, names, "my_tenant_functions_user_metric")
+}
+
+func TestMetricsNamespace_StartMetricsServer(t *testing.T) {
+	t.Cleanup(func() {
+		registerMetrics(PulsarFunctionMetricsPrefix)
+	})
+	gi := newGoInstance()
+	gi.context.instanceConf.metricsNamespace = "my_tenant"
+	assert.Equal(t, PulsarFunctionMetricsPrefix, metricsPrefix)
+
+	metricsServicer, err := gi.startMetricsServer()
+	assert.NoError(t, err)
+	t.Cleanup(metricsServicer.close)
+	gi.stats.incrTotalReceived()
+	time.Sleep(time.Second * 1)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", gi.context.GetMetricsPort()))
+	assert.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "my_tenant_received_total")
+	assert.NotContains(t, string(body), PulsarFunctionMetricsPrefix+"received_total")
+}
+
+func TestAckNackMetrics(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.instanceConf.funcDetails.AutoAck = true
+	instance.context.instanceConf.funcDetails.ProcessingGuarantees = pb.ProcessingGuarantees_ATLEAST_ONCE
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/output"
+	producer := &MockPulsarProducer{}
+	instance.producer = producer
+	consumer := &MockPulsarConsumer{}
+	instance.consumers["persistent://public/default/topic-01"] = consumer
+	message := &MockMessage{topic: "persistent://public/default/topic-01", messageID: &MockMessageID{}}
+
+	acks, nacks := instance.getTotalAcks(), instance.getTotalNacks()
+	instance.processResult(message, []byte("first"))
+	instance.processResult(message, []byte("second"))
+	assert.Equal(t, acks+2, instance.getTotalAcks())
+	assert.Equal(t, nacks, instance.getTotalNacks())
+
+	producer.sendErr = errors.New("send failed")
+	instance.context.instanceConf.onSendFailure = OnSendFailureNack
+	instance.processResult(message, []byte("third"))
+	assert.Equal(t, acks+2, instance.getTotalAcks())
+	assert.Equal(t, nacks+1, instance.getTotalNacks())
+	assert.Len(t, consumer.acked, 2)
+	assert.Len(t, consumer.nacked, 1)
+}
+
+func TestExceptionClassification(t *testing.T) {
+	userErr := errors.New("user error")
+	handler := func(_ context.Context, input []byte) ([]byte, error) {
+		if string(input) == "bad" {
+			return nil, userErr
+		}
+		return input, nil
+	}
+	instance, _, producer := newOutputValidatorTestInstance(handler, OnProcessErrorSkip)
+	instance.context.instanceConf.funcDetails.RetryDetails = nil
+	userExceptions, sysExceptions := instance.getTotalUserExceptions(), instance.getTotalSysExceptions()
+	before := time.Now().UnixMilli()
+
+	// the function fails on the first input, the client on the output of the second
+	assert.NoError(t, instance.processMessages(inputMessages("bad")))
+	assert.Equal(t, userExceptions+1, instance.getTotalUserExceptions())
+	assert.Equal(t, sysExceptions, instance.getTotalSysExceptions())
+
+	producer.sendErr = errors.New("client error")
+	instance.context.instanceConf.onSendFailure = OnSendFailureNack
+	assert.NoError(t, instance.processMessages(inputMessages("good")))
+	assert.Equal(t, userExceptions+1, instance.getTotalUserExceptions())
+	assert.Equal(t, sysExceptions+1, instance.getTotalSysExceptions())
+	after := time.Now().UnixMilli()
+
+	status := instance.getFunctionStatus()
+	if assert.Len(t, status.LatestUserExceptions, 1) {
+		assert.Equal(t, "user error", status.LatestUserExceptions[0].ExceptionString)
+		assert.GreaterOrEqual(t, status.LatestUserExceptions[0].MsSinceEpoch, before)
+		assert.LessOrEqual(t, status.LatestUserExceptions[0].MsSinceEpoch, after)
+	}
+	if assert.Len(t, status.LatestSystemExceptions, 1) {
+		assert.Equal(t, "client error", status.LatestSystemExceptions[0].ExceptionString)
+		assert.GreaterOrEqual(t, status.LatestSystemExceptions[0].MsSinceEpoch, before)
+		assert.LessOrEqual(t, status.LatestSystemExceptions[0].MsSinceEpoch, after)
+	}
+}
+
+func scrapeMetrics(t *testing.T, gi *goInstance, accept string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	NewMetricsServicer(gi).server.Handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	return recorder
+}
+
+func TestMetricsOpenMetrics(t *testing.T) {
+	gi, _, _ := newOutputValidatorTestInstance(echo, OnProcessErrorSkip)
+	channel := make(chan pulsar.ConsumerMessage, 1)
+	channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+		topic:      "persistent://public/default/topic-01",
+		messageID:  &MockMessageID{},
+		properties: map[string]string{TraceParentProperty: testTraceParent},
+	}}
+	assert.NoError(t, gi.processMessages(channel))
+	exemplar := `# {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"}`
+
+	// negotiated by default, the text format has no exemplars
+	recorder := scrapeMetrics(t, gi, "")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, recorder.Body.String(), "pulsar_function_process_latency_ms_histogram_bucket")
+	assert.NotContains(t, recorder.Body.String(), exemplar)
+	recorder = scrapeMetrics(t, gi, "application/openmetrics-text")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/openmetrics-text"))
+	assert.Contains(t, recorder.Body.String(), exemplar)
+
+	gi.context.instanceConf.metricsOpenMetrics = true
+	recorder = scrapeMetrics(t, gi, "text/plain")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/openmetrics-text"))
+	assert.Contains(t, recorder.Body.String(), exemplar)
+}
+
+func TestProcessLatencyHistogram_WithoutTraceContext(t *testing.T) {
+	gi := newGoInstance()
+	histogram := statProcessLatencyMsHistogram.WithLabelValues(gi.getMetricsLabels()...).(prometheus.Metric)
+	before := &prometheus_client.Metric{}
+	assert.NoError(t, histogram.Write(before))
+
+	gi.stats.processTimeStart()
+	gi.stats.processTimeEnd(TraceContext{})
+
+	after := &prometheus_client.Metric{}
+	assert.NoError(t, histogram.Write(after))
+	assert.Equal(t, before.GetHistogram().GetSampleCount()+1, after.GetHistogram().GetSampleCount())
+}
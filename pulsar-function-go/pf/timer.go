@@ -0,0 +1,60 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"time"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+// registerTimer calls fn every interval on a goroutine of its own until the
+// instance is closed. Callbacks of all timers run one at a time, so a timer
+// firing while another one is still running waits for it to return.
+func (gi *goInstance) registerTimer(interval time.Duration, fn func(ctx context.Context)) {
+	if interval <= 0 {
+		log.Errorf("timer interval must be positive, got %v, the timer is not registered", interval)
+		return
+	}
+	ctx := NewContext(gi.timersCtx, gi.context)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-gi.timersCtx.Done():
+				return
+			case <-ticker.C:
+				gi.fireTimer(ctx, fn)
+			}
+		}
+	}()
+}
+
+func (gi *goInstance) fireTimer(ctx context.Context, fn func(ctx context.Context)) {
+	gi.timersMu.Lock()
+	defer gi.timersMu.Unlock()
+	// the instance may have been closed while waiting for another callback
+	if ctx.Err() != nil {
+		return
+	}
+	fn(ctx)
+}
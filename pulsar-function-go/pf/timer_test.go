@@ -0,0 +1,106 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterTimer_FiresOnInterval(t *testing.T) {
+	instance := newGoInstance()
+	defer instance.close()
+	interval := 20 * time.Millisecond
+	fired := make(chan time.Time, 10)
+	start := time.Now()
+	instance.context.RegisterTimer(interval, func(ctx context.Context) {
+		fc, ok := FromContext(ctx)
+		assert.True(t, ok)
+		assert.Same(t, instance.context, fc)
+		fired <- time.Now()
+	})
+
+	var last time.Time
+	for i := 0; i < 4; i++ {
+		select {
+		case last = <-fired:
+		case <-time.After(time.Second):
+			t.Fatal("timer did not fire")
+		}
+	}
+	elapsed := last.Sub(start)
+	assert.GreaterOrEqual(t, elapsed, 4*interval)
+	assert.Less(t, elapsed, 4*interval+200*time.Millisecond)
+}
+
+func TestRegisterTimer_Serialized(t *testing.T) {
+	instance := newGoInstance()
+	defer instance.close()
+	var running, maxRunning, calls atomic.Int32
+	callback := func(ctx context.Context) {
+		n := running.Add(1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		calls.Add(1)
+	}
+	instance.context.RegisterTimer(5*time.Millisecond, callback)
+	instance.context.RegisterTimer(5*time.Millisecond, callback)
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 6 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), maxRunning.Load())
+}
+
+func TestRegisterTimer_StopsOnShutdown(t *testing.T) {
+	instance := newGoInstance()
+	var calls atomic.Int32
+	var timerCtx atomic.Value
+	instance.context.RegisterTimer(5*time.Millisecond, func(ctx context.Context) {
+		timerCtx.Store(ctx)
+		calls.Add(1)
+	})
+	assert.Eventually(t, func() bool { return calls.Load() > 0 }, time.Second, time.Millisecond)
+
+	instance.close()
+	stopped := calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, calls.Load())
+	assert.Error(t, timerCtx.Load().(context.Context).Err())
+}
+
+func TestRegisterTimer_NonPositiveInterval(t *testing.T) {
+	instance := newGoInstance()
+	defer instance.close()
+	var calls atomic.Int32
+	assert.NotPanics(t, func() {
+		instance.context.RegisterTimer(0, func(ctx context.Context) { calls.Add(1) })
+	})
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, calls.Load())
+}
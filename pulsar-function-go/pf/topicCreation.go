@@ -0,0 +1,47 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTopicNotFound is returned, wrapped, when a producer cannot be created
+// because its topic does not exist and was not created by the broker
+var ErrTopicNotFound = errors.New("topic not found")
+
+const errMsgTopicNotFound = "TopicNotFound"
+
+// classifyTopicNotFound wraps err with ErrTopicNotFound when the producer for
+// topic could not be created as the topic does not exist, telling whether
+// allowAutoTopicCreation ruled out its creation
+func (gi *goInstance) classifyTopicNotFound(topic string, err error) error {
+	if !strings.Contains(err.Error(), errMsgTopicNotFound) {
+		return err
+	}
+	if topic == gi.context.instanceConf.funcDetails.Sink.Topic && !gi.context.instanceConf.allowAutoTopicCreation {
+		return fmt.Errorf("%w: sink topic %s does not exist and allowAutoTopicCreation is false, "+
+			"create it beforehand: %v", ErrTopicNotFound, topic, err)
+	}
+	return fmt.Errorf("%w: %s does not exist and the broker did not create it, "+
+		"check the allowAutoTopicCreation policy of its namespace: %v", ErrTopicNotFound, topic, err)
+}
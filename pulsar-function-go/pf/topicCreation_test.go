@@ -0,0 +1,111 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/apache/pulsar/pulsar-function-go/conf"
+)
+
+// topicCreatingClient creates producers for the existing topics, and for the
+// missing ones only when autoCreate is set, as a broker does following the
+// allowAutoTopicCreation policy of the namespace
+type topicCreatingClient struct {
+	pulsar.Client
+	existing   map[string]bool
+	autoCreate bool
+}
+
+func (c *topicCreatingClient) CreateProducer(options pulsar.ProducerOptions) (pulsar.Producer, error) {
+	if !c.existing[options.Topic] && !c.autoCreate {
+		return nil, errors.New("server error: TopicNotFound: Topic " + options.Topic + " does not exist")
+	}
+	c.existing[options.Topic] = true
+	return &MockPulsarProducer{}, nil
+}
+
+func newTopicCreationTestInstance(client pulsar.Client, allowAutoTopicCreation bool) *goInstance {
+	instance := newGoInstance()
+	instance.client = client
+	instance.context.instanceConf.funcDetails.Sink.Topic = "persistent://public/default/topic-02"
+	instance.context.instanceConf.allowAutoTopicCreation = allowAutoTopicCreation
+	return instance
+}
+
+func TestTopicCreation_Allowed(t *testing.T) {
+	client := &topicCreatingClient{existing: map[string]bool{}, autoCreate: true}
+	instance := newTopicCreationTestInstance(client, true)
+
+	assert.NoError(t, instance.setupProducer())
+	assert.NotNil(t, instance.producer)
+	assert.True(t, client.existing["persistent://public/default/topic-02"])
+}
+
+func TestTopicCreation_AllowedButNotCreated(t *testing.T) {
+	instance := newTopicCreationTestInstance(&topicCreatingClient{existing: map[string]bool{}}, true)
+
+	err := instance.setupProducer()
+	assert.ErrorIs(t, err, ErrTopicNotFound)
+	assert.ErrorContains(t, err, "persistent://public/default/topic-02 does not exist and the broker did not create it")
+}
+
+func TestTopicCreation_Disallowed(t *testing.T) {
+	instance := newTopicCreationTestInstance(&topicCreatingClient{existing: map[string]bool{}}, false)
+
+	err := instance.setupProducer()
+	assert.ErrorIs(t, err, ErrTopicNotFound)
+	assert.ErrorContains(t, err,
+		"sink topic persistent://public/default/topic-02 does not exist and allowAutoTopicCreation is false")
+	assert.Nil(t, instance.producer)
+}
+
+func TestTopicCreation_DisallowedExistingTopic(t *testing.T) {
+	client := &topicCreatingClient{existing: map[string]bool{"persistent://public/default/topic-02": true}}
+	instance := newTopicCreationTestInstance(client, false)
+
+	assert.NoError(t, instance.setupProducer())
+	assert.NotNil(t, instance.producer)
+}
+
+func TestTopicCreation_OtherErrorsUnchanged(t *testing.T) {
+	connErr := errors.New("connection error")
+	instance := newSchemaCreationTestInstance(&schemaRejectingClient{connErr: connErr})
+	instance.context.instanceConf.allowAutoTopicCreation = false
+
+	_, err := instance.getProducer("persistent://public/default/topic-02")
+	assert.Equal(t, connErr, err)
+	assert.NotErrorIs(t, err, ErrTopicNotFound)
+}
+
+func TestInstanceConf_AllowAutoTopicCreation(t *testing.T) {
+	instanceConf := newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3})
+	assert.True(t, instanceConf.allowAutoTopicCreation)
+
+	for _, allow := range []bool{true, false} {
+		allow := allow
+		instanceConf = newInstanceConfWithConf(&cfg.Conf{ProcessingGuarantees: 3, AllowAutoTopicCreation: &allow})
+		assert.Equal(t, allow, instanceConf.allowAutoTopicCreation)
+	}
+}
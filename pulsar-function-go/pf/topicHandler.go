@@ -0,0 +1,79 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ProcessFunc is a function handling messages from an input topic. It may have
+// any of the signatures accepted by Start.
+type ProcessFunc interface{}
+
+var (
+	topicHandlersMu sync.Mutex
+	topicHandlers   = make(map[string]function)
+)
+
+// RegisterTopicHandler routes messages received from topic to fn instead of the
+// function passed to Start, which remains the handler of all other input
+// topics. It must be called before Start.
+func RegisterTopicHandler(topic string, fn ProcessFunc) {
+	topicName, err := ParseTopicName(topic)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid topic %s for topic handler: %v", topic, err))
+	}
+	topicHandlersMu.Lock()
+	defer topicHandlersMu.Unlock()
+	topicHandlers[topicName.NameWithoutPartition()] = newFunction(fn)
+}
+
+// topicDispatcher dispatches each message to the handler registered for the topic
+// of the current record, falling back to the default handler
+type topicDispatcher struct {
+	handlers       map[string]function
+	defaultHandler function
+}
+
+func newTopicDispatcher(defaultHandler function) function {
+	topicHandlersMu.Lock()
+	defer topicHandlersMu.Unlock()
+	if len(topicHandlers) == 0 {
+		return defaultHandler
+	}
+	handlers := make(map[string]function, len(topicHandlers))
+	for topic, handler := range topicHandlers {
+		handlers[topic] = handler
+	}
+	return &topicDispatcher{handlers: handlers, defaultHandler: defaultHandler}
+}
+
+func (r *topicDispatcher) process(ctx context.Context, input []byte) ([]byte, error) {
+	if fc, ok := FromContext(ctx); ok && fc.GetCurrentRecord() != nil {
+		if topicName, err := ParseTopicName(fc.GetCurrentRecord().Topic()); err == nil {
+			if handler, ok := r.handlers[topicName.NameWithoutPartition()]; ok {
+				return handler.process(ctx, input)
+			}
+		}
+	}
+	return r.defaultHandler.process(ctx, input)
+}
@@ -0,0 +1,75 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetTopicHandlers(t *testing.T) {
+	t.Cleanup(func() {
+		topicHandlersMu.Lock()
+		defer topicHandlersMu.Unlock()
+		topicHandlers = make(map[string]function)
+	})
+}
+
+func handlerReturning(output string) func(context.Context, []byte) ([]byte, error) {
+	return func(ctx context.Context, input []byte) ([]byte, error) {
+		return []byte(output), nil
+	}
+}
+
+func TestTopicRouter_RoutesByTopic(t *testing.T) {
+	resetTopicHandlers(t)
+	RegisterTopicHandler("orders", handlerReturning("orders"))
+	RegisterTopicHandler("persistent://public/default/payments", handlerReturning("payments"))
+
+	instance := newGoInstance()
+	instance.function = newTopicDispatcher(newFunction(handlerReturning("default")))
+
+	tests := map[string]string{
+		"persistent://public/default/orders":               "orders",
+		"persistent://public/default/payments-partition-1": "payments",
+		"persistent://public/default/refunds":              "default",
+	}
+	for topic, expected := range tests {
+		output, err := instance.handlerMsg(&MockMessage{topic: topic, messageID: &MockMessageID{}})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(output), topic)
+	}
+}
+
+func TestTopicRouter_NoRegisteredHandlers(t *testing.T) {
+	resetTopicHandlers(t)
+	defaultHandler := newFunction(handlerReturning("default"))
+
+	assert.IsType(t, pulsarFunction(nil), newTopicDispatcher(defaultHandler))
+}
+
+func TestRegisterTopicHandler_InvalidTopic(t *testing.T) {
+	resetTopicHandlers(t)
+	assert.Panics(t, func() {
+		RegisterTopicHandler("persistent://invalid", handlerReturning("invalid"))
+	})
+}
@@ -34,6 +34,11 @@ type TopicName struct {
 	Partition int
 }
 
+const (
+	persistentDomain    = "persistent"
+	nonPersistentDomain = "non-persistent"
+)
+
 const (
 	publicTenant           = "public"
 	defaultNamespace       = "default"
@@ -68,7 +73,7 @@ func ParseTopicName(topic string) (*TopicName, error) {
 	// legacy: persistent://tenant/cluster/namespace/topic
 	parts := strings.SplitN(topic, "://", 2)
 	domain := parts[0]
-	if domain != "persistent" && domain != "non-persistent" {
+	if domain != persistentDomain && domain != nonPersistentDomain {
 		return nil, errors.New("Invalid topic domain: " + domain)
 	}
 	tn.Domain = domain
@@ -102,6 +107,12 @@ func ParseTopicName(topic string) (*TopicName, error) {
 	return tn, nil
 }
 
+// IsPersistent returns false for non-persistent topics, whose messages are not
+// stored and which keep no subscription cursor
+func (tn *TopicName) IsPersistent() bool {
+	return tn.Domain == persistentDomain
+}
+
 // NameWithoutPartition returns the topic name, sans the partition portion
 func (tn *TopicName) NameWithoutPartition() string {
 	if tn.Partition < 0 {
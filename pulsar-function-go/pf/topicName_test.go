@@ -81,3 +81,13 @@ func TestParseTopicNameErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestTopicName_IsPersistent(t *testing.T) {
+	topic, err := ParseTopicName("my-topic")
+	assert.Nil(t, err)
+	assert.True(t, topic.IsPersistent())
+
+	topic, err = ParseTopicName("non-persistent://my-tenant/my-ns/my-topic")
+	assert.Nil(t, err)
+	assert.False(t, topic.IsPersistent())
+}
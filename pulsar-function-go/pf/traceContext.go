@@ -0,0 +1,93 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"regexp"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// The message properties carrying the W3C trace context, see
+// https://www.w3.org/TR/trace-context/
+const (
+	TraceParentProperty = "traceparent"
+	TraceStateProperty  = "tracestate"
+)
+
+// version, trace id, parent id and flags, the all-zero ids being invalid
+var traceParentPattern = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+const (
+	invalidTraceID  = "00000000000000000000000000000000"
+	invalidParentID = "0000000000000000"
+)
+
+// TraceContext is the W3C trace context of a message
+type TraceContext struct {
+	TraceParent string
+	TraceState  string
+}
+
+// IsValid reports whether there is a trace context, i.e. a traceparent
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceParent != ""
+}
+
+// traceIDExemplarLabel is the label of the exemplars carrying a trace id
+const traceIDExemplarLabel = "trace_id"
+
+// TraceID returns the trace id of the traceparent, or an empty string when
+// the trace context is not valid
+func (tc TraceContext) TraceID() string {
+	if !tc.IsValid() {
+		return ""
+	}
+	return tc.TraceParent[3:35]
+}
+
+// traceContextOf returns the trace context in the properties of msg. As the
+// specification requires, a malformed traceparent is ignored together with
+// the tracestate.
+func traceContextOf(msg pulsar.Message) TraceContext {
+	properties := msg.Properties()
+	traceParent := properties[TraceParentProperty]
+	if !traceParentPattern.MatchString(traceParent) || traceParent[:2] == "ff" ||
+		traceParent[3:35] == invalidTraceID || traceParent[36:52] == invalidParentID {
+		return TraceContext{}
+	}
+	return TraceContext{TraceParent: traceParent, TraceState: properties[TraceStateProperty]}
+}
+
+// inject adds the trace context to props, allocated if nil, and returns them.
+// props is returned as is when the trace context is not valid.
+func (tc TraceContext) inject(props map[string]string) map[string]string {
+	if !tc.IsValid() {
+		return props
+	}
+	if props == nil {
+		props = make(map[string]string, 2)
+	}
+	props[TraceParentProperty] = tc.TraceParent
+	if tc.TraceState != "" {
+		props[TraceStateProperty] = tc.TraceState
+	}
+	return props
+}
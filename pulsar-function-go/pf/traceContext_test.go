@@ -0,0 +1,133 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceContextOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]string
+		expected   TraceContext
+	}{
+		{name: "none"},
+		{
+			name:       "traceparent and tracestate",
+			properties: map[string]string{"traceparent": testTraceParent, "tracestate": "congo=t61rcWkgMzE"},
+			expected:   TraceContext{TraceParent: testTraceParent, TraceState: "congo=t61rcWkgMzE"},
+		},
+		{
+			name:       "traceparent only",
+			properties: map[string]string{"traceparent": testTraceParent},
+			expected:   TraceContext{TraceParent: testTraceParent},
+		},
+		{
+			name:       "malformed traceparent",
+			properties: map[string]string{"traceparent": "00-4bf92f35-00f067aa0ba902b7-01", "tracestate": "congo=t61rcWkgMzE"},
+		},
+		{
+			name:       "upper case traceparent",
+			properties: map[string]string{"traceparent": "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"},
+		},
+		{
+			name:       "invalid version",
+			properties: map[string]string{"traceparent": "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		},
+		{
+			name:       "zero trace id",
+			properties: map[string]string{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		},
+		{
+			name:       "zero parent id",
+			properties: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, traceContextOf(&MockMessage{properties: tt.properties}))
+		})
+	}
+}
+
+func TestTraceContext_TraceID(t *testing.T) {
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", TraceContext{TraceParent: testTraceParent}.TraceID())
+	assert.Empty(t, TraceContext{}.TraceID())
+}
+
+func TestFunctionContext_TraceContext(t *testing.T) {
+	fc := NewFuncContext()
+	assert.Equal(t, TraceContext{}, fc.GetTraceContext())
+	assert.Nil(t, fc.InjectTraceContext(nil))
+
+	fc.SetCurrentRecord(&MockMessage{properties: map[string]string{"traceparent": testTraceParent}})
+	traceContext := fc.GetTraceContext()
+	assert.True(t, traceContext.IsValid())
+	assert.Equal(t, testTraceParent, traceContext.TraceParent)
+
+	assert.Equal(t, map[string]string{"traceparent": testTraceParent}, fc.InjectTraceContext(nil))
+	props := map[string]string{"user-key": "user-value"}
+	assert.Equal(t, map[string]string{"user-key": "user-value", "traceparent": testTraceParent},
+		fc.InjectTraceContext(props))
+}
+
+func TestTraceContext_PropagatedToOutputs(t *testing.T) {
+	for _, propagate := range []bool{true, false} {
+		instance, _, producer := newOutputValidatorTestInstance(echo, "")
+		instance.context.instanceConf.propagateTraceContext = propagate
+		channel := make(chan pulsar.ConsumerMessage, 2)
+		channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+			topic: "persistent://public/default/topic-01",
+			properties: map[string]string{
+				"traceparent": testTraceParent,
+				"tracestate":  "congo=t61rcWkgMzE",
+				"user-key":    "user-value",
+			},
+			messageID: &MockMessageID{},
+			payload:   []byte("traced"),
+		}}
+		channel <- pulsar.ConsumerMessage{Message: &MockMessage{
+			topic:     "persistent://public/default/topic-01",
+			messageID: &MockMessageID{},
+			payload:   []byte("untraced"),
+		}}
+
+		assert.NoError(t, instance.processMessages(channel))
+
+		if assert.Len(t, producer.messages, 2) {
+			if propagate {
+				// only the trace context is carried over, not the other properties
+				assert.Equal(t, map[string]string{
+					"traceparent": testTraceParent,
+					"tracestate":  "congo=t61rcWkgMzE",
+				}, producer.messages[0].Properties)
+			} else {
+				assert.Nil(t, producer.messages[0].Properties)
+			}
+			assert.Nil(t, producer.messages[1].Properties)
+		}
+	}
+}
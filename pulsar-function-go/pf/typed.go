@@ -0,0 +1,103 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// ProcessTyped adapts a typed handler to the byte-oriented function API. The
+// input is decoded into T with the Avro schema configured for its topic
+// through inputSchemaDefinition, or as JSON otherwise. The output is encoded
+// with the outputSchemaDefinition of the sink if there is one, or as JSON
+// otherwise. The result can be passed to Start or RegisterTopicHandler.
+func ProcessTyped[T, R any](handler func(context.Context, T) (R, error)) func(context.Context, []byte) ([]byte, error) {
+	var (
+		outputSchemaOnce sync.Once
+		outputSchema     pulsar.Schema
+		outputSchemaErr  error
+	)
+	return func(ctx context.Context, input []byte) ([]byte, error) {
+		fc, _ := FromContext(ctx)
+		var value T
+		if err := decodeTypedInput(fc, input, &value); err != nil {
+			return nil, fmt.Errorf("%w: failed to decode the input into %T: %w", ErrDeserialization, value, err)
+		}
+		output, err := handler(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+		outputSchemaOnce.Do(func() {
+			outputSchema, outputSchemaErr = newTypedOutputSchema(fc)
+		})
+		if outputSchemaErr != nil {
+			return nil, outputSchemaErr
+		}
+		payload, err := encodeTypedOutput(outputSchema, output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the output %T: %w", output, err)
+		}
+		return payload, nil
+	}
+}
+
+// StartTyped starts the pulsar function with a typed handler, see
+// ProcessTyped for how the input and output are decoded and encoded
+func StartTyped[T, R any](handler func(context.Context, T) (R, error)) {
+	Start(ProcessTyped(handler))
+}
+
+// decodeTypedInput decodes input with the schema of the consumer of the
+// current record when its topic has an input schema definition
+func decodeTypedInput(fc *FunctionContext, input []byte, value interface{}) error {
+	if fc != nil {
+		if record := fc.GetCurrentRecord(); record != nil {
+			if topicName, err := ParseTopicName(record.Topic()); err == nil {
+				if _, ok := fc.instanceConf.inputSchemaDefinitions[topicName.NameWithoutPartition()]; ok {
+					return record.GetSchemaValue(value)
+				}
+			}
+		}
+	}
+	return json.Unmarshal(input, value)
+}
+
+// encodeTypedOutput encodes output with schema, or as JSON when it is nil
+func encodeTypedOutput(schema pulsar.Schema, output interface{}) ([]byte, error) {
+	if schema != nil {
+		return schema.Encode(output)
+	}
+	return json.Marshal(output)
+}
+
+// newTypedOutputSchema returns the schema of the sink, or nil when there is no
+// output schema definition and the output is encoded as JSON
+func newTypedOutputSchema(fc *FunctionContext) (pulsar.Schema, error) {
+	if fc == nil || fc.instanceConf.outputSchemaDefinition == "" {
+		return nil, nil
+	}
+	sink := fc.instanceConf.funcDetails.Sink
+	return newOutputSchema(sink.GetSchemaType(), fc.instanceConf.outputSchemaDefinition, sink.GetSchemaProperties())
+}
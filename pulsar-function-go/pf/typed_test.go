@@ -0,0 +1,113 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+type typedOrder struct {
+	ID       string `json:"id"`
+	Quantity int    `json:"quantity"`
+}
+
+type typedInvoice struct {
+	OrderID string `json:"orderId"`
+	Total   int    `json:"total"`
+}
+
+const typedInvoiceSchema = `{"type":"record","name":"Invoice","fields":[` +
+	`{"name":"orderId","type":"string"},{"name":"total","type":"int"}]}`
+
+const typedOrderSchema = `{"type":"record","name":"Order","fields":[` +
+	`{"name":"id","type":"string"},{"name":"quantity","type":"int"}]}`
+
+// schemaMessage decodes its payload with the schema of its consumer
+type schemaMessage struct {
+	MockMessage
+	schema pulsar.Schema
+}
+
+func (m *schemaMessage) GetSchemaValue(v interface{}) error {
+	return m.schema.Decode(m.payload, v)
+}
+
+func newTypedTestInstance(handler func(context.Context, typedOrder) (typedInvoice, error)) *goInstance {
+	instance := newGoInstance()
+	instance.function = newFunction(ProcessTyped(handler))
+	return instance
+}
+
+func invoice(_ context.Context, order typedOrder) (typedInvoice, error) {
+	return typedInvoice{OrderID: order.ID, Total: order.Quantity * 3}, nil
+}
+
+func TestProcessTyped_JSON(t *testing.T) {
+	instance := newTypedTestInstance(invoice)
+	message := &MockMessage{topic: "persistent://public/default/orders", payload: []byte(`{"id":"o-1","quantity":2}`)}
+
+	output, err := instance.handlerMsg(message)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"orderId":"o-1","total":6}`, string(output))
+}
+
+func TestProcessTyped_Errors(t *testing.T) {
+	calls := 0
+	instance := newTypedTestInstance(func(_ context.Context, order typedOrder) (typedInvoice, error) {
+		calls++
+		return typedInvoice{}, errors.New("out of stock")
+	})
+
+	_, err := instance.handlerMsg(&MockMessage{topic: "persistent://public/default/orders", payload: []byte("not json")})
+	assert.ErrorContains(t, err, "failed to decode the input into pf.typedOrder")
+	assert.Equal(t, 0, calls)
+
+	_, err = instance.handlerMsg(&MockMessage{topic: "persistent://public/default/orders", payload: []byte("{}")})
+	assert.EqualError(t, err, "out of stock")
+	assert.Equal(t, 1, calls)
+}
+
+func TestProcessTyped_AvroSchemas(t *testing.T) {
+	instance := newTypedTestInstance(invoice)
+	instance.context.instanceConf.inputSchemaDefinitions = map[string]string{
+		"persistent://public/default/orders": typedOrderSchema,
+	}
+	instance.context.instanceConf.funcDetails.Sink.SchemaType = "avro"
+	instance.context.instanceConf.outputSchemaDefinition = typedInvoiceSchema
+
+	orderSchema := pulsar.NewAvroSchema(typedOrderSchema, nil)
+	payload, err := orderSchema.Encode(typedOrder{ID: "o-2", Quantity: 5})
+	assert.NoError(t, err)
+	message := &schemaMessage{
+		MockMessage: MockMessage{topic: "persistent://public/default/orders-partition-1", payload: payload},
+		schema:      orderSchema,
+	}
+
+	output, err := instance.handlerMsg(message)
+	assert.NoError(t, err)
+	var decoded typedInvoice
+	assert.NoError(t, pulsar.NewAvroSchema(typedInvoiceSchema, nil).Decode(output, &decoded))
+	assert.Equal(t, typedInvoice{OrderID: "o-2", Total: 15}, decoded)
+}
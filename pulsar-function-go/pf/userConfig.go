@@ -0,0 +1,251 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/apache/pulsar/pulsar-function-go/logutil"
+)
+
+var (
+	userConfigKeysMu       sync.Mutex
+	declaredUserConfigKeys map[string]bool
+)
+
+// DeclareUserConfigKeys declares keys as known keys of the user config. Once
+// any key is declared, the instance warns about, or with
+// failOnUnknownUserConfigKeys refuses to start with, user config keys that
+// were not declared. It must be called before Start.
+func DeclareUserConfigKeys(keys ...string) {
+	userConfigKeysMu.Lock()
+	defer userConfigKeysMu.Unlock()
+	if declaredUserConfigKeys == nil {
+		declaredUserConfigKeys = make(map[string]bool)
+	}
+	for _, key := range keys {
+		declaredUserConfigKeys[key] = true
+	}
+}
+
+// findUnknownUserConfigKeys returns the sorted keys of userConfigs that were
+// not declared, or nil when no key was declared at all
+func findUnknownUserConfigKeys(userConfigs map[string]interface{}) []string {
+	userConfigKeysMu.Lock()
+	defer userConfigKeysMu.Unlock()
+	if len(declaredUserConfigKeys) == 0 {
+		return nil
+	}
+	var unknown []string
+	for key := range userConfigs {
+		if !declaredUserConfigKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// checkUserConfigKeys reports the user config keys that were not declared,
+// it fails only when failOnUnknownUserConfigKeys is set
+func (gi *goInstance) checkUserConfigKeys() error {
+	unknown := findUnknownUserConfigKeys(gi.context.GetUserConfMap())
+	if len(unknown) == 0 {
+		return nil
+	}
+	gi.stats.addTotalUnknownUserConfigKeys(len(unknown))
+	if gi.context.instanceConf.failOnUnknownUserConfigKeys {
+		err := fmt.Errorf("unknown user config keys: %s", strings.Join(unknown, ", "))
+		log.Errorf("%v", err)
+		return withExitCode(ExitCodeConfigError, err)
+	}
+	log.Warnf("unknown user config keys, check them for typos: %s", strings.Join(unknown, ", "))
+	return nil
+}
+
+// userConfigTag is the struct tag BindUserConfig maps user config keys with
+const userConfigTag = "pulsar"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// bindUserConfig sets the fields of the struct out points to from
+// userConfigs, as their userConfigTag tags say. It carries on past the keys
+// that fail and returns all of their errors joined.
+func bindUserConfig(userConfigs map[string]interface{}, out interface{}) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("user config can only be bound to a pointer to a struct, not %T", out)
+	}
+	value = value.Elem()
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup(userConfigTag)
+		if !ok || tag == "-" {
+			continue
+		}
+		key, defaultValue, hasDefault, required := parseUserConfigTag(tag)
+		if key == "" {
+			key = field.Name
+		}
+		if !field.IsExported() {
+			errs = append(errs, fmt.Errorf("user config key %q is bound to unexported field %s", key, field.Name))
+			continue
+		}
+		raw, present := userConfigs[key]
+		switch {
+		case present:
+		case required:
+			errs = append(errs, fmt.Errorf("user config key %q is required", key))
+			continue
+		case hasDefault:
+			raw = defaultValue
+		default:
+			continue
+		}
+		if err := setUserConfigField(value.Field(i), raw); err != nil {
+			errs = append(errs, fmt.Errorf("user config key %q: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseUserConfigTag parses a `pulsar:"key,default=value,required"` tag, the
+// default value runs up to the next comma
+func parseUserConfigTag(tag string) (key, defaultValue string, hasDefault, required bool) {
+	parts := strings.Split(tag, ",")
+	for _, option := range parts[1:] {
+		switch {
+		case option == "required":
+			required = true
+		case strings.HasPrefix(option, "default="):
+			defaultValue, hasDefault = strings.TrimPrefix(option, "default="), true
+		}
+	}
+	return parts[0], defaultValue, hasDefault, required
+}
+
+// setUserConfigField sets field to raw, a value decoded from the JSON user
+// config or a default from a tag. Strings are parsed into numbers, booleans
+// and durations, and numbers are formatted into strings.
+func setUserConfigField(field reflect.Value, raw interface{}) error {
+	mismatch := fmt.Errorf("cannot use %#v as %s", raw, field.Type())
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setUserConfigField(elem.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	if field.Type() == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			return mismatch
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return mismatch
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		switch raw.(type) {
+		case string, float64, bool:
+			field.SetString(fmt.Sprint(raw))
+			return nil
+		}
+		return mismatch
+	case reflect.Bool:
+		switch v := raw.(type) {
+		case bool:
+			field.SetBool(v)
+			return nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return mismatch
+			}
+			field.SetBool(b)
+			return nil
+		}
+		return mismatch
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := userConfigNumber(raw)
+		if !ok || f != math.Trunc(f) || field.OverflowInt(int64(f)) {
+			return mismatch
+		}
+		field.SetInt(int64(f))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := userConfigNumber(raw)
+		if !ok || f < 0 || f != math.Trunc(f) || field.OverflowUint(uint64(f)) {
+			return mismatch
+		}
+		field.SetUint(uint64(f))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := userConfigNumber(raw)
+		if !ok || field.OverflowFloat(f) {
+			return mismatch
+		}
+		field.SetFloat(f)
+		return nil
+	}
+
+	// slices, maps and structs are decoded as JSON, a string being the JSON
+	// itself
+	data, ok := raw.(string)
+	if !ok {
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return mismatch
+		}
+		data = string(encoded)
+	}
+	if err := json.Unmarshal([]byte(data), field.Addr().Interface()); err != nil {
+		return mismatch
+	}
+	return nil
+}
+
+// userConfigNumber returns raw as a number, parsing it if it is a string
+func userConfigNumber(raw interface{}) (float64, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,158 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package pf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func declareTestUserConfigKeys(t *testing.T, keys ...string) {
+	DeclareUserConfigKeys(keys...)
+	t.Cleanup(func() {
+		userConfigKeysMu.Lock()
+		defer userConfigKeysMu.Unlock()
+		declaredUserConfigKeys = nil
+	})
+}
+
+func TestUserConfigKeys_NoneDeclared(t *testing.T) {
+	instance := newGoInstance()
+	instance.context.userConfigs = map[string]interface{}{"anything": "goes"}
+	instance.context.instanceConf.failOnUnknownUserConfigKeys = true
+
+	assert.Nil(t, findUnknownUserConfigKeys(instance.context.userConfigs))
+	assert.NoError(t, instance.checkUserConfigKeys())
+}
+
+func TestUserConfigKeys_Warn(t *testing.T) {
+	declareTestUserConfigKeys(t, "batchSize", "endpoint")
+	instance := newGoInstance()
+	instance.context.userConfigs = map[string]interface{}{"batchSize": 10, "endpiont": "x", "bachSize": 5}
+	before := instance.getTotalUnknownUserConfigKeys()
+
+	assert.Equal(t, []string{"bachSize", "endpiont"}, findUnknownUserConfigKeys(instance.context.userConfigs))
+	assert.NoError(t, instance.checkUserConfigKeys())
+	assert.Equal(t, before+2, instance.getTotalUnknownUserConfigKeys())
+
+	// declared keys that are missing are not reported
+	instance.context.userConfigs = map[string]interface{}{"endpoint": "x"}
+	assert.NoError(t, instance.checkUserConfigKeys())
+	assert.Equal(t, before+2, instance.getTotalUnknownUserConfigKeys())
+}
+
+func TestUserConfigKeys_Fail(t *testing.T) {
+	declareTestUserConfigKeys(t, "batchSize")
+	instance := newGoInstance()
+	instance.context.userConfigs = map[string]interface{}{"batchSize": 10, "bachSize": 5}
+	instance.context.instanceConf.failOnUnknownUserConfigKeys = true
+	before := instance.getTotalUnknownUserConfigKeys()
+
+	err := instance.checkUserConfigKeys()
+	assert.EqualError(t, err, "unknown user config keys: bachSize")
+	assert.Equal(t, before+1, instance.getTotalUnknownUserConfigKeys())
+	// startup stops before connecting to anything
+	assert.Equal(t, err, instance.startFunction(&countingHandler{}))
+}
+
+type boundUserConfig struct {
+	Endpoint  string            `pulsar:"endpoint,required"`
+	BatchSize int               `pulsar:"batchSize,default=100"`
+	Ratio     float64           `pulsar:"ratio,default=0.5"`
+	Enabled   bool              `pulsar:"enabled,default=true"`
+	Timeout   time.Duration     `pulsar:"timeout,default=30s"`
+	Retries   *int              `pulsar:"retries"`
+	Regions   []string          `pulsar:"regions"`
+	Labels    map[string]string `pulsar:"labels"`
+	Name      string            `pulsar:",default=unnamed"`
+	Ignored   string            `pulsar:"-"`
+	Untagged  string
+}
+
+func TestBindUserConfig_Defaults(t *testing.T) {
+	var config boundUserConfig
+	err := bindUserConfig(map[string]interface{}{"endpoint": "http://localhost"}, &config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, boundUserConfig{
+		Endpoint:  "http://localhost",
+		BatchSize: 100,
+		Ratio:     0.5,
+		Enabled:   true,
+		Timeout:   30 * time.Second,
+		Name:      "unnamed",
+	}, config)
+}
+
+func TestBindUserConfig_Coercion(t *testing.T) {
+	userConfigs := buildUserConfig(`{"endpoint": 8080, "batchSize": "25", "ratio": 2, "enabled": "false",
+		"timeout": "1m", "retries": 3, "regions": ["eu", "us"], "labels": {"team": "data"}, "Name": "bound",
+		"Ignored": "x", "Untagged": "x"}`)
+	var config boundUserConfig
+	err := bindUserConfig(userConfigs, &config)
+
+	assert.NoError(t, err)
+	retries := 3
+	assert.Equal(t, boundUserConfig{
+		Endpoint:  "8080",
+		BatchSize: 25,
+		Ratio:     2,
+		Enabled:   false,
+		Timeout:   time.Minute,
+		Retries:   &retries,
+		Regions:   []string{"eu", "us"},
+		Labels:    map[string]string{"team": "data"},
+		Name:      "bound",
+	}, config)
+}
+
+func TestBindUserConfig_Errors(t *testing.T) {
+	userConfigs := buildUserConfig(`{"batchSize": 2.5, "enabled": "maybe", "timeout": 30, "regions": "eu"}`)
+	var config boundUserConfig
+	err := bindUserConfig(userConfigs, &config)
+
+	assert.EqualError(t, err, `user config key "endpoint" is required
+user config key "batchSize": cannot use 2.5 as int
+user config key "enabled": cannot use "maybe" as bool
+user config key "timeout": cannot use 30 as time.Duration
+user config key "regions": cannot use "eu" as []string`)
+	// the keys that did bind are set all the same
+	assert.Equal(t, 0.5, config.Ratio)
+
+	var small struct {
+		Level int8 `pulsar:"level"`
+	}
+	assert.EqualError(t, bindUserConfig(map[string]interface{}{"level": float64(300)}, &small),
+		`user config key "level": cannot use 300 as int8`)
+	assert.EqualError(t, bindUserConfig(nil, config),
+		"user config can only be bound to a pointer to a struct, not pf.boundUserConfig")
+}
+
+func TestBindUserConfig_Context(t *testing.T) {
+	fc := NewFuncContext()
+	fc.userConfigs = map[string]interface{}{"endpoint": "http://localhost", "batchSize": float64(10)}
+	var config boundUserConfig
+
+	assert.NoError(t, fc.BindUserConfig(&config))
+	assert.Equal(t, "http://localhost", config.Endpoint)
+	assert.Equal(t, 10, config.BatchSize)
+}
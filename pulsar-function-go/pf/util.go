@@ -21,8 +21,12 @@ package pf
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/apache/pulsar-client-go/pulsar"
+
+	pb "github.com/apache/pulsar/pulsar-function-go/pb"
 )
 
 func getProperties(fullyQualifiedName string, instanceID int) map[string]string {
@@ -50,3 +54,47 @@ func messageIDStr(msg pulsar.Message) string {
 		msg.ID().PartitionIdx(),
 		msg.ID().BatchIdx())
 }
+
+// parseMessageID parses a message ID in the format of messageIDStr, the
+// partition and batch indexes may be left out
+func parseMessageID(id string) (pulsar.MessageID, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) < 2 || len(parts) > 4 {
+		return nil, fmt.Errorf("invalid message ID %q, expected "+
+			"<ledger ID>:<entry ID>[:<partition index>[:<batch index>]]", id)
+	}
+	// ledger ID, entry ID, partition index and batch index, -1 is an unset index
+	values := []int64{0, 0, -1, -1}
+	for i, part := range parts {
+		bitSize := 64
+		if i >= 2 {
+			bitSize = 32
+		}
+		value, err := strconv.ParseInt(part, 10, bitSize)
+		if err != nil || value < -1 || (i < 2 && value < 0) {
+			return nil, fmt.Errorf("invalid message ID %q, %q is not a valid ID or index", id, part)
+		}
+		values[i] = value
+	}
+	return pulsar.NewMessageID(values[0], values[1], int32(values[3]), int32(values[2])), nil
+}
+
+// isTombstone reports whether msg deletes its key from a compacted topic. The
+// client does not expose the null value flag of the message metadata, so
+// tombstones are recognized the way the compactor does: a key and no payload.
+func isTombstone(msg pulsar.Message) bool {
+	return msg.Key() != "" && len(msg.Payload()) == 0
+}
+
+func getCompressionType(compressionType pb.CompressionType) pulsar.CompressionType {
+	switch compressionType {
+	case pb.CompressionType_NONE:
+		return pulsar.NoCompression
+	case pb.CompressionType_ZLIB:
+		return pulsar.ZLib
+	case pb.CompressionType_ZSTD:
+		return pulsar.ZSTD
+	default:
+		return pulsar.LZ4 // go doesn't support SNAPPY yet
+	}
+}
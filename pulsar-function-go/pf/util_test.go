@@ -50,3 +50,33 @@ func TestUtils(t *testing.T) {
 	expectedRes := getFullyQualifiedInstanceID(tenant, namespace, name, instanceID)
 	assert.Equal(t, expectedRes, "pulsar/function/go:100")
 }
+
+func TestIsTombstone(t *testing.T) {
+	assert.True(t, isTombstone(&MockMessage{key: "key"}))
+	assert.True(t, isTombstone(&MockMessage{key: "key", payload: []byte{}}))
+	assert.False(t, isTombstone(&MockMessage{key: "key", payload: []byte("value")}))
+	// an empty message without a key does not delete anything
+	assert.False(t, isTombstone(&MockMessage{payload: []byte{}}))
+	assert.False(t, isTombstone(&MockMessage{}))
+}
+
+func TestParseMessageID(t *testing.T) {
+	msgID, err := parseMessageID("12:34")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), msgID.LedgerID())
+	assert.Equal(t, int64(34), msgID.EntryID())
+	assert.Equal(t, int32(-1), msgID.PartitionIdx())
+	assert.Equal(t, int32(-1), msgID.BatchIdx())
+
+	// the format of messageIDStr: <ledger ID>:<entry ID>:<partition index>:<batch index>
+	msgID, err = parseMessageID("12:34:2:5")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), msgID.PartitionIdx())
+	assert.Equal(t, int32(5), msgID.BatchIdx())
+
+	for _, invalid := range []string{"", "12", "12:34:1:2:3", "a:34", "12:-1", "12:34:x", "12:34:0:-2",
+		"12:34:4294967296"} {
+		_, err := parseMessageID(invalid)
+		assert.Error(t, err, invalid)
+	}
+}